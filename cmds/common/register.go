@@ -4,15 +4,32 @@ package common
 
 import (
 	"cloudiac/configs"
+	"cloudiac/utils"
 	"cloudiac/utils/consul"
 	"cloudiac/utils/logs"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// runnerRegisterInterval db 模式下 runner 重复注册的间隔
+const runnerRegisterInterval = time.Minute
+
 func ServiceRegister(serviceName string) {
 	conf := configs.Get()
 	logger := logs.Get()
 
+	if conf.Registry.IsDB() {
+		logger.Debugf("Registry type is db, skip register %s service to consul", serviceName)
+		return
+	}
+
 	logger.Debugf("Start register %s service", serviceName)
 	err := consul.Register(serviceName, conf.Consul)
 	if err != nil {
@@ -28,3 +45,100 @@ func ReRegisterService(register bool, serviceName string) {
 		os.Exit(0)
 	}
 }
+
+// RegisterRunner 注册 runner 服务。
+// registry 为 db 时通过 portal 接口注册，并定时重复注册以更新服务信息，退出时注销，否则注册到 consul
+func RegisterRunner(register bool, serviceName string) {
+	conf := configs.Get()
+	if !conf.Registry.IsDB() {
+		ReRegisterService(register, serviceName)
+		return
+	}
+
+	logger := logs.Get()
+	if err := registerRunnerToPortal(serviceName); err != nil {
+		logger.Errorf("register runner to portal failed: %v", err)
+	} else {
+		logger.Debug("Service register success.")
+	}
+	if register {
+		os.Exit(0)
+	}
+
+	go func() {
+		for range time.Tick(runnerRegisterInterval) {
+			if err := registerRunnerToPortal(serviceName); err != nil {
+				logger.Errorf("register runner to portal failed: %v", err)
+			}
+		}
+	}()
+
+	// 退出时注销 runner，避免 portal 继续向已停止的 runner 派发任务
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigCh
+		logger.Infof("received signal %v, deregister runner", sig)
+		if err := deregisterRunnerFromPortal(); err != nil {
+			logger.Errorf("deregister runner from portal failed: %v", err)
+		}
+		os.Exit(0)
+	}()
+}
+
+func registerRunnerToPortal(serviceName string) error {
+	conf := configs.Get()
+	if conf.Portal.Address == "" {
+		return fmt.Errorf("missing portal address config")
+	}
+
+	var tags []string
+	if conf.Consul.ServiceTags != "" {
+		tags = strings.Split(conf.Consul.ServiceTags, ";")
+	}
+	data := map[string]interface{}{
+		"id":      conf.Consul.ServiceID,
+		"service": serviceName,
+		"address": conf.Consul.ServiceIP,
+		"port":    conf.Consul.ServicePort,
+		"tags":    tags,
+	}
+
+	return requestPortalRegistry(http.MethodPost, "/api/v1/runners/register", data)
+}
+
+// deregisterRunnerFromPortal 从 portal 注销 runner
+func deregisterRunnerFromPortal() error {
+	conf := configs.Get()
+	if conf.Portal.Address == "" {
+		return fmt.Errorf("missing portal address config")
+	}
+	path := fmt.Sprintf("/api/v1/runners/register/%s", url.PathEscape(conf.Consul.ServiceID))
+	return requestPortalRegistry(http.MethodDelete, path, nil)
+}
+
+// requestPortalRegistry 使用 registry token 请求 portal 的 runner 注册接口
+func requestPortalRegistry(method string, path string, data interface{}) error {
+	conf := configs.Get()
+	header := &http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", conf.Registry.Token)
+	reqUrl := utils.JoinURL(conf.Portal.Address, path)
+	respData, err := utils.HttpService(reqUrl, method, header, data, 5, 30)
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		Code          int    `json:"code"`
+		Message       string `json:"message"`
+		MessageDetail string `json:"message_detail"`
+	}{}
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return fmt.Errorf("unexpected response: %s", respData)
+	}
+	if resp.Code != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Message, resp.MessageDetail)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cloudiac/configs"
+)
+
+func initTestRegistryConfig(t *testing.T, portalAddr string) {
+	content := fmt.Sprintf(`secretKey: "test"
+consul:
+  id: "runner-01"
+  ip: "10.0.0.2"
+  port: 19030
+  tags: "a;b"
+registry:
+  type: "db"
+  token: "registry-token"
+portal:
+  address: "%s"
+`, portalAddr)
+	file := filepath.Join(t.TempDir(), "config-runner.yml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.ParseRunnerConfig(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterRunnerToPortal(t *testing.T) {
+	var (
+		token string
+		body  map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/runners/register", r.URL.Path)
+		token = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if token != "registry-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":40310010,"message":"permission deny"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":200,"message":""}`))
+	}))
+	defer server.Close()
+
+	initTestRegistryConfig(t, server.URL)
+	assert.NoError(t, registerRunnerToPortal("CT-Runner"))
+	assert.Equal(t, "registry-token", token)
+	assert.Equal(t, map[string]interface{}{
+		"id":      "runner-01",
+		"service": "CT-Runner",
+		"address": "10.0.0.2",
+		"port":    float64(19030),
+		"tags":    []interface{}{"a", "b"},
+	}, body)
+
+	configs.Get().Registry.Token = "invalid"
+	assert.Error(t, registerRunnerToPortal("CT-Runner"))
+}
+
+func TestDeregisterRunnerFromPortal(t *testing.T) {
+	var method, path, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, token = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"code":200,"message":""}`))
+	}))
+	defer server.Close()
+
+	initTestRegistryConfig(t, server.URL)
+	assert.NoError(t, deregisterRunnerFromPortal())
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/api/v1/runners/register/runner-01", path)
+	assert.Equal(t, "registry-token", token)
+}
@@ -76,6 +76,7 @@ func main() {
 		services.MaintenanceRunnerPerMax()
 		kafka.InitKafkaProducerBuilder()
 		rbac.InitPolicy()
+		services.InitRolePolicies()
 	}
 
 	// 注册到 consul
@@ -221,6 +222,10 @@ func initSystemConfig(tx *db.Session) (err error) {
 			Name:        models.SysCfgNamePeriodOfLogSave,
 			Value:       "Permanent",
 			Description: "日志保存周期",
+		}, {
+			Name:        models.SysCfgNamePeriodOfAuditLogSave,
+			Value:       "Permanent",
+			Description: "审计日志保存周期",
 		},
 	}
 
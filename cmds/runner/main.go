@@ -3,6 +3,7 @@
 package main
 
 import (
+	"cloudiac/runner"
 	v1 "cloudiac/runner/api/v1"
 	"cloudiac/utils"
 	"encoding/json"
@@ -52,7 +53,10 @@ func main() {
 	runnerConfJson, _ := json.Marshal(configs.Get().Runner)
 	logs.Get().Infof("runner configs: %s", runnerConfJson)
 
-	common.ReRegisterService(opt.ReRegister, "CT-Runner")
+	// 启动时初始化任务执行器，配置错误时直接退出
+	runner.GetExecutor()
+
+	common.RegisterRunner(opt.ReRegister, "CT-Runner")
 	StartServer()
 }
 
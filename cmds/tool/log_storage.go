@@ -0,0 +1,77 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package main
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services/logstorage"
+	"fmt"
+)
+
+// ./iac-tool log-storage migrate [--delete]
+
+type LogStorageCmd struct {
+	Migrate LogStorageMigrateCmd `command:"migrate" description:"migrate iac_storage records to the configured log storage"`
+}
+
+type LogStorageMigrateCmd struct {
+	BatchSize int  `long:"batch-size" default:"100" description:"number of records per batch"`
+	Delete    bool `long:"delete" description:"delete records from iac_storage after migrated"`
+}
+
+func (c *LogStorageMigrateCmd) Execute(args []string) error {
+	configs.Init(opt.Config)
+	db.Init(configs.Get().Mysql)
+	models.Init(false)
+
+	cfg := configs.Get().LogStorage
+	if cfg.Type == "" || cfg.Type == configs.LogStorageTypeDB {
+		return fmt.Errorf("log storage type is '%s', nothing to migrate", configs.LogStorageTypeDB)
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", c.BatchSize)
+	}
+
+	storage, err := logstorage.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	var (
+		lastId uint
+		count  int
+		size   int64
+	)
+	for {
+		rows := make([]models.DBStorage, 0, c.BatchSize)
+		if err := db.Get().Where("id > ?", lastId).Order("id").Limit(c.BatchSize).Find(&rows); err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		ids := make([]uint, 0, len(rows))
+		for _, r := range rows {
+			if err := storage.Write(r.Path, r.Content); err != nil {
+				return fmt.Errorf("write '%s': %v", r.Path, err)
+			}
+			ids = append(ids, r.Id)
+			count += 1
+			size += int64(len(r.Content))
+			lastId = r.Id
+		}
+
+		if c.Delete {
+			if _, err := db.Get().Where("id IN (?)", ids).Delete(&models.DBStorage{}); err != nil {
+				return err
+			}
+		}
+		logger.Infof("%d records migrated", count)
+	}
+
+	logger.Infof("migrate done, %d records, %d bytes", count, size)
+	return nil
+}
@@ -17,6 +17,8 @@ type Option struct {
 	Version        common.VersionCommand `command:"version" description:"show version"`
 	InitDemo       InitDemo              `command:"init-demo" description:"init demo data with config file"`
 	Scan           ScanCmd               `command:"scan" description:"scan template with policy"`
+	LogStorage     LogStorageCmd         `command:"log-storage" description:"log storage management"`
+	Pipeline       PipelineCmd           `command:"pipeline" description:"pipeline tools"`
 }
 
 var (
@@ -0,0 +1,40 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package main
+
+import (
+	"cloudiac/common"
+	"cloudiac/portal/services"
+	"fmt"
+	"os"
+)
+
+// ./iac-tool pipeline lint [file...]
+
+type PipelineCmd struct {
+	Lint PipelineLintCmd `command:"lint" description:"check pipeline files"`
+}
+
+type PipelineLintCmd struct{}
+
+func (c *PipelineLintCmd) Execute(args []string) error {
+	if len(args) == 0 {
+		args = []string{common.PipelineFileName}
+	}
+
+	count := 0
+	for _, file := range args {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		for _, e := range services.LintPipeline(string(content)) {
+			fmt.Printf("%s:%d: %s\n", file, e.Line, e.Message)
+			count += 1
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%d problem(s) found", count)
+	}
+	return nil
+}
@@ -15,6 +15,9 @@ const (
 	TaskTypeScan    = "scan"    // 策略扫描，只执行策略扫描，不修改资源或配置
 	TaskTypeParse   = "parse"   // 策略扫描，只执行策略扫描，不修改资源或配置
 
+	TaskTypeStateQuery = "stateQuery" // 查询 state(state list/show)，不修改资源或 state
+	TaskTypeStateEdit  = "stateEdit"  // 修改 state(state mv/rm、import)，不修改资源，执行后重新采集资源信息
+
 	// TODO 与 taskTypexxx 重复，需要替换
 	TaskJobPlan    = "plan"
 	TaskJobApply   = "apply"
@@ -28,6 +31,7 @@ const (
 	TaskRejected  = "rejected"
 	TaskFailed    = "failed"
 	TaskComplete  = "complete"
+	TaskAborted   = "aborted"
 
 	TaskStepCheckout  = "checkout"
 	TaskStepTfInit    = "terraformInit"
@@ -35,6 +39,13 @@ const (
 	TaskStepTfApply   = "terraformApply"
 	TaskStepTfDestroy = "terraformDestroy"
 
+	TaskStepTfStateList = "terraformStateList"
+	TaskStepTfStateShow = "terraformStateShow"
+	TaskStepTfStateMv   = "terraformStateMv"
+	TaskStepTfStateRm   = "terraformStateRm"
+	TaskStepTfImport    = "terraformImport"
+	TaskStepTfStatePush = "terraformStatePush" // 恢复 state 到指定版本
+
 	TaskStepRegoParse = "regoParse" // 解析资源为 rego 的 input
 	TaskStepOpaScan   = "opaScan"   // 云模板策略扫描
 
@@ -42,7 +53,9 @@ const (
 	TaskStepCommand     = "command"     // run command
 	TaskStepCollect     = "collect"     // 任务结束后的信息采集
 	TaskStepScanInit    = "scaninit"
-	CronDriftTaskName   = "Drift Detection" // 漂移检测任务名称
+	CronDriftTaskName   = "Drift Detection"  // 漂移检测任务名称
+	CronDeployTaskName  = "Scheduled Deploy" // 定时部署任务名称
+	DeployStackTaskName = "Deploy Stack"     // 环境栈部署任务名称
 
 	PipelineFileName = ".cloudiac-pipeline.yml"
 
@@ -56,6 +69,8 @@ const (
 	TaskStepFailed    = "failed"
 	TaskStepComplete  = "complete"
 	TaskStepTimeout   = "timeout"
+	TaskStepAborted   = "aborted"
+	TaskStepSkipped   = "skipped" // 不满足 when 条件而跳过的步骤
 
 	TaskStepPolicyViolationExitCode = 3 // 合规检查不通过时的退出码
 
@@ -64,6 +79,9 @@ const (
 	TaskTypeDestroyName = "destroy"
 	TaskTypeScanName    = "scan"
 
+	TaskTypeStateQueryName = "state query"
+	TaskTypeStateEditName  = "state edit"
+
 	// 默认步骤超时时间(秒)
 	DefaultTaskStepTimeout = 1800
 
@@ -84,6 +102,11 @@ const (
 
 	PolicySuppressTypeSource = "source"
 	PolicySuppressTypePolicy = "policy"
+
+	// 模板使用的 IaC 工具
+	IacToolTerraform  = "terraform"
+	IacToolOpenTofu   = "opentofu"
+	IacToolTerragrunt = "terragrunt"
 )
 
 var (
@@ -95,4 +118,6 @@ var (
 		"0.15.5",
 		"1.0.6",
 	}
+
+	IacTools = []string{IacToolTerraform, IacToolOpenTofu, IacToolTerragrunt}
 )
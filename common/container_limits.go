@@ -0,0 +1,88 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package common
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ContainerLimits 任务容器的资源限制及隔离配置，可以在 runner、项目、环境三个级别配置。
+// 数值为 0、开关为 nil 表示未配置
+type ContainerLimits struct {
+	CPUs            float64 `json:"cpus,omitempty" yaml:"cpus"`                        // cpu 核数
+	Memory          int64   `json:"memory,omitempty" yaml:"memory"`                    // 内存限制(MB)
+	Pids            int64   `json:"pids,omitempty" yaml:"pids"`                        // 进程数限制
+	NetworkDisabled *bool   `json:"networkDisabled,omitempty" yaml:"network_disabled"` // 禁用容器网络
+	ReadonlyRootfs  *bool   `json:"readonlyRootfs,omitempty" yaml:"readonly_rootfs"`   // 只读根文件系统
+}
+
+func (l ContainerLimits) Validate() error {
+	if l.CPUs < 0 || l.Memory < 0 || l.Pids < 0 {
+		return fmt.Errorf("container limits must not be negative")
+	}
+	return nil
+}
+
+// Merge 合并项目配置 l 及环境配置 o，环境配置只能收紧项目的限制:
+// 数值限制取两者中己配置的较小值，隔离选项任一开启即生效
+func (l ContainerLimits) Merge(o ContainerLimits) ContainerLimits {
+	return l.Restrict(o)
+}
+
+// Restrict 应用 runner 级别的配置: 数值限制未配置时使用 runner 配置，己配置时不能超过 runner 配置;
+// runner 开启的隔离选项强制生效
+func (l ContainerLimits) Restrict(runner ContainerLimits) ContainerLimits {
+	restrict := func(v, max float64) float64 {
+		if max > 0 && (v <= 0 || v > max) {
+			return max
+		}
+		return v
+	}
+	l.CPUs = restrict(l.CPUs, runner.CPUs)
+	l.Memory = int64(restrict(float64(l.Memory), float64(runner.Memory)))
+	l.Pids = int64(restrict(float64(l.Pids), float64(runner.Pids)))
+	if runner.NetworkDisabled != nil && *runner.NetworkDisabled {
+		l.NetworkDisabled = runner.NetworkDisabled
+	}
+	if runner.ReadonlyRootfs != nil && *runner.ReadonlyRootfs {
+		l.ReadonlyRootfs = runner.ReadonlyRootfs
+	}
+	return l
+}
+
+func (l ContainerLimits) IsNetworkDisabled() bool {
+	return l.NetworkDisabled != nil && *l.NetworkDisabled
+}
+
+func (l ContainerLimits) IsReadonlyRootfs() bool {
+	return l.ReadonlyRootfs != nil && *l.ReadonlyRootfs
+}
+
+func (l ContainerLimits) Value() (driver.Value, error) {
+	bs, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(bs), nil
+}
+
+func (l *ContainerLimits) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v, l)
+	case string:
+		if v == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("invalid type %T", value)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerLimits(t *testing.T) {
+	yes, no := true, false
+
+	// 环境配置不能放宽项目的限制
+	project := ContainerLimits{CPUs: 2, Memory: 2048, NetworkDisabled: &yes}
+	env := ContainerLimits{CPUs: 1, Memory: 4096, Pids: 256, NetworkDisabled: &no, ReadonlyRootfs: &yes}
+	merged := project.Merge(env)
+	assert.Equal(t, ContainerLimits{CPUs: 1, Memory: 2048, Pids: 256, NetworkDisabled: &yes, ReadonlyRootfs: &yes}, merged)
+
+	runner := ContainerLimits{CPUs: 0.5, Memory: 8192, Pids: 512, ReadonlyRootfs: &yes}
+	limits := ContainerLimits{Memory: 4096, NetworkDisabled: &no}.Restrict(runner)
+	assert.Equal(t, 0.5, limits.CPUs, "use runner cpus limit as default")
+	assert.Equal(t, int64(4096), limits.Memory)
+	assert.Equal(t, int64(512), limits.Pids, "use runner pids limit as default")
+	assert.False(t, limits.IsNetworkDisabled())
+	assert.True(t, limits.IsReadonlyRootfs(), "runner isolation option is enforced")
+	assert.Equal(t, 0.5, ContainerLimits{CPUs: 2}.Restrict(runner).CPUs, "cpus exceeds runner limit")
+
+	assert.Error(t, ContainerLimits{Memory: -1}.Validate())
+	assert.NoError(t, ContainerLimits{}.Validate())
+}
@@ -12,4 +12,18 @@ var (
 		TaskJobScan,
 		TaskJobParse,
 	}
+
+	// PipelineStepTypes 可以在 pipeline 中使用的步骤类型(runner 支持的步骤中排除了内部使用的步骤)
+	PipelineStepTypes = []string{
+		TaskStepCheckout,
+		TaskStepTfInit,
+		TaskStepTfPlan,
+		TaskStepTfApply,
+		TaskStepTfDestroy,
+		TaskStepAnsiblePlay,
+		TaskStepCommand,
+		TaskStepScanInit,
+		TaskStepRegoParse,
+		TaskStepOpaScan,
+	}
 )
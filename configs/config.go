@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v2"
+
+	"cloudiac/common"
 )
 
 type KafkaConfig struct {
@@ -47,6 +49,40 @@ type RunnerConfig struct {
 	PluginCachePath  string `yaml:"plugin_cache_path"`
 	OfflineMode      bool   `yaml:"offline_mode"`       // 离线模式?
 	ReserveContainer bool   `yaml:"reserver_container"` // 任务结束后保留容器?(停止容器但不删除)
+	MaxTasks         int    `yaml:"max_tasks"`          // 最大并发任务数，通过心跳上报给 portal，为 0 时使用 portal 的全局配置
+
+	Executor   string                   `yaml:"executor"` // 任务执行器: docker(默认)、kubernetes
+	Kubernetes KubernetesExecutorConfig `yaml:"kubernetes"`
+
+	// 任务容器资源限制及隔离配置，做为项目、环境未配置时的默认值及数值限制的上限
+	Limits common.ContainerLimits `yaml:"limits"`
+}
+
+// KubernetesExecutorConfig kubernetes 执行器配置，每个任务以 pod 的方式运行
+type KubernetesExecutorConfig struct {
+	Kubeconfig     string `yaml:"kubeconfig"`      // kubeconfig 文件路径，为空则使用 in-cluster 配置
+	Namespace      string `yaml:"namespace"`       // 任务 pod 所在的 namespace，默认为 default
+	ServiceAccount string `yaml:"service_account"` // 任务 pod 使用的 service account
+	// 任务 pod 通过该 pvc 访问 runner 的 storage、plugin cache 等目录，pvc 需要同时挂载到 runner(ReadWriteMany)。
+	// 未配置时使用 hostPath 挂载，此时 runner 需要与任务 pod 运行在同一节点
+	VolumeClaim     string `yaml:"volume_claim"`
+	VolumeMountPath string `yaml:"volume_mount_path"` // pvc 在 runner 中的挂载路径
+}
+
+const (
+	RegistryConsul = "consul"
+	RegistryDB     = "db"
+)
+
+// RegistryConfig 服务注册配置。db 模式下不依赖 consul，runner 通过 portal 接口注册到数据库，
+// task manager 使用数据库锁选主。两种模式下服务自身的信息(id、ip、port、tags)都读取 consul 配置项
+type RegistryConfig struct {
+	Type  string `yaml:"type"`  // consul(默认)、db
+	Token string `yaml:"token"` // db 模式下 runner 注册使用的共享 token，portal 与 runner 需要配置相同的值
+}
+
+func (c RegistryConfig) IsDB() bool {
+	return c.Type == RegistryDB
 }
 
 type PortalConfig struct {
@@ -87,6 +123,10 @@ func (c *RunnerConfig) AbsTfenvVersionsCachePath() string {
 	return c.mustAbs(filepath.Join(c.PluginCachePath, ".tfenv-versions"))
 }
 
+func (c *RunnerConfig) AbsTofuenvVersionsCachePath() string {
+	return c.mustAbs(filepath.Join(c.PluginCachePath, ".tofuenv-versions"))
+}
+
 type LogConfig struct {
 	LogLevel   string `yaml:"log_level"`
 	LogPath    string `yaml:"log_path"`
@@ -106,6 +146,80 @@ type PolicyConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+const (
+	LogStorageTypeDB = "db"
+	LogStorageTypeFS = "fs"
+	LogStorageTypeS3 = "s3"
+)
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"` // 如 https://s3.amazonaws.com、http://minio:9000
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	Prefix    string `yaml:"prefix"`     // 对象 key 前缀
+	PathStyle bool   `yaml:"path_style"` // 使用 path-style 访问(MinIO 等兼容服务一般需要开启)
+}
+
+// LogStorageConfig 任务日志及 state/plan 等 json 文件的存储配置
+type LogStorageConfig struct {
+	Type string   `yaml:"type"` // db(默认), fs, s3
+	Path string   `yaml:"path"` // fs 类型的存储目录
+	S3   S3Config `yaml:"s3"`
+}
+
+// LdapConfig ldap 登录配置。开启后系统中不存在的用户及 ldap 来源的用户通过 ldap 认证，
+// 首次登录时自动创建用户，每次登录时根据 group_mappings 同步用户的组织及项目角色
+type LdapConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	Url                string `yaml:"url"` // 如 ldap://ldap.example.com:389、ldaps://ldap.example.com:636
+	StartTLS           bool   `yaml:"start_tls"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	BindDN             string `yaml:"bind_dn"` // 查询用户使用的账号，为空则匿名查询
+	BindPassword       string `yaml:"bind_password"`
+
+	BaseDN     string `yaml:"base_dn"`
+	UserFilter string `yaml:"user_filter"` // 查询用户的 filter，%s 替换为登录邮箱，默认为 (mail=%s)
+	EmailAttr  string `yaml:"email_attr"`  // 默认为 mail
+	NameAttr   string `yaml:"name_attr"`   // 默认为 cn
+	GroupAttr  string `yaml:"group_attr"`  // 用户所在组的属性，默认为 memberOf
+
+	// 配置 group_base_dn 后会额外通过 group_filter 查询用户所在的组(适用于没有 memberOf 属性的 ldap 服务)
+	GroupBaseDN string `yaml:"group_base_dn"`
+	GroupFilter string `yaml:"group_filter"` // %s 替换为用户 DN，默认为 (member=%s)
+
+	GroupMappings []GroupRoleMapping `yaml:"group_mappings"`
+}
+
+// OidcConfig oidc 单点登录配置，用户通过 email 与系统中的用户关联，不存在时自动创建
+type OidcConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Issuer       string   `yaml:"issuer"` // 通过 <issuer>/.well-known/openid-configuration 获取服务配置
+	ClientId     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectUrl  string   `yaml:"redirect_url"` // 默认为 <portal.address>/api/v1/auth/oidc/callback
+	Scopes       []string `yaml:"scopes"`       // 默认为 openid, profile, email
+	SuccessUrl   string   `yaml:"success_url"`  // 登录成功后跳转的页面，token 通过 url fragment 传递，默认为 <portal.address>/
+	SsoOnly      bool     `yaml:"sso_only"`     // 开启后只有平台管理员可以使用密码登录
+
+	EmailClaim  string `yaml:"email_claim"`  // 默认为 email
+	NameClaim   string `yaml:"name_claim"`   // 默认为 name
+	GroupsClaim string `yaml:"groups_claim"` // 默认为 groups
+
+	AdminGroups   []string           `yaml:"admin_groups"` // 属于其中任一组的用户设置为平台管理员
+	GroupMappings []GroupRoleMapping `yaml:"group_mappings"`
+}
+
+// GroupRoleMapping ldap 组或 oidc groups claim 与组织、项目角色的映射，ldap 组可以使用 DN 或 CN
+type GroupRoleMapping struct {
+	Group       string `yaml:"group"`
+	OrgId       string `yaml:"org_id"`
+	OrgRole     string `yaml:"org_role"`     // admin, member，为空时为 member
+	ProjectId   string `yaml:"project_id"`   // 可选，项目需要属于 org_id 指定的组织
+	ProjectRole string `yaml:"project_role"` // manager, approver, operator, guest，为空时为 operator
+}
+
 func (ut *yamlTimeDuration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var ds string
 	if err := unmarshal(&ds); err != nil {
@@ -123,6 +237,7 @@ type Config struct {
 	Mysql        string           `yaml:"mysql"`
 	Listen       string           `yaml:"listen"`
 	Consul       ConsulConfig     `yaml:"consul"`
+	Registry     RegistryConfig   `yaml:"registry"`
 	Portal       PortalConfig     `yaml:"portal"`
 	Runner       RunnerConfig     `yaml:"runner"`
 	Log          LogConfig        `yaml:"log"`
@@ -134,6 +249,11 @@ type Config struct {
 	ExportSecretKey string `yaml:"exportSecretKey"`
 
 	Policy PolicyConfig `yaml:"policy"`
+
+	LogStorage LogStorageConfig `yaml:"log_storage"`
+
+	Ldap LdapConfig `yaml:"ldap"`
+	Oidc OidcConfig `yaml:"oidc"`
 }
 
 const (
@@ -149,6 +269,23 @@ var (
 			SSHPrivateKey: "var/private_key",
 			SSHPublicKey:  "var/private_key.pub",
 		},
+		LogStorage: LogStorageConfig{
+			Type: LogStorageTypeDB,
+			Path: "var/storage",
+		},
+		Ldap: LdapConfig{
+			UserFilter:  "(mail=%s)",
+			EmailAttr:   "mail",
+			NameAttr:    "cn",
+			GroupAttr:   "memberOf",
+			GroupFilter: "(member=%s)",
+		},
+		Oidc: OidcConfig{
+			Scopes:      []string{"openid", "profile", "email"},
+			EmailClaim:  "email",
+			NameClaim:   "name",
+			GroupsClaim: "groups",
+		},
 	}
 )
 
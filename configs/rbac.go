@@ -112,10 +112,22 @@ var Polices = []Policy{
 	{"approver", "envs", "*"},
 	{"approver", "tasks", "*"},
 	{"operator", "envs", "read/update/deploy/destroy"},
-	{"operator", "tasks", "read"},
+	{"operator", "tasks", "read/abort/resume"},
 	{"guest", "envs", "read"},
 	{"guest", "tasks", "read"},
 
+	// 审批策略
+	{"manager", "approval_policies", "*"},
+	{"approver", "approval_policies", "read"},
+	{"operator", "approval_policies", "read"},
+	{"guest", "approval_policies", "read"},
+
+	// 维护窗口
+	{"manager", "maintenance_windows", "*"},
+	{"approver", "maintenance_windows", "read"},
+	{"operator", "maintenance_windows", "read"},
+	{"guest", "maintenance_windows", "read"},
+
 	{"manager", "templates", "*"},
 	{"approver", "templates", "*"},
 	{"operator", "templates", "read"},
@@ -161,6 +173,14 @@ var Polices = []Policy{
 	{"admin", "keys", "*"},
 	{"member", "keys", "*"},
 
+	// 自定义角色
+	{"admin", "roles", "*"},
+	{"member", "roles", "read"},
+	{"manager", "roles", "read"},
+
+	// 审计日志
+	{"admin", "audit", "read"},
+
 	// 演示模式，当访问演示组织下的资源，进入受限模式
 	{"demo", "orgs", "read"},
 	{"demo", "users", "read"},
@@ -171,8 +191,11 @@ var Polices = []Policy{
 	{"demo", "vcs", "read"},
 	{"demo", "runners", "read"},
 	{"demo", "keys", "read"},
+	{"demo", "roles", "read"},
 	{"demo", "templates", "read"},
 	{"demo", "envs", "*"},
 	{"demo", "tasks", "*"},
+	{"demo", "approval_policies", "read"},
+	{"demo", "maintenance_windows", "read"},
 	{"demo", "variables", "*"},
 }
@@ -0,0 +1,152 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/page"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// checkApproverRoles 检查审批角色，允许内置项目角色及组织的项目自定义角色
+func checkApproverRoles(c *ctx.ServiceContext, roles ...[]string) e.Error {
+	for _, rs := range roles {
+		for _, r := range rs {
+			if err := services.CheckRole(c.DB(), c.OrgId, consts.ScopeProject, r); err != nil {
+				if err.Code() == e.InvalidRoleName {
+					return e.New(e.BadParam, fmt.Errorf("invalid approver role '%s'", r), http.StatusBadRequest)
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func SearchApprovalPolicy(c *ctx.ServiceContext, form *forms.SearchApprovalPolicyForm) (interface{}, e.Error) {
+	query := services.SearchApprovalPolicy(c.DB(), c.ProjectId, form.EnvId)
+	p := page.New(form.CurrentPage(), form.PageSize(), query)
+	policies := make([]*models.ApprovalPolicy, 0)
+	if err := p.Scan(&policies); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return page.PageResp{
+		Total:    p.MustTotal(),
+		PageSize: p.Size,
+		List:     policies,
+	}, nil
+}
+
+func CreateApprovalPolicy(c *ctx.ServiceContext, form *forms.CreateApprovalPolicyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("create approval policy, env '%s'", form.EnvId))
+
+	if err := checkApproverRoles(c, form.ApplyApproverRoles, form.DestroyApproverRoles); err != nil {
+		return nil, err
+	}
+	if form.EnvId != "" {
+		query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+		if _, err := services.GetEnvById(query, form.EnvId); err != nil {
+			if err.Code() == e.EnvNotExists {
+				return nil, e.New(err.Code(), err, http.StatusBadRequest)
+			}
+			return nil, err
+		}
+	}
+	requiredApprovals := form.RequiredApprovals
+	if requiredApprovals == 0 {
+		requiredApprovals = 1
+	}
+
+	return services.CreateApprovalPolicy(c.DB(), models.ApprovalPolicy{
+		OrgId:                c.OrgId,
+		ProjectId:            c.ProjectId,
+		EnvId:                form.EnvId,
+		RequiredApprovals:    requiredApprovals,
+		AllowSelfApproval:    form.AllowSelfApproval,
+		ExpireMinutes:        form.ExpireMinutes,
+		ApplyApproverIds:     pq.StringArray(form.ApplyApproverIds),
+		ApplyApproverRoles:   pq.StringArray(form.ApplyApproverRoles),
+		DestroyApproverIds:   pq.StringArray(form.DestroyApproverIds),
+		DestroyApproverRoles: pq.StringArray(form.DestroyApproverRoles),
+		CreatorId:            c.UserId,
+	})
+}
+
+func UpdateApprovalPolicy(c *ctx.ServiceContext, form *forms.UpdateApprovalPolicyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("update approval policy %s", form.Id))
+
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	if _, err := services.GetApprovalPolicyById(query, form.Id); err != nil {
+		if err.Code() == e.ApprovalPolicyNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if err := checkApproverRoles(c, form.ApplyApproverRoles, form.DestroyApproverRoles); err != nil {
+		return nil, err
+	}
+
+	attrs := models.Attrs{}
+	if form.HasKey("requiredApprovals") {
+		if form.RequiredApprovals == 0 {
+			form.RequiredApprovals = 1
+		}
+		attrs["required_approvals"] = form.RequiredApprovals
+	}
+	if form.HasKey("allowSelfApproval") {
+		attrs["allow_self_approval"] = form.AllowSelfApproval
+	}
+	if form.HasKey("expireMinutes") {
+		attrs["expire_minutes"] = form.ExpireMinutes
+	}
+	if form.HasKey("applyApproverIds") {
+		attrs["apply_approver_ids"] = pq.StringArray(form.ApplyApproverIds)
+	}
+	if form.HasKey("applyApproverRoles") {
+		attrs["apply_approver_roles"] = pq.StringArray(form.ApplyApproverRoles)
+	}
+	if form.HasKey("destroyApproverIds") {
+		attrs["destroy_approver_ids"] = pq.StringArray(form.DestroyApproverIds)
+	}
+	if form.HasKey("destroyApproverRoles") {
+		attrs["destroy_approver_roles"] = pq.StringArray(form.DestroyApproverRoles)
+	}
+
+	return services.UpdateApprovalPolicy(query, form.Id, attrs)
+}
+
+func DeleteApprovalPolicy(c *ctx.ServiceContext, form *forms.DeleteApprovalPolicyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("delete approval policy %s", form.Id))
+
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	if _, err := services.GetApprovalPolicyById(query, form.Id); err != nil {
+		if err.Code() == e.ApprovalPolicyNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if err := services.DeleteApprovalPolicy(query, form.Id); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func DetailApprovalPolicy(c *ctx.ServiceContext, form *forms.DetailApprovalPolicyForm) (interface{}, e.Error) {
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	policy, err := services.GetApprovalPolicyById(query, form.Id)
+	if err != nil {
+		if err.Code() == e.ApprovalPolicyNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	return policy, nil
+}
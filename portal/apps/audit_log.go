@@ -0,0 +1,134 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"bytes"
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/libs/page"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditLogFormatCsv   = "csv"
+	AuditLogFormatJsonl = "jsonl"
+)
+
+// auditLogQuery 平台管理员可以查询所有日志，其他用户只能查询当前组织的日志
+func auditLogQuery(c *ctx.ServiceContext, form *forms.SearchAuditLogForm) (*db.Session, e.Error) {
+	if !c.IsSuperAdmin {
+		if c.OrgId == "" {
+			return nil, e.New(e.PermissionDeny, fmt.Errorf("org id is required"), http.StatusForbidden)
+		}
+		form.OrgId = c.OrgId
+	}
+	if !form.From.IsZero() && !form.To.IsZero() && form.From.After(form.To) {
+		return nil, e.New(e.BadParam, fmt.Errorf("'from' must before 'to'"), http.StatusBadRequest)
+	}
+	return services.SearchOperationLog(c.DB(), form), nil
+}
+
+// maskAuditLogs 对请求内容重新脱敏，历史日志中可能记录了未脱敏或非 json 格式的请求内容
+func maskAuditLogs(logs []*models.OperationLog) {
+	for _, l := range logs {
+		l.Desc = services.MaskAuditBody(l.Desc)
+	}
+}
+
+func SearchAuditLog(c *ctx.ServiceContext, form *forms.SearchAuditLogForm) (interface{}, e.Error) {
+	query, err := auditLogQuery(c, form)
+	if err != nil {
+		return nil, err
+	}
+
+	p := page.New(form.CurrentPage(), form.PageSize(), query)
+	logs := make([]*models.OperationLog, 0)
+	if err := p.Scan(&logs); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	maskAuditLogs(logs)
+	return page.PageResp{
+		Total:    p.MustTotal(),
+		PageSize: p.Size,
+		List:     logs,
+	}, nil
+}
+
+// ExportAuditLog 按查询条件导出审计日志，单次最多导出 consts.AuditLogExportMaxRows 条
+func ExportAuditLog(c *ctx.ServiceContext, form *forms.SearchAuditLogForm) ([]byte, e.Error) {
+	if form.Format != AuditLogFormatCsv && form.Format != AuditLogFormatJsonl {
+		return nil, e.New(e.BadParam, fmt.Errorf("invalid export format '%s'", form.Format), http.StatusBadRequest)
+	}
+	query, err := auditLogQuery(c, form)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]*models.OperationLog, 0)
+	if err := query.Limit(consts.AuditLogExportMaxRows).Find(&logs); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	maskAuditLogs(logs)
+
+	var (
+		data []byte
+		er   error
+	)
+	if form.Format == AuditLogFormatCsv {
+		data, er = auditLogsToCsv(logs)
+	} else {
+		data, er = auditLogsToJsonl(logs)
+	}
+	if er != nil {
+		return nil, e.New(e.InternalError, er)
+	}
+	return data, nil
+}
+
+func auditLogsToJsonl(logs []*models.OperationLog) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buf)
+	for _, l := range logs {
+		if err := encoder.Encode(l); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func auditLogsToCsv(logs []*models.OperationLog) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{
+		"id", "operationAt", "userId", "username", "userAddr", "orgId", "projectId",
+		"action", "resourceType", "resourceId", "method", "path", "statusCode", "changes", "desc",
+	})
+	for _, l := range logs {
+		changes := ""
+		if len(l.Changes) > 0 {
+			bs, err := json.Marshal(l.Changes)
+			if err != nil {
+				return nil, err
+			}
+			changes = string(bs)
+		}
+		_ = w.Write([]string{
+			l.Id.String(), time.Time(l.OperationAt).Format(time.RFC3339), l.UserID.String(), l.Username, l.UserAddr,
+			l.OrgId.String(), l.ProjectId.String(), l.Action, l.ResourceType, l.ResourceId.String(),
+			l.Method, l.Path, strconv.Itoa(l.StatusCode), changes, string(l.Desc),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -3,6 +3,8 @@
 package apps
 
 import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts"
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models"
@@ -11,6 +13,7 @@ import (
 	"cloudiac/utils"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -19,20 +22,39 @@ func Login(c *ctx.ServiceContext, form *forms.LoginForm) (resp interface{}, err
 	c.AddLogField("action", fmt.Sprintf("user login: %s", form.Email))
 
 	user, err := services.GetUserByEmail(c.DB(), form.Email)
-	if err != nil {
-		if err.Code() == e.UserNotExists {
-			// 找不到账号时也返回 InvalidPassword 错误，避免暴露系统中己有用户账号
-			return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
-		}
+	if err != nil && err.Code() != e.UserNotExists {
 		return nil, e.New(e.DBError, err)
 	}
 
-	valid, er := utils.CheckPassword(form.Password, user.Password)
-	if er != nil {
-		return nil, e.New(e.ValidateError, http.StatusInternalServerError, er)
-	}
-	if !valid {
-		return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+	// 开启 sso only 后只有平台管理员可以使用密码登录
+	ssoOnly := configs.Get().Oidc.Enabled && configs.Get().Oidc.SsoOnly
+
+	ldapCfg := configs.Get().Ldap
+	if ldapCfg.Enabled && (user == nil || user.Source == consts.UserSourceLdap) {
+		if ssoOnly && (user == nil || !user.IsAdmin) {
+			return nil, e.New(e.PasswordLoginDisabled, http.StatusForbidden)
+		}
+		// 开启 ldap 后系统中不存在的用户及 ldap 来源的用户通过 ldap 认证
+		user, err = ldapLogin(c, ldapCfg, user, form)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// 找不到账号时也返回 InvalidPassword 错误，避免暴露系统中己有用户账号
+		if user == nil || user.Source != "" {
+			return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+		}
+
+		valid, er := utils.CheckPassword(form.Password, user.Password)
+		if er != nil {
+			return nil, e.New(e.ValidateError, http.StatusInternalServerError, er)
+		}
+		if !valid {
+			return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+		}
+		if ssoOnly && !user.IsAdmin {
+			return nil, e.New(e.PasswordLoginDisabled, http.StatusForbidden)
+		}
 	}
 
 	token, er := services.GenerateToken(user.Id, user.Name, user.IsAdmin, 1*24*time.Hour)
@@ -49,3 +71,106 @@ func Login(c *ctx.ServiceContext, form *forms.LoginForm) (resp interface{}, err
 	return data, nil
 }
 
+// ldapLogin 通过 ldap 认证用户，首次登录时自动创建用户，并根据 ldap 组同步用户的组织及项目角色
+func ldapLogin(c *ctx.ServiceContext, cfg configs.LdapConfig, user *models.User, form *forms.LoginForm) (*models.User, e.Error) {
+	ldapUser, err := services.LdapAuthenticate(cfg, form.Email, form.Password)
+	if err != nil {
+		if err.Code() == e.LdapError {
+			c.Logger().Errorf("ldap authenticate %s: %v", form.Email, err)
+		}
+		return nil, err
+	}
+
+	// 登录输入可能不是 email(如 uid)，使用 ldap 返回的 email 关联系统中的用户
+	if user == nil && ldapUser.Email != form.Email {
+		user, err = services.GetUserByEmail(c.DB(), ldapUser.Email)
+		if err != nil && err.Code() != e.UserNotExists {
+			return nil, e.New(e.DBError, err)
+		}
+		// 不关联非 ldap 来源的用户，避免通过 ldap 登录本地账号
+		if user != nil && user.Source != consts.UserSourceLdap {
+			return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+		}
+	}
+	if user != nil && user.Status == models.Disable {
+		return nil, e.New(e.UserDisabled, http.StatusForbidden)
+	}
+
+	return syncExternalUser(c, user, externalUser{
+		Source: consts.UserSourceLdap,
+		Email:  ldapUser.Email,
+		Name:   ldapUser.Name,
+		Groups: ldapUser.Groups,
+	}, cfg.GroupMappings, nil)
+}
+
+// externalUser ldap、oidc 等外部认证服务返回的用户信息
+type externalUser struct {
+	Source string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// syncExternalUser 同步外部认证的用户，用户不存在时自动创建，并根据组同步用户的组织、项目角色及平台管理员权限
+func syncExternalUser(c *ctx.ServiceContext, user *models.User, ext externalUser,
+	mappings []configs.GroupRoleMapping, adminGroups []string) (*models.User, e.Error) {
+	name := ext.Name
+	if name == "" {
+		name = strings.Split(ext.Email, "@")[0]
+	}
+	if r := []rune(name); len(r) > 32 {
+		name = string(r[:32])
+	}
+	isAdmin := len(adminGroups) > 0 && services.GroupsMatchAny(adminGroups, ext.Groups)
+
+	tx := c.Tx()
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	var err e.Error
+	if user == nil {
+		// 外部用户不使用本地密码登录，这里设置一个随机密码
+		hashedPassword, er := utils.HashPassword(utils.RandomStr(32))
+		if er != nil {
+			_ = tx.Rollback()
+			return nil, e.New(e.InternalError, er)
+		}
+		user, err = services.CreateUser(tx, models.User{
+			Name:     name,
+			Email:    ext.Email,
+			Password: hashedPassword,
+			IsAdmin:  isAdmin,
+			Source:   ext.Source,
+		})
+	} else {
+		attrs := models.Attrs{}
+		if user.Name != name {
+			attrs["name"] = name
+		}
+		// 本地创建的用户不会被取消平台管理员权限，避免因组配置错误导致管理员无法登录
+		if len(adminGroups) > 0 && isAdmin != user.IsAdmin && (isAdmin || user.Source != "") {
+			attrs["is_admin"] = isAdmin
+		}
+		if len(attrs) > 0 {
+			user, err = services.UpdateUser(tx, user.Id, attrs)
+		}
+	}
+	if err == nil {
+		err = services.SyncUserGroupRoles(tx, user.Id, ext.Groups, mappings)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = tx.Rollback()
+		return nil, e.New(e.DBError, err)
+	}
+	return user, nil
+}
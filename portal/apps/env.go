@@ -4,6 +4,7 @@ package apps
 
 import (
 	"cloudiac/common"
+	"cloudiac/configs"
 	"cloudiac/portal/consts"
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/ctx"
@@ -42,6 +43,43 @@ func ParseCronpress(cronDriftExpress string) (*time.Time, e.Error) {
 	return &nextTime, nil
 }
 
+// ParseCronDeployExpress 解析定时部署表达式，返回下次执行定时部署的时间，表达式为空表示不开启定时部署
+func ParseCronDeployExpress(express string) (*time.Time, e.Error) {
+	if express == "" {
+		return nil, nil
+	}
+	return ParseCronpress(express)
+}
+
+// checkStateBackend 检查 state 存储配置，并返回加密后的认证信息
+func checkStateBackend(backend string, conf models.StateBackendConfig, secret *models.StateBackendSecret) (string, e.Error) {
+	if err := services.CheckStateBackend(backend, conf, configs.Get().Registry); err != nil {
+		return "", e.New(err.Code(), err, http.StatusBadRequest)
+	}
+	if secret == nil {
+		return "", nil
+	}
+	return services.EncryptStateBackendSecret(*secret)
+}
+
+// checkRunnerTags local backend 的 state 保存在 runner 本地，环境需要固定 runner，不能通过 runner tags 调度
+func checkRunnerTags(backend string, tags []string) e.Error {
+	if len(tags) > 0 && backend == models.StateBackendLocal {
+		return e.New(e.BadParam, fmt.Errorf("runner tags can not be used with local state backend"), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// IsInCronWindow 判断 now 是否处于以 cron 表达式为开始时间、持续 duration 的时间窗口内
+func IsInCronWindow(express string, duration time.Duration, now time.Time) (bool, e.Error) {
+	expr, err := SpecParser.Parse(express)
+	if err != nil {
+		return false, e.New(e.BadParam, http.StatusBadRequest, err)
+	}
+	// 窗口开始时间在 (now - duration, now] 之间即表示当前处于窗口内
+	return !expr.Next(now.Add(-duration)).After(now), nil
+}
+
 // 获取环境偏移检测任务类型并且检查其参数
 func GetCronTaskTypeAndCheckParam(cronExpress string, autoRepairDrift, openCronDrift bool) (string, e.Error) {
 	if openCronDrift {
@@ -138,6 +176,15 @@ func CreateEnv(c *ctx.ServiceContext, form *forms.CreateEnvForm) (*models.EnvDet
 	if form.Timeout == 0 {
 		form.Timeout = common.DefaultTaskStepTimeout
 	}
+	if err := form.ContainerLimits.Validate(); err != nil {
+		return nil, e.New(e.BadParam, err, http.StatusBadRequest)
+	}
+	if form.StateBackend == "" {
+		form.StateBackend = services.DefaultStateBackend(configs.Get().Registry)
+	}
+	if err := checkRunnerTags(form.StateBackend, form.RunnerTags); err != nil {
+		return nil, err
+	}
 
 	var (
 		destroyAt models.Time
@@ -165,7 +212,7 @@ func CreateEnv(c *ctx.ServiceContext, form *forms.CreateEnvForm) (*models.EnvDet
 	}()
 
 	var runnerId string = form.RunnerId
-	if runnerId == "" {
+	if runnerId == "" && len(form.RunnerTags) == 0 {
 		rId, err := services.GetDefaultRunner()
 		if err != nil {
 			return nil, err
@@ -221,6 +268,21 @@ func CreateEnv(c *ctx.ServiceContext, form *forms.CreateEnvForm) (*models.EnvDet
 		}
 		envModel.NextDriftTaskTime = nextTime
 	}
+	// 定时部署
+	if envModel.NextDeployTaskTime, err = ParseCronDeployExpress(form.CronDeployExpress); err != nil {
+		return nil, err
+	}
+	envModel.CronDeployExpress = form.CronDeployExpress
+	// state 存储
+	envModel.StateBackend = form.StateBackend
+	envModel.StateBackendConfig = form.StateBackendConfig
+	envModel.ContainerLimits = form.ContainerLimits
+	envModel.RunnerTags = form.RunnerTags
+	if envModel.StateBackendSecret, err = checkStateBackend(
+		envModel.StateBackend, form.StateBackendConfig, form.StateBackendSecret); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
 	env, err := services.CreateEnv(tx, envModel)
 	if err != nil && err.Code() == e.EnvAlreadyExists {
 		_ = tx.Rollback()
@@ -461,6 +523,7 @@ func UpdateEnv(c *ctx.ServiceContext, form *forms.UpdateEnvForm) (*models.EnvDet
 	if env.Archived && !form.Archived {
 		return nil, e.New(e.EnvArchived, http.StatusBadRequest)
 	}
+	before := *env
 
 	attrs := models.Attrs{}
 
@@ -479,6 +542,60 @@ func UpdateEnv(c *ctx.ServiceContext, form *forms.UpdateEnvForm) (*models.EnvDet
 	attrs["cronDriftExpress"] = cronDriftParam.CronDriftExpress
 	attrs["nextDriftTaskTime"] = cronDriftParam.NextDriftTaskTime
 
+	if form.HasKey("cronDeployExpress") {
+		nextTime, err := ParseCronDeployExpress(form.CronDeployExpress)
+		if err != nil {
+			return nil, err
+		}
+		attrs["cronDeployExpress"] = form.CronDeployExpress
+		attrs["nextDeployTaskTime"] = nextTime
+	}
+
+	if form.HasKey("stateBackend") || form.HasKey("stateBackendConfig") || form.StateBackendSecret != nil {
+		backend := env.StateBackend
+		if form.HasKey("stateBackend") {
+			// 环境存在资源时修改 state 存储方式会导致 state 丢失
+			if form.StateBackend != env.StateBackend && env.Status == models.EnvStatusActive {
+				return nil, e.New(e.EnvStateBackendInUse, http.StatusBadRequest)
+			}
+			backend = form.StateBackend
+		}
+		conf := env.StateBackendConfig
+		if form.HasKey("stateBackendConfig") {
+			conf = form.StateBackendConfig
+		}
+		secret, err := checkStateBackend(backend, conf, form.StateBackendSecret)
+		if err != nil {
+			return nil, err
+		}
+		attrs["stateBackend"] = backend
+		attrs["stateBackendConfig"] = conf
+		if form.StateBackendSecret != nil {
+			attrs["stateBackendSecret"] = secret
+		}
+	}
+
+	if form.HasKey("containerLimits") {
+		if err := form.ContainerLimits.Validate(); err != nil {
+			return nil, e.New(e.BadParam, err, http.StatusBadRequest)
+		}
+		attrs["containerLimits"] = form.ContainerLimits
+	}
+
+	if form.HasKey("runnerTags") || form.HasKey("stateBackend") {
+		backend, tags := env.StateBackend, []string(env.RunnerTags)
+		if form.HasKey("stateBackend") {
+			backend = form.StateBackend
+		}
+		if form.HasKey("runnerTags") {
+			tags = form.RunnerTags
+			attrs["runner_tags"] = pq.StringArray(form.RunnerTags)
+		}
+		if err := checkRunnerTags(backend, tags); err != nil {
+			return nil, err
+		}
+	}
+
 	if form.HasKey("name") {
 		attrs["name"] = form.Name
 	}
@@ -579,6 +696,7 @@ func UpdateEnv(c *ctx.ServiceContext, form *forms.UpdateEnvForm) (*models.EnvDet
 		c.Logger().Errorf("error update env, err %s", err)
 		return nil, err
 	}
+	c.AddAuditChange(services.DiffAuditChange("env", env.Id, before, env))
 
 	env.MergeTaskStatus()
 	detail := &models.EnvDetail{Env: *env}
@@ -719,6 +837,12 @@ func envDeploy(c *ctx.ServiceContext, tx *db.Session, form *forms.DeployEnvForm)
 	if cronDriftParam.CronDriftExpress != nil {
 		env.CronDriftExpress = *cronDriftParam.CronDriftExpress
 	}
+	if form.HasKey("cronDeployExpress") {
+		if env.NextDeployTaskTime, err = ParseCronDeployExpress(form.CronDeployExpress); err != nil {
+			return nil, err
+		}
+		env.CronDeployExpress = form.CronDeployExpress
+	}
 
 	if form.HasKey("triggers") {
 		env.Triggers = form.Triggers
@@ -730,6 +854,12 @@ func envDeploy(c *ctx.ServiceContext, tx *db.Session, form *forms.DeployEnvForm)
 	if form.HasKey("runnerId") {
 		env.RunnerId = form.RunnerId
 	}
+	if form.HasKey("runnerTags") {
+		if err := checkRunnerTags(env.StateBackend, form.RunnerTags); err != nil {
+			return nil, err
+		}
+		env.RunnerTags = form.RunnerTags
+	}
 	if form.HasKey("timeout") {
 		env.Timeout = form.Timeout
 	}
@@ -0,0 +1,211 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+)
+
+func getProjectEnv(c *ctx.ServiceContext, query *db.Session, envId models.Id) (*models.Env, e.Error) {
+	query = services.QueryWithProjectId(services.QueryWithOrgId(query, c.OrgId), c.ProjectId)
+	env, err := services.GetEnvById(query, envId)
+	if err != nil {
+		if err.Code() == e.EnvNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	return env, nil
+}
+
+func checkOutputMappings(mappings []models.EnvOutputMapping) e.Error {
+	vars := make(map[string]bool)
+	for _, m := range mappings {
+		if m.Output == "" || m.Variable == "" {
+			return e.New(e.BadParam, fmt.Errorf("output and variable are required"), http.StatusBadRequest)
+		}
+		if vars[m.Variable] {
+			return e.New(e.BadParam, fmt.Errorf("duplicate variable '%s'", m.Variable), http.StatusBadRequest)
+		}
+		vars[m.Variable] = true
+	}
+	return nil
+}
+
+func getEnvDependency(c *ctx.ServiceContext, envId, id models.Id) (*models.EnvDependency, e.Error) {
+	dep, err := services.GetEnvDependencyById(services.QueryWithProjectId(c.DB(), c.ProjectId), id)
+	if err != nil {
+		if err.Code() == e.EnvDependencyNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if dep.EnvId != envId {
+		return nil, e.New(e.EnvDependencyNotExists, http.StatusNotFound)
+	}
+	return dep, nil
+}
+
+// SearchEnvDependency 查询环境依赖的上游环境
+func SearchEnvDependency(c *ctx.ServiceContext, form *forms.SearchEnvDependencyForm) (interface{}, e.Error) {
+	if _, err := getProjectEnv(c, c.DB(), form.Id); err != nil {
+		return nil, err
+	}
+	return services.GetEnvDependencies(c.DB(), form.Id)
+}
+
+func CreateEnvDependency(c *ctx.ServiceContext, form *forms.CreateEnvDependencyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("create env dependency %s -> %s", form.Id, form.DependsOnEnvId))
+
+	if form.Id == form.DependsOnEnvId {
+		return nil, e.New(e.EnvDependencyCycle, http.StatusBadRequest)
+	}
+	if err := checkOutputMappings(form.OutputMappings); err != nil {
+		return nil, err
+	}
+
+	var (
+		dep *models.EnvDependency
+		er  e.Error
+	)
+	_ = c.DB().Transaction(func(tx *db.Session) error {
+		dep, er = createEnvDependency(c, tx, form)
+		return er
+	})
+	return dep, er
+}
+
+func createEnvDependency(c *ctx.ServiceContext, tx *db.Session, form *forms.CreateEnvDependencyForm) (*models.EnvDependency, e.Error) {
+	if _, err := getProjectEnv(c, tx, form.Id); err != nil {
+		return nil, err
+	}
+	if _, err := getProjectEnv(c, tx, form.DependsOnEnvId); err != nil {
+		if err.Code() == e.EnvNotExists {
+			return nil, e.New(err.Code(), err, http.StatusBadRequest)
+		}
+		return nil, err
+	}
+
+	deps, err := services.GetProjectEnvDependencies(tx, c.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	if services.HasEnvDependencyCycle(deps, form.Id, form.DependsOnEnvId) {
+		return nil, e.New(e.EnvDependencyCycle, http.StatusBadRequest)
+	}
+
+	dep, err := services.CreateEnvDependency(tx, models.EnvDependency{
+		OrgId:          c.OrgId,
+		ProjectId:      c.ProjectId,
+		EnvId:          form.Id,
+		DependsOnEnvId: form.DependsOnEnvId,
+		OutputMappings: form.OutputMappings,
+		CreatorId:      c.UserId,
+	})
+	if err != nil && err.Code() == e.EnvDependencyExists {
+		return nil, e.New(err.Code(), err, http.StatusBadRequest)
+	}
+	return dep, err
+}
+
+func UpdateEnvDependency(c *ctx.ServiceContext, form *forms.UpdateEnvDependencyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("update env dependency %s", form.DependencyId))
+
+	if _, err := getEnvDependency(c, form.Id, form.DependencyId); err != nil {
+		return nil, err
+	}
+	if err := checkOutputMappings(form.OutputMappings); err != nil {
+		return nil, err
+	}
+
+	attrs := models.Attrs{}
+	if form.HasKey("outputMappings") {
+		attrs["output_mappings"] = models.EnvOutputMappings(form.OutputMappings)
+	}
+	return services.UpdateEnvDependency(c.DB(), form.DependencyId, attrs)
+}
+
+func DeleteEnvDependency(c *ctx.ServiceContext, form *forms.DeleteEnvDependencyForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("delete env dependency %s", form.DependencyId))
+
+	if _, err := getEnvDependency(c, form.Id, form.DependencyId); err != nil {
+		return nil, err
+	}
+	if err := services.DeleteEnvDependency(c.DB(), form.DependencyId); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// DeployStack 部署环境及其所有下游环境，按依赖顺序依次创建部署任务
+func DeployStack(c *ctx.ServiceContext, form *forms.DeployStackForm) (ret *models.DeployStack, er e.Error) {
+	c.AddLogField("action", fmt.Sprintf("deploy env stack %s", form.Id))
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	_ = c.DB().Transaction(func(tx *db.Session) error {
+		ret, er = deployStack(c, tx, form)
+		return er
+	})
+	return ret, er
+}
+
+func deployStack(c *ctx.ServiceContext, tx *db.Session, form *forms.DeployStackForm) (*models.DeployStack, e.Error) {
+	if _, err := getProjectEnv(c, tx, form.Id); err != nil {
+		return nil, err
+	}
+	deps, err := services.GetProjectEnvDependencies(tx, c.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	envIds := services.GetDownstreamEnvsInOrder(deps, form.Id)
+	for _, id := range envIds {
+		env, err := getProjectEnv(c, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if env.Archived {
+			return nil, e.New(e.EnvArchived, fmt.Errorf("env '%s' is archived", env.Name), http.StatusBadRequest)
+		}
+		if env.Deploying {
+			return nil, e.New(e.EnvDeploying, fmt.Errorf("env '%s' is deploying", env.Name), http.StatusBadRequest)
+		}
+		if env.LastTaskId == "" {
+			return nil, e.New(e.EnvNotDeployed, fmt.Errorf("env '%s' has never been deployed", env.Name),
+				http.StatusBadRequest)
+		}
+	}
+
+	ids := make(models.StrSlice, 0, len(envIds))
+	for _, id := range envIds {
+		ids = append(ids, id.String())
+	}
+	return services.CreateDeployStack(tx, models.DeployStack{
+		OrgId:     c.OrgId,
+		ProjectId: c.ProjectId,
+		EnvId:     form.Id,
+		EnvIds:    ids,
+		CreatorId: c.UserId,
+	})
+}
+
+func DeployStackDetail(c *ctx.ServiceContext, form *forms.DetailDeployStackForm) (interface{}, e.Error) {
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	stack, err := services.GetDeployStackById(query, form.Id)
+	if err != nil {
+		if err.Code() == e.DeployStackNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	return stack, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	EnvStateActionList   = "list"
+	EnvStateActionShow   = "show"
+	EnvStateActionMv     = "mv"
+	EnvStateActionRm     = "rm"
+	EnvStateActionImport = "import"
+)
+
+// envStateStep 根据 state 操作生成任务类型及执行步骤
+func envStateStep(action string, args []string) (taskType string, step models.PipelineStep, er e.Error) {
+	// 参数为资源地址、资源 id，不允许以 - 开头，避免被当作命令选项(如 -state-out)
+	for _, arg := range args {
+		if strings.TrimSpace(arg) == "" || strings.ContainsAny(arg, "\r\n") || strings.HasPrefix(arg, "-") {
+			return "", step, e.New(e.BadParam, fmt.Errorf("invalid argument '%s'", arg), http.StatusBadRequest)
+		}
+	}
+
+	argsNum := -1 // 参数个数要求，-1 表示不限制
+	taskType = models.TaskTypeStateEdit
+	switch action {
+	case EnvStateActionList:
+		taskType = models.TaskTypeStateQuery
+		step = models.PipelineStep{Type: models.TaskStepStateList, Name: "Terraform State List"}
+	case EnvStateActionShow:
+		argsNum = 1
+		taskType = models.TaskTypeStateQuery
+		step = models.PipelineStep{Type: models.TaskStepStateShow, Name: "Terraform State Show"}
+	case EnvStateActionMv:
+		argsNum = 2
+		step = models.PipelineStep{Type: models.TaskStepStateMv, Name: "Terraform State Mv"}
+	case EnvStateActionRm:
+		if len(args) == 0 {
+			return "", step, e.New(e.BadParam, fmt.Errorf("resource address is required"), http.StatusBadRequest)
+		}
+		step = models.PipelineStep{Type: models.TaskStepStateRm, Name: "Terraform State Rm"}
+	case EnvStateActionImport:
+		argsNum = 2
+		step = models.PipelineStep{Type: models.TaskStepImport, Name: "Terraform Import"}
+	default:
+		return "", step, e.New(e.BadParam, fmt.Errorf("invalid action '%s'", action), http.StatusBadRequest)
+	}
+	if argsNum >= 0 && len(args) != argsNum {
+		return "", step, e.New(e.BadParam,
+			fmt.Errorf("action '%s' requires %d arguments", action, argsNum), http.StatusBadRequest)
+	}
+	step.Args = args
+	return taskType, step, nil
+}
+
+// EnvState 创建 state 管理任务(state list/show/mv/rm、import)，需要环境的部署权限，
+// 修改 state 的任务需要审批，执行后会重新采集环境资源
+func EnvState(c *ctx.ServiceContext, form *forms.EnvStateForm) (task *models.Task, er e.Error) {
+	c.AddLogField("action", fmt.Sprintf("create env state task %s, %s", form.Id, form.Action))
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	taskType, step, err := envStateStep(form.Action, form.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.DB().Transaction(func(tx *db.Session) error {
+		task, er = envState(c, tx, form, taskType, step)
+		return er
+	})
+	return task, er
+}
+
+// EnvStateQuery 创建只读的 state 查询任务(state list/show)，只需要环境的读权限
+func EnvStateQuery(c *ctx.ServiceContext, form *forms.EnvStateForm) (*models.Task, e.Error) {
+	taskType, _, err := envStateStep(form.Action, form.Args)
+	if err != nil {
+		return nil, err
+	}
+	if taskType != models.TaskTypeStateQuery {
+		return nil, e.New(e.BadParam,
+			fmt.Errorf("action '%s' modifies state, use POST /envs/%s/state", form.Action, form.Id), http.StatusBadRequest)
+	}
+	return EnvState(c, form)
+}
+
+func envState(c *ctx.ServiceContext, tx *db.Session, form *forms.EnvStateForm,
+	taskType string, step models.PipelineStep) (*models.Task, e.Error) {
+	env, err := getProjectEnv(c, tx, form.Id)
+	if err != nil {
+		return nil, err
+	}
+	if env.Archived {
+		return nil, e.New(e.EnvArchived, http.StatusBadRequest)
+	}
+
+	tpl, err := services.GetTemplateById(services.QueryWithOrgId(tx, c.OrgId), env.TplId)
+	if err != nil {
+		if err.Code() == e.TemplateNotExists {
+			return nil, e.New(err.Code(), err, http.StatusBadRequest)
+		}
+		return nil, err
+	}
+	if tpl.Status == models.Disable {
+		return nil, e.New(e.TemplateDisabled, http.StatusBadRequest)
+	}
+
+	vars, er := services.GetValidVarsAndVgVars(tx, env.OrgId, env.ProjectId, env.TplId, env.Id)
+	if er != nil {
+		return nil, e.AutoNew(er, e.InternalError)
+	}
+
+	task, err := services.CreateStateTask(tx, tpl, env, models.Task{
+		Name:        fmt.Sprintf("state %s", form.Action),
+		CreatorId:   c.UserId,
+		KeyId:       env.KeyId,
+		Variables:   vars,
+		AutoApprove: env.AutoApproval,
+		Revision:    env.Revision,
+		BaseTask: models.BaseTask{
+			Type:        taskType,
+			StepTimeout: env.Timeout,
+			RunnerId:    env.RunnerId,
+		},
+	}, step)
+	if err != nil {
+		c.Logger().Errorf("error creating state task, err %s", err)
+		return nil, e.New(err.Code(), err, http.StatusInternalServerError)
+	}
+	return task, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/libs/page"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+)
+
+// SearchEnvStateVersion 查询环境 state 版本列表
+func SearchEnvStateVersion(c *ctx.ServiceContext, form *forms.SearchEnvStateVersionForm) (interface{}, e.Error) {
+	if _, err := getProjectEnv(c, c.DB(), form.Id); err != nil {
+		return nil, err
+	}
+
+	query := services.QueryEnvStateVersion(c.DB(), form.Id)
+	if form.SortField() == "" {
+		query = query.Order("iac_env_state_version.created_at DESC")
+	}
+	p := page.New(form.CurrentPage(), form.PageSize(), query)
+	versions := make([]*models.EnvStateVersionDetail, 0)
+	if err := p.Scan(&versions); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return page.PageResp{
+		Total:    p.MustTotal(),
+		PageSize: p.Size,
+		List:     versions,
+	}, nil
+}
+
+func getEnvStateVersion(c *ctx.ServiceContext, query *db.Session, envId, versionId models.Id) (
+	*models.Env, *models.EnvStateVersion, e.Error) {
+	env, err := getProjectEnv(c, query, envId)
+	if err != nil {
+		return nil, nil, err
+	}
+	version, err := services.GetEnvStateVersionById(query, versionId)
+	if err != nil {
+		if err.Code() == e.EnvStateVersionNotExists {
+			return nil, nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, nil, err
+	}
+	if version.EnvId != env.Id {
+		return nil, nil, e.New(e.EnvStateVersionNotExists, http.StatusNotFound)
+	}
+	return env, version, nil
+}
+
+// EnvStateVersionContent 下载 state 版本文件
+func EnvStateVersionContent(c *ctx.ServiceContext, form *forms.EnvStateVersionForm) ([]byte, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("download env %s state version %s", form.Id, form.VersionId))
+	_, version, err := getEnvStateVersion(c, c.DB(), form.Id, form.VersionId)
+	if err != nil {
+		return nil, err
+	}
+	return services.GetEnvStateVersionContent(version)
+}
+
+// RestoreEnvStateVersion 恢复环境 state 到指定版本。
+// 恢复通过 state push 任务执行，任务始终需要审批，且只允许恢复与当前 state lineage 相同的版本
+func RestoreEnvStateVersion(c *ctx.ServiceContext, form *forms.EnvStateVersionForm) (task *models.Task, er e.Error) {
+	c.AddLogField("action", fmt.Sprintf("restore env %s state to version %s", form.Id, form.VersionId))
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	_ = c.DB().Transaction(func(tx *db.Session) error {
+		task, er = restoreEnvStateVersion(c, tx, form)
+		return er
+	})
+	return task, er
+}
+
+func restoreEnvStateVersion(c *ctx.ServiceContext, tx *db.Session, form *forms.EnvStateVersionForm) (
+	*models.Task, e.Error) {
+	_, version, err := getEnvStateVersion(c, tx, form.Id, form.VersionId)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := services.GetLatestEnvStateVersion(tx, form.Id)
+	if err != nil {
+		return nil, err
+	}
+	if latest.Lineage != version.Lineage {
+		return nil, e.New(e.EnvStateLineageMismatch,
+			fmt.Errorf("lineage '%s' != '%s'", version.Lineage, latest.Lineage), http.StatusBadRequest)
+	}
+	if latest.Id == version.Id {
+		return nil, e.New(e.BadParam, fmt.Errorf("version is already the latest"), http.StatusBadRequest)
+	}
+
+	step := models.PipelineStep{
+		Type: models.TaskStepStatePush,
+		Name: "Terraform State Push",
+		Args: []string{version.Id.String()},
+	}
+	stateForm := forms.EnvStateForm{Id: form.Id, Action: "restore"}
+	return envState(c, tx, &stateForm, models.TaskTypeStateEdit, step)
+}
@@ -0,0 +1,76 @@
+package apps
+
+import (
+	"cloudiac/portal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInCronWindow(t *testing.T) {
+	// 每周五 22 点开始，持续 2 小时
+	express := "0 22 * * 5"
+	duration := 2 * time.Hour
+	// 2021-10-15 为周五
+	cases := []struct {
+		now    string
+		expect bool
+	}{
+		{"2021-10-15 21:59:59", false},
+		{"2021-10-15 22:00:00", true},
+		{"2021-10-15 23:59:59", true},
+		{"2021-10-16 00:00:00", false},
+		{"2021-10-18 22:30:00", false},
+	}
+
+	for _, c := range cases {
+		now, _ := time.ParseInLocation("2006-01-02 15:04:05", c.now, time.Local)
+		in, err := IsInCronWindow(express, duration, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if in != c.expect {
+			t.Errorf("%s: expect %v, got %v", c.now, c.expect, in)
+		}
+	}
+
+	if _, err := IsInCronWindow("invalid", duration, time.Now()); err == nil {
+		t.Errorf("expect error for invalid express")
+	}
+}
+
+func TestEnvStateStep(t *testing.T) {
+	cases := []struct {
+		action   string
+		args     []string
+		taskType string
+		stepType string
+		valid    bool
+	}{
+		{EnvStateActionList, nil, models.TaskTypeStateQuery, models.TaskStepStateList, true},
+		{EnvStateActionList, []string{"module.vpc"}, models.TaskTypeStateQuery, models.TaskStepStateList, true},
+		{EnvStateActionShow, []string{`aws_instance.web["a"]`}, models.TaskTypeStateQuery, models.TaskStepStateShow, true},
+		{EnvStateActionShow, nil, "", "", false},
+		{EnvStateActionMv, []string{"aws_instance.a", "aws_instance.b"}, models.TaskTypeStateEdit, models.TaskStepStateMv, true},
+		{EnvStateActionMv, []string{"aws_instance.a"}, "", "", false},
+		{EnvStateActionRm, []string{"aws_instance.a", "aws_instance.b"}, models.TaskTypeStateEdit, models.TaskStepStateRm, true},
+		{EnvStateActionRm, nil, "", "", false},
+		{EnvStateActionImport, []string{"aws_instance.a", "i-123"}, models.TaskTypeStateEdit, models.TaskStepImport, true},
+		{EnvStateActionImport, []string{"aws_instance.a", "i-123\nrm -rf /"}, "", "", false},
+		{EnvStateActionRm, []string{"-state-out=/tmp/state", "aws_instance.a"}, "", "", false},
+		{EnvStateActionImport, []string{"aws_instance.a", "-allow-missing-config"}, "", "", false},
+		{"push", nil, "", "", false},
+	}
+	for _, c := range cases {
+		typ, step, err := envStateStep(c.action, c.args)
+		if !c.valid {
+			assert.NotNil(t, err, "%s %v", c.action, c.args)
+			continue
+		}
+		assert.Nil(t, err, "%s %v", c.action, c.args)
+		assert.Equal(t, c.taskType, typ)
+		assert.Equal(t, c.stepType, step.Type)
+		assert.Equal(t, len(c.args), len(step.Args))
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/libs/page"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// GetActiveMaintenanceWindows 获取当前处于维护窗口期间的项目，返回 map[projectId]MaintenanceWindow
+func GetActiveMaintenanceWindows(query *db.Session, now time.Time) (map[models.Id]*models.MaintenanceWindow, e.Error) {
+	windows, err := services.GetEnabledMaintenanceWindows(query)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[models.Id]*models.MaintenanceWindow)
+	for _, w := range windows {
+		if _, ok := active[w.ProjectId]; ok {
+			continue
+		}
+		in, err := IsInCronWindow(w.CronExpress, time.Duration(w.Duration)*time.Minute, now)
+		if err != nil {
+			// 保存时已校验过表达式，这里忽略错误的配置
+			continue
+		}
+		if in {
+			active[w.ProjectId] = w
+		}
+	}
+	return active, nil
+}
+
+// IsFreezeTask 任务是否受维护窗口限制，只有 apply、destroy 及修改 state 的任务在窗口期间会被暂停执行
+func IsFreezeTask(task *models.Task) bool {
+	return !task.FreezeOverride && (task.Type == models.TaskTypeApply || task.Type == models.TaskTypeDestroy ||
+		task.Type == models.TaskTypeStateEdit)
+}
+
+func SearchMaintenanceWindow(c *ctx.ServiceContext, form *forms.SearchMaintenanceWindowForm) (interface{}, e.Error) {
+	query := services.SearchMaintenanceWindow(c.DB(), c.ProjectId)
+	p := page.New(form.CurrentPage(), form.PageSize(), query)
+	windows := make([]*models.MaintenanceWindow, 0)
+	if err := p.Scan(&windows); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return page.PageResp{
+		Total:    p.MustTotal(),
+		PageSize: p.Size,
+		List:     windows,
+	}, nil
+}
+
+func CreateMaintenanceWindow(c *ctx.ServiceContext, form *forms.CreateMaintenanceWindowForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("create maintenance window %s", form.Name))
+
+	if _, err := ParseCronpress(form.CronExpress); err != nil {
+		return nil, err
+	}
+	enabled := true
+	if form.Enabled != nil {
+		enabled = *form.Enabled
+	}
+
+	return services.CreateMaintenanceWindow(c.DB(), models.MaintenanceWindow{
+		OrgId:       c.OrgId,
+		ProjectId:   c.ProjectId,
+		Name:        form.Name,
+		Description: form.Description,
+		CronExpress: form.CronExpress,
+		Duration:    form.Duration,
+		Enabled:     enabled,
+		CreatorId:   c.UserId,
+	})
+}
+
+func UpdateMaintenanceWindow(c *ctx.ServiceContext, form *forms.UpdateMaintenanceWindowForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("update maintenance window %s", form.Id))
+
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	if _, err := services.GetMaintenanceWindowById(query, form.Id); err != nil {
+		if err.Code() == e.MaintenanceWindowNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+
+	attrs := models.Attrs{}
+	if form.HasKey("name") {
+		attrs["name"] = form.Name
+	}
+	if form.HasKey("description") {
+		attrs["description"] = form.Description
+	}
+	if form.HasKey("cronExpress") {
+		if _, err := ParseCronpress(form.CronExpress); err != nil {
+			return nil, err
+		}
+		attrs["cron_express"] = form.CronExpress
+	}
+	if form.HasKey("duration") {
+		if form.Duration <= 0 {
+			return nil, e.New(e.BadParam, fmt.Errorf("invalid duration"), http.StatusBadRequest)
+		}
+		attrs["duration"] = form.Duration
+	}
+	if form.HasKey("enabled") {
+		attrs["enabled"] = form.Enabled
+	}
+
+	return services.UpdateMaintenanceWindow(query, form.Id, attrs)
+}
+
+func DeleteMaintenanceWindow(c *ctx.ServiceContext, form *forms.DeleteMaintenanceWindowForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("delete maintenance window %s", form.Id))
+
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	if _, err := services.GetMaintenanceWindowById(query, form.Id); err != nil {
+		if err.Code() == e.MaintenanceWindowNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if err := services.DeleteMaintenanceWindow(query, form.Id); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func DetailMaintenanceWindow(c *ctx.ServiceContext, form *forms.DetailMaintenanceWindowForm) (interface{}, e.Error) {
+	query := services.QueryWithProjectId(c.DB(), c.ProjectId)
+	window, err := services.GetMaintenanceWindowById(query, form.Id)
+	if err != nil {
+		if err.Code() == e.MaintenanceWindowNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	return window, nil
+}
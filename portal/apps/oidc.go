@@ -0,0 +1,116 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// OidcAuthRequestExpire 发起登录到回调的最长时间
+	OidcAuthRequestExpire = 10 * time.Minute
+	oidcTimeout           = 30 * time.Second
+)
+
+// getOidcConfig 返回填充了默认值的 oidc 配置
+func getOidcConfig() (configs.OidcConfig, e.Error) {
+	cfg := configs.Get().Oidc
+	if !cfg.Enabled {
+		return cfg, e.New(e.BadRequest, fmt.Errorf("oidc login is not enabled"), http.StatusBadRequest)
+	}
+
+	address := strings.TrimRight(configs.Get().Portal.Address, "/")
+	if cfg.RedirectUrl == "" {
+		cfg.RedirectUrl = address + "/api/v1/auth/oidc/callback"
+	}
+	if cfg.SuccessUrl == "" {
+		cfg.SuccessUrl = address + "/"
+	}
+	return cfg, nil
+}
+
+// OidcLogin 生成 oidc 服务的登录地址，authReq 为签名后的登录请求参数，需要保存到 cookie 中
+func OidcLogin(c *ctx.ServiceContext) (authUrl string, authReq string, err e.Error) {
+	cfg, err := getOidcConfig()
+	if err != nil {
+		return "", "", err
+	}
+
+	req := services.NewOidcAuthRequest(OidcAuthRequestExpire)
+	authUrl, er := services.OidcAuthCodeURL(cfg, req)
+	if er != nil {
+		c.Logger().Errorf("oidc auth code url: %v", er)
+		return "", "", e.New(e.OidcError, er, http.StatusInternalServerError)
+	}
+	authReq, er = services.EncodeOidcAuthRequest(req, configs.Get().JwtSecretKey)
+	if er != nil {
+		return "", "", e.New(e.InternalError, er)
+	}
+	return authUrl, authReq, nil
+}
+
+// OidcCallback 处理 oidc 登录回调，校验 state 后获取用户信息并登录，返回登录成功后跳转的地址(token 通过 url fragment 传递)
+func OidcCallback(c *ctx.ServiceContext, form *forms.OidcCallbackForm, authReq string) (redirectUrl string, err e.Error) {
+	cfg, err := getOidcConfig()
+	if err != nil {
+		return "", err
+	}
+	if form.Error != "" {
+		return "", e.New(e.OidcError, fmt.Errorf("%s: %s", form.Error, form.ErrorDescription), http.StatusBadRequest)
+	}
+
+	req, er := services.DecodeOidcAuthRequest(authReq, configs.Get().JwtSecretKey)
+	if er != nil || req.State == "" || req.State != form.State {
+		return "", e.New(e.BadParam, fmt.Errorf("invalid oidc state"), http.StatusBadRequest)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+
+	oidcUser, er := services.OidcExchange(timeoutCtx, cfg, form.Code, *req)
+	if er != nil {
+		c.Logger().Errorf("oidc exchange: %v", er)
+		return "", e.New(e.OidcError, er, http.StatusForbidden)
+	}
+	c.AddLogField("action", fmt.Sprintf("user oidc login: %s", oidcUser.Email))
+
+	// 通过 email 关联系统中己有的用户
+	user, err := services.GetUserByEmail(c.DB(), oidcUser.Email)
+	if err != nil && err.Code() != e.UserNotExists {
+		return "", e.New(e.DBError, err)
+	}
+	if user != nil && user.Status == models.Disable {
+		return "", e.New(e.UserDisabled, http.StatusForbidden)
+	}
+	// 关联非 oidc 创建的用户(如本地用户)时需要 idp 确认 email 已验证，避免通过未验证的 email 登录他人账号
+	if user != nil && user.Source != consts.UserSourceOidc && !oidcUser.EmailVerified {
+		return "", e.New(e.OidcError, fmt.Errorf("email '%s' is not verified", oidcUser.Email), http.StatusForbidden)
+	}
+	user, err = syncExternalUser(c, user, externalUser{
+		Source: consts.UserSourceOidc,
+		Email:  oidcUser.Email,
+		Name:   oidcUser.Name,
+		Groups: oidcUser.Groups,
+	}, cfg.GroupMappings, cfg.AdminGroups)
+	if err != nil {
+		return "", err
+	}
+
+	token, er := services.GenerateToken(user.Id, user.Name, user.IsAdmin, 1*24*time.Hour)
+	if er != nil {
+		return "", e.New(e.InternalError, er)
+	}
+	return fmt.Sprintf("%s#%s", cfg.SuccessUrl, url.Values{"token": {token}}.Encode()), nil
+}
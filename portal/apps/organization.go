@@ -101,6 +101,10 @@ func UpdateOrganization(c *ctx.ServiceContext, form *forms.UpdateOrganizationFor
 		attrs["runner_id"] = form.RunnerId
 	}
 
+	if form.HasKey("maxConcurrentTasks") {
+		attrs["max_concurrent_tasks"] = form.MaxConcurrentTasks
+	}
+
 	// 变更组织状态
 	if form.HasKey("status") {
 		if _, err := ChangeOrgStatus(c, &forms.DisableOrganizationForm{Id: form.Id, Status: form.Status}); err != nil {
@@ -255,8 +259,8 @@ func AddUserOrgRel(c *ctx.ServiceContext, form *forms.AddUserOrgRelForm) (*model
 		query = query.Where(fmt.Sprintf("%s.id in (?)", models.User{}.TableName()), userIds)
 	}
 
-	if form.Role != consts.OrgRoleMember && form.Role != consts.OrgRoleAdmin {
-		return nil, e.New(e.InvalidRoleName, http.StatusBadRequest)
+	if err := services.CheckRole(c.DB(), form.Id, consts.ScopeOrg, form.Role); err != nil {
+		return nil, err
 	}
 	user, err := services.GetUserById(query, form.UserId)
 	if err != nil && err.Code() == e.UserNotExists {
@@ -301,6 +305,9 @@ func UpdateUserOrgRel(c *ctx.ServiceContext, form *forms.UpdateUserOrgRelForm) (
 		c.Logger().Errorf("error get user by id, err %s", err)
 		return nil, e.New(e.DBError, err)
 	}
+	if err := services.CheckRole(c.DB(), c.OrgId, consts.ScopeOrg, form.Role); err != nil {
+		return nil, err
+	}
 
 	if err := services.UpdateUserOrgRel(c.DB(), models.UserOrg{OrgId: c.OrgId, UserId: form.UserId, Role: form.Role}); err != nil {
 		c.Logger().Errorf("error create user org rel, err %s", err)
@@ -331,6 +338,9 @@ func InviteUser(c *ctx.ServiceContext, form *forms.InviteUserForm) (*models.User
 	if form.Role == "" {
 		form.Role = consts.OrgRoleMember
 	}
+	if err := services.CheckRole(c.DB(), form.Id, consts.ScopeOrg, form.Role); err != nil {
+		return nil, err
+	}
 
 	tx := c.Tx()
 	defer func() {
@@ -523,6 +533,9 @@ func UpdateUserOrg(c *ctx.ServiceContext, form *forms.UpdateUserOrgForm) (userRe
 		c.Logger().Errorf("error get user by id, err %s", err)
 		return nil, e.New(e.DBError, err)
 	}
+	if err := services.CheckRole(tx, c.OrgId, consts.ScopeOrg, form.Role); err != nil {
+		return nil, err
+	}
 
 	if err := services.UpdateUserOrgRel(tx, models.UserOrg{OrgId: c.OrgId, UserId: form.UserId, Role: form.Role}); err != nil {
 		c.Logger().Errorf("error create user org rel, err %s", err)
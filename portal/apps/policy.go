@@ -288,6 +288,15 @@ func UpdatePolicy(c *ctx.ServiceContext, form *forms.UpdatePolicyForm) (interfac
 		}
 	}()
 
+	before, err := services.GetPolicyById(tx, form.Id)
+	if err != nil {
+		_ = tx.Rollback()
+		if err.Code() == e.PolicyNotExist {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+
 	attr := models.Attrs{}
 	if form.HasKey("name") {
 		attr["name"] = form.Name
@@ -354,11 +363,17 @@ func UpdatePolicy(c *ctx.ServiceContext, form *forms.UpdatePolicyForm) (interfac
 		_ = tx.Rollback()
 		return nil, err
 	}
+	after, err := services.GetPolicyById(tx, form.Id)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		_ = tx.Rollback()
 		return nil, e.New(e.DBError, err, http.StatusInternalServerError)
 	}
+	c.AddAuditChange(services.DiffAuditChange("policy", form.Id, before, after))
 
 	return nil, nil
 }
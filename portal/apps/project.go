@@ -17,6 +17,10 @@ import (
 )
 
 func CreateProject(c *ctx.ServiceContext, form *forms.CreateProjectForm) (interface{}, e.Error) {
+	if err := form.ContainerLimits.Validate(); err != nil {
+		return nil, e.New(e.BadParam, err, http.StatusBadRequest)
+	}
+
 	tx := c.DB().Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -30,6 +34,8 @@ func CreateProject(c *ctx.ServiceContext, form *forms.CreateProjectForm) (interf
 		OrgId:       c.OrgId,
 		Description: form.Description,
 		CreatorId:   c.UserId,
+
+		ContainerLimits: form.ContainerLimits,
 	})
 
 	if err != nil && err.Code() == e.ProjectAlreadyExists {
@@ -44,8 +50,13 @@ func CreateProject(c *ctx.ServiceContext, form *forms.CreateProjectForm) (interf
 	// 检查用户是否属于本组织用户
 	for _, userAuth := range form.UserAuthorization {
 		if !services.UserHasOrgRole(userAuth.UserId, c.OrgId, "") {
+			_ = tx.Rollback()
 			return nil, e.New(e.BadParam, fmt.Errorf("invalid user"), http.StatusBadRequest)
 		}
+		if err := services.CheckRole(tx, c.OrgId, consts.ScopeProject, userAuth.Role); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
 	}
 	// 如果创建人不是超级管理员就把创建人加到项目里面
 	if !c.IsSuperAdmin {
@@ -141,6 +152,18 @@ func UpdateProject(c *ctx.ServiceContext, form *forms.UpdateProjectForm) (interf
 		attrs["status"] = form.Status
 	}
 
+	if form.HasKey("maxConcurrentTasks") {
+		attrs["max_concurrent_tasks"] = form.MaxConcurrentTasks
+	}
+
+	if form.HasKey("containerLimits") {
+		if err := form.ContainerLimits.Validate(); err != nil {
+			_ = tx.Rollback()
+			return nil, e.New(e.BadParam, err, http.StatusBadRequest)
+		}
+		attrs["container_limits"] = form.ContainerLimits
+	}
+
 	project := &models.Project{}
 	project.Id = form.Id
 	err := services.UpdateProject(tx, project, attrs)
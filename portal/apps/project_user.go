@@ -3,6 +3,7 @@
 package apps
 
 import (
+	"cloudiac/portal/consts"
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models"
@@ -17,6 +18,9 @@ func CreateProjectUser(c *ctx.ServiceContext, form *forms.CreateProjectUserForm)
 	if !services.UserHasOrgRole(form.UserId, c.OrgId, "") {
 		return nil, e.New(e.BadParam, fmt.Errorf("invalid user"), http.StatusBadRequest)
 	}
+	if err := services.CheckRole(c.DB(), c.OrgId, consts.ScopeProject, form.Role); err != nil {
+		return nil, err
+	}
 	pu, err := services.CreateProjectUser(c.DB(), models.UserProject{
 		Role:      form.Role,
 		UserId:    form.UserId,
@@ -80,6 +84,9 @@ func UpdateProjectUser(c *ctx.ServiceContext, form *forms.UpdateProjectUserForm)
 
 	attrs := models.Attrs{}
 	if form.HasKey("role") {
+		if err := services.CheckRole(c.DB(), c.OrgId, consts.ScopeProject, form.Role); err != nil {
+			return nil, err
+		}
 		attrs["role"] = form.Role
 	}
 
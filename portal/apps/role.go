@@ -0,0 +1,133 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package apps
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/page"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/portal/services"
+	"fmt"
+	"net/http"
+)
+
+func getOrgRole(c *ctx.ServiceContext, id models.Id) (*models.Role, e.Error) {
+	role, err := services.GetRoleById(services.QueryWithOrgId(c.DB(), c.OrgId), id)
+	if err != nil {
+		if err.Code() == e.RoleNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+// reloadRolePolicies 角色变更后重新加载权限策略，其他 portal 实例通过定时加载同步
+func reloadRolePolicies(c *ctx.ServiceContext) {
+	if err := services.LoadRolePolicies(c.DB()); err != nil {
+		c.Logger().Errorf("reload role policies: %v", err)
+	}
+}
+
+func SearchRole(c *ctx.ServiceContext, form *forms.SearchRoleForm) (interface{}, e.Error) {
+	query := services.SearchRole(c.DB(), c.OrgId, form.Scope, form.Q)
+	p := page.New(form.CurrentPage(), form.PageSize(), query)
+	roles := make([]*models.Role, 0)
+	if err := p.Scan(&roles); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return page.PageResp{
+		Total:    p.MustTotal(),
+		PageSize: p.Size,
+		List:     roles,
+	}, nil
+}
+
+func CreateRole(c *ctx.ServiceContext, form *forms.CreateRoleForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("create role %s", form.Name))
+
+	if form.Scope != consts.ScopeOrg && form.Scope != consts.ScopeProject {
+		return nil, e.New(e.BadParam, fmt.Errorf("invalid scope '%s'", form.Scope), http.StatusBadRequest)
+	}
+	if err := services.CheckRolePermissions(form.Scope, form.Permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := services.CreateRole(c.DB(), models.Role{
+		OrgId:       c.OrgId,
+		Name:        form.Name,
+		Scope:       form.Scope,
+		Description: form.Description,
+		Permissions: form.Permissions,
+		CreatorId:   c.UserId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	reloadRolePolicies(c)
+	return role, nil
+}
+
+func UpdateRole(c *ctx.ServiceContext, form *forms.UpdateRoleForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("update role %s", form.Id))
+
+	role, err := getOrgRole(c, form.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := models.Attrs{}
+	if form.HasKey("name") {
+		if form.Name == "" {
+			return nil, e.New(e.BadParam, fmt.Errorf("name is required"), http.StatusBadRequest)
+		}
+		attrs["name"] = form.Name
+	}
+	if form.HasKey("description") {
+		attrs["description"] = form.Description
+	}
+	if form.HasKey("permissions") {
+		// 角色类型创建后不能修改，权限按原角色类型校验
+		if err := services.CheckRolePermissions(role.Scope, form.Permissions); err != nil {
+			return nil, err
+		}
+		attrs["permissions"] = form.Permissions
+	}
+
+	role, err = services.UpdateRole(services.QueryWithOrgId(c.DB(), c.OrgId), form.Id, attrs)
+	if err != nil {
+		return nil, err
+	}
+	reloadRolePolicies(c)
+	return role, nil
+}
+
+func DeleteRole(c *ctx.ServiceContext, form *forms.DeleteRoleForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("delete role %s", form.Id))
+
+	if _, err := getOrgRole(c, form.Id); err != nil {
+		return nil, err
+	}
+	if inUse, err := services.IsRoleInUse(c.DB(), form.Id); err != nil {
+		return nil, err
+	} else if inUse {
+		return nil, e.New(e.RoleInUse, http.StatusBadRequest)
+	}
+
+	if err := services.DeleteRole(services.QueryWithOrgId(c.DB(), c.OrgId), form.Id); err != nil {
+		return nil, err
+	}
+	reloadRolePolicies(c)
+	return nil, nil
+}
+
+func DetailRole(c *ctx.ServiceContext, form *forms.DetailRoleForm) (interface{}, e.Error) {
+	role, err := getOrgRole(c, form.Id)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
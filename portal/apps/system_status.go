@@ -4,8 +4,13 @@ package apps
 
 import (
 	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
 	"cloudiac/portal/models/forms"
 	"cloudiac/portal/services"
+
+	"github.com/hashicorp/consul/api"
 )
 
 type SystemStatusResp struct {
@@ -73,22 +78,58 @@ func ConsulKVSearch(key string) (interface{}, e.Error) {
 	return services.ConsulKVSearch(key)
 }
 
+// RunnerResp runner 信息及心跳状态，未获取到心跳状态时 status 为 null
+type RunnerResp struct {
+	*api.AgentService
+	Status *models.RunnerStatus `json:"status"`
+}
+
 func RunnerSearch() (interface{}, e.Error) {
-	return services.RunnerSearch()
+	runners, err := services.RunnerSearch()
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := services.QueryRunnerStatus(db.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	statusMap := make(map[string]*models.RunnerStatus, len(statuses))
+	for _, s := range statuses {
+		statusMap[s.RunnerId] = s
+	}
+	resp := make([]RunnerResp, 0, len(runners))
+	for _, r := range runners {
+		resp = append(resp, RunnerResp{AgentService: r, Status: statusMap[r.ID]})
+	}
+	return resp, nil
 }
 
 func ConsulTagUpdate(form forms.ConsulTagUpdateForm) (interface{}, e.Error) {
-	//将修改后的tag存到consul中
-	if err := services.ConsulKVSave(form.ServiceId, form.Tags); err != nil {
+	if err := services.GetRunnerRegistry().UpdateRunnerTags(form.ServiceId, form.Tags); err != nil {
 		return nil, err
 	}
-	//根据serviceId查询在consul中保存的数据
-	agentService, err := services.ConsulServiceInfo(form.ServiceId)
-	if err != nil {
+	return nil, nil
+}
+
+// RegisterRunner runner 注册(registry 为 db 时使用)
+func RegisterRunner(c *ctx.ServiceContext, form *forms.RegisterRunnerForm) (interface{}, e.Error) {
+	runner := &models.Runner{
+		Id:      form.Id,
+		Service: form.Service,
+		Address: form.Address,
+		Port:    form.Port,
+		Tags:    form.Tags,
+	}
+	if err := services.RegisterRunner(c.DB(), runner); err != nil {
 		return nil, err
 	}
-	//重新注册
-	if err := services.ConsulServiceRegistered(agentService, form.Tags); err != nil {
+	return nil, nil
+}
+
+// DeregisterRunner runner 注销(registry 为 db 时使用)
+func DeregisterRunner(c *ctx.ServiceContext, form *forms.DeregisterRunnerForm) (interface{}, e.Error) {
+	if err := services.DeregisterRunner(c.DB(), form.Id); err != nil {
 		return nil, err
 	}
 	return nil, nil
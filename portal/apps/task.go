@@ -7,6 +7,7 @@ import (
 	"cloudiac/portal/consts"
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/libs/db"
 	"cloudiac/portal/libs/page"
 	"cloudiac/portal/models"
 	"cloudiac/portal/models/forms"
@@ -19,6 +20,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/sse"
 )
@@ -56,7 +58,12 @@ func SearchTask(c *ctx.ServiceContext, form *forms.SearchTaskForm) (interface{},
 
 type taskDetailResp struct {
 	models.Task
-	Creator string `json:"creator" example:"超级管理员"`
+	Creator       string `json:"creator" example:"超级管理员"`
+	QueuePosition int    `json:"queuePosition,omitempty" example:"3"` // 任务排队位置(从 1 开始)，只有排队中的任务返回
+
+	Approval *services.TaskApprovalStatus `json:"approval,omitempty"` // 审批进度，只有待审批的任务返回
+
+	MaintenanceWindow *models.MaintenanceWindow `json:"maintenanceWindow,omitempty"` // 任务因项目维护窗口暂停执行时返回当前所处的维护窗口
 }
 
 // TaskDetail 任务信息详情
@@ -100,6 +107,23 @@ func TaskDetail(c *ctx.ServiceContext, form forms.DetailTaskForm) (*taskDetailRe
 		Creator: user.Name,
 	}
 
+	if o.QueuePosition, err = services.GetTaskQueuePosition(c.DB(), task); err != nil {
+		c.Logger().Errorf("error get task queue position, err %s", err)
+		return nil, err
+	}
+	if o.Approval, err = services.GetTaskApprovalStatus(c.DB(), task); err != nil {
+		c.Logger().Errorf("error get task approval status, err %s", err)
+		return nil, err
+	}
+	if task.Status == models.TaskPending && IsFreezeTask(task) {
+		windows, err := GetActiveMaintenanceWindows(c.DB().Where("project_id = ?", task.ProjectId), time.Now())
+		if err != nil {
+			c.Logger().Errorf("error get maintenance windows, err %s", err)
+			return nil, err
+		}
+		o.MaintenanceWindow = windows[task.ProjectId]
+	}
+
 	return &o, nil
 }
 
@@ -155,6 +179,9 @@ func ApproveTask(c *ctx.ServiceContext, form *forms.ApproveTaskForm) (interface{
 	if c.OrgId == "" || c.ProjectId == "" {
 		return nil, e.New(e.BadRequest, http.StatusBadRequest)
 	}
+	if !utils.StrInArray(form.Action, forms.TaskActionApproved, forms.TaskActionRejected) {
+		return nil, e.New(e.BadParam, fmt.Errorf("invalid action '%s'", form.Action), http.StatusBadRequest)
+	}
 
 	taskQuery := services.QueryWithProjectId(services.QueryWithOrgId(c.DB(), c.OrgId), c.ProjectId)
 	task, err := services.GetTask(taskQuery, form.Id)
@@ -182,20 +209,189 @@ func ApproveTask(c *ctx.ServiceContext, form *forms.ApproveTaskForm) (interface{
 		return nil, e.New(e.TaskApproveNotPending, http.StatusBadRequest)
 	}
 
-	// 更新审批状态
-	step.ApproverId = c.UserId
+	policy, err := services.GetTaskApprovalPolicy(c.DB(), task)
+	if err != nil {
+		return nil, err
+	}
+	projectRole := ""
+	if up := services.UserProjectRoles(c.UserId)[task.ProjectId]; up != nil {
+		projectRole = up.Role
+	}
+	if err = services.CheckTaskApprover(policy, task, step, c.UserId, projectRole); err != nil {
+		return nil, e.New(err.Code(), err, http.StatusForbidden)
+	}
+
+	tx := c.Tx()
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = approveTaskStep(c, tx, task, step, policy, form); err != nil {
+		_ = tx.Rollback()
+		c.Logger().Errorf("error approve task, err %s", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		_ = tx.Rollback()
+		return nil, e.New(e.DBError, err)
+	}
+	return nil, nil
+}
+
+// approveTaskStep 记录审批意见，驳回或审批通过人数达到策略要求时更新步骤审批状态
+func approveTaskStep(c *ctx.ServiceContext, tx *db.Session, task *models.Task, step *models.TaskStep,
+	policy *models.ApprovalPolicy, form *forms.ApproveTaskForm) e.Error {
+	// 锁定步骤，避免并发审批时重复计数
+	if err := services.LockTaskStep(tx, step.Id); err != nil {
+		return err
+	}
+	step, err := services.GetTaskStep(tx, task.Id, step.Index)
+	if err != nil {
+		return err
+	}
+	if step.Status != models.TaskStepApproving || step.ApproverId != "" {
+		return e.New(e.TaskApproveNotPending, http.StatusBadRequest)
+	}
+
+	approvals, err := services.GetTaskStepApprovals(tx, step.Id)
+	if err != nil {
+		return err
+	}
+	for _, a := range approvals {
+		if a.CreatorId == c.UserId {
+			return e.New(e.TaskAlreadyApproved, http.StatusBadRequest)
+		}
+	}
+
+	if _, err = services.CreateTaskComment(tx, models.TaskComment{
+		TaskId:    task.Id,
+		Creator:   c.Username,
+		CreatorId: c.UserId,
+		Comment:   form.Comment,
+		StepId:    step.Id,
+		Action:    form.Action,
+	}); err != nil {
+		return err
+	}
+
 	switch form.Action {
 	case forms.TaskActionApproved:
-		err = services.ApproveTaskStep(c.DB(), task.Id, step.Index, c.UserId)
+		if len(approvals)+1 >= services.RequiredApprovals(policy) {
+			return services.ApproveTaskStep(tx, task.Id, step.Index, c.UserId)
+		}
 	case forms.TaskActionRejected:
-		err = services.RejectTaskStep(c.DB(), task.Id, step.Index, c.UserId)
+		return services.RejectTaskStep(tx, task.Id, step.Index, c.UserId)
 	}
+	return nil
+}
+
+// AbortTask 中止任务
+func AbortTask(c *ctx.ServiceContext, form *forms.AbortTaskForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("abort task %s", form.Id))
+
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	taskQuery := services.QueryWithProjectId(services.QueryWithOrgId(c.DB(), c.OrgId), c.ProjectId)
+	task, err := services.GetTaskById(taskQuery, form.Id)
+	if err != nil && err.Code() == e.TaskNotExists {
+		return nil, e.New(err.Code(), err, http.StatusNotFound)
+	} else if err != nil {
+		c.Logger().Errorf("error get task, err %s", err)
+		return nil, e.New(e.DBError, err, http.StatusInternalServerError)
+	}
+
+	user, err := services.GetUserByIdRaw(c.DB(), c.UserId)
 	if err != nil {
-		c.Logger().Errorf("error approve task, err %s", err)
+		return nil, e.AutoNew(err, e.DBError)
+	}
+	message := fmt.Sprintf("aborted by %s", user.Name)
+	if err = services.AbortTask(c.DB(), task, message); err != nil {
+		c.Logger().Errorf("error abort task, err %s", err)
+		if err.Code() == e.TaskCannotAbort {
+			return nil, e.New(err.Code(), err, http.StatusBadRequest)
+		}
 		return nil, err
 	}
+	return task, nil
+}
 
-	return nil, nil
+// ResumeTask 从指定步骤恢复执行失败的任务
+func ResumeTask(c *ctx.ServiceContext, form *forms.ResumeTaskForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("resume task %s", form.Id))
+
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	taskQuery := services.QueryWithProjectId(services.QueryWithOrgId(c.DB(), c.OrgId), c.ProjectId)
+	task, err := services.GetTaskById(taskQuery, form.Id)
+	if err != nil && err.Code() == e.TaskNotExists {
+		return nil, e.New(err.Code(), err, http.StatusNotFound)
+	} else if err != nil {
+		c.Logger().Errorf("error get task, err %s", err)
+		return nil, e.New(e.DBError, err, http.StatusInternalServerError)
+	}
+
+	stepIndex := task.CurrStep
+	if form.Step != nil {
+		stepIndex = *form.Step
+	}
+
+	tx := c.Tx()
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = services.ResumeTask(tx, task, stepIndex); err != nil {
+		_ = tx.Rollback()
+		if err.Code() == e.TaskCannotResume || err.Code() == e.BadParam {
+			return nil, e.New(err.Code(), err, http.StatusBadRequest)
+		}
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		_ = tx.Rollback()
+		return nil, e.New(e.DBError, err)
+	}
+	return task, nil
+}
+
+// OverrideTaskFreeze 管理员强制执行处于维护窗口期间的任务
+func OverrideTaskFreeze(c *ctx.ServiceContext, form *forms.OverrideTaskFreezeForm) (interface{}, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("override task freeze %s", form.Id))
+
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+	if !c.IsSuperAdmin && !services.UserHasOrgRole(c.UserId, c.OrgId, consts.OrgRoleAdmin) {
+		return nil, e.New(e.PermissionDeny, http.StatusForbidden)
+	}
+
+	taskQuery := services.QueryWithProjectId(services.QueryWithOrgId(c.DB(), c.OrgId), c.ProjectId)
+	task, err := services.GetTaskById(taskQuery, form.Id)
+	if err != nil && err.Code() == e.TaskNotExists {
+		return nil, e.New(err.Code(), err, http.StatusNotFound)
+	} else if err != nil {
+		c.Logger().Errorf("error get task, err %s", err)
+		return nil, e.New(e.DBError, err, http.StatusInternalServerError)
+	}
+	if task.Status != models.TaskPending {
+		return nil, e.New(e.BadRequest, fmt.Errorf("task is not pending"), http.StatusBadRequest)
+	}
+
+	if err = services.OverrideTaskFreeze(c.DB(), task.Id); err != nil {
+		return nil, err
+	}
+	task.FreezeOverride = true
+	return task, nil
 }
 
 func FollowTaskLog(c *ctx.GinRequest, form forms.TaskLogForm) e.Error {
@@ -354,6 +550,35 @@ func GetTaskStep(c *ctx.ServiceContext, form *forms.GetTaskStepLogForm) (interfa
 	return string(content), nil
 }
 
+// TaskStepArtifacts 下载步骤保存的 artifacts
+func TaskStepArtifacts(c *ctx.ServiceContext, form *forms.TaskStepArtifactsForm) (*models.TaskStep, []byte, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("download task %s step %s artifacts", form.Id, form.StepId))
+	if c.OrgId == "" || c.ProjectId == "" {
+		return nil, nil, e.New(e.BadRequest, http.StatusBadRequest)
+	}
+
+	taskQuery := services.QueryWithProjectId(services.QueryWithOrgId(c.DB(), c.OrgId), c.ProjectId)
+	if _, err := services.GetTaskById(taskQuery, form.Id); err != nil {
+		if err.Code() == e.TaskNotExists {
+			return nil, nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, nil, e.New(e.DBError, err, http.StatusInternalServerError)
+	}
+
+	step, err := services.GetTaskStepByStepId(c.DB(), form.StepId)
+	if err != nil && !e.Is(err, e.TaskStepNotExists) {
+		return nil, nil, e.AutoNew(err, e.DBError)
+	} else if err != nil || step.TaskId != form.Id {
+		return nil, nil, e.New(e.TaskStepNotExists, http.StatusNotFound)
+	}
+
+	content, er := services.GetTaskStepArtifacts(step)
+	if er != nil {
+		return nil, nil, er
+	}
+	return step, content, nil
+}
+
 // SearchTaskResourcesGraph 查询环境资源列表
 func SearchTaskResourcesGraph(c *ctx.ServiceContext, form *forms.SearchTaskResourceGraphForm) (interface{}, e.Error) {
 	if c.OrgId == "" || c.ProjectId == "" || form.Id == "" {
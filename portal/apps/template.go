@@ -3,6 +3,7 @@
 package apps
 
 import (
+	"cloudiac/common"
 	"cloudiac/configs"
 	"cloudiac/portal/consts"
 	"cloudiac/portal/consts/e"
@@ -80,6 +81,13 @@ func getRepo(vcsId models.Id, query *db.Session, repoId string) (*vcsrv.Projects
 func CreateTemplate(c *ctx.ServiceContext, form *forms.CreateTemplateForm) (*models.Template, e.Error) {
 	c.AddLogField("action", fmt.Sprintf("create template %s", form.Name))
 
+	if err := checkTplIacTool(form.IacTool); err != nil {
+		return nil, err
+	}
+	if err := checkTplTfVersion(form.IacTool, form.TfVersion); err != nil {
+		return nil, err
+	}
+
 	tx := c.Tx()
 	defer func() {
 		if r := recover(); r != nil {
@@ -104,6 +112,7 @@ func CreateTemplate(c *ctx.ServiceContext, form *forms.CreateTemplateForm) (*mod
 		PlayVarsFile: form.PlayVarsFile,
 		TfVarsFile:   form.TfVarsFile,
 		TfVersion:    form.TfVersion,
+		IacTool:      utils.FirstValueStr(form.IacTool, common.IacToolTerraform),
 	})
 
 	if err != nil {
@@ -183,8 +192,27 @@ func UpdateTemplate(c *ctx.ServiceContext, form *forms.UpdateTemplateForm) (*mod
 	if form.HasKey("playVarsFile") {
 		attrs["playVarsFile"] = form.PlayVarsFile
 	}
-	if form.HasKey("tfVersion") {
-		attrs["tfVersion"] = form.TfVersion
+	oldIacTool := utils.FirstValueStr(tpl.IacTool, common.IacToolTerraform)
+	iacTool := oldIacTool
+	if form.HasKey("iacTool") {
+		if err := checkTplIacTool(form.IacTool); err != nil {
+			return nil, err
+		}
+		iacTool = utils.FirstValueStr(form.IacTool, common.IacToolTerraform)
+		attrs["iacTool"] = iacTool
+	}
+	if form.HasKey("tfVersion") || iacTool != oldIacTool {
+		tfVersion := tpl.TfVersion
+		if form.HasKey("tfVersion") {
+			tfVersion = form.TfVersion
+		} else if checkTplTfVersion(iacTool, tfVersion) != nil {
+			// 切换 iac 工具后原版本不可用，使用新工具的默认版本
+			tfVersion = ""
+		}
+		if err := checkTplTfVersion(iacTool, tfVersion); err != nil {
+			return nil, err
+		}
+		attrs["tfVersion"] = tfVersion
 	}
 	if form.HasKey("repoRevision") {
 		attrs["repoRevision"] = form.RepoRevision
@@ -418,7 +446,51 @@ func TemplateChecks(c *ctx.ServiceContext, form *forms.TemplateChecksForm) (inte
 			return nil, e.New(e.TemplateWorkdirError, err)
 		}
 	}
+	if form.RepoId != "" {
+		if err := checkTplPipeline(c, form); err != nil {
+			return nil, err
+		}
+	}
 	return TemplateChecksResp{
 		CheckResult: consts.TplTfCheckSuccess,
 	}, nil
 }
+
+// checkTplPipeline 检查模板仓库中的 pipeline 文件，文件不存在时不检查
+func checkTplPipeline(c *ctx.ServiceContext, form *forms.TemplateChecksForm) e.Error {
+	vcs, err := services.QueryVcsByVcsId(form.VcsId, c.DB())
+	if err != nil {
+		return err
+	}
+	repo, er := vcsrv.GetRepo(vcs, form.RepoId)
+	if er != nil {
+		return e.New(e.VcsError, er)
+	}
+
+	content, err := services.ReadRepoPipeline(repo, form.RepoRevision, form.Workdir)
+	if err != nil || content == "" {
+		return err
+	}
+	if errs := services.LintPipeline(content); len(errs) > 0 {
+		return e.New(e.InvalidPipeline, errs)
+	}
+	return nil
+}
+
+type PipelineValidateResp struct {
+	Valid  bool                        `json:"valid"`
+	Errors services.PipelineLintErrors `json:"errors"`
+}
+
+// ValidatePipeline 检查 pipeline 内容，返回带行号的错误列表
+func ValidatePipeline(c *ctx.ServiceContext, form *forms.PipelineValidateForm) (interface{}, e.Error) {
+	errs := services.LintPipeline(form.Content)
+	return PipelineValidateResp{Valid: len(errs) == 0, Errors: errs}, nil
+}
+
+func checkTplIacTool(tool string) e.Error {
+	if tool != "" && !utils.StrInArray(tool, common.IacTools...) {
+		return e.New(e.BadParam, fmt.Errorf("invalid iac tool '%s'", tool), http.StatusBadRequest)
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"cloudiac/portal/models/forms"
 	"cloudiac/portal/services"
 	"cloudiac/portal/services/vcsrv"
+	"cloudiac/utils"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -23,6 +25,7 @@ import (
 )
 
 var TfListVersions []string
+var TofuListVersions []string
 var m sync.RWMutex
 
 type tfVersionList struct {
@@ -43,18 +46,20 @@ func AutoGetTfVersion(c *ctx.ServiceContext, form *forms.TemplateTfVersionSearch
 	if er != nil {
 		return nil, e.New(e.VcsError, er)
 	}
-	content, er := repoDetail.ReadFileContent(form.VcsBranch, "versions.tf")
-	// 没有找到versions.tf 文件，使用默认版本，不报错
-	if er != nil {
-		return consts.DefaultTerraformVersion, nil
+
+	// opentofu 没有内置版本，版本号从 opentofu 官方版本列表中查找
+	defaultVersion, builtinVersions, getOfficialVersions := consts.DefaultTerraformVersion, common.TerraformVersions, getTfVersions
+	if form.IacTool == common.IacToolOpenTofu {
+		defaultVersion, builtinVersions, getOfficialVersions = consts.DefaultOpenTofuVersion, nil, getTofuVersions
 	}
-	tfconstraint := GetUserTfVersion(content)
-	// 如果用户versions.tf 中没有制定terraform 版本，使用我们默认版本
+
+	tfconstraint := getRepoVersionConstraint(repoDetail, form.VcsBranch, form.IacTool)
+	// 如果用户没有指定 terraform 版本(或者没有找到 versions.tf 文件)，使用我们默认版本
 	if tfconstraint == "" {
-		return consts.DefaultTerraformVersion, nil
+		return defaultVersion, nil
 	}
 	// 查看内置版本中有无满足用户约束条件的版本
-	tfVersion, tferr := GetDetailTfVersion(common.TerraformVersions, tfconstraint)
+	tfVersion, tferr := GetDetailTfVersion(builtinVersions, tfconstraint)
 	if tferr != nil {
 		return nil, e.New(e.InvalidTfVersion, tferr)
 	}
@@ -62,7 +67,7 @@ func AutoGetTfVersion(c *ctx.ServiceContext, form *forms.TemplateTfVersionSearch
 		return tfVersion, nil
 	} else {
 		// 如果内置版本中没有满足用户版本，则从官方提供所有版本中查找
-		tflist := getTfVersions()
+		tflist := getOfficialVersions()
 		if len(tflist) > 0 {
 			tfVersion, tferr = GetDetailTfVersion(tflist, tfconstraint)
 			// 官方提供所有版本没有找到，则抛错认定用户指定版本不存在
@@ -103,16 +108,41 @@ func GetDetailTfVersion(tflist []string, tfconstraint string) (string, error) {
 	return "", nil
 }
 
+// getRepoVersionConstraint 读取仓库中的版本约束，
+// terragrunt 优先使用 terragrunt.hcl 中的 terraform_version_constraint，未配置时使用 versions.tf 中的 required_version
+func getRepoVersionConstraint(repo vcsrv.RepoIface, branch string, iacTool string) string {
+	if iacTool == common.IacToolTerragrunt {
+		if content, err := repo.ReadFileContent(branch, "terragrunt.hcl"); err == nil {
+			if constraint := getVersionConstraint(content, "terraform_version_constraint"); constraint != "" {
+				return constraint
+			}
+		}
+	}
+
+	content, err := repo.ReadFileContent(branch, "versions.tf")
+	if err != nil {
+		return ""
+	}
+	return GetUserTfVersion(content)
+}
+
 func GetUserTfVersion(f []byte) string {
+	return getVersionConstraint(f, "required_version")
+}
+
+func getVersionConstraint(f []byte, key string) string {
 	lines := strings.Split(string(f), "\n")
 	for _, v := range lines {
-		lineInfo := strings.Contains(v, "required_version")
+		lineInfo := strings.Contains(v, key)
 		if lineInfo {
 			re1, _ := regexp.Compile(`".*"`)
 			if re1 == nil {
 				return ""
 			}
 			result := re1.FindAllStringSubmatch(v, -1)
+			if len(result) == 0 {
+				return ""
+			}
 			// 去除匹配到字符串双引号
 			return strings.Trim(result[0][0], "\"")
 		}
@@ -195,6 +225,48 @@ func initTfversions() {
 	}
 }
 
+// 获取 opentofu 官方提供的版本列表
+func GetTofuList(apiURL string) ([]string, error) {
+	cli := http.Client{Timeout: consts.HttpClientTimeout * time.Second}
+	resp, err := cli.Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return []string{}, nil
+	}
+
+	result := struct {
+		Versions []struct {
+			Id string `json:"id"`
+		} `json:"versions"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(result.Versions))
+	for _, v := range result.Versions {
+		versions = append(versions, v.Id)
+	}
+	return versions, nil
+}
+
+func initTofuVersions() {
+	for {
+		versions, err := GetTofuList(consts.DefaultTofuMirror)
+		if err == nil {
+			m.Lock()
+			TofuListVersions = versions
+			m.Unlock()
+			break
+		} else {
+			time.Sleep(1 * time.Second)
+		}
+	}
+}
+
 func InitTfVersions() {
 	go func() {
 		initTfversions()
@@ -204,6 +276,13 @@ func InitTfVersions() {
 		}
 	}()
 
+	go func() {
+		initTofuVersions()
+		for {
+			time.Sleep(86400 * 7 * time.Second)
+			initTofuVersions()
+		}
+	}()
 }
 
 func getTfVersions() []string {
@@ -212,3 +291,30 @@ func getTfVersions() []string {
 	defer m.RUnlock()
 	return TfListVersions
 }
+
+func getTofuVersions() []string {
+	m.RLock()
+	defer m.RUnlock()
+	return TofuListVersions
+}
+
+// checkTplTfVersion 检查版本是否为 iac 工具可用的版本，版本为空表示执行任务时使用工具的默认版本。
+// 官方版本列表未获取到时只检查版本号格式
+func checkTplTfVersion(tool string, version string) e.Error {
+	if version == "" {
+		return nil
+	}
+	// opentofu 没有内置版本，terragrunt 使用 terraform 的版本
+	builtinVersions, officialVersions := common.TerraformVersions, getTfVersions()
+	if tool == common.IacToolOpenTofu {
+		builtinVersions, officialVersions = nil, getTofuVersions()
+	}
+	if utils.StrInArray(version, builtinVersions...) || utils.StrInArray(version, officialVersions...) {
+		return nil
+	}
+	if _, err := semver.NewVersion(version); err == nil && len(officialVersions) == 0 {
+		return nil
+	}
+	return e.New(e.InvalidTfVersion, fmt.Errorf("invalid %s version '%s'",
+		utils.FirstValueStr(tool, common.IacToolTerraform), version), http.StatusBadRequest)
+}
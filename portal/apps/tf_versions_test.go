@@ -0,0 +1,31 @@
+package apps
+
+import (
+	"cloudiac/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTplTfVersion(t *testing.T) {
+	tfVersions, tofuVersions := TfListVersions, TofuListVersions
+	defer func() {
+		TfListVersions, TofuListVersions = tfVersions, tofuVersions
+	}()
+
+	// 未获取到官方版本列表时只检查格式
+	TfListVersions, TofuListVersions = nil, nil
+	assert.Nil(t, checkTplTfVersion(common.IacToolTerraform, ""))
+	assert.Nil(t, checkTplTfVersion(common.IacToolTerraform, "0.14.11"))
+	assert.Nil(t, checkTplTfVersion(common.IacToolOpenTofu, "1.6.2"))
+	assert.NotNil(t, checkTplTfVersion(common.IacToolOpenTofu, "latest"))
+
+	TfListVersions = []string{"0.14.11", "1.5.7"}
+	TofuListVersions = []string{"1.6.2"}
+	assert.Nil(t, checkTplTfVersion(common.IacToolTerraform, "1.5.7"))
+	assert.Nil(t, checkTplTfVersion(common.IacToolTerragrunt, "1.0.6"))
+	assert.NotNil(t, checkTplTfVersion(common.IacToolTerraform, "1.6.2"))
+	// terraform 的内置版本对 opentofu 不可用
+	assert.NotNil(t, checkTplTfVersion(common.IacToolOpenTofu, "0.14.11"))
+	assert.Nil(t, checkTplTfVersion(common.IacToolOpenTofu, "1.6.2"))
+}
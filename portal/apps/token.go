@@ -16,12 +16,63 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"net/http"
+	"time"
 )
 
+// MaxTokenRotateGracePeriod token 轮换时旧 token 最长的宽限期(分钟)
+const MaxTokenRotateGracePeriod = 7 * 24 * 60
+
+// isTokenOrgAdmin 当前用户(或 api token)是否拥有组织管理员权限
+func isTokenOrgAdmin(c *ctx.ServiceContext) bool {
+	if c.IsSuperAdmin {
+		return true
+	}
+	if c.ApiToken != nil {
+		return c.ApiToken.Scope == consts.ScopeOrg &&
+			(c.ApiToken.Role == "" || c.ApiToken.Role == consts.OrgRoleAdmin)
+	}
+	return services.UserHasOrgRole(c.UserId, c.OrgId, consts.OrgRoleAdmin)
+}
+
+// checkTokenPermission 检查是否有权限管理 api token，组织 token 只有组织管理员可以管理，
+// 项目及环境 token 项目管理者也可以管理
+func checkTokenPermission(c *ctx.ServiceContext, scope string, projectId models.Id) e.Error {
+	if isTokenOrgAdmin(c) {
+		return nil
+	}
+	if scope != consts.ScopeOrg && c.ApiToken == nil &&
+		services.UserHasProjectRole(c.UserId, c.OrgId, projectId, consts.ProjectRoleManager) {
+		return nil
+	}
+	return e.New(e.PermissionDeny, fmt.Errorf("not allowed to manage %s token", scope), http.StatusForbidden)
+}
+
+// getOrgApiToken 查询组织下的 api token 并检查管理权限
+func getOrgApiToken(c *ctx.ServiceContext, id models.Id) (*models.Token, e.Error) {
+	token, err := services.GetTokenById(services.QueryWithOrgId(c.DB(), c.OrgId), id)
+	if err != nil {
+		if err.Code() == e.TokenNotExists {
+			return nil, e.New(err.Code(), err, http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if token.Type == consts.TokenApi {
+		if err := checkTokenPermission(c, token.Scope, token.ProjectId); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
 func SearchToken(c *ctx.ServiceContext, form *forms.SearchTokenForm) (interface{}, e.Error) {
-	//todo 鉴权
 	query := services.QueryToken(c.DB(), consts.TokenApi)
 	query = query.Where("org_id = ?", c.OrgId)
+	if !isTokenOrgAdmin(c) {
+		// 非组织管理员只能查看当前项目的 token
+		query = query.Where("scope != ? and project_id = ?", consts.ScopeOrg, c.ProjectId)
+	} else if c.ProjectId != "" {
+		query = query.Where("project_id = ?", c.ProjectId)
+	}
 	if form.Status != "" {
 		query = query.Where("status = ?", form.Status)
 	}
@@ -36,6 +87,10 @@ func SearchToken(c *ctx.ServiceContext, form *forms.SearchTokenForm) (interface{
 	if err := p.Scan(&tokens); err != nil {
 		return nil, e.New(e.DBError, err)
 	}
+	for i := range tokens {
+		// 数据库中保存的是 token 的 sha256 值，不返回
+		tokens[i].Key = ""
+	}
 
 	return &page.PageResp{
 		Total:    p.MustTotal(),
@@ -44,6 +99,41 @@ func SearchToken(c *ctx.ServiceContext, form *forms.SearchTokenForm) (interface{
 	}, nil
 }
 
+// checkApiTokenScope 检查 api token 的作用范围及角色，返回 token 所属的项目
+func checkApiTokenScope(c *ctx.ServiceContext, form *forms.CreateTokenForm) (projectId models.Id, envId models.Id, err e.Error) {
+	switch form.Scope {
+	case consts.ScopeOrg:
+		// 组织 token 可以不指定角色，不指定时按组织管理员鉴权
+		if form.Role != "" {
+			err = services.CheckRole(c.DB(), c.OrgId, consts.ScopeOrg, form.Role)
+		}
+		return "", "", err
+	case consts.ScopeProject:
+		project, er := services.GetProjectsById(c.DB(), form.ProjectId)
+		if er != nil || project.OrgId != c.OrgId {
+			return "", "", e.New(e.ProjectNotExists, fmt.Errorf("invalid project id"), http.StatusBadRequest)
+		}
+		projectId = project.Id
+	case consts.ScopeEnv:
+		env, er := services.GetEnvById(c.DB(), form.EnvId)
+		if er != nil || env.OrgId != c.OrgId {
+			return "", "", e.New(e.EnvNotExists, fmt.Errorf("invalid env id"), http.StatusBadRequest)
+		}
+		projectId, envId = env.ProjectId, env.Id
+	default:
+		return "", "", e.New(e.BadParam, fmt.Errorf("invalid scope '%s'", form.Scope), http.StatusBadRequest)
+	}
+
+	// 项目及环境 token 使用项目角色
+	if form.Role == "" {
+		return "", "", e.New(e.BadParam, fmt.Errorf("role is required for %s token", form.Scope), http.StatusBadRequest)
+	}
+	if err = services.CheckRole(c.DB(), c.OrgId, consts.ScopeProject, form.Role); err != nil {
+		return "", "", err
+	}
+	return projectId, envId, nil
+}
+
 func CreateToken(c *ctx.ServiceContext, form *forms.CreateTokenForm) (*models.Token, e.Error) {
 	c.AddLogField("action", fmt.Sprintf("create token for user %s", c.UserId))
 	var (
@@ -51,25 +141,43 @@ func CreateToken(c *ctx.ServiceContext, form *forms.CreateTokenForm) (*models.To
 		er        error
 	)
 
+	token := models.Token{
+		Type:        form.Type,
+		OrgId:       c.OrgId,
+		Role:        form.Role,
+		Description: form.Description,
+		CreatorId:   c.UserId,
+		//EnvId:       form.EnvId,
+		//Action:      form.Action,
+	}
+	if form.Type == consts.TokenApi {
+		if form.Scope == "" {
+			form.Scope = consts.ScopeOrg
+		}
+		projectId, envId, err := checkApiTokenScope(c, form)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTokenPermission(c, form.Scope, projectId); err != nil {
+			return nil, err
+		}
+		token.Scope, token.ProjectId, token.EnvId = form.Scope, projectId, envId
+	}
+
 	tokenStr, _ := utils.GetUUID()
+	token.Key = tokenStr
+	if form.Type == consts.TokenApi {
+		token.Key = services.HashApiToken(tokenStr)
+	}
 	if form.ExpiredAt != "" {
 		expiredAt, er = models.Time{}.Parse(form.ExpiredAt)
 		if er != nil {
 			return nil, e.New(e.BadParam, http.StatusBadRequest, er)
 		}
 	}
+	token.ExpiredAt = &expiredAt
 
-	token, err := services.CreateToken(c.DB(), models.Token{
-		Key:         string(tokenStr),
-		Type:        form.Type,
-		OrgId:       c.OrgId,
-		Role:        form.Role,
-		ExpiredAt:   &expiredAt,
-		Description: form.Description,
-		CreatorId:   c.UserId,
-		//EnvId:       form.EnvId,
-		//Action:      form.Action,
-	})
+	t, err := services.CreateToken(c.DB(), token)
 	if err != nil && err.Code() == e.TokenAlreadyExists {
 		return nil, e.New(err.Code(), err, http.StatusBadRequest)
 	} else if err != nil {
@@ -77,7 +185,9 @@ func CreateToken(c *ctx.ServiceContext, form *forms.CreateTokenForm) (*models.To
 		return nil, e.AutoNew(err, e.DBError)
 	}
 
-	return token, nil
+	// 明文 token 只在创建时返回
+	t.Key = tokenStr
+	return t, nil
 }
 
 func UpdateToken(c *ctx.ServiceContext, form *forms.UpdateTokenForm) (token *models.Token, err e.Error) {
@@ -85,6 +195,10 @@ func UpdateToken(c *ctx.ServiceContext, form *forms.UpdateTokenForm) (token *mod
 	if form.Id == "" {
 		return nil, e.New(e.BadRequest, fmt.Errorf("missing 'id'"))
 	}
+	before, err := getOrgApiToken(c, form.Id)
+	if err != nil {
+		return nil, err
+	}
 
 	attrs := models.Attrs{}
 	if form.HasKey("status") {
@@ -102,11 +216,59 @@ func UpdateToken(c *ctx.ServiceContext, form *forms.UpdateTokenForm) (token *mod
 		c.Logger().Errorf("error update token, err %s", err)
 		return nil, err
 	}
+	c.AddAuditChange(services.DiffAuditChange("token", token.Id, before, token))
+	if token.Type == consts.TokenApi {
+		token.Key = ""
+	}
 	return
 }
 
+// RotateToken 轮换 api token，生成新的 token，旧 token 在宽限期内仍然可以使用
+func RotateToken(c *ctx.ServiceContext, form *forms.RotateTokenForm) (*models.Token, e.Error) {
+	c.AddLogField("action", fmt.Sprintf("rotate token %s", form.Id))
+
+	token, err := getOrgApiToken(c, form.Id)
+	if err != nil {
+		return nil, err
+	}
+	if token.Type != consts.TokenApi {
+		return nil, e.New(e.BadParam, fmt.Errorf("only api token can be rotated"), http.StatusBadRequest)
+	}
+	if form.GracePeriod < 0 || form.GracePeriod > MaxTokenRotateGracePeriod {
+		return nil, e.New(e.BadParam, fmt.Errorf("grace period must between 0 and %d minutes", MaxTokenRotateGracePeriod),
+			http.StatusBadRequest)
+	}
+
+	tokenStr, er := utils.GetUUID()
+	if er != nil {
+		return nil, e.New(e.InternalError, er)
+	}
+	attrs := models.Attrs{
+		"key":                 services.HashApiToken(tokenStr),
+		"prev_key":            "",
+		"prev_key_expired_at": nil,
+	}
+	if form.GracePeriod > 0 {
+		attrs["prev_key"] = token.Key
+		attrs["prev_key_expired_at"] = time.Now().Add(time.Duration(form.GracePeriod) * time.Minute)
+	}
+
+	before := *token
+	token, err = services.UpdateToken(c.DB(), form.Id, attrs)
+	if err != nil {
+		c.Logger().Errorf("error rotate token, err %s", err)
+		return nil, err
+	}
+	c.AddAuditChange(services.DiffAuditChange("token", token.Id, before, token))
+	token.Key = tokenStr
+	return token, nil
+}
+
 func DeleteToken(c *ctx.ServiceContext, form *forms.DeleteTokenForm) (result interface{}, re e.Error) {
 	c.AddLogField("action", fmt.Sprintf("delete token %s", form.Id))
+	if _, err := getOrgApiToken(c, form.Id); err != nil {
+		return nil, err
+	}
 	if err := services.DeleteToken(c.DB(), form.Id); err != nil {
 		return nil, err
 	}
@@ -199,6 +361,7 @@ func ApiTriggerHandler(c *ctx.ServiceContext, form forms.ApiTriggerHandler) (int
 		KeyId:       env.KeyId,
 		Variables:   vars,
 		AutoApprove: env.AutoApproval,
+		Priority:    models.TaskPriorityWebhook,
 		BaseTask: models.BaseTask{
 			Type:        taskType,
 			StepTimeout: env.Timeout,
@@ -120,14 +120,41 @@ func updateObjectVars(c *ctx.ServiceContext, tx *db.Session, form *forms.UpdateO
 	}
 
 	tx = services.QueryWithOrgId(tx, c.OrgId)
+	oldVars := make([]models.Variable, 0)
+	if err := services.WithVarScopeIdWhere(tx, models.Variable{}.TableName(), scope, objectId).Find(&oldVars); err != nil {
+		return nil, e.AutoNew(err, e.DBError)
+	}
 	retVars, err := services.UpdateObjectVars(tx, scope, objectId, vars)
 	if err != nil {
 		c.Logger().Warnf("update object %s(%s) vars error: %v", form.Scope, form.ObjectId, err)
 		return nil, e.AutoNew(err, e.InternalError)
 	}
+	auditVarChanges(c, oldVars, retVars)
 	return services.VarsDesensitization(retVars), nil
 }
 
+// auditVarChanges 按变量名称比较并记录变量的新增、删除及修改
+func auditVarChanges(c *ctx.ServiceContext, before, after []models.Variable) {
+	afterVars := make(map[string]models.Variable)
+	for _, v := range after {
+		afterVars[v.Name] = v
+	}
+	beforeVars := make(map[string]models.Variable)
+	for _, v := range before {
+		beforeVars[v.Name] = v
+		if av, ok := afterVars[v.Name]; ok {
+			c.AddAuditChange(services.DiffAuditChange("variable", av.Id, v, av))
+		} else {
+			c.AddAuditChange(services.DiffAuditChange("variable", v.Id, v, nil))
+		}
+	}
+	for _, v := range after {
+		if _, ok := beforeVars[v.Name]; !ok {
+			c.AddAuditChange(services.DiffAuditChange("variable", v.Id, nil, v))
+		}
+	}
+}
+
 type newVariable []VariableResp
 
 func (v newVariable) Len() int {
@@ -106,6 +106,7 @@ func CreateWebhookTask(tx *db.Session, taskType, revision, commitId string, user
 		AutoApprove: env.AutoApproval,
 		Revision:    revision,
 		CommitId:    commitId,
+		Priority:    models.TaskPriorityWebhook,
 		BaseTask: models.BaseTask{
 			Type:        taskType,
 			RunnerId:    env.RunnerId,
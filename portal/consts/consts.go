@@ -20,6 +20,11 @@ const (
 	RunnerConnectTimeout = time.Second * 5
 	DbTaskPollInterval   = time.Second // 轮询 db 任务状态的间隔
 
+	RolePolicyReloadInterval = 30 * time.Second // 重新加载自定义角色权限策略的间隔
+	TokenLastUsedInterval    = time.Minute      // 更新 api token 最后使用时间的最小间隔
+
+	AuditLogExportMaxRows = 100000 // 单次导出审计日志的最大条数
+
 	DefaultAdminEmail = "admin@example.com"
 
 	CtxKey = "__request_ctx__"
@@ -31,6 +36,7 @@ const (
 	DefaultSysName  = "System"
 
 	DefaultTerraformVersion = "0.14.11"
+	DefaultOpenTofuVersion  = "1.6.2"
 )
 
 const (
@@ -89,6 +95,10 @@ const (
 	ProjectRoleOperator = "operator" // 可以发起 plan、apply
 	ProjectRoleGuest    = "guest"    // 访客，只读权限
 
+	// 用户来源，为空表示本地创建的用户
+	UserSourceLdap = "ldap"
+	UserSourceOidc = "oidc"
+
 	ScopeOrg      = "org"
 	ScopeProject  = "project"
 	ScopeTemplate = "template"
@@ -113,9 +123,11 @@ const (
 	EventTaskRunning   = "task.running"
 	EventTaskApproving = "task.approving"
 	EventTaskRejected  = "task.rejected"
+	EventTaskAborted   = "task.aborted"
 	EvenvtCronDrift    = "task.crondrift"
 
 	DefaultTfMirror   = "https://releases.hashicorp.com/terraform"
+	DefaultTofuMirror = "https://get.opentofu.org/tofu/api.json"
 	HttpClientTimeout = 20
 
 	TaskCallbackKafka = "kafka"
@@ -132,6 +144,10 @@ var (
 	VariableGroupProject = []string{ScopeOrg, ScopeProject}
 	VariableGroupOrg     = []string{ScopeOrg}
 
+	// 内置的组织角色及项目角色
+	OrgRoles     = []string{OrgRoleAdmin, OrgRoleMember}
+	ProjectRoles = []string{ProjectRoleManager, ProjectRoleApprover, ProjectRoleOperator, ProjectRoleGuest}
+
 	StatusTranslation = map[string]string{
 		"complete": "成功",
 		"failed":   "失败",
@@ -154,6 +170,7 @@ var (
 		common.TaskRunning:   EventTaskRunning,
 		common.TaskApproving: EventTaskApproving,
 		common.TaskRejected:  EventTaskFailed,
+		common.TaskAborted:   EventTaskAborted,
 		EvenvtCronDrift: EvenvtCronDrift,
 	}
 )
@@ -60,6 +60,7 @@ const (
 	MailServerError = 10420
 	ConsulConnError = 10430
 	VcsError        = 10440
+	OidcError       = 10450 // oidc 服务出错
 
 	//// 导入导出错误 105
 	ImportError       = 10510
@@ -75,6 +76,8 @@ const (
 	InvalidOrgId      = 20006 // 无效的 orgId
 	InvalidProjectId  = 20007 // 无效的 projectId
 
+	PasswordLoginDisabled = 20011 // 只允许单点登录
+
 	//// 权限 201
 	PermissionDeny   = 20110
 	ValidateError    = 20111
@@ -129,13 +132,23 @@ const (
 	TemplateActiveEnvExists = 30730
 
 	//// environment 308
-	EnvAlreadyExists       = 30810
-	EnvNotExists           = 30811
-	EnvAliasDuplicate      = 30812
-	EnvArchived            = 30813
-	EnvCannotArchiveActive = 30814
-	EnvDeploying           = 30815
-	EnvCheckAutoApproval   = 30816
+	EnvAlreadyExists         = 30810
+	EnvNotExists             = 30811
+	EnvAliasDuplicate        = 30812
+	EnvArchived              = 30813
+	EnvCannotArchiveActive   = 30814
+	EnvDeploying             = 30815
+	EnvCheckAutoApproval     = 30816
+	EnvDependencyExists      = 30817
+	EnvDependencyNotExists   = 30818
+	EnvDependencyCycle       = 30819
+	EnvDependencyOutput      = 30820
+	EnvNotDeployed           = 30821
+	DeployStackNotExists     = 30822
+	EnvStateBackendInvalid   = 30823
+	EnvStateBackendInUse     = 30824
+	EnvStateVersionNotExists = 30825
+	EnvStateLineageMismatch  = 30826
 
 	//// task 309
 	TaskAlreadyExists     = 30910
@@ -143,6 +156,12 @@ const (
 	TaskApproveNotPending = 30913
 	TaskStepNotExists     = 30914
 	TaskNotHaveStep       = 30916
+	TaskCannotAbort       = 30917
+	TaskApproveSelf       = 30918
+	TaskApproverNotAllow  = 30919
+	TaskAlreadyApproved   = 30920
+	TaskCannotResume      = 30921
+	TaskArtifactNotExists = 30922
 
 	//// ssh key 310
 	KeyAlreadyExists  = 31010
@@ -182,6 +201,21 @@ const (
 	//cron 315
 	CronExpressError = 31500
 	CronTaskFailed   = 31501
+
+	// approval policy 316
+	ApprovalPolicyAlreadyExists = 31610
+	ApprovalPolicyNotExists     = 31611
+
+	// maintenance window 317
+	MaintenanceWindowNotExists = 31710
+
+	// runner 318
+	RunnerNotExists = 31810
+
+	// role 319
+	RoleNotExists     = 31910
+	RoleAlreadyExists = 31911
+	RoleInUse         = 31912
 )
 
 var errorMsgs = map[int]map[string]string{
@@ -272,9 +306,15 @@ var errorMsgs = map[int]map[string]string{
 	DecryptError: {
 		"zh-cn": "数据解密错误",
 	},
+	LdapError: {
+		"zh-cn": "LDAP 服务错误",
+	},
 	MailServerError: {
 		"zh-cn": "邮件服务错误",
 	},
+	OidcError: {
+		"zh-cn": "OIDC 认证错误",
+	},
 	InvalidAccessKeyId: {
 		"zh-cn": "AccessKeyId错误",
 	},
@@ -299,6 +339,9 @@ var errorMsgs = map[int]map[string]string{
 	ColValidateError: {
 		"zh-cn": "字段校验错误",
 	},
+	PasswordLoginDisabled: {
+		"zh-cn": "密码登录已禁用，请使用单点登录",
+	},
 	InvalidPassword: {
 		"zh-cn": "无效的邮箱或密码",
 	},
@@ -450,6 +493,36 @@ var errorMsgs = map[int]map[string]string{
 	EnvCheckAutoApproval: {
 		"zh-cn": "配置自动纠漂移、推送到分支时重新部署时，必须配置自动审批",
 	},
+	EnvDependencyExists: {
+		"zh-cn": "环境依赖已存在",
+	},
+	EnvDependencyNotExists: {
+		"zh-cn": "环境依赖不存在",
+	},
+	EnvDependencyCycle: {
+		"zh-cn": "环境依赖存在循环",
+	},
+	EnvDependencyOutput: {
+		"zh-cn": "依赖环境的输出不存在",
+	},
+	EnvNotDeployed: {
+		"zh-cn": "环境未执行过部署",
+	},
+	DeployStackNotExists: {
+		"zh-cn": "环境栈部署记录不存在",
+	},
+	EnvStateBackendInvalid: {
+		"zh-cn": "无效的 state 存储配置",
+	},
+	EnvStateBackendInUse: {
+		"zh-cn": "环境存在资源，不允许修改 state 存储类型",
+	},
+	EnvStateVersionNotExists: {
+		"zh-cn": "state 版本不存在",
+	},
+	EnvStateLineageMismatch: {
+		"zh-cn": "state 版本与环境当前 state 不属于同一 lineage，不允许恢复",
+	},
 	TaskAlreadyExists: {
 		"zh-cn": "任务已经存在",
 	},
@@ -477,6 +550,24 @@ var errorMsgs = map[int]map[string]string{
 	TaskApproveNotPending: {
 		"zh-cn": "作业状态非待审批，不允许操作",
 	},
+	TaskCannotAbort: {
+		"zh-cn": "作业已结束，无法中止",
+	},
+	TaskApproveSelf: {
+		"zh-cn": "不允许审批自己发起的作业",
+	},
+	TaskApproverNotAllow: {
+		"zh-cn": "当前用户不在作业的审批人列表中",
+	},
+	TaskAlreadyApproved: {
+		"zh-cn": "当前用户已审批过该作业",
+	},
+	TaskCannotResume: {
+		"zh-cn": "作业状态不允许恢复执行",
+	},
+	TaskArtifactNotExists: {
+		"zh-cn": "步骤产物不存在",
+	},
 	KeyAlreadyExists: {
 		"zh-cn": "管理秘钥已存在",
 	},
@@ -557,4 +648,25 @@ var errorMsgs = map[int]map[string]string{
 	CronTaskFailed: {
 		"zh-cn": "cron定时任务执行失败",
 	},
+	ApprovalPolicyAlreadyExists: {
+		"zh-cn": "审批策略已存在",
+	},
+	ApprovalPolicyNotExists: {
+		"zh-cn": "审批策略不存在",
+	},
+	MaintenanceWindowNotExists: {
+		"zh-cn": "维护窗口不存在",
+	},
+	RunnerNotExists: {
+		"zh-cn": "runner 不存在",
+	},
+	RoleNotExists: {
+		"zh-cn": "角色不存在",
+	},
+	RoleAlreadyExists: {
+		"zh-cn": "角色名称重复",
+	},
+	RoleInUse: {
+		"zh-cn": "角色正在使用中，不能删除",
+	},
 }
@@ -126,6 +126,29 @@ var IacTaskFailedTpl = `
 </html>
 `
 
+var IacTaskAbortedTpl = `
+<html>
+<body>
+<p>尊敬的CloudIaC用户：</p>
+<br />
+<p>	【{{.Creator}}】在CloudIaC平台发起的部署任务已被中止，详情如下：</p>
+<br />
+<p>	所属组织：{{.OrgName}}</p>
+<p>	所属项目：{{.ProjectName}}</p>
+<p>	云模板：{{.TemplateName}}</p>
+<p>	分支/tag：{{.Revision}}</p>
+<p>	环境名称：{{.EnvName}}</p>
+<p>	任务类型：{{.TaskType}}</p>
+<p>	执行结果：已中止</p>
+<p>	中止信息：{{.Message}}</p>
+<br />
+<p>	更多详情请点击：{{.Addr}}</p>
+<br />
+<p>	-----该邮件由系统自动发出，请勿回复-----</p>
+</body>
+</html>
+`
+
 var IacTaskApprovingTpl = `
 <html>
 <body>
@@ -220,6 +243,31 @@ const (
 
 	-----该消息由系统自动发出，请勿回复-----
 
+`
+	IacTaskAbortedMarkdown = `
+尊敬的CloudIaC用户：
+
+	【{{.Creator}}】在CloudIaC平台发起的部署任务已被中止，详情如下：
+
+	所属组织：{{.OrgName}}
+
+	所属项目：{{.ProjectName}}
+
+	云模板：{{.TemplateName}}
+
+	分支/tag：{{.Revision}}
+
+	环境名称：{{.EnvName}}
+
+	任务类型：{{.TaskType}}
+
+	执行结果：已中止
+
+	中止信息：{{.Message}}
+
+	更多详情请点击：{{.Addr}}
+
+	-----该消息由系统自动发出，请勿回复-----
 `
 	IacTaskCompleteMarkdown = `
 尊敬的CloudIaC用户：
@@ -7,4 +7,7 @@ const (
 	RunnerTaskStepStatusURL    = "/api/v1/task/step/status"
 	RunnerTaskStepLogFollowURL = "/api/v1/task/step/log/follow"
 	RunnerStopTaskURL          = "/api/v1/task/stop"
+	RunnerTaskArtifactsURL     = "/api/v1/task/artifacts"
+	RunnerTaskArtifactURL      = "/api/v1/task/artifacts/download"
+	RunnerHeartbeatURL         = "/api/v1/heartbeat"
 )
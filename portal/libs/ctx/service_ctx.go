@@ -21,6 +21,9 @@ type ServiceContext struct {
 	Username     string    // 用户名称
 	IsSuperAdmin bool      // 是否平台管理员
 	UserIpAddr   string
+	ApiToken     *models.Token // 通过 api token 访问时使用的 token
+
+	AuditChanges models.AuditChanges // 本次请求修改的资源及字段差异，由操作日志中间件记录
 }
 
 func NewServiceContext(rc RequestContext) *ServiceContext {
@@ -55,3 +58,11 @@ func (c *ServiceContext) AddLogField(key string, val string) *ServiceContext {
 	c.logger = c.logger.WithField(key, val)
 	return c
 }
+
+// AddAuditChange 记录资源修改前后的字段差异，没有字段变化时忽略
+func (c *ServiceContext) AddAuditChange(change models.AuditChange) {
+	if len(change.Fields) == 0 {
+		return
+	}
+	c.AuditChanges = append(c.AuditChanges, change)
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import (
+	"cloudiac/portal/libs/db"
+
+	"github.com/lib/pq"
+)
+
+// ApprovalPolicy 任务审批策略，可关联到项目或环境，环境级别的策略优先于项目级别的策略。
+// 未配置审批策略时保持原有逻辑: 任一拥有审批权限的用户审批即可
+type ApprovalPolicy struct {
+	TimedModel
+
+	OrgId     Id `json:"orgId" gorm:"size:32;not null;comment:组织ID"`
+	ProjectId Id `json:"projectId" gorm:"size:32;not null;comment:项目ID"`
+	EnvId     Id `json:"envId" gorm:"size:32;not null;default:'';comment:环境ID，为空表示项目级别的策略"`
+
+	RequiredApprovals int  `json:"requiredApprovals" gorm:"not null;default:1;comment:需要的审批通过人数"`
+	AllowSelfApproval bool `json:"allowSelfApproval" gorm:"not null;default:0;comment:是否允许任务发起人审批"`
+	ExpireMinutes     int  `json:"expireMinutes" gorm:"not null;default:0;comment:审批超时时间(分钟)，超时后自动驳回，0 表示不超时"`
+
+	// 审批人可以通过用户 id 或项目角色指定，都为空表示所有拥有审批权限的用户
+	ApplyApproverIds     pq.StringArray `json:"applyApproverIds" gorm:"type:text;comment:部署审批人" swaggertype:"array,string"`
+	ApplyApproverRoles   pq.StringArray `json:"applyApproverRoles" gorm:"type:text;comment:部署审批角色" swaggertype:"array,string"`
+	DestroyApproverIds   pq.StringArray `json:"destroyApproverIds" gorm:"type:text;comment:销毁审批人" swaggertype:"array,string"`
+	DestroyApproverRoles pq.StringArray `json:"destroyApproverRoles" gorm:"type:text;comment:销毁审批角色" swaggertype:"array,string"`
+
+	CreatorId Id `json:"creatorId" gorm:"size:32;not null;comment:创建人"`
+}
+
+func (ApprovalPolicy) TableName() string {
+	return "iac_approval_policy"
+}
+
+func (p ApprovalPolicy) Migrate(sess *db.Session) error {
+	return p.AddUniqueIndex(sess, "unique__project__env", "project_id", "env_id")
+}
+
+// Approvers 获取指定审批步骤类型的审批人及审批角色
+func (p *ApprovalPolicy) Approvers(stepType string) (ids []string, roles []string) {
+	if stepType == TaskStepDestroy {
+		return p.DestroyApproverIds, p.DestroyApproverRoles
+	}
+	return p.ApplyApproverIds, p.ApplyApproverRoles
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+const (
+	DeployStackRunning  = "running"
+	DeployStackComplete = "complete"
+	DeployStackFailed   = "failed"
+)
+
+// DeployStack 环境栈部署记录，按依赖顺序依次为每个环境创建部署任务，
+// 前一个环境的任务执行成功后才会创建下一个环境的任务，任一任务失败则中止后续部署
+type DeployStack struct {
+	TimedModel
+
+	OrgId     Id `json:"orgId" gorm:"size:32;not null"`
+	ProjectId Id `json:"projectId" gorm:"size:32;not null"`
+	EnvId     Id `json:"envId" gorm:"size:32;not null;comment:发起部署的环境ID"`
+
+	EnvIds  StrSlice `json:"envIds" gorm:"type:json;comment:按依赖顺序排列的环境ID"`
+	TaskIds StrSlice `json:"taskIds" gorm:"type:json;comment:已创建的任务ID"`
+	Current int      `json:"current" gorm:"not null;default:0;comment:当前部署的环境序号"`
+
+	Status    string `json:"status" gorm:"type:enum('running','complete','failed');default:'running'"`
+	Message   string `json:"message" gorm:"type:text"`
+	CreatorId Id     `json:"creatorId" gorm:"size:32;not null"`
+}
+
+func (DeployStack) TableName() string {
+	return "iac_deploy_stack"
+}
@@ -3,6 +3,7 @@
 package models
 
 import (
+	"cloudiac/common"
 	"cloudiac/portal/libs/db"
 	"path"
 	"time"
@@ -43,6 +44,14 @@ type Env struct {
 
 	StatePath string `json:"statePath" gorm:"not null" swaggerignore:"true"` // Terraform tfstate 文件路径（内部）
 
+	// Terraform state 存储方式，默认使用 consul
+	StateBackend       string             `json:"stateBackend" gorm:"size:32;default:'consul'" enums:"'consul','s3','http','local'"`
+	StateBackendConfig StateBackendConfig `json:"stateBackendConfig" gorm:"type:json"`
+	StateBackendSecret string             `json:"-" gorm:"type:text"` // 加密保存的 StateBackendSecret
+
+	// 任务容器资源限制及隔离配置，未配置的项使用项目配置
+	ContainerLimits common.ContainerLimits `json:"containerLimits" gorm:"type:json"`
+
 	// 环境可以覆盖模板中的 vars file 配置，具体说明见 Template model
 	TfVarsFile   string `json:"tfVarsFile" gorm:"default:''"`   // Terraform tfvars 变量文件路径
 	PlayVarsFile string `json:"playVarsFile" gorm:"default:''"` // Ansible 变量文件路径
@@ -53,6 +62,9 @@ type Env struct {
 	Revision string `json:"revision" gorm:"size:64;default:'master'"` // Vcs仓库分支/标签
 	KeyId    Id     `json:"keyId" gorm:"size32"`                      // 部署密钥ID
 
+	// 配置了 runner tags 时任务不使用 RunnerId，而是在执行时从包含所有 tags 的健康 runner 中选择负载最低的
+	RunnerTags pq.StringArray `json:"runnerTags" gorm:"type:text" swaggertype:"array,string"`
+
 	LastTaskId    Id `json:"lastTaskId" gorm:"size:32"`    // 最后一次部署或销毁任务的 id(plan 任务不记录)
 	LastResTaskId Id `json:"lastResTaskId" gorm:"size:32"` // 最后一次进行了资源列表统计的部署任务的 id
 
@@ -83,6 +95,10 @@ type Env struct {
 	AutoRepairDrift   bool       `json:"autoRepairDrift" gorm:"default:false"`   // 是否进行自动纠偏
 	OpenCronDrift     bool       `json:"openCronDrift" gorm:"default:false"`     // 是否开启偏移检测
 	NextDriftTaskTime *time.Time `json:"nextDriftTaskTime" gorm:"type:datetime"` // 下次执行偏移检测任务的时间
+
+	// 定时部署
+	CronDeployExpress  string     `json:"cronDeployExpress" gorm:"default:''"`     // 定时部署任务的Cron表达式，为空表示不开启定时部署
+	NextDeployTaskTime *time.Time `json:"nextDeployTaskTime" gorm:"type:datetime"` // 下次执行定时部署任务的时间
 }
 
 func (Env) TableName() string {
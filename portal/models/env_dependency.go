@@ -0,0 +1,46 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import (
+	"cloudiac/portal/libs/db"
+	"database/sql/driver"
+)
+
+// EnvOutputMapping 上游环境 output 到下游环境 terraform 变量的映射
+type EnvOutputMapping struct {
+	Output   string `json:"output" binding:"required"`   // 上游环境的 output 名称
+	Variable string `json:"variable" binding:"required"` // 下游环境的 terraform 变量名称
+}
+
+type EnvOutputMappings []EnvOutputMapping
+
+func (v EnvOutputMappings) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *EnvOutputMappings) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
+// EnvDependency 环境依赖，环境(EnvId)依赖于上游环境(DependsOnEnvId)，
+// 环境创建任务时会将上游环境的 outputs 按映射关系传入 terraform 变量
+type EnvDependency struct {
+	TimedModel
+
+	OrgId          Id `json:"orgId" gorm:"size:32;not null"`
+	ProjectId      Id `json:"projectId" gorm:"size:32;not null"`
+	EnvId          Id `json:"envId" gorm:"size:32;not null;comment:环境ID"`
+	DependsOnEnvId Id `json:"dependsOnEnvId" gorm:"size:32;not null;comment:依赖的上游环境ID"`
+
+	OutputMappings EnvOutputMappings `json:"outputMappings" gorm:"type:json;comment:output 到变量的映射"`
+	CreatorId      Id                `json:"creatorId" gorm:"size:32;not null"`
+}
+
+func (EnvDependency) TableName() string {
+	return "iac_env_dependency"
+}
+
+func (d EnvDependency) Migrate(sess *db.Session) error {
+	return d.AddUniqueIndex(sess, "unique__env__depends_on", "env_id", "depends_on_env_id")
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+// EnvStateVersion 环境 terraform state 版本，部署、销毁及修改 state 的任务执行后保存一份 state 快照，
+// state 内容未变化时不生成新版本
+type EnvStateVersion struct {
+	TimedModel
+
+	OrgId     Id `json:"orgId" gorm:"size:32;not null"`
+	ProjectId Id `json:"projectId" gorm:"size:32;not null"`
+	EnvId     Id `json:"envId" gorm:"size:32;not null;index"`
+	TaskId    Id `json:"taskId" gorm:"size:32;not null;comment:生成该版本的任务ID"`
+
+	Serial   int    `json:"serial" gorm:"not null;default:0;comment:state serial"`
+	Lineage  string `json:"lineage" gorm:"size:64;default:'';comment:state lineage"`
+	Size     int    `json:"size" gorm:"not null;default:0"`                           // state 文件大小(字节)
+	Checksum string `json:"checksum" gorm:"size:64;not null;comment:sha256 checksum"` // state 内容 sha256
+	Path     string `json:"-" gorm:"not null"`                                        // state 文件在日志存储中的路径
+}
+
+func (EnvStateVersion) TableName() string {
+	return "iac_env_state_version"
+}
+
+type EnvStateVersionDetail struct {
+	EnvStateVersion
+	TaskName string `json:"taskName"` // 任务名称
+	TaskType string `json:"taskType"` // 任务类型
+	Creator  string `json:"creator"`  // 任务执行人
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package forms
+
+import "cloudiac/portal/models"
+
+type SearchApprovalPolicyForm struct {
+	PageForm
+
+	EnvId models.Id `form:"envId" json:"envId"` // 环境ID，为空时查询项目下所有的策略
+}
+
+type CreateApprovalPolicyForm struct {
+	BaseForm
+
+	EnvId                models.Id `form:"envId" json:"envId"`                                         // 环境ID，为空表示项目级别的策略
+	RequiredApprovals    int       `form:"requiredApprovals" json:"requiredApprovals" binding:"min=0"` // 需要的审批通过人数，默认为 1
+	AllowSelfApproval    bool      `form:"allowSelfApproval" json:"allowSelfApproval"`                 // 是否允许任务发起人审批
+	ExpireMinutes        int       `form:"expireMinutes" json:"expireMinutes" binding:"min=0"`         // 审批超时时间(分钟)，0 表示不超时
+	ApplyApproverIds     []string  `form:"applyApproverIds" json:"applyApproverIds"`                   // 部署审批人
+	ApplyApproverRoles   []string  `form:"applyApproverRoles" json:"applyApproverRoles"`               // 部署审批角色，内置项目角色或项目自定义角色 id
+	DestroyApproverIds   []string  `form:"destroyApproverIds" json:"destroyApproverIds"`               // 销毁审批人
+	DestroyApproverRoles []string  `form:"destroyApproverRoles" json:"destroyApproverRoles"`           // 销毁审批角色
+}
+
+type UpdateApprovalPolicyForm struct {
+	BaseForm
+
+	Id                   models.Id `uri:"id" json:"id" swaggerignore:"true"`
+	RequiredApprovals    int       `form:"requiredApprovals" json:"requiredApprovals" binding:"min=0"`
+	AllowSelfApproval    bool      `form:"allowSelfApproval" json:"allowSelfApproval"`
+	ExpireMinutes        int       `form:"expireMinutes" json:"expireMinutes" binding:"min=0"`
+	ApplyApproverIds     []string  `form:"applyApproverIds" json:"applyApproverIds"`
+	ApplyApproverRoles   []string  `form:"applyApproverRoles" json:"applyApproverRoles"`
+	DestroyApproverIds   []string  `form:"destroyApproverIds" json:"destroyApproverIds"`
+	DestroyApproverRoles []string  `form:"destroyApproverRoles" json:"destroyApproverRoles"`
+}
+
+type DeleteApprovalPolicyForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
+
+type DetailApprovalPolicyForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package forms
+
+import (
+	"cloudiac/portal/models"
+	"time"
+)
+
+type SearchAuditLogForm struct {
+	PageForm
+
+	UserId       models.Id `form:"userId" json:"userId"`                                 // 操作用户ID
+	OrgId        models.Id `form:"orgId" json:"orgId"`                                   // 组织ID，非平台管理员只能查询当前组织的日志
+	ProjectId    models.Id `form:"projectId" json:"projectId"`                           // 项目ID
+	ResourceType string    `form:"resourceType" json:"resourceType" example:"envs"`      // 资源类型
+	ResourceId   models.Id `form:"resourceId" json:"resourceId"`                         // 资源ID
+	Action       string    `form:"action" json:"action" enums:"create,update,delete"`    // 操作类型
+	From         time.Time `form:"from" json:"from" example:"2006-01-02T15:04:05Z07:00"` // 开始时间
+	To           time.Time `form:"to" json:"to" example:"2006-01-02T15:04:05Z07:00"`     // 结束时间
+	Format       string    `form:"format" json:"format" enums:"csv,jsonl"`               // 导出格式，为空时分页查询
+}
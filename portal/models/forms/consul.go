@@ -8,3 +8,19 @@ type ConsulTagUpdateForm struct {
 	Tags      []string `json:"tags" form:"tags" `
 	ServiceId string   `json:"serviceId" form:"serviceId" `
 }
+
+type RegisterRunnerForm struct {
+	BaseForm
+
+	Id      string   `json:"id" form:"id" binding:"required,max=64"`
+	Service string   `json:"service" form:"service" binding:"required,max=64"`
+	Address string   `json:"address" form:"address" binding:"required,max=255"`
+	Port    int      `json:"port" form:"port" binding:"required"`
+	Tags    []string `json:"tags" form:"tags"`
+}
+
+type DeregisterRunnerForm struct {
+	BaseForm
+
+	Id string `uri:"id" json:"id" swaggerignore:"true"`
+}
@@ -3,6 +3,7 @@
 package forms
 
 import (
+	"cloudiac/common"
 	"cloudiac/portal/models"
 )
 
@@ -28,6 +29,9 @@ type CreateEnvForm struct {
 	Revision        string `form:"revision" json:"revision" binding:""`                             // 分支/标签
 	Timeout         int    `form:"timeout" json:"timeout" binding:""`                               // 部署超时时间（单位：秒）
 
+	// 通过 runner tags 选择部署通道，配置后任务在包含所有 tags 的健康 runner 中选择负载最低的执行，此时忽略 runnerId
+	RunnerTags []string `form:"runnerTags" json:"runnerTags" binding:""`
+
 	Variables []Variable `form:"variables" json:"variables" binding:""` // 自定义变量列表，该变量列表会覆盖现有的变量
 
 	TfVarsFile   string    `form:"tfVarsFile" json:"tfVarsFile" binding:""`     // Terraform tfvars 变量文件路径
@@ -47,10 +51,16 @@ type CreateEnvForm struct {
 
 	Callback string `json:"callback" form:"callback"` // 外部请求的回调方式
 
-	CronDriftExpress string `json:"cronDriftExpress" form:"cronDriftExpress"` // 偏移检测表达式
-	AutoRepairDrift  bool   `json:"autoRepairDrift" form:"autoRepairDrift"`   // 是否进行自动纠偏
-	OpenCronDrift    bool   `json:"openCronDrift" form:"openCronDrift"`       // 是否开启偏移检测
+	CronDriftExpress  string `json:"cronDriftExpress" form:"cronDriftExpress"`   // 偏移检测表达式
+	AutoRepairDrift   bool   `json:"autoRepairDrift" form:"autoRepairDrift"`     // 是否进行自动纠偏
+	OpenCronDrift     bool   `json:"openCronDrift" form:"openCronDrift"`         // 是否开启偏移检测
+	CronDeployExpress string `json:"cronDeployExpress" form:"cronDeployExpress"` // 定时部署表达式，为空表示不开启定时部署
 
+	StateBackend       string                     `json:"stateBackend" form:"stateBackend" enums:"consul,s3,http,local"` // state 存储方式，默认为 consul
+	StateBackendConfig models.StateBackendConfig  `json:"stateBackendConfig" form:"stateBackendConfig"`                  // state 存储配置
+	StateBackendSecret *models.StateBackendSecret `json:"stateBackendSecret" form:"stateBackendSecret"`                  // state 存储认证信息，加密保存，不会在接口中返回
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits" form:"containerLimits"` // 任务容器资源限制，只能收紧项目配置的限制
 }
 
 type SampleVariables struct {
@@ -75,18 +85,26 @@ type UpdateEnvForm struct {
 	Description string    `form:"description" json:"description" binding:"max=255"` // 环境描述
 	KeyId       models.Id `form:"keyId" json:"keyId" binding:""`                    // 部署密钥ID
 	RunnerId    string    `form:"runnerId" json:"runnerId" binding:""`              // 环境默认部署通道
+	RunnerTags  []string  `form:"runnerTags" json:"runnerTags" binding:""`          // 通过 runner tags 选择部署通道，传空数组表示清除
 	Archived    bool      `form:"archived" json:"archived" enums:"true,false"`      // 归档状态，默认返回未归档环境
 
 	AutoApproval    bool `form:"autoApproval" json:"autoApproval"  binding:"" enums:"true,false"` // 是否自动审批
 	StopOnViolation bool `form:"stopOnViolation" json:"stopOnViolation" enums:"true,false"`       // 合规不通过是否中止任务
 
-	Triggers         []string `form:"triggers" json:"triggers" binding:""`       // 启用触发器，触发器：commit（每次推送自动部署），prmr（提交PR/MR的时候自动执行plan）
-	RetryNumber      int      `form:"retryNumber" json:"retryNumber" binding:""` // 重试总次数
-	RetryDelay       int      `form:"retryDelay" json:"retryDelay" binding:""`   // 重试时间间隔
-	RetryAble        bool     `form:"retryAble" json:"retryAble" binding:""`     // 是否允许任务进行重试
-	CronDriftExpress string   `json:"cronDriftExpress" form:"cronDriftExpress"`  // 偏移检测表达式
-	AutoRepairDrift  bool     `json:"autoRepairDrift" form:"autoRepairDrift"`    // 是否进行自动纠偏
-	OpenCronDrift    bool     `json:"openCronDrift" form:"openCronDrift"`        // 是否开启偏移检测
+	Triggers          []string `form:"triggers" json:"triggers" binding:""`        // 启用触发器，触发器：commit（每次推送自动部署），prmr（提交PR/MR的时候自动执行plan）
+	RetryNumber       int      `form:"retryNumber" json:"retryNumber" binding:""`  // 重试总次数
+	RetryDelay        int      `form:"retryDelay" json:"retryDelay" binding:""`    // 重试时间间隔
+	RetryAble         bool     `form:"retryAble" json:"retryAble" binding:""`      // 是否允许任务进行重试
+	CronDriftExpress  string   `json:"cronDriftExpress" form:"cronDriftExpress"`   // 偏移检测表达式
+	AutoRepairDrift   bool     `json:"autoRepairDrift" form:"autoRepairDrift"`     // 是否进行自动纠偏
+	OpenCronDrift     bool     `json:"openCronDrift" form:"openCronDrift"`         // 是否开启偏移检测
+	CronDeployExpress string   `json:"cronDeployExpress" form:"cronDeployExpress"` // 定时部署表达式，为空表示不开启定时部署
+
+	StateBackend       string                     `json:"stateBackend" form:"stateBackend" enums:"consul,s3,http,local"` // state 存储方式
+	StateBackendConfig models.StateBackendConfig  `json:"stateBackendConfig" form:"stateBackendConfig"`                  // state 存储配置
+	StateBackendSecret *models.StateBackendSecret `json:"stateBackendSecret" form:"stateBackendSecret"`                  // state 存储认证信息，传 null 表示不修改
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits" form:"containerLimits"` // 任务容器资源限制，只能收紧项目配置的限制
 }
 
 type DeployEnvForm struct {
@@ -106,6 +124,8 @@ type DeployEnvForm struct {
 	Revision string `form:"revision" json:"revision" binding:""`                                    // 分支/标签
 	Timeout  int    `form:"timeout" json:"timeout" binding:""`                                      // 部署超时时间（单位：秒）
 
+	RunnerTags []string `form:"runnerTags" json:"runnerTags" binding:""` // 通过 runner tags 选择部署通道，传空数组表示清除
+
 	RetryNumber int  `form:"retryNumber" json:"retryNumber" binding:""` // 重试总次数
 	RetryDelay  int  `form:"retryDelay" json:"retryDelay" binding:""`   // 重试时间间隔
 	RetryAble   bool `form:"retryAble" json:"retryAble" binding:""`     // 是否允许任务进行重试
@@ -120,9 +140,10 @@ type DeployEnvForm struct {
 	VarGroupIds    []models.Id `json:"varGroupIds" form:"varGroupIds" `
 	DelVarGroupIds []models.Id `json:"delVarGroupIds" form:"delVarGroupIds" `
 
-	CronDriftExpress string `json:"cronDriftExpress" form:"cronDriftExpress"` // 偏移检测表达式
-	AutoRepairDrift  bool   `json:"autoRepairDrift" form:"autoRepairDrift"`   // 是否进行自动纠偏
-	OpenCronDrift    bool   `json:"openCronDrift" form:"openCronDrift"`       // 是否开启偏移检测
+	CronDriftExpress  string `json:"cronDriftExpress" form:"cronDriftExpress"`   // 偏移检测表达式
+	AutoRepairDrift   bool   `json:"autoRepairDrift" form:"autoRepairDrift"`     // 是否进行自动纠偏
+	OpenCronDrift     bool   `json:"openCronDrift" form:"openCronDrift"`         // 是否开启偏移检测
+	CronDeployExpress string `json:"cronDeployExpress" form:"cronDeployExpress"` // 定时部署表达式，为空表示不开启定时部署
 }
 
 type ArchiveEnvForm struct {
@@ -172,6 +193,29 @@ type DestroyEnvForm struct {
 	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境ID，swagger 参数通过 param path 指定，这里忽略
 }
 
+type EnvStateForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境ID，swagger 参数通过 param path 指定，这里忽略
+
+	Action string `form:"action" json:"action" binding:"required" enums:"list,show,mv,rm,import"` // state 操作
+	// 操作参数: list 为资源地址(可选，用于过滤); show 为资源地址; mv 为源地址、目标地址; rm 为资源地址列表; import 为资源地址、资源ID
+	Args []string `form:"args" json:"args"`
+}
+
+type SearchEnvStateVersionForm struct {
+	NoPageSizeForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境ID，swagger 参数通过 param path 指定，这里忽略
+}
+
+type EnvStateVersionForm struct {
+	BaseForm
+
+	Id        models.Id `uri:"id" json:"id" swaggerignore:"true"`               // 环境ID，swagger 参数通过 param path 指定，这里忽略
+	VersionId models.Id `uri:"versionId" json:"versionId" swaggerignore:"true"` // state 版本ID，swagger 参数通过 param path 指定，这里忽略
+}
+
 type SearchEnvVariableForm struct {
 	BaseForm
 
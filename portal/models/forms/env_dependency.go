@@ -0,0 +1,46 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package forms
+
+import "cloudiac/portal/models"
+
+type SearchEnvDependencyForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境ID，swagger 参数通过 param path 指定，这里忽略
+}
+
+type CreateEnvDependencyForm struct {
+	BaseForm
+
+	Id             models.Id                 `uri:"id" json:"id" swaggerignore:"true"`                        // 环境ID，swagger 参数通过 param path 指定，这里忽略
+	DependsOnEnvId models.Id                 `form:"dependsOnEnvId" json:"dependsOnEnvId" binding:"required"` // 依赖的上游环境ID
+	OutputMappings []models.EnvOutputMapping `form:"outputMappings" json:"outputMappings" binding:"dive"`     // 上游环境 output 到 terraform 变量的映射
+}
+
+type UpdateEnvDependencyForm struct {
+	BaseForm
+
+	Id             models.Id                 `uri:"id" json:"id" swaggerignore:"true"`
+	DependencyId   models.Id                 `uri:"dependencyId" json:"dependencyId" swaggerignore:"true"`
+	OutputMappings []models.EnvOutputMapping `form:"outputMappings" json:"outputMappings" binding:"dive"`
+}
+
+type DeleteEnvDependencyForm struct {
+	BaseForm
+
+	Id           models.Id `uri:"id" json:"id" swaggerignore:"true"`
+	DependencyId models.Id `uri:"dependencyId" json:"dependencyId" swaggerignore:"true"`
+}
+
+type DeployStackForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境ID，swagger 参数通过 param path 指定，这里忽略
+}
+
+type DetailDeployStackForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 环境栈部署记录ID
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package forms
+
+import "cloudiac/portal/models"
+
+type SearchMaintenanceWindowForm struct {
+	PageForm
+}
+
+type CreateMaintenanceWindowForm struct {
+	BaseForm
+
+	Name        string `form:"name" json:"name" binding:"required,max=255"`       // 名称
+	Description string `form:"description" json:"description" binding:"max=255"`  // 描述
+	CronExpress string `form:"cronExpress" json:"cronExpress" binding:"required"` // 窗口开始时间的 Cron 表达式
+	Duration    int    `form:"duration" json:"duration" binding:"required,min=1"` // 窗口持续时间(分钟)
+	Enabled     *bool  `form:"enabled" json:"enabled"`                            // 是否启用，默认启用
+}
+
+type UpdateMaintenanceWindowForm struct {
+	BaseForm
+
+	Id          models.Id `uri:"id" json:"id" swaggerignore:"true"`
+	Name        string    `form:"name" json:"name" binding:"max=255"`
+	Description string    `form:"description" json:"description" binding:"max=255"`
+	CronExpress string    `form:"cronExpress" json:"cronExpress"`
+	Duration    int       `form:"duration" json:"duration" binding:"min=0"`
+	Enabled     bool      `form:"enabled" json:"enabled"`
+}
+
+type DeleteMaintenanceWindowForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
+
+type DetailMaintenanceWindowForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
@@ -19,6 +19,8 @@ type UpdateOrganizationForm struct {
 	Description string `form:"description" json:"description" binding:"max=255"` // 组织描述
 	RunnerId    string `form:"runnerId" json:"runnerId" binding:""`              // 组织默认部署通道
 	Status      string `form:"status" json:"status" enums:"enable,disable"`      // 组织状态
+
+	MaxConcurrentTasks int `form:"maxConcurrentTasks" json:"maxConcurrentTasks" binding:"omitempty,min=0"` // 组织并发部署任务数限制，0 表示不限制
 }
 
 type SearchOrganizationForm struct {
@@ -2,7 +2,10 @@
 
 package forms
 
-import "cloudiac/portal/models"
+import (
+	"cloudiac/common"
+	"cloudiac/portal/models"
+)
 
 type UserAuthorization struct {
 	UserId models.Id `json:"userId" form:"userId" `                                     // 用户id
@@ -15,6 +18,8 @@ type CreateProjectForm struct {
 	Name              string              `json:"name" form:"name" binding:"required"` // 项目名称
 	Description       string              `json:"description" form:"description" `     // 项目描述
 	UserAuthorization []UserAuthorization `json:"userAuthorization" form:"userAuthorization" `
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits" form:"containerLimits"` // 任务容器资源限制
 }
 
 type SearchProjectForm struct {
@@ -31,6 +36,10 @@ type UpdateProjectForm struct {
 	Status      string    `json:"status" form:"status" `           // 项目状态 ('enable','disable')
 	Name        string    `json:"name" form:"name"`                // 项目名称
 	Description string    `json:"description" form:"description" ` // 项目描述
+
+	MaxConcurrentTasks int `json:"maxConcurrentTasks" form:"maxConcurrentTasks" binding:"omitempty,min=0"` // 项目并发部署任务数限制，0 表示不限制
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits" form:"containerLimits"` // 任务容器资源限制
 }
 
 type DeleteProjectForm struct {
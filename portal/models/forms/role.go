@@ -0,0 +1,42 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package forms
+
+import "cloudiac/portal/models"
+
+type SearchRoleForm struct {
+	PageForm
+
+	Q     string `form:"q" json:"q" binding:""`                  // 角色名称，支持模糊查询
+	Scope string `form:"scope" json:"scope" enums:"org,project"` // 角色类型
+}
+
+type CreateRoleForm struct {
+	BaseForm
+
+	Name        string                 `form:"name" json:"name" binding:"required,max=64"`                // 角色名称
+	Scope       string                 `form:"scope" json:"scope" binding:"required" enums:"org,project"` // 角色类型，org: 组织角色，project: 项目角色
+	Description string                 `form:"description" json:"description" binding:"max=255"`          // 描述
+	Permissions models.RolePermissions `form:"permissions" json:"permissions" binding:"required"`         // 权限列表
+}
+
+type UpdateRoleForm struct {
+	BaseForm
+
+	Id          models.Id              `uri:"id" json:"id" swaggerignore:"true"`
+	Name        string                 `form:"name" json:"name" binding:"max=64"`
+	Description string                 `form:"description" json:"description" binding:"max=255"`
+	Permissions models.RolePermissions `form:"permissions" json:"permissions"`
+}
+
+type DeleteRoleForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
+
+type DetailRoleForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"`
+}
@@ -69,8 +69,28 @@ const (
 type ApproveTaskForm struct {
 	BaseForm
 
-	Id     models.Id `uri:"id" json:"id" swaggerignore:"true"`                                  // 任务ID，swagger 参数通过 param path 指定，这里忽略
-	Action string    `form:"action" json:"action" binding:"required" enums:"approved,rejected"` // 审批动作：approved通过, rejected驳回
+	Id      models.Id `uri:"id" json:"id" swaggerignore:"true"`                                  // 任务ID，swagger 参数通过 param path 指定，这里忽略
+	Action  string    `form:"action" json:"action" binding:"required" enums:"approved,rejected"` // 审批动作：approved通过, rejected驳回
+	Comment string    `form:"comment" json:"comment"`                                            // 审批意见
+}
+
+type AbortTaskForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 任务ID，swagger 参数通过 param path 指定，这里忽略
+}
+
+type ResumeTaskForm struct {
+	BaseForm
+
+	Id   models.Id `uri:"id" json:"id" swaggerignore:"true"` // 任务ID，swagger 参数通过 param path 指定，这里忽略
+	Step *int      `json:"step" form:"step"`                 // 从该步骤(index)开始恢复执行，默认为失败的步骤
+}
+
+type OverrideTaskFreezeForm struct {
+	BaseForm
+
+	Id models.Id `uri:"id" json:"id" swaggerignore:"true"` // 任务ID，swagger 参数通过 param path 指定，这里忽略
 }
 
 type SearchEnvTasksForm struct {
@@ -99,6 +119,12 @@ type GetTaskStepLogForm struct {
 	StepId models.Id `uri:"stepId" json:"stepId"` //步骤ID
 }
 
+type TaskStepArtifactsForm struct {
+	BaseForm
+	Id     models.Id `uri:"id" json:"id" swaggerignore:"true"`         // 任务ID
+	StepId models.Id `uri:"stepId" json:"stepId" swaggerignore:"true"` // 步骤ID
+}
+
 type SearchTaskResourceGraphForm struct {
 	BaseForm
 
@@ -29,6 +29,8 @@ type CreateTemplateForm struct {
 	TfVarsFile   string      `form:"tfVarsFile" json:"tfVarsFile"`
 	ProjectId    []models.Id `form:"projectId" json:"projectId"` // 项目ID
 	TfVersion    string      `form:"tfVersion" json:"tfVersion"` // 模版使用terraform版本号
+	// 模板使用的 IaC 工具，默认为 terraform
+	IacTool string `form:"iacTool" json:"iacTool" enums:"terraform,opentofu,terragrunt"`
 
 	Variables []Variable `json:"variables" form:"variables" `
 
@@ -60,6 +62,7 @@ type UpdateTemplateForm struct {
 	RepoId       string      `form:"repoId" json:"repoId" binding:""`
 	RepoFullName string      `form:"repoFullName" json:"repoFullName" binding:""`
 	TfVersion    string      `form:"tfVersion" json:"tfVersion" binding:""`
+	IacTool      string      `form:"iacTool" json:"iacTool" binding:"" enums:"terraform,opentofu,terragrunt"`
 
 	Variables []Variable `json:"variables" form:"variables" `
 
@@ -119,6 +122,7 @@ type TemplateTfVersionSearchForm struct {
 	VcsId     models.Id `json:"vcsId" form:"vcsId"`
 	VcsBranch string    `json:"vcsBranch" form:"vcsBranch"`
 	RepoId    string    `json:"repoId" form:"repoId"`
+	IacTool   string    `json:"iacTool" form:"iacTool" enums:"terraform,opentofu,terragrunt"` // 为空表示 terraform
 }
 
 type TemplateChecksForm struct {
@@ -131,3 +135,8 @@ type TemplateChecksForm struct {
 	Workdir      string    `json:"workdir" form:"path"`
 	TemplateId   models.Id `json:"templateId" form:"templateId"`
 }
+
+type PipelineValidateForm struct {
+	BaseForm
+	Content string `json:"content" form:"content" binding:"required"` // pipeline 文件内容
+}
@@ -15,6 +15,9 @@ type CreateTokenForm struct {
 	Description string    `json:"description" form:"description" `     //描述
 	EnvId       models.Id `json:"envId" form:"envId"`                  //创建触发器token时必传，其他可不传
 	Action      string    `json:"action" form:"action"`                //创建触发器token时必传，其他可不传('apply','plan','destroy')
+
+	Scope     string    `json:"scope" form:"scope" enums:"org,project,env"` // api token 作用范围，默认为 org，env 范围需要传 envId
+	ProjectId models.Id `json:"projectId" form:"projectId"`                 // 项目ID，scope 为 project 时必传
 }
 
 type UpdateTokenForm struct {
@@ -24,6 +27,12 @@ type UpdateTokenForm struct {
 	Description string    `json:"description" form:"description" ` //描述
 }
 
+type RotateTokenForm struct {
+	BaseForm
+	Id          models.Id `uri:"id" form:"id" json:"id" binding:"required"`
+	GracePeriod int       `json:"gracePeriod" form:"gracePeriod"` // 旧 token 的宽限期(分钟)，宽限期内旧 token 仍然可以使用，默认为 0 立即失效
+}
+
 type SearchTokenForm struct {
 	PageForm
 	Q      string `form:"q" json:"q" binding:""`
@@ -48,6 +57,15 @@ type LoginForm struct {
 	Password string `json:"password" form:"password" binding:"required"` // 密码
 }
 
+type OidcCallbackForm struct {
+	BaseForm
+
+	Code             string `json:"code" form:"code"`                           // authorization code
+	State            string `json:"state" form:"state"`                         // 发起登录时生成的 state
+	Error            string `json:"error" form:"error"`                         // oidc 服务返回的错误
+	ErrorDescription string `json:"error_description" form:"error_description"` // 错误详情
+}
+
 type ApiTriggerHandler struct {
 	BaseForm
 	Token string `json:"token" form:"token" binding:"required"`
@@ -0,0 +1,18 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import "time"
+
+// LeaderLock 基于数据库实现的分布式锁，持有者需要在 ExpireAt 之前续期，过期后其他实例可以获取该锁
+type LeaderLock struct {
+	AbstractModel
+
+	Name     string    `gorm:"size:64;primary_key"`
+	Holder   string    `gorm:"size:128;not null;default:''"`
+	ExpireAt time.Time `gorm:"type:datetime;not null"`
+}
+
+func (LeaderLock) TableName() string {
+	return "iac_leader_lock"
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+// MaintenanceWindow 项目维护窗口(冻结期)，
+// 窗口期间 task manager 会暂停执行项目下的 apply、destroy 任务，任务保持排队状态直到窗口结束或管理员强制执行
+type MaintenanceWindow struct {
+	TimedModel
+
+	OrgId     Id `json:"orgId" gorm:"size:32;not null;comment:组织ID"`
+	ProjectId Id `json:"projectId" gorm:"size:32;not null;index;comment:项目ID"`
+
+	Name        string `json:"name" gorm:"not null;comment:名称"`
+	Description string `json:"description" gorm:"type:text;comment:描述"`
+	CronExpress string `json:"cronExpress" gorm:"not null;comment:窗口开始时间的Cron表达式"` // 如 "0 22 * * 5" 表示每周五 22 点开始
+	Duration    int    `json:"duration" gorm:"not null;comment:窗口持续时间(分钟)"`
+	Enabled     bool   `json:"enabled" gorm:"not null;default:true;comment:是否启用"`
+	CreatorId   Id     `json:"creatorId" gorm:"size:32;not null;comment:创建人"`
+}
+
+func (MaintenanceWindow) TableName() string {
+	return "iac_maintenance_window"
+}
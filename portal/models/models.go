@@ -217,6 +217,7 @@ func Init(migrate bool) {
 	autoMigrate(&User{}, sess)
 	autoMigrate(&UserOrg{}, sess)
 	autoMigrate(&UserProject{}, sess)
+	autoMigrate(&Role{}, sess)
 
 	autoMigrate(&Notification{}, sess)
 	autoMigrate(&NotificationEvent{}, sess)
@@ -227,6 +228,14 @@ func Init(migrate bool) {
 	autoMigrate(&Token{}, sess)
 	autoMigrate(&Key{}, sess)
 	autoMigrate(&TaskComment{}, sess)
+	autoMigrate(&ApprovalPolicy{}, sess)
+	autoMigrate(&MaintenanceWindow{}, sess)
+	autoMigrate(&EnvDependency{}, sess)
+	autoMigrate(&DeployStack{}, sess)
+	autoMigrate(&EnvStateVersion{}, sess)
+	autoMigrate(&RunnerStatus{}, sess)
+	autoMigrate(&Runner{}, sess)
+	autoMigrate(&LeaderLock{}, sess)
 	autoMigrate(&ProjectTemplate{}, sess)
 	autoMigrate(&Policy{}, sess)
 	autoMigrate(&PolicyGroup{}, sess)
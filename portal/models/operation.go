@@ -4,18 +4,59 @@ package models
 
 import (
 	"cloudiac/portal/libs/db"
+	"database/sql/driver"
 )
 
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditFieldDiff 资源字段修改前后的值，敏感字段的值会被脱敏
+type AuditFieldDiff struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChange 一次操作中单个资源的修改内容
+type AuditChange struct {
+	ResourceType string           `json:"resourceType"`
+	ResourceId   Id               `json:"resourceId"`
+	Fields       []AuditFieldDiff `json:"fields"`
+}
+
+type AuditChanges []AuditChange
+
+func (v AuditChanges) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *AuditChanges) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
 type OperationLog struct {
 	BaseModel
 
-	UserID        Id     `json:"userId" form:"userId" gorm:"size:32"`
+	UserID        Id     `json:"userId" form:"userId" gorm:"size:32;index"`
 	Username      string `json:"username" form:"username" `
 	UserAddr      string `json:"userAddr" form:"userAddr" `
-	OperationAt   Time   `json:"operationAt"  gorm:"type:datetime" form:"operationAt" `
+	OperationAt   Time   `json:"operationAt"  gorm:"type:datetime;index" form:"operationAt" `
 	OperationType string `json:"operationType" form:"operationType" `
 	OperationInfo string `json:"operationInfo" form:"operationInfo" `
 	Desc          JSON   `json:"desc" form:"desc" gorm:"type:text"`
+
+	OrgId        Id           `json:"orgId" gorm:"size:32;default:'';index;comment:组织ID"`
+	ProjectId    Id           `json:"projectId" gorm:"size:32;default:'';index;comment:项目ID"`
+	Action       string       `json:"action" gorm:"size:16;default:'';comment:操作类型(create/update/delete)"`
+	ResourceType string       `json:"resourceType" gorm:"size:32;default:'';comment:资源类型"`
+	ResourceId   Id           `json:"resourceId" gorm:"size:64;default:'';index;comment:资源ID"`
+	Method       string       `json:"method" gorm:"size:8;default:''"`
+	Path         string       `json:"path" gorm:"size:255;default:'';comment:请求路径"`
+	StatusCode   int          `json:"statusCode" gorm:"default:0;comment:响应状态码"`
+	Changes      AuditChanges `json:"changes" gorm:"type:json;null;comment:资源修改前后的字段差异"`
 }
 
 func (o *OperationLog) InsertLog() error {
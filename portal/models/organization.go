@@ -23,6 +23,8 @@ type Organization struct {
 	RunnerId    string `json:"runnerId" gorm:"not null" example:"runner-01"`                                                            // 组织默认部署通道
 
 	IsDemo bool `json:"isDemo,omitempty" gorm:"default:false"` // 是否演示组织
+
+	MaxConcurrentTasks int `json:"maxConcurrentTasks" gorm:"default:0;comment:并发任务数限制"` // 组织同时执行的部署任务数量限制，0 表示不限制
 }
 
 func (Organization) TableName() string {
@@ -2,7 +2,10 @@
 
 package models
 
-import "cloudiac/portal/libs/db"
+import (
+	"cloudiac/common"
+	"cloudiac/portal/libs/db"
+)
 
 type Project struct {
 	SoftDeleteModel
@@ -12,6 +15,10 @@ type Project struct {
 	Description string `json:"description" gorm:"type:text"`      //组织详情
 	CreatorId   Id     `json:"creatorId" form:"creatorId" `       //用户id
 	Status      string `json:"status" gorm:"type:enum('enable','disable');default:'enable';comment:状态"`
+
+	MaxConcurrentTasks int `json:"maxConcurrentTasks" gorm:"default:0;comment:并发任务数限制"` // 项目同时执行的部署任务数量限制，0 表示不限制
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits" gorm:"type:json"` // 任务容器资源限制及隔离配置
 }
 
 func (Project) TableName() string {
@@ -0,0 +1,49 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import (
+	"cloudiac/portal/libs/db"
+	"database/sql/driver"
+)
+
+// RolePermission 角色对资源的操作权限，对应 rbac 策略中的 obj 和 act
+type RolePermission struct {
+	Obj string `json:"obj" binding:"required"` // 资源名称，如 envs、tasks
+	Act string `json:"act" binding:"required"` // 动作，如 read、deploy，* 表示所有动作
+}
+
+type RolePermissions []RolePermission
+
+func (v RolePermissions) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *RolePermissions) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
+// Role 组织自定义角色，org 角色可以分配给组织成员及 api token，project 角色可以分配给项目成员。
+// 分配角色时保存的是角色 id，rbac 中同样以角色 id 作为策略的 sub
+type Role struct {
+	TimedModel
+
+	OrgId       Id              `json:"orgId" gorm:"size:32;not null;comment:组织ID"`
+	Name        string          `json:"name" gorm:"size:64;not null;comment:角色名称"`
+	Scope       string          `json:"scope" gorm:"type:enum('org','project');not null;comment:角色类型"`
+	Description string          `json:"description" gorm:"type:text;comment:描述"`
+	Permissions RolePermissions `json:"permissions" gorm:"type:json;comment:权限列表"`
+	CreatorId   Id              `json:"creatorId" gorm:"size:32;not null;comment:创建人"`
+}
+
+func (Role) TableName() string {
+	return "iac_role"
+}
+
+func (Role) NewId() Id {
+	return NewId("role")
+}
+
+func (r Role) Migrate(sess *db.Session) error {
+	return r.AddUniqueIndex(sess, "unique__org__name", "org_id", "name")
+}
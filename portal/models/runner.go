@@ -0,0 +1,39 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/lib/pq"
+)
+
+// Runner 通过接口注册到数据库的 runner(registry 配置为 db 时使用)
+type Runner struct {
+	AbstractModel
+
+	Id      string         `json:"id" gorm:"size:64;primary_key"`
+	Service string         `json:"service" gorm:"size:64"` // 服务名称
+	Address string         `json:"address" gorm:"size:255"`
+	Port    int            `json:"port"`
+	Tags    pq.StringArray `json:"tags" gorm:"type:text" swaggertype:"array,string"`
+
+	// tags 是否通过接口修改过，修改过之后 runner 重新注册时不再覆盖 tags
+	TagsUpdated bool `json:"-" gorm:"default:false"`
+
+	CreatedAt Time `json:"createdAt" gorm:"type:datetime"`
+	UpdatedAt Time `json:"updatedAt" gorm:"type:datetime"` // 最后一次注册的时间
+}
+
+func (Runner) TableName() string {
+	return "iac_runner"
+}
+
+func (r *Runner) AgentService() *api.AgentService {
+	return &api.AgentService{
+		ID:      r.Id,
+		Service: r.Service,
+		Tags:    r.Tags,
+		Address: r.Address,
+		Port:    r.Port,
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import (
+	"cloudiac/utils"
+
+	"github.com/lib/pq"
+)
+
+// RunnerStatus portal 通过心跳获取的 runner 状态，由 task manager 定时更新
+type RunnerStatus struct {
+	AbstractModel
+
+	RunnerId string         `json:"runnerId" gorm:"size:64;primary_key"`
+	Tags     pq.StringArray `json:"tags" gorm:"type:text" swaggertype:"array,string"` // runner 的 tags(consul service tags)
+	Version  string         `json:"version" gorm:"size:64"`
+
+	Healthy     bool   `json:"healthy" gorm:"default:false"` // 是否健康，不健康的 runner 不会被分配任务
+	Message     string `json:"message" gorm:"type:text"`     // 不健康的原因
+	FailedCount int    `json:"failedCount" gorm:"default:0"` // 连续心跳失败次数
+	MaxTasks    int    `json:"maxTasks" gorm:"default:0"`    // runner 上报的最大并发任务数，0 表示使用全局配置
+	Running     int    `json:"running" gorm:"-"`             // 正在执行的任务数量(不保存)
+
+	CPUs            int     `json:"cpus" gorm:"default:0"`
+	LoadAvg         float64 `json:"loadAvg" gorm:"default:0"`
+	DiskTotal       uint64  `json:"diskTotal" gorm:"default:0"` // storage_path 所在磁盘的总空间(bytes)
+	DiskFree        uint64  `json:"diskFree" gorm:"default:0"`  // storage_path 所在磁盘的可用空间(bytes)
+	ExecutorHealthy bool    `json:"executorHealthy" gorm:"default:false"`
+
+	LastHeartbeatAt *Time `json:"lastHeartbeatAt" gorm:"type:datetime"` // 最后一次心跳成功的时间
+	UpdatedAt       Time  `json:"updatedAt" gorm:"type:datetime"`
+}
+
+func (RunnerStatus) TableName() string {
+	return "iac_runner_status"
+}
+
+// HasTags 判断 runner 是否包含所有给定的 tags
+func (s *RunnerStatus) HasTags(tags []string) bool {
+	for _, t := range tags {
+		if !utils.StrInArray(t, s.Tags...) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package models
+
+import "database/sql/driver"
+
+const (
+	StateBackendConsul = "consul"
+	StateBackendS3     = "s3"
+	StateBackendHttp   = "http"
+	StateBackendLocal  = "local"
+)
+
+var StateBackends = []string{StateBackendConsul, StateBackendS3, StateBackendHttp, StateBackendLocal}
+
+// StateBackendConfig 环境 terraform state 存储配置(不包含认证信息)，
+// 未配置的字段使用默认值，state 文件路径(s3 key、local path)统一使用 env.StatePath
+type StateBackendConfig struct {
+	// s3 (兼容 minio 等 s3 协议的存储)
+	Bucket         string `json:"bucket,omitempty"`
+	Region         string `json:"region,omitempty"`
+	Endpoint       string `json:"endpoint,omitempty"`       // 自定义 endpoint，使用 minio 时需要配置
+	ForcePathStyle bool   `json:"forcePathStyle,omitempty"` // 使用 path style 访问 bucket，minio 需要开启
+
+	// http
+	Address       string `json:"address,omitempty"`
+	LockAddress   string `json:"lockAddress,omitempty"`
+	UnlockAddress string `json:"unlockAddress,omitempty"`
+}
+
+func (v StateBackendConfig) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *StateBackendConfig) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
+// StateBackendSecret state 存储的认证信息，加密后保存，任务执行时以系统环境变量的方式传入
+type StateBackendSecret struct {
+	AccessKey string `json:"accessKey,omitempty"` // s3
+	SecretKey string `json:"secretKey,omitempty"` // s3
+	Username  string `json:"username,omitempty"`  // http
+	Password  string `json:"password,omitempty"`  // http
+}
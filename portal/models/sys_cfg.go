@@ -9,6 +9,8 @@ import (
 const (
 	SysCfgNameMaxJobsPerRunner = "MAX_JOBS_PER_RUNNER"
 	SysCfgNamePeriodOfLogSave  = "PERIOD_OF_LOG_SAVE"
+
+	SysCfgNamePeriodOfAuditLogSave = "PERIOD_OF_AUDIT_LOG_SAVE"
 )
 
 type SystemCfg struct {
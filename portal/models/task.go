@@ -9,6 +9,8 @@ import (
 	"cloudiac/utils"
 	"database/sql/driver"
 	"path"
+
+	"github.com/lib/pq"
 )
 
 type TaskVariables []VariableBody
@@ -67,12 +69,16 @@ const (
 	TaskTypeScan    = common.TaskTypeScan
 	TaskTypeParse   = common.TaskTypeParse
 
+	TaskTypeStateQuery = common.TaskTypeStateQuery
+	TaskTypeStateEdit  = common.TaskTypeStateEdit
+
 	TaskPending   = common.TaskPending
 	TaskRunning   = common.TaskRunning
 	TaskApproving = common.TaskApproving
 	TaskRejected  = common.TaskRejected
 	TaskFailed    = common.TaskFailed
 	TaskComplete  = common.TaskComplete
+	TaskAborted   = common.TaskAborted
 )
 
 type Tasker interface {
@@ -108,6 +114,7 @@ type Task struct {
 	Playbook     string   `json:"playbook" gorm:"default:''"`
 	TfVarsFile   string   `json:"tfVarsFile" gorm:"default:''"`
 	TfVersion    string   `json:"tfVersion" gorm:"default:''"`
+	IacTool      string   `json:"iacTool" gorm:"size:32;default:''"` // 使用的 IaC 工具，为空表示 terraform
 	PlayVarsFile string   `json:"playVarsFile" gorm:"default:''"`
 	Targets      StrSlice `json:"targets" gorm:"type:json"` // 指定 terraform target 参数
 
@@ -115,6 +122,9 @@ type Task struct {
 
 	StatePath string `json:"statePath" gorm:"not null"`
 
+	// 通过 runner tags 调度的任务，创建时 RunnerId 为空，由 task manager 执行任务时选择 runner
+	RunnerTags pq.StringArray `json:"runnerTags" gorm:"type:text" swaggertype:"array,string"`
+
 	// 扩展属性，包括 source, transitionId 等
 	ExtraData JSON `json:"extraData" gorm:"type:json"` // 扩展字段，用于存储外部服务调用时的信息
 
@@ -130,8 +140,23 @@ type Task struct {
 	RetryAble   bool   `json:"retryAble" gorm:"default:false"`
 	Callback    string `json:"callback" gorm:"default:''"`       // 外部请求的回调方式
 	IsDriftTask bool   `json:"isDritfTask" gorm:"default:false"` // 是否是偏移检测任务
+
+	Priority int `json:"priority" gorm:"default:0;comment:任务优先级"` // 任务优先级，值越大越先执行
+
+	FreezeOverride bool `json:"freezeOverride" gorm:"default:false"` // 是否忽略项目维护窗口(冻结期)限制，由管理员设置
+
+	StackId Id `json:"stackId,omitempty" gorm:"size:32;default:''"` // 环境栈部署记录 id
 }
 
+// 任务优先级，手动触发 > webhook(含 api token 触发) > 定时部署 > 偏移检测 > 自动销毁
+const (
+	TaskPriorityAutoDestroy = 10
+	TaskPriorityCronDrift   = 20
+	TaskPriorityCronDeploy  = 25
+	TaskPriorityWebhook     = 30
+	TaskPriorityManual      = 40
+)
+
 func (Task) TableName() string {
 	return "iac_task"
 }
@@ -170,7 +195,7 @@ func (BaseTask) IsStartedStatus(status string) bool {
 }
 
 func (BaseTask) IsExitedStatus(status string) bool {
-	return utils.InArrayStr([]string{TaskFailed, TaskRejected, TaskComplete}, status)
+	return utils.InArrayStr([]string{TaskFailed, TaskRejected, TaskComplete, TaskAborted}, status)
 }
 
 func (t *BaseTask) IsEffectTask() bool {
@@ -182,6 +207,16 @@ func (BaseTask) IsEffectTaskType(typ string) bool {
 	return utils.StrInArray(typ, TaskTypeApply, TaskTypeDestroy)
 }
 
+// IsStateTaskType 是否为 state 管理任务(state list/show/mv/rm、import)
+func (BaseTask) IsStateTaskType(typ string) bool {
+	return utils.StrInArray(typ, TaskTypeStateQuery, TaskTypeStateEdit)
+}
+
+// IsCollectTask 任务结束后是否需要执行信息采集步骤(统计资源及 outputs)
+func (t *BaseTask) IsCollectTask() bool {
+	return t.IsEffectTask() || t.Type == TaskTypeStateEdit
+}
+
 func (BaseTask) GetTaskNameByType(typ string) string {
 	switch typ {
 	case TaskTypePlan:
@@ -194,6 +229,10 @@ func (BaseTask) GetTaskNameByType(typ string) string {
 		return common.TaskTypeScanName
 	case TaskTypeParse:
 		return common.TaskTypeParse
+	case TaskTypeStateQuery:
+		return common.TaskTypeStateQueryName
+	case TaskTypeStateEdit:
+		return common.TaskTypeStateEditName
 	default:
 		panic("invalid task type")
 	}
@@ -203,6 +242,11 @@ func (t *Task) StateJsonPath() string {
 	return path.Join(t.ProjectId.String(), t.EnvId.String(), t.Id.String(), runner.TFStateJsonFile)
 }
 
+// TfStatePath terraform state pull 导出的 state 文件路径，用于保存 state 版本
+func (t *Task) TfStatePath() string {
+	return path.Join(t.ProjectId.String(), t.EnvId.String(), t.Id.String(), runner.TFStateFile)
+}
+
 func (t *Task) ProviderSchemaJsonPath() string {
 	return path.Join(t.ProjectId.String(), t.EnvId.String(), t.Id.String(), runner.TFProviderSchema)
 }
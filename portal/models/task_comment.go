@@ -10,6 +10,10 @@ type TaskComment struct {
 	Creator   string `json:"creator" form:"creator" gorm:"size:32;not null;comment:评论人"`
 	CreatorId Id     `json:"creatorId" form:"creatorId" gorm:"size:32;not null;comment:评论人id"`
 	Comment   string `json:"comment" form:"comment"  gorm:"type:text;comment:评论"`
+
+	// 审批记录
+	StepId Id     `json:"stepId,omitempty" gorm:"size:32;not null;default:'';comment:审批步骤id"`
+	Action string `json:"action,omitempty" gorm:"size:16;not null;default:'';comment:审批动作"`
 }
 
 func (TaskComment) TableName() string {
@@ -5,6 +5,7 @@ import (
 	"cloudiac/common"
 	"database/sql/driver"
 	"fmt"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -54,6 +55,72 @@ type PipelineStep struct {
 	Type string   `json:"type,omitempty" yaml:"type" gorm:"size:32;not null"`
 	Name string   `json:"name,omitempty" yaml:"name" gorm:"size:32;not null"`
 	Args StrSlice `json:"args,omitempty" yaml:"args" gorm:"type:text"`
+
+	// 以下字段从 pipeline 0.4 版本开始支持
+	Image           string           `json:"image,omitempty" yaml:"image" gorm:"default:''"`                        // 步骤使用的镜像，配置后步骤在独立的容器中执行
+	Env             PipelineStepEnv  `json:"env,omitempty" yaml:"env" gorm:"type:json"`                             // 步骤的环境变量
+	Timeout         int              `json:"timeout,omitempty" yaml:"timeout" gorm:"default:0"`                     // 步骤超时时间(秒)，0 表示使用环境的配置
+	ContinueOnError bool             `json:"continueOnError,omitempty" yaml:"continueOnError" gorm:"default:false"` // 步骤失败后继续执行，且不影响任务状态
+	When            PipelineStepWhen `json:"when" yaml:"when" gorm:"type:json"`                                     // 步骤的执行条件
+
+	// 步骤执行成功后保存的文件或目录(相对代码工作目录)，保存后可以通过接口下载
+	Artifacts StrSlice `json:"artifacts,omitempty" yaml:"artifacts" gorm:"type:text"`
+	// 步骤的缓存，执行前恢复，执行成功后保存，同一环境的任务间共享
+	Cache PipelineStepCache `json:"cache" yaml:"cache" gorm:"type:json"`
+
+	// 步骤的重试策略，未配置时使用环境的重试配置。创建步骤时转换为 TaskStep 的 RetryNumber、RetryDelay，不单独保存
+	Retry *PipelineStepRetry `json:"retry,omitempty" yaml:"retry" gorm:"-"`
+}
+
+// PipelineStepRetry 步骤执行失败(包括启动失败及超时)后的重试策略，只重新执行失败的步骤
+type PipelineStepRetry struct {
+	Count int `json:"count" yaml:"count"` // 最大重试次数，0 表示不重试
+	Delay int `json:"delay" yaml:"delay"` // 重试间隔，单位为秒
+}
+
+const (
+	PipelineWhenSuccess = "success" // 之前的步骤都执行成功时执行(默认)
+	PipelineWhenFailure = "failure" // 之前有步骤执行失败时执行
+	PipelineWhenAlways  = "always"  // 总是执行
+)
+
+// PipelineStepWhen 步骤的执行条件，所有条件都满足时才执行步骤，否则跳过
+type PipelineStepWhen struct {
+	Status  string   `json:"status,omitempty" yaml:"status"`   // 之前步骤的执行结果: success、failure、always
+	Branch  []string `json:"branch,omitempty" yaml:"branch"`   // 任务的分支/标签匹配任意一项时执行，支持通配符
+	Changes *bool    `json:"changes,omitempty" yaml:"changes"` // true: plan 有资源变更时执行; false: 无资源变更时执行
+}
+
+func (v PipelineStepWhen) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *PipelineStepWhen) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
+// PipelineStepCache 步骤缓存配置，相同 key 的缓存在环境的任务间共享
+type PipelineStepCache struct {
+	Key   string   `json:"key,omitempty" yaml:"key"`
+	Paths []string `json:"paths,omitempty" yaml:"paths"` // 缓存的目录或文件(相对代码工作目录)
+}
+
+func (v PipelineStepCache) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *PipelineStepCache) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
+}
+
+type PipelineStepEnv map[string]string
+
+func (v PipelineStepEnv) Value() (driver.Value, error) {
+	return MarshalValue(v)
+}
+
+func (v *PipelineStepEnv) Scan(value interface{}) error {
+	return UnmarshalValue(value, v)
 }
 
 func (v PipelineTask) Value() (driver.Value, error) {
@@ -130,11 +197,15 @@ parse:
     - type: regoParse 
 `
 
+// 0.4 版本的默认流程与 0.3 一致，新增了步骤的 when、image、env、timeout、continueOnError、artifacts、cache 配置
+var pipelineV0dot4 = strings.Replace(pipelineV0dot3, "version: 0.3", "version: 0.4", 1)
+
 const defaultPipelineVersion = "0.3"
 
 var (
 	defaultPipelineTpls = map[string]string{
 		"0.3": pipelineV0dot3,
+		"0.4": pipelineV0dot4,
 	}
 	defaultPipelines = make(map[string]Pipeline)
 )
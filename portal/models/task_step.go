@@ -21,6 +21,13 @@ const (
 	TaskStepOpaScan   = common.TaskStepOpaScan
 	TaskStepScanInit  = common.TaskStepScanInit
 
+	TaskStepStateList = common.TaskStepTfStateList
+	TaskStepStateShow = common.TaskStepTfStateShow
+	TaskStepStateMv   = common.TaskStepTfStateMv
+	TaskStepStateRm   = common.TaskStepTfStateRm
+	TaskStepImport    = common.TaskStepTfImport
+	TaskStepStatePush = common.TaskStepTfStatePush
+
 	TaskStepPending   = common.TaskStepPending
 	TaskStepApproving = common.TaskStepApproving
 	TaskStepRejected  = common.TaskStepRejected
@@ -28,6 +35,8 @@ const (
 	TaskStepFailed    = common.TaskStepFailed
 	TaskStepComplete  = common.TaskStepComplete
 	TaskStepTimeout   = common.TaskStepTimeout
+	TaskStepAborted   = common.TaskStepAborted
+	TaskStepSkipped   = common.TaskStepSkipped
 )
 
 type TaskStep struct {
@@ -40,7 +49,7 @@ type TaskStep struct {
 	TaskId    Id     `json:"taskId" gorm:"size:32;not null"`
 	NextStep  Id     `json:"nextStep" gorm:"size:32;default:''"`
 	Index     int    `json:"index" gorm:"size:32;not null"`
-	Status    string `json:"status" gorm:"type:enum('pending','approving','rejected','running','failed','complete','timeout')"`
+	Status    string `json:"status" gorm:"type:enum('pending','approving','rejected','running','failed','complete','timeout','aborted','skipped')"`
 	ExitCode  int    `json:"exitCode" gorm:"default:0"` // 执行退出码，status 为 failed 时才有意义
 	Message   string `json:"message" gorm:"type:text"`
 	StartAt   *Time  `json:"startAt" gorm:"type:datetime"`
@@ -50,9 +59,12 @@ type TaskStep struct {
 	MustApproval bool `json:"requireApproval" gorm:""`            // 步骤需要审批
 	ApproverId   Id   `json:"approverId" gorm:"size:32;not null"` // 审批者用户 id
 
+	ApprovalExpireAt *Time `json:"approvalExpireAt,omitempty" gorm:"type:datetime"` // 审批超时时间，超时后自动驳回
+
 	CurrentRetryCount int   `json:"currentRetryCount" gorm:"size:32;default:0"` // 当前重试次数
 	NextRetryTime     int64 `json:"nextRetryTime" gorm:"default:0"`             // 下次重试时间
 	RetryNumber       int   `json:"retryNumber" gorm:"size:32;default:0"`       // 每个步骤可以重试的总次数
+	RetryDelay        int   `json:"retryDelay" gorm:"default:0"`                // 每次重试的间隔时间，单位为秒
 
 	IsCallback bool `json:"isCallback" gorm:"default:0"` // 步骤是否为回调
 }
@@ -65,6 +77,9 @@ func (t *TaskStep) Migrate(sess *db.Session) (err error) {
 	if err := sess.ModifyModelColumn(t, "type"); err != nil {
 		return err
 	}
+	if err := sess.ModifyModelColumn(t, "status"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -77,7 +92,8 @@ func (s *TaskStep) IsExited() bool {
 }
 
 func (TaskStep) IsExitedStatus(status string) bool {
-	return utils.StrInArray(status, TaskStepRejected, TaskStepComplete, TaskStepFailed, TaskStepTimeout)
+	return utils.StrInArray(status, TaskStepRejected, TaskStepComplete, TaskStepFailed, TaskStepTimeout, TaskStepAborted,
+		TaskStepSkipped)
 }
 
 // 执行成功
@@ -104,6 +120,28 @@ func (s *TaskStep) IsRejected() bool {
 	return s.Status == TaskStepRejected
 }
 
+func (s *TaskStep) IsAborted() bool {
+	return s.Status == TaskStepAborted
+}
+
+// GetTimeout 返回步骤的超时时间(秒)，步骤未配置时使用任务的超时时间
+func (s *TaskStep) GetTimeout(taskTimeout int) int {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return taskTimeout
+}
+
+// ArtifactsPath 步骤 artifacts 打包文件在 logstorage 中的路径
+func (s *TaskStep) ArtifactsPath() string {
+	return path.Join(path.Dir(s.GenLogPath()), runner.StepArtifactsFile)
+}
+
+// CachePath 步骤缓存在 logstorage 中的路径，同一环境相同 key 的缓存共享
+func (s *TaskStep) CachePath() string {
+	return path.Join(s.ProjectId.String(), s.EnvId.String(), "cache", s.Cache.Key, runner.StepCacheFile)
+}
+
 func (s *TaskStep) GenLogPath() string {
 	return path.Join(
 		s.ProjectId.String(),
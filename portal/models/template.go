@@ -37,6 +37,8 @@ type Template struct {
 	LastScanTaskId Id `json:"lastScanTaskId" gorm:"size:32"` // 最后一次策略扫描任务 id
 
 	TfVersion string `json:"tfVersion" gorm:"default:''"` // 模版使用的terraform版本号
+	// 模板使用的 IaC 工具，选择 opentofu 时 TfVersion 为 tofu 的版本号，terragrunt 使用 TfVersion 指定的 terraform 版本
+	IacTool string `json:"iacTool" gorm:"size:32;default:'terraform'" enums:"'terraform','opentofu','terragrunt'"`
 }
 
 func (Template) TableName() string {
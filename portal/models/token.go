@@ -4,6 +4,8 @@ package models
 
 import (
 	"cloudiac/portal/libs/db"
+	"crypto/sha256"
+	"encoding/hex"
 )
 
 type Token struct {
@@ -21,6 +23,15 @@ type Token struct {
 	// 触发器需要的字段
 	EnvId  Id     `json:"envId" form:"envId"  gorm:"not null"`
 	Action string `json:"action" form:"action" gorm:"type:enum('apply','plan','destroy');default:'plan'"`
+
+	// api token 的作用范围，project、env 范围的 token 只能访问对应的项目或环境(EnvId)
+	Scope     string `json:"scope" gorm:"type:enum('org','project','env');default:'org';comment:作用范围"`
+	ProjectId Id     `json:"projectId" gorm:"size:32;default:'';comment:项目ID"`
+
+	// api token 的 Key 保存的是 token 的 sha256 值，轮换后旧 token 在 PrevKeyExpiredAt 之前仍然可以使用
+	PrevKey          string `json:"-" gorm:"size:64;default:'';index;comment:轮换前的token"`
+	PrevKeyExpiredAt *Time  `json:"prevKeyExpiredAt" gorm:"type:datetime;comment:轮换前的token过期时间"`
+	LastUsedAt       *Time  `json:"lastUsedAt" gorm:"type:datetime;comment:最后使用时间"`
 }
 
 func (Token) TableName() string {
@@ -36,6 +47,24 @@ func (o Token) Migrate(sess *db.Session) (err error) {
 	if err != nil {
 		return err
 	}
+
+	// api token 改为保存 sha256 值，转换历史数据中的明文 token(uuid 格式)，转换后不能还原，但原 token 仍然可以使用。
+	// 升级前的 api token 都按组织管理员鉴权，转换为未指定角色的组织 token 以保持原有权限
+	tokens := make([]Token, 0)
+	if err = sess.Model(&Token{}).Where("`type` = ? AND LENGTH(`key`) != 64", "api").Find(&tokens); err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		sum := sha256.Sum256([]byte(t.Key))
+		if _, err = sess.Model(&Token{}).Where("id = ?", t.Id).UpdateAttrs(Attrs{
+			"key":        hex.EncodeToString(sum[:]),
+			"scope":      "org",
+			"role":       "",
+			"project_id": "",
+		}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
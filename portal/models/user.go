@@ -16,6 +16,9 @@ type User struct {
 	IsAdmin     bool   `json:"isAdmin" gorm:"default:false;comment:是否为系统管理员" example:"false"`                                                     // 是否为系统管理员
 	Status      string `json:"status" gorm:"type:enum('enable','disable');default:'enable';comment:用户状态" enums:"enable,disable" example:"enable"` // 用户状态
 	NewbieGuide JSON   `json:"newbieGuide" gorm:"type:json;null;comment:新手引导状态" swaggertype:"string" example:"{\"1\"}"`                           // 新手引导状态
+
+	// 用户来源，为空表示本地用户，ldap 用户只能通过 ldap 认证
+	Source string `json:"source" gorm:"size:16;default:'';comment:用户来源"`
 }
 
 func (User) TableName() string {
@@ -7,9 +7,9 @@ import "cloudiac/portal/libs/db"
 type UserOrg struct {
 	BaseModel
 
-	UserId Id     `json:"userId" gorm:"size:32;not null;comment:用户ID"`              // 用户ID
-	OrgId  Id     `json:"orgId" gorm:"size:32;not null;comment:组织ID"`               // 组织ID
-	Role   string `json:"role" gorm:"type:enum('admin','member');default:'member'"` // 角色
+	UserId Id     `json:"userId" gorm:"size:32;not null;comment:用户ID"`     // 用户ID
+	OrgId  Id     `json:"orgId" gorm:"size:32;not null;comment:组织ID"`      // 组织ID
+	Role   string `json:"role" gorm:"size:32;default:'member';comment:角色"` // 角色，内置角色(admin、member)或自定义角色 id
 }
 
 func (UserOrg) TableName() string {
@@ -17,6 +17,10 @@ func (UserOrg) TableName() string {
 }
 
 func (m UserOrg) Migrate(sess *db.Session) (err error) {
+	// 支持自定义角色后 role 字段由 enum 改为 varchar
+	if err = sess.ModifyModelColumn(&m, "role"); err != nil {
+		return err
+	}
 	err = m.AddUniqueIndex(sess, "unique__org_id__user_id", "org_id", "user_id")
 	if err != nil {
 		return err
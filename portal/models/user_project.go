@@ -11,7 +11,7 @@ type UserProject struct {
 
 	UserId    Id     `json:"userId" gorm:"size:32;not null;comment:用户ID"`
 	ProjectId Id     `json:"projectId" gorm:"size:32;not null"`
-	Role      string `json:"role" gorm:"type:enum('manager','approver','operator','guest');default:'operator';comment:角色"`
+	Role      string `json:"role" gorm:"size:32;default:'operator';comment:角色"` // 内置角色(manager、approver、operator、guest)或自定义角色 id
 }
 
 func (UserProject) TableName() string {
@@ -19,5 +19,9 @@ func (UserProject) TableName() string {
 }
 
 func (u UserProject) Migrate(sess *db.Session) error {
+	// 支持自定义角色后 role 字段由 enum 改为 varchar
+	if err := sess.ModifyModelColumn(&u, "role"); err != nil {
+		return err
+	}
 	return u.AddUniqueIndex(sess, "unique__user__project", "user_id", "project_id")
 }
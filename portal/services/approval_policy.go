@@ -0,0 +1,214 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"cloudiac/utils"
+	"fmt"
+	"time"
+)
+
+func CreateApprovalPolicy(tx *db.Session, policy models.ApprovalPolicy) (*models.ApprovalPolicy, e.Error) {
+	if policy.Id == "" {
+		policy.Id = models.NewId("ap")
+	}
+	if err := models.Create(tx, &policy); err != nil {
+		if e.IsDuplicate(err) {
+			return nil, e.New(e.ApprovalPolicyAlreadyExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &policy, nil
+}
+
+func UpdateApprovalPolicy(tx *db.Session, id models.Id, attrs models.Attrs) (*models.ApprovalPolicy, e.Error) {
+	if _, err := models.UpdateAttr(tx.Where("id = ?", id), &models.ApprovalPolicy{}, attrs); err != nil {
+		if e.IsDuplicate(err) {
+			return nil, e.New(e.ApprovalPolicyAlreadyExists, err)
+		}
+		return nil, e.New(e.DBError, fmt.Errorf("update approval policy error: %v", err))
+	}
+	return GetApprovalPolicyById(tx, id)
+}
+
+func DeleteApprovalPolicy(tx *db.Session, id models.Id) e.Error {
+	if _, err := tx.Where("id = ?", id).Delete(&models.ApprovalPolicy{}); err != nil {
+		return e.New(e.DBError, fmt.Errorf("delete approval policy error: %v", err))
+	}
+	return nil
+}
+
+func GetApprovalPolicyById(query *db.Session, id models.Id) (*models.ApprovalPolicy, e.Error) {
+	policy := models.ApprovalPolicy{}
+	if err := query.Where("id = ?", id).First(&policy); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.ApprovalPolicyNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &policy, nil
+}
+
+func SearchApprovalPolicy(query *db.Session, projectId models.Id, envId models.Id) *db.Session {
+	query = query.Model(&models.ApprovalPolicy{}).Where("project_id = ?", projectId)
+	if envId != "" {
+		query = query.Where("env_id = ?", envId)
+	}
+	return query.Order("created_at DESC")
+}
+
+// GetTaskApprovalPolicy 获取任务适用的审批策略，环境的策略优先于项目的策略，未配置策略时返回 nil
+func GetTaskApprovalPolicy(query *db.Session, task *models.Task) (*models.ApprovalPolicy, e.Error) {
+	policies := make([]*models.ApprovalPolicy, 0)
+	if err := query.Model(&models.ApprovalPolicy{}).
+		Where("project_id = ? AND env_id IN (?)", task.ProjectId, []models.Id{task.EnvId, ""}).
+		Order("env_id DESC").Find(&policies); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	return policies[0], nil
+}
+
+// CheckTaskApprover 检查用户是否可以审批任务的步骤，projectRole 为用户在任务所属项目中的角色
+func CheckTaskApprover(policy *models.ApprovalPolicy, task *models.Task, step *models.TaskStep,
+	userId models.Id, projectRole string) e.Error {
+	if policy == nil {
+		return nil
+	}
+	if !policy.AllowSelfApproval && task.CreatorId == userId {
+		return e.New(e.TaskApproveSelf)
+	}
+
+	ids, roles := policy.Approvers(step.Type)
+	if len(ids) == 0 && len(roles) == 0 {
+		return nil
+	}
+	if utils.StrInArray(userId.String(), ids...) ||
+		(projectRole != "" && utils.StrInArray(projectRole, roles...)) {
+		return nil
+	}
+	return e.New(e.TaskApproverNotAllow)
+}
+
+// GetTaskStepApprovals 查询步骤的审批通过记录
+func GetTaskStepApprovals(query *db.Session, stepId models.Id) ([]*models.TaskComment, e.Error) {
+	comments := make([]*models.TaskComment, 0)
+	if err := query.Model(&models.TaskComment{}).
+		Where("step_id = ? AND action = ?", stepId, forms.TaskActionApproved).
+		Order("created_at").Find(&comments); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return comments, nil
+}
+
+// LockTaskStep 在事务中锁定步骤记录，用于串行化同一步骤的并发审批
+func LockTaskStep(tx *db.Session, stepId models.Id) e.Error {
+	var id string
+	if err := tx.Raw(fmt.Sprintf("SELECT id FROM %s WHERE id = ? FOR UPDATE",
+		models.TaskStep{}.TableName()), stepId).Row().Scan(&id); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
+
+// GetApprovalExpireAt 根据审批策略计算审批超时时间，不超时返回 nil
+func GetApprovalExpireAt(policy *models.ApprovalPolicy, from time.Time) *models.Time {
+	if policy == nil || policy.ExpireMinutes <= 0 {
+		return nil
+	}
+	t := models.Time(from.Add(time.Duration(policy.ExpireMinutes) * time.Minute))
+	return &t
+}
+
+// RequiredApprovals 审批通过需要的人数
+func RequiredApprovals(policy *models.ApprovalPolicy) int {
+	if policy == nil || policy.RequiredApprovals < 1 {
+		return 1
+	}
+	return policy.RequiredApprovals
+}
+
+type TaskApprover struct {
+	UserId    models.Id   `json:"userId"`
+	Username  string      `json:"username"`
+	Comment   string      `json:"comment"`
+	CreatedAt models.Time `json:"createdAt"`
+}
+
+// TaskApprovalStatus 任务当前步骤的审批进度
+type TaskApprovalStatus struct {
+	StepId            models.Id       `json:"stepId"`
+	Required          int             `json:"required"`  // 需要的审批通过人数
+	Remaining         int             `json:"remaining"` // 还需要的审批通过人数
+	Approved          []*TaskApprover `json:"approved"`  // 已审批通过的用户
+	ApproverIds       []string        `json:"approverIds"`
+	ApproverRoles     []string        `json:"approverRoles"`
+	AllowSelfApproval bool            `json:"allowSelfApproval"`
+	ExpireAt          *models.Time    `json:"expireAt,omitempty"`
+}
+
+// GetTaskApprovalStatus 查询待审批任务的审批进度，任务不在待审批状态时返回 nil
+func GetTaskApprovalStatus(query *db.Session, task *models.Task) (*TaskApprovalStatus, e.Error) {
+	if task.Status != models.TaskApproving {
+		return nil, nil
+	}
+
+	step, err := GetTaskStep(query, task.Id, task.CurrStep)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := GetTaskApprovalPolicy(query, task)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := GetTaskStepApprovals(query, step.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TaskApprovalStatus{
+		StepId:            step.Id,
+		Required:          RequiredApprovals(policy),
+		Approved:          make([]*TaskApprover, 0, len(approvals)),
+		AllowSelfApproval: policy == nil || policy.AllowSelfApproval,
+		ExpireAt:          step.ApprovalExpireAt,
+	}
+	if policy != nil {
+		status.ApproverIds, status.ApproverRoles = policy.Approvers(step.Type)
+	}
+	for _, a := range approvals {
+		status.Approved = append(status.Approved, &TaskApprover{
+			UserId:    a.CreatorId,
+			Username:  a.Creator,
+			Comment:   a.Comment,
+			CreatedAt: a.CreatedAt,
+		})
+	}
+	if status.Remaining = status.Required - len(approvals); status.Remaining < 0 {
+		status.Remaining = 0
+	}
+	return status, nil
+}
+
+// ExpireTaskStepApproval 审批超时，自动驳回步骤并记录驳回意见
+func ExpireTaskStepApproval(dbSess *db.Session, task models.Tasker, taskStep *models.TaskStep) e.Error {
+	message := "approval expired"
+	if _, err := CreateTaskComment(dbSess, models.TaskComment{
+		TaskId:    taskStep.TaskId,
+		Creator:   "system",
+		CreatorId: consts.SysUserId,
+		Comment:   message,
+		StepId:    taskStep.Id,
+		Action:    forms.TaskActionRejected,
+	}); err != nil {
+		return err
+	}
+	return ChangeTaskStepStatusAndUpdate(dbSess, task, taskStep, models.TaskStepRejected, message)
+}
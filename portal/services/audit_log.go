@@ -0,0 +1,173 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"bytes"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/models/forms"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const auditMaskValue = "******"
+
+// 不记录差异的字段
+var auditIgnoreFields = map[string]bool{
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// isAuditSensitiveField 字段值是否需要脱敏
+func isAuditSensitiveField(field string) bool {
+	f := strings.ToLower(field)
+	if f == "key" || f == "token" {
+		return true
+	}
+	for _, s := range []string{"password", "secret", "privatekey"} {
+		if strings.Contains(f, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskAuditValue(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	return auditMaskValue
+}
+
+// toAuditFields 将资源转为 json 字段表，便于比较差异
+func toAuditFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(bs, &fields)
+	return fields
+}
+
+// DiffAuditChange 比较资源修改前后的字段差异，before 或 after 为 nil 表示资源新增或删除。
+// 敏感字段(如 token key、password)及敏感变量的值只记录是否变化
+func DiffAuditChange(resourceType string, id models.Id, before, after interface{}) models.AuditChange {
+	change := models.AuditChange{
+		ResourceType: resourceType,
+		ResourceId:   id,
+		Fields:       make([]models.AuditFieldDiff, 0),
+	}
+
+	b, a := toAuditFields(before), toAuditFields(after)
+	keys := make([]string, 0, len(b)+len(a))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	sensitive := b["sensitive"] == true || a["sensitive"] == true
+	for _, k := range keys {
+		if auditIgnoreFields[k] || reflect.DeepEqual(b[k], a[k]) {
+			continue
+		}
+		diff := models.AuditFieldDiff{Field: k, Before: b[k], After: a[k]}
+		if isAuditSensitiveField(k) || (sensitive && k == "value") {
+			diff.Before, diff.After = maskAuditValue(b[k]), maskAuditValue(a[k])
+		}
+		change.Fields = append(change.Fields, diff)
+	}
+	return change
+}
+
+func maskAuditJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		sensitive := val["sensitive"] == true
+		for k, fv := range val {
+			if isAuditSensitiveField(k) || (sensitive && k == "value") {
+				val[k] = maskAuditValue(fv)
+			} else {
+				val[k] = maskAuditJSON(fv)
+			}
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = maskAuditJSON(val[i])
+		}
+	}
+	return v
+}
+
+// MaskAuditBody 对请求内容中的敏感字段脱敏，非 json 格式的内容不记录
+func MaskAuditBody(body []byte) models.JSON {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil
+	}
+	bs, err := json.Marshal(maskAuditJSON(v))
+	if err != nil {
+		return nil
+	}
+	return bs
+}
+
+func SearchOperationLog(query *db.Session, form *forms.SearchAuditLogForm) *db.Session {
+	query = query.Model(&models.OperationLog{})
+	if form.UserId != "" {
+		query = query.Where("user_id = ?", form.UserId)
+	}
+	if form.OrgId != "" {
+		query = query.Where("org_id = ?", form.OrgId)
+	}
+	if form.ProjectId != "" {
+		query = query.Where("project_id = ?", form.ProjectId)
+	}
+	if form.ResourceType != "" {
+		query = query.Where("resource_type = ?", form.ResourceType)
+	}
+	if form.ResourceId != "" {
+		query = query.Where("resource_id = ?", form.ResourceId)
+	}
+	if form.Action != "" {
+		query = query.Where("action = ?", form.Action)
+	}
+	if !form.From.IsZero() {
+		query = query.Where("operation_at >= ?", form.From)
+	}
+	if !form.To.IsZero() {
+		query = query.Where("operation_at <= ?", form.To)
+	}
+	return query.Order("operation_at DESC")
+}
+
+// CleanExpiredOperationLogs 删除 before 之前的操作日志，每次最多删除 limit 条，返回删除的数量
+func CleanExpiredOperationLogs(sess *db.Session, before time.Time, limit int) (int64, e.Error) {
+	n, err := sess.Exec(fmt.Sprintf("DELETE FROM %s WHERE operation_at < ? LIMIT ?",
+		models.OperationLog{}.TableName()), before, limit)
+	if err != nil {
+		return 0, e.New(e.DBError, err)
+	}
+	return n, nil
+}
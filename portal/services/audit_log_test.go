@@ -0,0 +1,53 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffAuditChange(t *testing.T) {
+	before := models.Token{Key: "old", Description: "a", Status: "enable"}
+	after := models.Token{Key: "new", Description: "b", Status: "enable"}
+	change := DiffAuditChange("token", "at-1", before, after)
+	assert.Equal(t, "token", change.ResourceType)
+	assert.Equal(t, []models.AuditFieldDiff{
+		{Field: "description", Before: "a", After: "b"},
+		{Field: "key", Before: "******", After: "******"},
+	}, change.Fields)
+
+	// 敏感变量的值只记录是否变化
+	v := models.Variable{VariableBody: models.VariableBody{Name: "pass", Value: "x", Sensitive: true}}
+	change = DiffAuditChange("variable", "var-1", nil, v)
+	for _, f := range change.Fields {
+		if f.Field == "value" {
+			assert.Equal(t, nil, f.Before)
+			assert.Equal(t, "******", f.After)
+		}
+	}
+
+	change = DiffAuditChange("token", "at-1", &before, &before)
+	assert.Empty(t, change.Fields)
+}
+
+func TestMaskAuditBody(t *testing.T) {
+	body := MaskAuditBody([]byte(`{"email":"a@example.com","password":"123456","count":10,` +
+		`"variables":[{"name":"k","value":"v","sensitive":true},{"name":"k2","value":"v2"}]}`))
+	m := make(map[string]interface{})
+	if !assert.NoError(t, json.Unmarshal(body, &m)) {
+		return
+	}
+	assert.Equal(t, "a@example.com", m["email"])
+	assert.Equal(t, "******", m["password"])
+	assert.Equal(t, float64(10), m["count"])
+	vars := m["variables"].([]interface{})
+	assert.Equal(t, "******", vars[0].(map[string]interface{})["value"])
+	assert.Equal(t, "v2", vars[1].(map[string]interface{})["value"])
+
+	assert.Nil(t, MaskAuditBody([]byte("a=1&b=2")))
+	assert.Nil(t, MaskAuditBody(nil))
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"fmt"
+)
+
+func GetDeployStackById(query *db.Session, id models.Id) (*models.DeployStack, e.Error) {
+	stack := models.DeployStack{}
+	if err := query.Where("id = ?", id).First(&stack); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.DeployStackNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &stack, nil
+}
+
+// GetRunningDeployStacks 查询所有执行中的环境栈部署
+func GetRunningDeployStacks(query *db.Session) ([]*models.DeployStack, e.Error) {
+	stacks := make([]*models.DeployStack, 0)
+	if err := query.Model(&models.DeployStack{}).Where("status = ?", models.DeployStackRunning).
+		Order("created_at").Find(&stacks); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return stacks, nil
+}
+
+// CreateDeployStack 创建环境栈部署记录，并创建第一个环境的部署任务
+func CreateDeployStack(tx *db.Session, stack models.DeployStack) (*models.DeployStack, e.Error) {
+	if stack.Id == "" {
+		stack.Id = models.NewId("stack")
+	}
+	stack.Status = models.DeployStackRunning
+	stack.Current = 0
+	stack.TaskIds = models.StrSlice{}
+	if err := models.Create(tx, &stack); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	if err := createStackEnvTask(tx, &stack); err != nil {
+		return nil, err
+	}
+	return &stack, nil
+}
+
+// createStackEnvTask 为环境栈中的当前环境创建部署任务
+func createStackEnvTask(tx *db.Session, stack *models.DeployStack) e.Error {
+	env, err := GetEnvById(tx, models.Id(stack.EnvIds[stack.Current]))
+	if err != nil {
+		return err
+	}
+	if env.LastTaskId == "" {
+		return e.New(e.EnvNotDeployed, fmt.Errorf("env '%s' has never been deployed", env.Name))
+	}
+	src, err := GetTaskById(tx, env.LastTaskId)
+	if err != nil {
+		return err
+	}
+
+	task, err := CloneNewStackTask(tx, *src, env, stack)
+	if err != nil {
+		return err
+	}
+	stack.TaskIds = append(stack.TaskIds, string(task.Id))
+	if _, er := tx.Model(stack).UpdateColumn("task_ids", stack.TaskIds); er != nil {
+		return e.New(e.DBError, er)
+	}
+	return nil
+}
+
+// ChangeDeployStackStatus 修改环境栈部署状态
+func ChangeDeployStackStatus(tx *db.Session, stack *models.DeployStack, status, message string) e.Error {
+	stack.Status = status
+	stack.Message = message
+	if _, err := tx.Model(stack).UpdateAttrs(models.Attrs{
+		"status": status, "message": message, "current": stack.Current}); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
+
+// ProcessDeployStack 检查环境栈当前任务的执行状态，任务成功后创建下一个环境的部署任务，任务失败则中止部署。
+// 创建下一个环境的任务失败时返回错误，由调用方回滚事务后标记部署失败
+func ProcessDeployStack(tx *db.Session, stack *models.DeployStack) e.Error {
+	if stack.Status != models.DeployStackRunning || stack.Current >= len(stack.TaskIds) {
+		return nil
+	}
+
+	task, err := GetTaskById(tx, models.Id(stack.TaskIds[stack.Current]))
+	if err != nil {
+		return err
+	}
+	if !task.Exited() {
+		return nil
+	}
+	if task.Status != models.TaskComplete {
+		return ChangeDeployStackStatus(tx, stack, models.DeployStackFailed,
+			fmt.Sprintf("task '%s' of env '%s' %s", task.Id, task.EnvId, task.Status))
+	}
+
+	stack.Current += 1
+	if stack.Current >= len(stack.EnvIds) {
+		return ChangeDeployStackStatus(tx, stack, models.DeployStackComplete, "")
+	}
+	if err := createStackEnvTask(tx, stack); err != nil {
+		stack.Current -= 1
+		return err
+	}
+	return ChangeDeployStackStatus(tx, stack, models.DeployStackRunning, "")
+}
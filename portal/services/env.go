@@ -147,8 +147,8 @@ func ChangeEnvStatusWithTaskAndStep(tx *db.Session, id models.Id, task *models.T
 
 	if task.Exited() {
 		switch task.Status {
-		case models.TaskRejected:
-			// 任务驳回，环境状态不变
+		case models.TaskRejected, models.TaskAborted:
+			// 任务驳回或中止，环境状态不变
 			break
 		case models.TaskFailed:
 			envStatus = models.EnvStatusFailed
@@ -228,6 +228,7 @@ func GetEnvResourceCount(sess *db.Session, envId models.Id) (int, e.Error) {
 	return int(count), nil
 }
 
+// GetDefaultRunner 返回第一个在线的 runner，已过期的 runner 不会出现在 runner 列表中
 func GetDefaultRunner() (string, e.Error) {
 	runners, err := RunnerSearch()
 	if err != nil {
@@ -236,7 +237,7 @@ func GetDefaultRunner() (string, e.Error) {
 	if len(runners) > 0 {
 		return runners[0].ID, nil
 	}
-	return "", e.New(e.ConsulConnError, fmt.Errorf("runner list is null"))
+	return "", e.New(e.ConsulConnError, fmt.Errorf("no active runner found"))
 }
 
 //
@@ -0,0 +1,242 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/utils"
+	"encoding/json"
+	"fmt"
+)
+
+func CreateEnvDependency(tx *db.Session, dep models.EnvDependency) (*models.EnvDependency, e.Error) {
+	if dep.Id == "" {
+		dep.Id = models.NewId("ed")
+	}
+	if err := models.Create(tx, &dep); err != nil {
+		if e.IsDuplicate(err) {
+			return nil, e.New(e.EnvDependencyExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &dep, nil
+}
+
+func UpdateEnvDependency(tx *db.Session, id models.Id, attrs models.Attrs) (*models.EnvDependency, e.Error) {
+	if _, err := models.UpdateAttr(tx.Where("id = ?", id), &models.EnvDependency{}, attrs); err != nil {
+		return nil, e.New(e.DBError, fmt.Errorf("update env dependency error: %v", err))
+	}
+	return GetEnvDependencyById(tx, id)
+}
+
+func DeleteEnvDependency(tx *db.Session, id models.Id) e.Error {
+	if _, err := tx.Where("id = ?", id).Delete(&models.EnvDependency{}); err != nil {
+		return e.New(e.DBError, fmt.Errorf("delete env dependency error: %v", err))
+	}
+	return nil
+}
+
+func GetEnvDependencyById(query *db.Session, id models.Id) (*models.EnvDependency, e.Error) {
+	dep := models.EnvDependency{}
+	if err := query.Where("id = ?", id).First(&dep); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.EnvDependencyNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &dep, nil
+}
+
+// GetEnvDependencies 查询环境依赖的上游环境
+func GetEnvDependencies(query *db.Session, envId models.Id) ([]*models.EnvDependency, e.Error) {
+	deps := make([]*models.EnvDependency, 0)
+	if err := query.Model(&models.EnvDependency{}).Where("env_id = ?", envId).
+		Order("created_at").Find(&deps); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return deps, nil
+}
+
+// GetProjectEnvDependencies 查询项目下所有的环境依赖
+func GetProjectEnvDependencies(query *db.Session, projectId models.Id) ([]*models.EnvDependency, e.Error) {
+	deps := make([]*models.EnvDependency, 0)
+	if err := query.Model(&models.EnvDependency{}).Where("project_id = ?", projectId).
+		Order("created_at").Find(&deps); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return deps, nil
+}
+
+// HasEnvDependencyCycle 判断添加 envId 依赖 dependsOnEnvId 的关系后是否会产生循环依赖
+func HasEnvDependencyCycle(deps []*models.EnvDependency, envId, dependsOnEnvId models.Id) bool {
+	upstreams := make(map[models.Id][]models.Id)
+	for _, d := range deps {
+		upstreams[d.EnvId] = append(upstreams[d.EnvId], d.DependsOnEnvId)
+	}
+
+	// 从上游环境出发沿依赖关系查找，能找到 envId 则说明存在循环
+	visited := make(map[models.Id]bool)
+	stack := []models.Id{dependsOnEnvId}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == envId {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		stack = append(stack, upstreams[id]...)
+	}
+	return false
+}
+
+// GetDownstreamEnvsInOrder 获取 envId 及其所有(直接或间接)下游环境，并按依赖顺序排序(上游在前)
+func GetDownstreamEnvsInOrder(deps []*models.EnvDependency, envId models.Id) []models.Id {
+	downstreams := make(map[models.Id][]models.Id)
+	for _, d := range deps {
+		downstreams[d.DependsOnEnvId] = append(downstreams[d.DependsOnEnvId], d.EnvId)
+	}
+
+	// 查找需要部署的所有环境
+	included := map[models.Id]bool{envId: true}
+	queue := []models.Id{envId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, d := range downstreams[id] {
+			if !included[d] {
+				included[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+
+	// 拓扑排序，只计算包含在部署范围内的依赖关系
+	inDegree := make(map[models.Id]int)
+	for _, d := range deps {
+		if included[d.EnvId] && included[d.DependsOnEnvId] {
+			inDegree[d.EnvId] += 1
+		}
+	}
+	result := make([]models.Id, 0, len(included))
+	queue = []models.Id{envId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		result = append(result, id)
+		for _, d := range downstreams[id] {
+			if !included[d] {
+				continue
+			}
+			if inDegree[d] -= 1; inDegree[d] == 0 {
+				queue = append(queue, d)
+			}
+		}
+	}
+	return result
+}
+
+// outputToVarValue 将 output 的值转换为 terraform 变量值，非字符串类型使用 json 编码
+func outputToVarValue(output interface{}) (value string, sensitive bool, err error) {
+	bs, err := json.Marshal(output)
+	if err != nil {
+		return "", false, err
+	}
+	v := TfStateVariable{}
+	if err := json.Unmarshal(bs, &v); err != nil {
+		return "", false, err
+	}
+
+	if s, ok := v.Value.(string); ok {
+		return s, v.Sensitive, nil
+	}
+	bs, err = json.Marshal(v.Value)
+	if err != nil {
+		return "", false, err
+	}
+	return string(bs), v.Sensitive, nil
+}
+
+// GetEnvDependencyVariables 根据环境依赖的 output 映射关系，从上游环境最后一次部署的 outputs 中生成 terraform 变量。
+// ignoreMissing 为 true 时忽略上游环境中不存在的 output
+func GetEnvDependencyVariables(query *db.Session, envId models.Id, ignoreMissing bool) ([]models.VariableBody, e.Error) {
+	deps, err := GetEnvDependencies(query, envId)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]models.VariableBody, 0)
+	for _, dep := range deps {
+		if len(dep.OutputMappings) == 0 {
+			continue
+		}
+
+		upstream, err := GetEnvById(query, dep.DependsOnEnvId)
+		if err != nil {
+			return nil, err
+		}
+		outputs := map[string]interface{}{}
+		if upstream.LastResTaskId != "" {
+			task, err := GetTaskById(query, upstream.LastResTaskId)
+			if err != nil {
+				return nil, err
+			}
+			outputs = task.Result.Outputs
+		}
+
+		for _, m := range dep.OutputMappings {
+			output, ok := outputs[m.Output]
+			if !ok {
+				if ignoreMissing {
+					continue
+				}
+				return nil, e.New(e.EnvDependencyOutput,
+					fmt.Errorf("output '%s' of env '%s' not found", m.Output, upstream.Name))
+			}
+
+			value, sensitive, er := outputToVarValue(output)
+			if er != nil {
+				return nil, e.New(e.InternalError, er)
+			}
+			if sensitive {
+				if value, er = utils.AesEncrypt(value); er != nil {
+					return nil, e.New(e.InternalError, er)
+				}
+			}
+			vars = append(vars, models.VariableBody{
+				Scope:       consts.ScopeEnv,
+				Type:        consts.VarTypeTerraform,
+				Name:        m.Variable,
+				Value:       value,
+				Sensitive:   sensitive,
+				Description: fmt.Sprintf("output '%s' of env '%s'", m.Output, upstream.Name),
+			})
+		}
+	}
+	return vars, nil
+}
+
+// MergeEnvDependencyVariables 将上游环境 outputs 生成的变量合并到任务变量中，同名的 terraform 变量会被覆盖
+func MergeEnvDependencyVariables(taskVars models.TaskVariables, depVars []models.VariableBody) models.TaskVariables {
+	if len(depVars) == 0 {
+		return taskVars
+	}
+
+	merged := make(models.TaskVariables, 0, len(taskVars)+len(depVars))
+	overrides := make(map[string]bool)
+	for _, v := range depVars {
+		overrides[v.Name] = true
+	}
+	for _, v := range taskVars {
+		if v.Type == consts.VarTypeTerraform && overrides[v.Name] {
+			continue
+		}
+		merged = append(merged, v)
+	}
+	return append(merged, depVars...)
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvDependencyOrder(t *testing.T) {
+	// a <- b, a <- c, b <- d, c <- d
+	deps := []*models.EnvDependency{
+		{EnvId: "b", DependsOnEnvId: "a"},
+		{EnvId: "c", DependsOnEnvId: "a"},
+		{EnvId: "d", DependsOnEnvId: "b"},
+		{EnvId: "d", DependsOnEnvId: "c"},
+	}
+
+	assert.True(t, HasEnvDependencyCycle(deps, "a", "d"))
+	assert.True(t, HasEnvDependencyCycle(deps, "b", "d"))
+	assert.False(t, HasEnvDependencyCycle(deps, "d", "a"))
+	assert.False(t, HasEnvDependencyCycle(deps, "e", "d"))
+
+	order := GetDownstreamEnvsInOrder(deps, "a")
+	assert.Equal(t, 4, len(order))
+	assert.Equal(t, models.Id("a"), order[0])
+	assert.Equal(t, models.Id("d"), order[3])
+
+	assert.Equal(t, []models.Id{"c", "d"}, GetDownstreamEnvsInOrder(deps, "c"))
+	assert.Equal(t, []models.Id{"d"}, GetDownstreamEnvsInOrder(deps, "d"))
+}
+
+func TestMergeEnvDependencyVariables(t *testing.T) {
+	taskVars := models.TaskVariables{
+		{Type: consts.VarTypeTerraform, Name: "vpc_id", Value: "old"},
+		{Type: consts.VarTypeEnv, Name: "vpc_id", Value: "env"},
+		{Type: consts.VarTypeTerraform, Name: "region", Value: "cn"},
+	}
+	merged := MergeEnvDependencyVariables(taskVars, []models.VariableBody{
+		{Type: consts.VarTypeTerraform, Name: "vpc_id", Value: "new"},
+	})
+	assert.Equal(t, models.TaskVariables{
+		{Type: consts.VarTypeEnv, Name: "vpc_id", Value: "env"},
+		{Type: consts.VarTypeTerraform, Name: "region", Value: "cn"},
+		{Type: consts.VarTypeTerraform, Name: "vpc_id", Value: "new"},
+	}, merged)
+
+	value, sensitive, err := outputToVarValue(map[string]interface{}{"value": []string{"a"}, "sensitive": true})
+	assert.NoError(t, err)
+	assert.True(t, sensitive)
+	assert.Equal(t, `["a"]`, value)
+}
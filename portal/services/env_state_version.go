@@ -0,0 +1,124 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services/logstorage"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+type tfStateMeta struct {
+	Serial  int    `json:"serial"`
+	Lineage string `json:"lineage"`
+}
+
+// parseStateMeta 解析 state 文件的 serial、lineage 及 sha256 checksum
+func parseStateMeta(content []byte) (meta tfStateMeta, checksum string, err error) {
+	if err = json.Unmarshal(content, &meta); err != nil {
+		return meta, "", fmt.Errorf("unmarshal state: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	return meta, hex.EncodeToString(sum[:]), nil
+}
+
+func envStateVersionPath(task *models.Task, versionId models.Id) string {
+	return path.Join(task.ProjectId.String(), task.EnvId.String(), "states", versionId.String()+".tfstate")
+}
+
+// SaveEnvStateVersion 保存任务执行后的 state 版本，任务未导出 state 或 state 与上一版本相同时返回 nil
+func SaveEnvStateVersion(sess *db.Session, task *models.Task) (*models.EnvStateVersion, e.Error) {
+	storage := logstorage.Get()
+	content, err := storage.Read(task.TfStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, e.New(e.InternalError, err)
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	meta, checksum, err := parseStateMeta(content)
+	if err != nil {
+		return nil, e.New(e.InternalError, err)
+	}
+
+	last, er := GetLatestEnvStateVersion(sess, task.EnvId)
+	if er != nil && er.Code() != e.EnvStateVersionNotExists {
+		return nil, er
+	}
+	if last != nil && last.Checksum == checksum {
+		return nil, nil
+	}
+
+	// state 版本单独保存，不受任务日志清理的影响
+	version := models.EnvStateVersion{
+		OrgId:     task.OrgId,
+		ProjectId: task.ProjectId,
+		EnvId:     task.EnvId,
+		TaskId:    task.Id,
+		Serial:    meta.Serial,
+		Lineage:   meta.Lineage,
+		Size:      len(content),
+		Checksum:  checksum,
+	}
+	version.Id = models.NewId("sv")
+	version.Path = envStateVersionPath(task, version.Id)
+	if err := storage.Write(version.Path, content); err != nil {
+		return nil, e.New(e.InternalError, err)
+	}
+	if err := models.Create(sess, &version); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return &version, nil
+}
+
+func GetEnvStateVersionById(query *db.Session, id models.Id) (*models.EnvStateVersion, e.Error) {
+	version := models.EnvStateVersion{}
+	if err := query.Where("id = ?", id).First(&version); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.EnvStateVersionNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &version, nil
+}
+
+// GetLatestEnvStateVersion 查询环境最新的 state 版本
+func GetLatestEnvStateVersion(query *db.Session, envId models.Id) (*models.EnvStateVersion, e.Error) {
+	version := models.EnvStateVersion{}
+	if err := query.Where("env_id = ?", envId).Order("created_at DESC").First(&version); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.EnvStateVersionNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &version, nil
+}
+
+// QueryEnvStateVersion 查询环境 state 版本，同时返回生成该版本的任务信息
+func QueryEnvStateVersion(query *db.Session, envId models.Id) *db.Session {
+	return query.Model(&models.EnvStateVersion{}).
+		Where("iac_env_state_version.env_id = ?", envId).
+		Joins("left join iac_task as t on t.id = iac_env_state_version.task_id").
+		Joins("left join iac_user as u on u.id = t.creator_id").
+		LazySelectAppend("iac_env_state_version.*, t.name as task_name, t.type as task_type, u.name as creator")
+}
+
+// GetEnvStateVersionContent 读取 state 版本的文件内容
+func GetEnvStateVersionContent(version *models.EnvStateVersion) ([]byte, e.Error) {
+	content, err := logstorage.Get().Read(version.Path)
+	if err != nil {
+		return nil, e.New(e.InternalError, fmt.Errorf("read state version '%s': %v", version.Id, err))
+	}
+	return content, nil
+}
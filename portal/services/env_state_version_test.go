@@ -0,0 +1,25 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStateMeta(t *testing.T) {
+	state := []byte(`{"version":4,"terraform_version":"1.0.6","serial":12,"lineage":"a9c3c1f6-1e5b","outputs":{},"resources":[]}`)
+	meta, checksum, err := parseStateMeta(state)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, meta.Serial)
+	assert.Equal(t, "a9c3c1f6-1e5b", meta.Lineage)
+	assert.Len(t, checksum, 64)
+
+	_, checksum2, err := parseStateMeta(append(state, '\n'))
+	assert.NoError(t, err)
+	assert.NotEqual(t, checksum, checksum2)
+
+	_, _, err = parseStateMeta([]byte("not json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts/e"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// LdapUser ldap 认证通过的用户信息
+type LdapUser struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string // 用户所在组的 DN
+}
+
+// LdapAuthenticate 使用 ldap 认证用户，用户不存在或者密码错误时返回 InvalidPassword 错误
+func LdapAuthenticate(cfg configs.LdapConfig, email string, password string) (*LdapUser, e.Error) {
+	if password == "" {
+		// 空密码会被 ldap 服务当作匿名绑定处理
+		return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+	}
+
+	conn, err := ldapConnect(cfg)
+	if err != nil {
+		return nil, e.New(e.LdapError, err, http.StatusInternalServerError)
+	}
+	defer conn.Close()
+
+	if err := ldapServiceBind(conn, cfg); err != nil {
+		return nil, e.New(e.LdapError, fmt.Errorf("bind: %v", err), http.StatusInternalServerError)
+	}
+
+	attrs := []string{cfg.EmailAttr, cfg.NameAttr, cfg.GroupAttr}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(cfg.UserFilter, ldap.EscapeFilter(email)), attrs, nil,
+	))
+	if err != nil {
+		return nil, e.New(e.LdapError, fmt.Errorf("search user: %v", err), http.StatusInternalServerError)
+	}
+	if len(result.Entries) == 0 {
+		return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+	} else if len(result.Entries) > 1 {
+		return nil, e.New(e.LdapError, fmt.Errorf("multiple entries found for '%s'", email), http.StatusInternalServerError)
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, e.New(e.InvalidPassword, http.StatusBadRequest)
+		}
+		return nil, e.New(e.LdapError, fmt.Errorf("bind user: %v", err), http.StatusInternalServerError)
+	}
+
+	user := &LdapUser{
+		DN:     entry.DN,
+		Email:  entry.GetAttributeValue(cfg.EmailAttr),
+		Name:   entry.GetAttributeValue(cfg.NameAttr),
+		Groups: entry.GetAttributeValues(cfg.GroupAttr),
+	}
+	if user.Email == "" {
+		user.Email = email
+	}
+
+	if cfg.GroupBaseDN != "" {
+		// 用户账号可能没有查询组的权限，所以重新使用查询账号绑定
+		if err := ldapServiceBind(conn, cfg); err != nil {
+			return nil, e.New(e.LdapError, fmt.Errorf("bind: %v", err), http.StatusInternalServerError)
+		}
+		result, err := conn.Search(ldap.NewSearchRequest(
+			cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false,
+			fmt.Sprintf(cfg.GroupFilter, ldap.EscapeFilter(entry.DN)), []string{"dn"}, nil,
+		))
+		if err != nil {
+			return nil, e.New(e.LdapError, fmt.Errorf("search groups: %v", err), http.StatusInternalServerError)
+		}
+		for _, g := range result.Entries {
+			user.Groups = append(user.Groups, g.DN)
+		}
+	}
+	return user, nil
+}
+
+func ldapConnect(cfg configs.LdapConfig) (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(cfg.Url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func ldapServiceBind(conn *ldap.Conn, cfg configs.LdapConfig) error {
+	if cfg.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(cfg.BindDN, cfg.BindPassword)
+}
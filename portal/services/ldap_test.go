@@ -0,0 +1,197 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts/e"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLdapEntry struct {
+	password string
+	attrs    map[string][]string
+}
+
+// testLdapServer 测试使用的 ldap 服务，只支持 simple bind 及单个等值条件的 search
+type testLdapServer struct {
+	listener net.Listener
+	entries  map[string]testLdapEntry
+}
+
+func newTestLdapServer(t *testing.T, entries map[string]testLdapEntry) *testLdapServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testLdapServer{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testLdapServer) Url() string {
+	return fmt.Sprintf("ldap://%s", s.listener.Addr())
+}
+
+func (s *testLdapServer) Close() {
+	_ = s.listener.Close()
+}
+
+func (s *testLdapServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgId := packet.Children[0].Value.(int64)
+		req := packet.Children[1]
+
+		var resps []*ber.Packet
+		switch req.Tag {
+		case ldap.ApplicationBindRequest:
+			code := int64(ldap.LDAPResultSuccess)
+			dn, password := req.Children[1].Value.(string), req.Children[2].Data.String()
+			if entry, ok := s.entries[dn]; dn != "" && (!ok || entry.password != password) {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			resps = append(resps, testLdapResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			baseDN := req.Children[0].Value.(string)
+			filter, _ := ldap.DecompileFilter(req.Children[6])
+			for dn, entry := range s.entries {
+				if strings.HasSuffix(strings.ToLower(dn), strings.ToLower(baseDN)) && entry.match(filter) {
+					resps = append(resps, testLdapSearchEntry(dn, entry))
+				}
+			}
+			resps = append(resps, testLdapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			return
+		}
+
+		for _, resp := range resps {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgId, ""))
+			envelope.AppendChild(resp)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// match 判断 entry 是否满足 (attr=value) 格式的 filter
+func (e testLdapEntry) match(filter string) bool {
+	kv := strings.SplitN(strings.Trim(filter, "()"), "=", 2)
+	if len(kv) != 2 {
+		return false
+	}
+	for name, values := range e.attrs {
+		if !strings.EqualFold(name, kv[0]) {
+			continue
+		}
+		for _, v := range values {
+			if strings.EqualFold(v, kv[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func testLdapResult(tag ber.Tag, code int64) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return p
+}
+
+func testLdapSearchEntry(dn string, entry testLdapEntry) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for name, values := range entry.attrs {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	p.AppendChild(attrs)
+	return p
+}
+
+func TestLdapAuthenticate(t *testing.T) {
+	const aliceDN = "uid=alice,ou=users,dc=example,dc=com"
+	server := newTestLdapServer(t, map[string]testLdapEntry{
+		"cn=admin,dc=example,dc=com": {password: "admin"},
+		aliceDN: {password: "secret", attrs: map[string][]string{
+			"mail":     {"alice@example.com"},
+			"cn":       {"Alice"},
+			"memberOf": {"cn=devops,ou=groups,dc=example,dc=com"},
+		}},
+		"cn=ops,ou=groups,dc=example,dc=com": {attrs: map[string][]string{
+			"member": {aliceDN},
+		}},
+	})
+	defer server.Close()
+
+	cfg := configs.LdapConfig{
+		Enabled:      true,
+		Url:          server.Url(),
+		BindDN:       "cn=admin,dc=example,dc=com",
+		BindPassword: "admin",
+		BaseDN:       "ou=users,dc=example,dc=com",
+		UserFilter:   "(mail=%s)",
+		EmailAttr:    "mail",
+		NameAttr:     "cn",
+		GroupAttr:    "memberOf",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+		GroupFilter:  "(member=%s)",
+	}
+
+	user, err := LdapAuthenticate(cfg, "alice@example.com", "secret")
+	if assert.Nil(t, err) {
+		assert.Equal(t, aliceDN, user.DN)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.Equal(t, "Alice", user.Name)
+		assert.ElementsMatch(t, []string{
+			"cn=devops,ou=groups,dc=example,dc=com",
+			"cn=ops,ou=groups,dc=example,dc=com",
+		}, user.Groups)
+	}
+
+	_, err = LdapAuthenticate(cfg, "alice@example.com", "wrong")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, e.InvalidPassword, err.Code())
+	}
+	_, err = LdapAuthenticate(cfg, "bob@example.com", "secret")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, e.InvalidPassword, err.Code())
+	}
+
+	cfg.BindPassword = "wrong"
+	_, err = LdapAuthenticate(cfg, "alice@example.com", "secret")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, e.LdapError, err.Code())
+	}
+}
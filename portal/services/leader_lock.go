@@ -0,0 +1,120 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/utils"
+)
+
+const (
+	leaderLockTTL        = 10 * time.Second
+	leaderLockRetryDelay = time.Second
+)
+
+// DBLocker 基于数据库实现的分布式锁，registry 为 db 时替代 consul lock 用于 task manager 选主。
+// 锁的过期时间使用数据库时间计算，避免多个实例之间的时钟偏差
+type DBLocker struct {
+	name   string
+	holder string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	stopCh chan struct{} // 停止续期
+}
+
+func NewDBLocker(name string, id string) *DBLocker {
+	return &DBLocker{
+		name:   name,
+		holder: fmt.Sprintf("%s-%s", id, utils.GenGuid("")),
+		ttl:    leaderLockTTL,
+	}
+}
+
+// Lock 阻塞直到获取锁，获取成功后返回的 channel 会在锁丢失时关闭。
+// 与 consul lock 一致，stopCh 关闭时放弃加锁并返回 nil, nil
+func (l *DBLocker) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	for {
+		ok, err := l.tryLock()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-stopCh:
+			return nil, nil
+		case <-time.After(leaderLockRetryDelay):
+		}
+	}
+
+	l.mu.Lock()
+	l.stopCh = make(chan struct{})
+	renewStopCh := l.stopCh
+	l.mu.Unlock()
+
+	lostCh := make(chan struct{})
+	go l.renew(renewStopCh, lostCh)
+	return lostCh, nil
+}
+
+func (l *DBLocker) renew(stopCh <-chan struct{}, lostCh chan<- struct{}) {
+	defer close(lostCh)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			// 续期失败(包括数据库连接异常)即认为锁已丢失，由调用方重新加锁
+			if ok, err := l.tryLock(); err != nil || !ok {
+				return
+			}
+		}
+	}
+}
+
+// tryLock 锁不存在、已过期或由自己持有时获取(续期)锁
+func (l *DBLocker) tryLock() (bool, error) {
+	sess := db.Get()
+	if _, err := sess.Exec("INSERT IGNORE INTO iac_leader_lock(name, holder, expire_at) "+
+		"VALUES (?, '', '1970-01-01 00:00:00')", l.name); err != nil {
+		return false, err
+	}
+
+	if _, err := sess.Exec("UPDATE iac_leader_lock SET holder = ?, expire_at = DATE_ADD(NOW(), INTERVAL ? SECOND) "+
+		"WHERE name = ? AND (holder = ? OR expire_at < NOW())",
+		l.holder, int(l.ttl.Seconds()), l.name, l.holder); err != nil {
+		return false, err
+	}
+
+	// 更新的值未变化时 mysql 返回的 affected rows 为 0，所以需要重新查询确认持有者
+	lock := models.LeaderLock{}
+	if err := sess.Where("name = ?", l.name).First(&lock); err != nil {
+		return false, err
+	}
+	return lock.Holder == l.holder, nil
+}
+
+// Unlock 停止续期并释放锁
+func (l *DBLocker) Unlock() error {
+	l.mu.Lock()
+	if l.stopCh != nil {
+		close(l.stopCh)
+		l.stopCh = nil
+	}
+	l.mu.Unlock()
+
+	_, err := db.Get().Exec("UPDATE iac_leader_lock SET holder = '', expire_at = '1970-01-01 00:00:00' "+
+		"WHERE name = ? AND holder = ?", l.name, l.holder)
+	return err
+}
@@ -3,14 +3,18 @@
 package logstorage
 
 import (
+	"cloudiac/configs"
 	"cloudiac/portal/consts"
 	"cloudiac/portal/libs/db"
+	"fmt"
 	"sync"
 )
 
 type LogStorage interface {
 	Write(path string, content []byte) error
 	Read(path string) ([]byte, error)
+	// Delete 删除 path 对应的内容，返回被删除内容的大小，path 不存在时返回 0
+	Delete(path string) (int64, error)
 }
 
 var (
@@ -21,14 +25,36 @@ var (
 func Get() LogStorage {
 	initOnce.Do(func() {
 		if logStorage == nil {
-			logStorage = &dBLogStorage{db: db.Get()}
+			s, err := New(configs.Get().LogStorage)
+			if err != nil {
+				panic(err)
+			}
+			logStorage = s
 		}
 	})
 	return logStorage
 }
 
+// New 根据配置创建存储后端
+func New(cfg configs.LogStorageConfig) (LogStorage, error) {
+	switch cfg.Type {
+	case "", configs.LogStorageTypeDB:
+		return &dBLogStorage{db: db.Get()}, nil
+	case configs.LogStorageTypeFS:
+		return newFSLogStorage(cfg.Path)
+	case configs.LogStorageTypeS3:
+		return newS3LogStorage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown log storage type '%s'", cfg.Type)
+	}
+}
+
 // CutLogContent 判断内容日志长度是否超限，若超限则截断(保留最新内容)
+// 只有数据库存储需要截断，其他存储后端直接返回原内容
 func CutLogContent(content []byte) []byte {
+	if _, ok := Get().(*dBLogStorage); !ok {
+		return content
+	}
 	size := len(content)
 	if size > consts.MaxLogContentSize {
 		content = content[size-consts.MaxLogContentSize:]
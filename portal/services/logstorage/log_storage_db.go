@@ -6,6 +6,7 @@ import (
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/db"
 	"cloudiac/portal/models"
+	"database/sql"
 	"os"
 )
 
@@ -28,3 +29,17 @@ func (s *dBLogStorage) Read(path string) ([]byte, error) {
 	}
 	return dbLog.Content, nil
 }
+
+func (s *dBLogStorage) Delete(path string) (int64, error) {
+	var size int64
+	if err := s.db.Raw("SELECT LENGTH(content) FROM iac_storage WHERE path = ?", path).Row().Scan(&size); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if _, err := s.db.Exec("DELETE FROM iac_storage WHERE path = ?", path); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package logstorage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fsLogStorage 将内容保存到本地(或挂载的共享)目录，path 即为相对于根目录的文件路径
+type fsLogStorage struct {
+	root string
+}
+
+func newFSLogStorage(root string) (*fsLogStorage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("log storage path is required")
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absRoot, 0755); err != nil {
+		return nil, err
+	}
+	return &fsLogStorage{root: absRoot}, nil
+}
+
+func (s *fsLogStorage) fullPath(path string) (string, error) {
+	// 先转为绝对路径再 Clean，避免 path 中包含 ".." 时跳出根目录
+	p := filepath.Join(s.root, filepath.Clean("/"+path))
+	if p == s.root || !strings.HasPrefix(p, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage path '%s'", path)
+	}
+	return p, nil
+}
+
+func (s *fsLogStorage) Write(path string, content []byte) error {
+	p, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再 rename，保证读取时不会拿到写了一半的内容
+	fp, err := ioutil.TempFile(filepath.Dir(p), ".tmp-"+filepath.Base(p))
+	if err != nil {
+		return err
+	}
+	tmpName := fp.Name()
+	if _, err = fp.Write(content); err == nil {
+		err = fp.Close()
+	} else {
+		_ = fp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmpName, p)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+	return err
+}
+
+func (s *fsLogStorage) Read(path string) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	p, err := s.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return content, nil
+}
+
+func (s *fsLogStorage) Delete(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+	p, err := s.fullPath(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if err := os.Remove(p); err != nil {
+		return 0, err
+	}
+
+	// 清理空目录，目录非空时 Remove 会失败，此时停止
+	for dir := filepath.Dir(p); dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return info.Size(), nil
+}
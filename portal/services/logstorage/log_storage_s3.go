@@ -0,0 +1,218 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package logstorage
+
+import (
+	"bytes"
+	"cloudiac/configs"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3Timeout       = 60 * time.Second
+)
+
+// s3LogStorage 将内容保存到 s3 兼容的对象存储中(aws s3、minio、oss 等)，
+// 请求使用 AWS Signature V4 签名，不依赖 aws sdk
+type s3LogStorage struct {
+	cfg      configs.S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func newS3LogStorage(cfg configs.S3Config) (*s3LogStorage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 endpoint and bucket is required")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %v", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint '%s'", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = s3DefaultRegion
+	}
+	return &s3LogStorage{
+		cfg:      cfg,
+		endpoint: u,
+		client:   &http.Client{Timeout: s3Timeout},
+	}, nil
+}
+
+func (s *s3LogStorage) objectURL(path string) *url.URL {
+	key := strings.TrimPrefix(path, "/")
+	if prefix := strings.Trim(s.cfg.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	u := *s.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		u.Path = fmt.Sprintf("%s/%s/%s", basePath, s.cfg.Bucket, key)
+	} else {
+		u.Host = fmt.Sprintf("%s.%s", s.cfg.Bucket, u.Host)
+		u.Path = fmt.Sprintf("%s/%s", basePath, key)
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+func (s *s3LogStorage) do(method string, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(path).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	s.sign(req, body, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+func (s *s3LogStorage) Write(path string, content []byte) error {
+	if content == nil {
+		content = []byte{}
+	}
+	resp, err := s.do(http.MethodPut, path, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return s3ResponseError(resp)
+	}
+	return nil
+}
+
+func (s *s3LogStorage) Read(path string) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	resp, err := s.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	} else if resp.StatusCode/100 != 2 {
+		return nil, s3ResponseError(resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *s3LogStorage) Delete(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+	resp, err := s.do(http.MethodHead, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	} else if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("s3 HEAD %s: %s", resp.Request.URL.Path, resp.Status)
+	}
+	size := resp.ContentLength
+
+	resp, err = s.do(http.MethodDelete, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return 0, s3ResponseError(resp)
+	}
+	if size < 0 {
+		size = 0
+	}
+	return size, nil
+}
+
+func s3ResponseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("s3 %s %s: %s, %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, string(body))
+}
+
+// sign 使用 AWS Signature V4 对请求进行签名
+// https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+func (s *s3LogStorage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.AccessKey == "" {
+		// 匿名访问
+		return
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.cfg.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSha256(key, s.cfg.Region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath 按 s3 的规则对 path 进行编码(除 "/" 外所有非 unreserved 字符都需要编码)
+func s3EscapePath(path string) string {
+	buf := strings.Builder{}
+	for _, b := range []byte(path) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || b == '/' {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
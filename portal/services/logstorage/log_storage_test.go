@@ -0,0 +1,157 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package logstorage
+
+import (
+	"cloudiac/configs"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLogStorage(t *testing.T, s LogStorage) {
+	_, err := s.Read("logs/run-1/step0")
+	assert.True(t, os.IsNotExist(err), "%v", err)
+
+	content := []byte(strings.Repeat("x", 2*1024*1024))
+	assert.NoError(t, s.Write("logs/run-1/step0", content))
+	got, err := s.Read("logs/run-1/step0")
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// 覆盖写入
+	assert.NoError(t, s.Write("logs/run-1/step0", []byte("new content")))
+	got, err = s.Read("logs/run-1/step0")
+	assert.NoError(t, err)
+	assert.Equal(t, "new content", string(got))
+
+	assert.NoError(t, s.Write("logs/run-1/empty", nil))
+	got, err = s.Read("logs/run-1/empty")
+	assert.NoError(t, err)
+	assert.Len(t, got, 0)
+
+	size, err := s.Delete("logs/run-1/step0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("new content")), size)
+	_, err = s.Read("logs/run-1/step0")
+	assert.True(t, os.IsNotExist(err), "%v", err)
+
+	size, err = s.Delete("logs/run-1/step0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestFSLogStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logstorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(configs.LogStorageConfig{Type: configs.LogStorageTypeFS, Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLogStorage(t, s)
+	// 删除文件后空目录也被清理
+	_, err = s.Delete("logs/run-1/empty")
+	assert.NoError(t, err)
+	_, err = os.Stat(dir + "/logs")
+	assert.True(t, os.IsNotExist(err), "%v", err)
+
+	// 不允许跳出存储目录
+	assert.NoError(t, s.Write("../../escape", []byte("x")))
+	_, err = os.Stat(dir + "/escape")
+	assert.NoError(t, err)
+	assert.Error(t, s.Write("/", []byte("x")))
+}
+
+// fakeS3Server 模拟 minio 的最简单实现，只支持 PUT/GET/HEAD/DELETE object
+type fakeS3Server struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ak/") ||
+		!strings.Contains(auth, "/cn-north-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		h := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(h[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3LogStorage(t *testing.T) {
+	fake := &fakeS3Server{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := New(configs.LogStorageConfig{
+		Type: configs.LogStorageTypeS3,
+		S3: configs.S3Config{
+			Endpoint:  srv.URL,
+			Region:    "cn-north-1",
+			Bucket:    "iac",
+			AccessKey: "ak",
+			SecretKey: "sk",
+			Prefix:    "cloudiac",
+			PathStyle: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.Write("logs/run-2/step0", []byte("x")))
+	_, ok := fake.objects["/iac/cloudiac/logs/run-2/step0"]
+	assert.True(t, ok)
+
+	testLogStorage(t, s)
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	s, err := newS3LogStorage(configs.S3Config{Endpoint: "https://s3.example.com", Bucket: "iac"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://iac.s3.example.com/logs/a%20b", s.objectURL("/logs/a b").String())
+
+	s.cfg.PathStyle = true
+	s.cfg.Prefix = "p/"
+	assert.Equal(t, "https://s3.example.com/iac/p/logs/run-1", s.objectURL("logs/run-1").String())
+}
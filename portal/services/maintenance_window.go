@@ -0,0 +1,58 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"fmt"
+)
+
+func CreateMaintenanceWindow(tx *db.Session, window models.MaintenanceWindow) (*models.MaintenanceWindow, e.Error) {
+	if window.Id == "" {
+		window.Id = models.NewId("mw")
+	}
+	if err := models.Create(tx, &window); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return &window, nil
+}
+
+func UpdateMaintenanceWindow(tx *db.Session, id models.Id, attrs models.Attrs) (*models.MaintenanceWindow, e.Error) {
+	if _, err := models.UpdateAttr(tx.Where("id = ?", id), &models.MaintenanceWindow{}, attrs); err != nil {
+		return nil, e.New(e.DBError, fmt.Errorf("update maintenance window error: %v", err))
+	}
+	return GetMaintenanceWindowById(tx, id)
+}
+
+func DeleteMaintenanceWindow(tx *db.Session, id models.Id) e.Error {
+	if _, err := tx.Where("id = ?", id).Delete(&models.MaintenanceWindow{}); err != nil {
+		return e.New(e.DBError, fmt.Errorf("delete maintenance window error: %v", err))
+	}
+	return nil
+}
+
+func GetMaintenanceWindowById(query *db.Session, id models.Id) (*models.MaintenanceWindow, e.Error) {
+	window := models.MaintenanceWindow{}
+	if err := query.Where("id = ?", id).First(&window); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.MaintenanceWindowNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &window, nil
+}
+
+func SearchMaintenanceWindow(query *db.Session, projectId models.Id) *db.Session {
+	return query.Model(&models.MaintenanceWindow{}).Where("project_id = ?", projectId).Order("created_at DESC")
+}
+
+// GetEnabledMaintenanceWindows 查询所有启用的维护窗口
+func GetEnabledMaintenanceWindows(query *db.Session) ([]*models.MaintenanceWindow, e.Error) {
+	windows := make([]*models.MaintenanceWindow, 0)
+	if err := query.Model(&models.MaintenanceWindow{}).Where("enabled = ?", true).Find(&windows); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return windows, nil
+}
@@ -193,6 +193,9 @@ func (ns *NotificationService) FindNotificationsAndMessageTpl() ([]models.Notifi
 	case consts.EventTaskFailed:
 		tplNotificationTemplate = consts.IacTaskFailedTpl
 		markdownNotificationTemplate = consts.IacTaskFailedMarkdown
+	case consts.EventTaskAborted:
+		tplNotificationTemplate = consts.IacTaskAbortedTpl
+		markdownNotificationTemplate = consts.IacTaskAbortedMarkdown
 	case consts.EventTaskComplete:
 		tplNotificationTemplate = consts.IacTaskCompleteTpl
 		markdownNotificationTemplate = consts.IacTaskCompleteMarkdown
@@ -0,0 +1,227 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+// OidcUser oidc 认证通过的用户信息
+type OidcUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool // id token 或 userinfo 中 email_verified 为 true
+	Name          string
+	Groups        []string
+}
+
+// OidcAuthRequest 发起 oidc 登录时生成的参数，签名后保存在 cookie 中，回调时用于校验
+type OidcAuthRequest struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	jwt.StandardClaims
+}
+
+const oidcHttpTimeout = 30 * time.Second
+
+var (
+	oidcProviders    = make(map[string]*oidc.Provider)
+	oidcProviderLock sync.Mutex
+)
+
+// getOidcProvider 通过 discovery 获取 oidc 服务配置，获取成功后缓存。
+// provider 会使用创建时的 context 更新 jwks，所以这里不使用请求的 context
+func getOidcProvider(issuer string) (*oidc.Provider, error) {
+	oidcProviderLock.Lock()
+	defer oidcProviderLock.Unlock()
+
+	if p, ok := oidcProviders[issuer]; ok {
+		return p, nil
+	}
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: oidcHttpTimeout})
+	p, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	oidcProviders[issuer] = p
+	return p, nil
+}
+
+func oidcOauth2Config(provider *oidc.Provider, cfg configs.OidcConfig) oauth2.Config {
+	return oauth2.Config{
+		ClientID:     cfg.ClientId,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  cfg.RedirectUrl,
+		Scopes:       cfg.Scopes,
+	}
+}
+
+func oidcRandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// NewOidcAuthRequest 生成 state、nonce 及 PKCE code verifier
+func NewOidcAuthRequest(expire time.Duration) OidcAuthRequest {
+	return OidcAuthRequest{
+		State:    oidcRandomString(),
+		Nonce:    oidcRandomString(),
+		Verifier: oidcRandomString(),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(expire).Unix(),
+		},
+	}
+}
+
+// oidc 登录请求使用单独的 audience 及由 secret 派生的签名密钥，避免被当作用户 token 使用
+const oidcAuthRequestAudience = "cloudiac-oidc-auth-request"
+
+func oidcAuthRequestKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(oidcAuthRequestAudience))
+	return mac.Sum(nil)
+}
+
+// EncodeOidcAuthRequest 将登录请求参数签名为 jwt
+func EncodeOidcAuthRequest(req OidcAuthRequest, secret string) (string, error) {
+	req.Audience = oidcAuthRequestAudience
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, req).SignedString(oidcAuthRequestKey(secret))
+}
+
+// DecodeOidcAuthRequest 校验签名及有效期并返回登录请求参数
+func DecodeOidcAuthRequest(s string, secret string) (*OidcAuthRequest, error) {
+	req := OidcAuthRequest{}
+	_, err := jwt.ParseWithClaims(s, &req, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return oidcAuthRequestKey(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !req.VerifyAudience(oidcAuthRequestAudience, true) {
+		return nil, fmt.Errorf("invalid audience")
+	}
+	return &req, nil
+}
+
+// OidcAuthCodeURL 返回 oidc 服务的登录地址(authorization code flow + PKCE)
+func OidcAuthCodeURL(cfg configs.OidcConfig, req OidcAuthRequest) (string, error) {
+	provider, err := getOidcProvider(cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(req.Verifier))
+	oauth2Config := oidcOauth2Config(provider, cfg)
+	return oauth2Config.AuthCodeURL(req.State,
+		oidc.Nonce(req.Nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// OidcExchange 使用 authorization code 获取并校验 id token，返回用户信息
+func OidcExchange(ctx context.Context, cfg configs.OidcConfig, code string, req OidcAuthRequest) (*OidcUser, error) {
+	provider, err := getOidcProvider(cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	oauth2Config := oidcOauth2Config(provider, cfg)
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", req.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %v", err)
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("id_token not found in token response")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: cfg.ClientId}).Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %v", err)
+	}
+	if idToken.Nonce != req.Nonce {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if claimString(claims, cfg.EmailClaim) == "" {
+		// id token 中没有 email 时从 userinfo 接口获取
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("get userinfo: %v", err)
+		}
+		infoClaims := make(map[string]interface{})
+		if err := userInfo.Claims(&infoClaims); err != nil {
+			return nil, err
+		}
+		for k, v := range infoClaims {
+			if _, ok := claims[k]; !ok {
+				claims[k] = v
+			}
+		}
+	}
+
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("email is not verified")
+	}
+	user := &OidcUser{
+		Subject:       idToken.Subject,
+		Email:         claimString(claims, cfg.EmailClaim),
+		EmailVerified: claims["email_verified"] == true,
+		Name:          claimString(claims, cfg.NameClaim),
+		Groups:        claimStrings(claims, cfg.GroupsClaim),
+	}
+	if user.Email == "" {
+		return nil, fmt.Errorf("claim '%s' not found", cfg.EmailClaim)
+	}
+	return user, nil
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	if v, ok := claims[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// claimStrings 获取字符串数组类型的 claim，兼容单个字符串的情况
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
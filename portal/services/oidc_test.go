@@ -0,0 +1,215 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// testOidcServer 测试使用的 oidc 服务，只支持 authorization code 及 PKCE(S256)
+type testOidcServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientId string
+
+	code      string
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newTestOidcServer(t *testing.T, clientId string) *testOidcServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testOidcServer{key: key, clientId: clientId}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                s.URL,
+			"authorization_endpoint":                s.URL + "/auth",
+			"token_endpoint":                        s.URL + "/token",
+			"jwks_uri":                              s.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// authorize 模拟用户在 oidc 服务完成登录，返回 authorization code
+func (s *testOidcServer) authorize(t *testing.T, authUrl string, claims jwt.MapClaims) string {
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	assert.Equal(t, s.clientId, q.Get("client_id"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	s.code = "code-" + q.Get("state")
+	s.challenge = q.Get("code_challenge")
+	s.claims = jwt.MapClaims{
+		"iss":   s.URL,
+		"aud":   s.clientId,
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		s.claims[k] = v
+	}
+	return s.code
+}
+
+func (s *testOidcServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("code") != s.code ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != s.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, s.claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func TestOidcExchange(t *testing.T) {
+	server := newTestOidcServer(t, "cloudiac")
+	defer server.Close()
+
+	cfg := configs.OidcConfig{
+		Enabled:     true,
+		Issuer:      server.URL,
+		ClientId:    "cloudiac",
+		RedirectUrl: "http://localhost/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		EmailClaim:  "email",
+		NameClaim:   "name",
+		GroupsClaim: "groups",
+	}
+	ctx := context.Background()
+	claims := jwt.MapClaims{
+		"email":  "alice@example.com",
+		"name":   "Alice",
+		"groups": []string{"devops", "ops"},
+	}
+
+	req := NewOidcAuthRequest(time.Minute)
+	authUrl, err := OidcAuthCodeURL(cfg, req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	code := server.authorize(t, authUrl, claims)
+	user, err := OidcExchange(ctx, cfg, code, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", user.Subject)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.False(t, user.EmailVerified)
+		assert.Equal(t, "Alice", user.Name)
+		assert.Equal(t, []string{"devops", "ops"}, user.Groups)
+	}
+
+	// email 已验证
+	code = server.authorize(t, authUrl, jwt.MapClaims{"email": "alice@example.com", "email_verified": true})
+	user, err = OidcExchange(ctx, cfg, code, req)
+	if assert.NoError(t, err) {
+		assert.True(t, user.EmailVerified)
+	}
+
+	// code verifier 不匹配
+	code = server.authorize(t, authUrl, claims)
+	badReq := req
+	badReq.Verifier = "wrong"
+	_, err = OidcExchange(ctx, cfg, code, badReq)
+	assert.Error(t, err)
+
+	// nonce 不匹配
+	code = server.authorize(t, authUrl, claims)
+	badReq = req
+	badReq.Nonce = "wrong"
+	_, err = OidcExchange(ctx, cfg, code, badReq)
+	assert.Error(t, err)
+
+	// email 未验证
+	code = server.authorize(t, authUrl, jwt.MapClaims{"email": "alice@example.com", "email_verified": false})
+	_, err = OidcExchange(ctx, cfg, code, req)
+	assert.Error(t, err)
+}
+
+func TestOidcAuthRequest(t *testing.T) {
+	req := NewOidcAuthRequest(time.Minute)
+	s, err := EncodeOidcAuthRequest(req, "secret")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	decoded, err := DecodeOidcAuthRequest(s, "secret")
+	if assert.NoError(t, err) {
+		assert.Equal(t, req.State, decoded.State)
+		assert.Equal(t, req.Nonce, decoded.Nonce)
+		assert.Equal(t, req.Verifier, decoded.Verifier)
+	}
+
+	_, err = DecodeOidcAuthRequest(s, "other")
+	assert.Error(t, err)
+
+	// 登录请求不能作为用户 token 使用
+	_, err = jwt.ParseWithClaims(s, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	})
+	assert.Error(t, err)
+
+	expired := NewOidcAuthRequest(-time.Minute)
+	s, _ = EncodeOidcAuthRequest(expired, "secret")
+	_, err = DecodeOidcAuthRequest(s, "secret")
+	assert.Error(t, err)
+}
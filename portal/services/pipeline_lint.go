@@ -0,0 +1,222 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/common"
+	"cloudiac/portal/models"
+	"cloudiac/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PipelineLintError pipeline 检查发现的错误，line 为 0 表示无法确定行号
+type PipelineLintError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e PipelineLintError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+type PipelineLintErrors []PipelineLintError
+
+func (es PipelineLintErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// 需要参数的步骤类型
+var pipelineStepsRequireArgs = []string{common.TaskStepCommand}
+
+var (
+	yamlErrorLineRegex    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlUnknownFieldRegex = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// LintPipeline 检查 pipeline 内容，包括 yaml 格式、未知的配置项、版本、步骤类型及参数等，返回带行号的错误列表
+func LintPipeline(content string) PipelineLintErrors {
+	errs := make(PipelineLintErrors, 0)
+
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(content), &raw); err != nil {
+		return append(errs, yamlLintErrors(err)...)
+	}
+	if raw == nil {
+		return append(errs, PipelineLintError{Line: 1, Message: "pipeline is empty"})
+	}
+	// 类型错误不影响行号的获取，在 pipeline 解码时报告
+	lines := pipelineLines{}
+	_ = yaml.Unmarshal([]byte(content), &lines)
+	if _, ok := raw.(map[interface{}]interface{}); !ok {
+		return append(errs, PipelineLintError{Line: lines.Line, Message: "pipeline must be a mapping"})
+	}
+
+	// 与 DecodePipeline 一样使用 yaml.v2 解码，strict 模式下会报告未知的配置项。
+	// 出现类型错误时 yaml 仍会解码其他字段，所以继续进行后续检查
+	pipeline := models.Pipeline{}
+	if err := yaml.UnmarshalStrict([]byte(content), &pipeline); err != nil {
+		errs = append(errs, yamlLintErrors(err)...)
+	}
+
+	if lines.Version == 0 {
+		errs = append(errs, PipelineLintError{Line: lines.Line, Message: "version is required"})
+	} else if _, ok := models.GetPipelineByVersion(pipeline.Version); !ok {
+		errs = append(errs, PipelineLintError{Line: int(lines.Version),
+			Message: fmt.Sprintf("unsupported version '%s'", pipeline.Version)})
+	}
+	stepOptionsSupported := pipelineVersionAtLeast(pipeline.Version, pipelineStepOptionsVersion)
+
+	for _, typ := range common.TaskJobTypes {
+		job := lines.Jobs[typ]
+		steps := make([]*pipelineStepLines, 0, len(job.Steps)+2)
+		for _, step := range job.Steps {
+			if step == nil {
+				// 值为 null 的步骤无法获取行号
+				step = &pipelineStepLines{}
+			}
+			steps = append(steps, step)
+		}
+		for _, step := range []*pipelineStepLines{job.OnSuccess, job.OnFail} {
+			if step != nil {
+				steps = append(steps, step)
+			}
+		}
+
+		for _, step := range steps {
+			if step.Invalid {
+				// 类型错误已在 pipeline 解码时报告
+				continue
+			}
+			errs = append(errs, lintPipelineStep(step, stepOptionsSupported)...)
+		}
+	}
+	return sortLintErrors(errs)
+}
+
+func lintPipelineStep(lines *pipelineStepLines, stepOptionsSupported bool) PipelineLintErrors {
+	errs := make(PipelineLintErrors, 0)
+
+	step := lines.Step
+	typeLine := lines.Line
+	if lines.TypeLine != 0 {
+		typeLine = lines.TypeLine
+	}
+	if step.Type == "" {
+		errs = append(errs, PipelineLintError{Line: lines.Line, Message: "step type is required"})
+	} else if !utils.StrInArray(step.Type, common.PipelineStepTypes...) {
+		errs = append(errs, PipelineLintError{Line: typeLine,
+			Message: fmt.Sprintf("unknown step type '%s'", step.Type)})
+	} else if utils.StrInArray(step.Type, pipelineStepsRequireArgs...) && len(step.Args) == 0 {
+		errs = append(errs, PipelineLintError{Line: lines.Line,
+			Message: fmt.Sprintf("step '%s' requires args", step.Type)})
+	}
+
+	if !stepOptionsSupported && hasPipelineStepOptions(step) {
+		errs = append(errs, PipelineLintError{Line: lines.Line, Message: errPipelineStepOptionsVersion.Error()})
+	}
+	if err := validatePipelineStep(step); err != nil {
+		errs = append(errs, PipelineLintError{Line: lines.Line, Message: err.Error()})
+	}
+	return errs
+}
+
+// yamlNodeLine 返回当前解码的 yaml 节点所在的行号。
+// yaml.v2 没有提供获取节点位置的接口，这里将节点解码为函数类型，从解码失败的错误信息中获取行号
+func yamlNodeLine(unmarshal func(interface{}) error) int {
+	var probe func()
+	if err := unmarshal(&probe); err != nil {
+		if errs := yamlLintErrors(err); len(errs) > 0 {
+			return errs[0].Line
+		}
+	}
+	return 0
+}
+
+// yamlLine 记录 yaml 节点所在的行号，0 表示节点不存在或者值为 null
+type yamlLine int
+
+func (l *yamlLine) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*l = yamlLine(yamlNodeLine(unmarshal))
+	return nil
+}
+
+// pipelineLines 记录 pipeline 中版本及各步骤所在的行号
+type pipelineLines struct {
+	Line    int                         `yaml:"-"`
+	Version yamlLine                    `yaml:"version"`
+	Jobs    map[string]pipelineJobLines `yaml:",inline"`
+}
+
+func (p *pipelineLines) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p.Line = yamlNodeLine(unmarshal)
+	type plain pipelineLines
+	return unmarshal((*plain)(p))
+}
+
+type pipelineJobLines struct {
+	Steps     []*pipelineStepLines `yaml:"steps"`
+	OnSuccess *pipelineStepLines   `yaml:"onSuccess"`
+	OnFail    *pipelineStepLines   `yaml:"onFail"`
+}
+
+type pipelineStepLines struct {
+	Line     int
+	TypeLine int
+	Step     models.PipelineStep
+	Invalid  bool // 步骤解码失败
+}
+
+func (s *pipelineStepLines) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	s.Line = yamlNodeLine(unmarshal)
+	typ := struct {
+		Type yamlLine `yaml:"type"`
+	}{}
+	_ = unmarshal(&typ)
+	s.TypeLine = int(typ.Type)
+	s.Invalid = unmarshal(&s.Step) != nil
+	return nil
+}
+
+// yamlLintErrors 将 yaml 解析错误转换为带行号的错误
+func yamlLintErrors(err error) PipelineLintErrors {
+	msgs := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		msgs = typeErr.Errors
+	}
+
+	errs := make(PipelineLintErrors, 0, len(msgs))
+	for _, msg := range msgs {
+		msg = strings.TrimPrefix(msg, "yaml: ")
+		if m := yamlErrorLineRegex.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			msg = m[2]
+			if m := yamlUnknownFieldRegex.FindStringSubmatch(msg); m != nil {
+				msg = fmt.Sprintf("unknown key '%s'", m[1])
+			}
+			errs = append(errs, PipelineLintError{Line: line, Message: msg})
+		} else {
+			errs = append(errs, PipelineLintError{Message: msg})
+		}
+	}
+	return errs
+}
+
+func sortLintErrors(errs PipelineLintErrors) PipelineLintErrors {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Line < errs[j].Line
+	})
+	return errs
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintPipeline(t *testing.T) {
+	errs := LintPipeline(`
+version: 0.4
+plan:
+  steps:
+    - type: checkout
+    - type: terrafromPlan
+    - type: command
+apply:
+  steps:
+    - type: terraformApply
+      timeout: abc
+      when:
+        state: failure
+  onFail:
+    type: command
+    args: ["echo failed"]
+    env:
+      1ST: x
+`)
+	assert.Equal(t, PipelineLintErrors{
+		{Line: 6, Message: "unknown step type 'terrafromPlan'"},
+		{Line: 7, Message: "step 'command' requires args"},
+		{Line: 11, Message: "cannot unmarshal !!str `abc` into int"},
+		{Line: 13, Message: "unknown key 'state'"},
+		{Line: 15, Message: "invalid env name '1ST'"},
+	}, errs)
+
+	errs = LintPipeline("version: 0.9\nplan:\n  steps: []\n")
+	assert.Equal(t, PipelineLintErrors{{Line: 1, Message: "unsupported version '0.9'"}}, errs)
+
+	errs = LintPipeline("plan:\n  steps:\n  - type: checkout\n    when:\n      status: always\n")
+	assert.Equal(t, 2, len(errs))
+	assert.Equal(t, "line 1: version is required", errs[0].Error())
+
+	errs = LintPipeline("version: 0.3\nplan: [\n")
+	assert.Equal(t, 1, len(errs))
+
+	assert.Empty(t, LintPipeline("version: 0.3\nplan:\n  steps:\n    - type: checkout\n"))
+
+	// 与 DecodePipeline 一样按 yaml 1.1 解码，yes/no 为布尔值
+	assert.Empty(t, LintPipeline("version: 0.4\nplan:\n  steps:\n    - type: checkout\n      continueOnError: yes\n"))
+
+	errs = LintPipeline("\n- type: checkout\n")
+	assert.Equal(t, PipelineLintErrors{{Line: 2, Message: "pipeline must be a mapping"}}, errs)
+}
@@ -5,6 +5,7 @@ package rbac
 import (
 	"cloudiac/configs"
 	"cloudiac/utils/logs"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -13,8 +14,9 @@ import (
 )
 
 var (
-	enforcer *casbin.Enforcer
-	initOnce sync.Once
+	enforcer     *casbin.Enforcer
+	enforcerLock sync.RWMutex
+	initOnce     sync.Once
 )
 
 // InitPolicy 初始化权限策略
@@ -22,35 +24,104 @@ func InitPolicy() {
 	initOnce.Do(func() {
 		logger := logs.Get().WithField("func", "initPolicy")
 		logger.Infoln("init rbac policies ...")
-		var (
-			cModel model.Model
-			err    error
-		)
 
-		// 加载策略模型
-		cModel, err = model.NewModelFromString(configs.RbacModel)
+		var err error
+		enforcer, err = newEnforcer(nil)
 		if err != nil {
-			logger.Panicf("casbin load model: %v", err)
+			logger.Panic(err)
 		}
+	})
+}
 
-		enforcer, err = casbin.NewEnforcer(cModel)
-		if err != nil {
-			logger.Panicf("create enforcer: %v", err)
-		}
+// newEnforcer 加载内置策略及自定义角色策略，创建 enforcer
+func newEnforcer(customPolicies []configs.Policy) (*casbin.Enforcer, error) {
+	logger := logs.Get().WithField("func", "newEnforcer")
+
+	// 加载策略模型
+	cModel, err := model.NewModelFromString(configs.RbacModel)
+	if err != nil {
+		return nil, fmt.Errorf("casbin load model: %v", err)
+	}
+
+	e, err := casbin.NewEnforcer(cModel)
+	if err != nil {
+		return nil, fmt.Errorf("create enforcer: %v", err)
+	}
 
-		// 加载策略
-		for _, policy := range configs.Polices {
-			for _, act := range strings.Split(policy.Act, "/") {
-				logger.Tracef("add policy: %s %s %s", policy.Sub, policy.Obj, act)
-				if _, err := enforcer.AddPolicy(policy.Sub, policy.Obj, act); err != nil {
-					logger.Panicf("add policy: %v", err)
-				}
+	// 加载策略
+	policies := make([]configs.Policy, 0, len(configs.Polices)+len(customPolicies))
+	policies = append(policies, configs.Polices...)
+	policies = append(policies, customPolicies...)
+	for _, policy := range policies {
+		for _, act := range strings.Split(policy.Act, "/") {
+			logger.Tracef("add policy: %s %s %s", policy.Sub, policy.Obj, act)
+			if _, err := e.AddPolicy(policy.Sub, policy.Obj, act); err != nil {
+				return nil, fmt.Errorf("add policy: %v", err)
 			}
 		}
-	})
+	}
+	return e, nil
+}
+
+// SetCustomPolicies 使用内置策略及传入的自定义角色策略重建 enforcer，
+// 自定义角色变更后调用，实现策略的热加载
+func SetCustomPolicies(policies []configs.Policy) error {
+	InitPolicy()
+
+	e, err := newEnforcer(policies)
+	if err != nil {
+		return err
+	}
+
+	enforcerLock.Lock()
+	defer enforcerLock.Unlock()
+	enforcer = e
+	return nil
 }
 
 func Enforce(vals ...interface{}) (bool, error) {
 	InitPolicy()
-	return enforcer.Enforce(vals...)
+
+	enforcerLock.RLock()
+	e := enforcer
+	enforcerLock.RUnlock()
+	return e.Enforce(vals...)
+}
+
+// GrantablePermissions 返回可以授权给自定义角色的权限(资源 -> 动作集合)，
+// 即内置角色实际拥有的权限，平台管理员等内置角色专有的权限不能授权。
+// 只有内置角色在资源上拥有 * 时动作集合中才包含 *
+func GrantablePermissions(builtinRoles []string) map[string]map[string]bool {
+	roles := make(map[string]bool)
+	for _, r := range builtinRoles {
+		roles[r] = true
+	}
+
+	perms := make(map[string]map[string]bool)
+	for _, p := range configs.Polices {
+		if !roles[p.Sub] {
+			continue
+		}
+		if perms[p.Obj] == nil {
+			perms[p.Obj] = make(map[string]bool)
+		}
+		for _, act := range strings.Split(p.Act, "/") {
+			perms[p.Obj][act] = true
+		}
+	}
+	return perms
+}
+
+// IsGrantable 检查资源的动作是否可以授权给自定义角色，act 支持 a/b/c 格式
+func IsGrantable(perms map[string]map[string]bool, obj string, act string) bool {
+	acts, ok := perms[obj]
+	if !ok {
+		return false
+	}
+	for _, a := range strings.Split(act, "/") {
+		if !acts["*"] && !acts[a] {
+			return false
+		}
+	}
+	return true
 }
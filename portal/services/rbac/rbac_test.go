@@ -0,0 +1,55 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package rbac
+
+import (
+	"cloudiac/configs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCustomPolicies(t *testing.T) {
+	const roleId = "role-c3ek0co6n88ldvq1n6ag"
+	enforce := func(org, proj, obj, act string) bool {
+		allow, err := Enforce(org, proj, obj, act)
+		assert.NoError(t, err)
+		return allow
+	}
+
+	assert.True(t, enforce("admin", "", "projects", "create"))
+	assert.False(t, enforce(roleId, "", "envs", "deploy"))
+
+	assert.NoError(t, SetCustomPolicies([]configs.Policy{
+		{Sub: roleId, Obj: "envs", Act: "read/deploy"},
+		{Sub: roleId, Obj: "tasks", Act: "*"},
+	}))
+	assert.True(t, enforce(roleId, "", "envs", "deploy"))
+	assert.True(t, enforce("", roleId, "envs", "read"))
+	assert.False(t, enforce(roleId, "", "envs", "destroy"))
+	assert.True(t, enforce(roleId, "", "tasks", "abort"))
+	// 内置策略保持不变
+	assert.True(t, enforce("admin", "", "projects", "create"))
+
+	// 重新加载后删除的角色不再有权限
+	assert.NoError(t, SetCustomPolicies(nil))
+	assert.False(t, enforce(roleId, "", "envs", "deploy"))
+}
+
+func TestGrantablePermissions(t *testing.T) {
+	perms := GrantablePermissions([]string{"manager", "guest"})
+	assert.True(t, IsGrantable(perms, "envs", "*"))
+	assert.True(t, IsGrantable(perms, "envs", "deploy"))
+	assert.False(t, IsGrantable(perms, "policies", "read"))
+	assert.False(t, IsGrantable(perms, "users", "read"))
+
+	perms = GrantablePermissions([]string{"admin", "member"})
+	assert.True(t, IsGrantable(perms, "orgs", "read"))
+	assert.True(t, IsGrantable(perms, "orgs", "read/update"))
+	assert.True(t, IsGrantable(perms, "orgs", "adduser"))
+	// orgs 的 create 及 * 只有平台管理员拥有
+	assert.False(t, IsGrantable(perms, "orgs", "create"))
+	assert.False(t, IsGrantable(perms, "orgs", "*"))
+	assert.False(t, IsGrantable(perms, "orgs", "read/create"))
+	assert.True(t, IsGrantable(perms, "projects", "*"))
+}
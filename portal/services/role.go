@@ -0,0 +1,173 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services/rbac"
+	"cloudiac/utils/logs"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+func CreateRole(tx *db.Session, role models.Role) (*models.Role, e.Error) {
+	if role.Id == "" {
+		role.Id = role.NewId()
+	}
+	if err := models.Create(tx, &role); err != nil {
+		if e.IsDuplicate(err) {
+			return nil, e.New(e.RoleAlreadyExists, err, http.StatusBadRequest)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &role, nil
+}
+
+func UpdateRole(tx *db.Session, id models.Id, attrs models.Attrs) (*models.Role, e.Error) {
+	if _, err := models.UpdateAttr(tx.Where("id = ?", id), &models.Role{}, attrs); err != nil {
+		if e.IsDuplicate(err) {
+			return nil, e.New(e.RoleAlreadyExists, err, http.StatusBadRequest)
+		}
+		return nil, e.New(e.DBError, fmt.Errorf("update role error: %v", err))
+	}
+	return GetRoleById(tx, id)
+}
+
+func DeleteRole(tx *db.Session, id models.Id) e.Error {
+	if _, err := tx.Where("id = ?", id).Delete(&models.Role{}); err != nil {
+		return e.New(e.DBError, fmt.Errorf("delete role error: %v", err))
+	}
+	return nil
+}
+
+func GetRoleById(query *db.Session, id models.Id) (*models.Role, e.Error) {
+	role := models.Role{}
+	if err := query.Where("id = ?", id).First(&role); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.RoleNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return &role, nil
+}
+
+func SearchRole(query *db.Session, orgId models.Id, scope string, q string) *db.Session {
+	query = query.Model(&models.Role{}).Where("org_id = ?", orgId)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if q != "" {
+		query = query.WhereLike("name", q)
+	}
+	return query.Order("created_at DESC")
+}
+
+// IsRoleInUse 角色是否已分配给组织成员、项目成员、api token 或者被审批策略使用
+func IsRoleInUse(query *db.Session, id models.Id) (bool, e.Error) {
+	for _, m := range []interface{}{&models.UserOrg{}, &models.UserProject{}, &models.Token{}} {
+		count, err := query.Model(m).Where("role = ?", id).Count()
+		if err != nil {
+			return false, e.New(e.DBError, err)
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	// 审批策略中的审批角色，角色 id 唯一，这里按子串匹配
+	pattern := "%" + id.String() + "%"
+	count, err := query.Model(&models.ApprovalPolicy{}).
+		Where("apply_approver_roles LIKE ? OR destroy_approver_roles LIKE ?", pattern, pattern).Count()
+	if err != nil {
+		return false, e.New(e.DBError, err)
+	}
+	return count > 0, nil
+}
+
+// CheckRole 检查角色是否可以在组织中分配，scope 为 org 时允许内置组织角色及组织自定义角色，
+// 为 project 时允许内置项目角色及组织的项目自定义角色
+func CheckRole(query *db.Session, orgId models.Id, scope string, role string) e.Error {
+	builtin := consts.OrgRoles
+	if scope == consts.ScopeProject {
+		builtin = consts.ProjectRoles
+	}
+	for _, r := range builtin {
+		if r == role {
+			return nil
+		}
+	}
+
+	r, err := GetRoleById(query, models.Id(role))
+	if err != nil && err.Code() != e.RoleNotExists {
+		return err
+	}
+	if r == nil || r.OrgId != orgId || r.Scope != scope {
+		return e.New(e.InvalidRoleName, fmt.Errorf("invalid %s role '%s'", scope, role), http.StatusBadRequest)
+	}
+	return nil
+}
+
+var roleActionRegex = regexp.MustCompile(`^(\*|[a-z_]+)$`)
+
+// CheckRolePermissions 检查自定义角色的权限，只能授权同类型的内置角色实际拥有的权限(资源及动作)，
+// 只有内置角色在资源上拥有 * 时才能授权 *
+func CheckRolePermissions(scope string, permissions models.RolePermissions) e.Error {
+	builtin := consts.OrgRoles
+	if scope == consts.ScopeProject {
+		builtin = consts.ProjectRoles
+	}
+	perms := rbac.GrantablePermissions(builtin)
+	for _, p := range permissions {
+		if !roleActionRegex.MatchString(p.Act) {
+			return e.New(e.BadParam, fmt.Errorf("invalid action '%s'", p.Act), http.StatusBadRequest)
+		}
+		if !rbac.IsGrantable(perms, p.Obj, p.Act) {
+			return e.New(e.BadParam, fmt.Errorf("permission '%s:%s' is not allowed for %s role", p.Obj, p.Act, scope),
+				http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// LoadRolePolicies 从数据库加载所有自定义角色的权限并更新 rbac 策略
+func LoadRolePolicies(query *db.Session) e.Error {
+	roles := make([]models.Role, 0)
+	if err := query.Model(&models.Role{}).Find(&roles); err != nil {
+		return e.New(e.DBError, err)
+	}
+
+	policies := make([]configs.Policy, 0)
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			policies = append(policies, configs.Policy{Sub: role.Id.String(), Obj: p.Obj, Act: p.Act})
+		}
+	}
+	if err := rbac.SetCustomPolicies(policies); err != nil {
+		return e.New(e.InternalError, err)
+	}
+	return nil
+}
+
+// InitRolePolicies 加载自定义角色策略，并定期重新加载，以同步其他 portal 实例对角色的修改
+func InitRolePolicies() {
+	logger := logs.Get().WithField("func", "InitRolePolicies")
+	if err := LoadRolePolicies(db.Get()); err != nil {
+		logger.Errorf("load role policies: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(consts.RolePolicyReloadInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := LoadRolePolicies(db.Get()); err != nil {
+				logger.Errorf("reload role policies: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/lib/pq"
+
+	"cloudiac/configs"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+)
+
+// RunnerRegistry runner 注册中心。
+// runner 信息统一使用 consul 的 AgentService 结构返回，以保持 runner 列表接口的返回格式不变
+type RunnerRegistry interface {
+	ListRunners() ([]*api.AgentService, e.Error)
+	GetRunner(runnerId string) (*api.AgentService, e.Error)
+	UpdateRunnerTags(runnerId string, tags []string) e.Error
+}
+
+// GetRunnerRegistry 根据 registry 配置返回 runner 注册中心
+func GetRunnerRegistry() RunnerRegistry {
+	if configs.Get().Registry.IsDB() {
+		return dbRunnerRegistry{}
+	}
+	return consulRunnerRegistry{}
+}
+
+type consulRunnerRegistry struct{}
+
+func (consulRunnerRegistry) ListRunners() ([]*api.AgentService, e.Error) {
+	resp := make([]*api.AgentService, 0)
+
+	conf := configs.Get()
+	config := api.DefaultConfig()
+	config.Address = conf.Consul.Address
+
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, e.New(e.ConsulConnError, err)
+	}
+	//client.Catalog().ServiceMultipleTags()
+	services, err := client.Agent().Services()
+	if err != nil {
+		return nil, e.New(e.ConsulConnError, err)
+	}
+
+	for serviceName, _ := range services {
+		if strings.Contains(strings.ToLower(serviceName), "runner") {
+			resp = append(resp, services[serviceName])
+		}
+	}
+
+	return resp, nil
+}
+
+func (consulRunnerRegistry) GetRunner(runnerId string) (*api.AgentService, e.Error) {
+	return ConsulServiceInfo(runnerId)
+}
+
+func (consulRunnerRegistry) UpdateRunnerTags(runnerId string, tags []string) e.Error {
+	//将修改后的tag存到consul中
+	if err := ConsulKVSave(runnerId, tags); err != nil {
+		return err
+	}
+	//根据serviceId查询在consul中保存的数据
+	agentService, err := ConsulServiceInfo(runnerId)
+	if err != nil {
+		return err
+	}
+	//重新注册
+	return ConsulServiceRegistered(agentService, tags)
+}
+
+const (
+	// runnerExpireTime runner 超过该时间未重新注册时视为已下线(runner 每分钟注册一次)
+	runnerExpireTime = 3 * time.Minute
+	// runnerCleanTime runner 超过该时间未重新注册时删除其注册信息
+	runnerCleanTime = 24 * time.Hour
+)
+
+type dbRunnerRegistry struct{}
+
+// ListRunners 只返回在线(未过期)的 runner
+func (dbRunnerRegistry) ListRunners() ([]*api.AgentService, e.Error) {
+	runners := make([]*models.Runner, 0)
+	query := db.Get().Where("updated_at > ?", time.Now().Add(-runnerExpireTime))
+	if err := query.Order("id").Find(&runners); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+
+	resp := make([]*api.AgentService, 0, len(runners))
+	for _, r := range runners {
+		resp = append(resp, r.AgentService())
+	}
+	return resp, nil
+}
+
+func (dbRunnerRegistry) GetRunner(runnerId string) (*api.AgentService, e.Error) {
+	runner := models.Runner{}
+	if err := db.Get().Where("id = ?", runnerId).First(&runner); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.RunnerNotExists, fmt.Errorf("runner %s not exists", runnerId), http.StatusNotFound)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return runner.AgentService(), nil
+}
+
+func (dbRunnerRegistry) UpdateRunnerTags(runnerId string, tags []string) e.Error {
+	attrs := models.Attrs{"tags": pq.StringArray(tags), "tags_updated": true}
+	if n, err := db.Get().Model(&models.Runner{}).Where("id = ?", runnerId).UpdateAttrs(attrs); err != nil {
+		return e.New(e.DBError, err)
+	} else if n == 0 {
+		return e.New(e.RunnerNotExists, fmt.Errorf("runner %s not exists", runnerId), http.StatusNotFound)
+	}
+	return nil
+}
+
+// RegisterRunner 保存 runner 注册信息(registry 配置为 db 时使用)，runner 会定时重复注册
+func RegisterRunner(sess *db.Session, runner *models.Runner) e.Error {
+	exists := models.Runner{}
+	if err := sess.Where("id = ?", runner.Id).First(&exists); err != nil {
+		if !e.IsRecordNotFound(err) {
+			return e.New(e.DBError, err)
+		}
+		if err := sess.Insert(runner); err != nil {
+			return e.New(e.DBError, err)
+		}
+		return nil
+	}
+
+	attrs := models.Attrs{
+		"service":    runner.Service,
+		"address":    runner.Address,
+		"port":       runner.Port,
+		"updated_at": models.Time(time.Now()),
+	}
+	// 通过接口修改过 tags 后不再使用 runner 配置的 tags
+	if !exists.TagsUpdated {
+		attrs["tags"] = runner.Tags
+	}
+	if _, err := sess.Model(&models.Runner{}).Where("id = ?", runner.Id).UpdateAttrs(attrs); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
+
+// DeregisterRunner 删除 runner 注册信息(registry 配置为 db 时使用)，runner 退出时调用
+func DeregisterRunner(sess *db.Session, runnerId string) e.Error {
+	if n, err := sess.Where("id = ?", runnerId).Delete(&models.Runner{}); err != nil {
+		return e.New(e.DBError, err)
+	} else if n == 0 {
+		return e.New(e.RunnerNotExists, fmt.Errorf("runner %s not exists", runnerId), http.StatusNotFound)
+	}
+	return nil
+}
+
+// CleanExpiredRunners 删除长时间未重新注册的 runner，返回删除的数量
+func CleanExpiredRunners(sess *db.Session) (int64, e.Error) {
+	n, err := sess.Where("updated_at < ?", time.Now().Add(-runnerCleanTime)).Delete(&models.Runner{})
+	if err != nil {
+		return 0, e.New(e.DBError, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/runner"
+	"cloudiac/utils"
+)
+
+// RunnerUnhealthyThreshold 连续心跳失败达到该次数后将 runner 标记为不健康
+const RunnerUnhealthyThreshold = 3
+
+// FetchRunnerStatus 调用 runner 心跳接口获取容量及健康状态
+func FetchRunnerStatus(runnerAddr string) (*runner.RunnerStatus, error) {
+	requestUrl := utils.JoinURL(runnerAddr, consts.RunnerHeartbeatURL)
+	respData, err := utils.HttpService(requestUrl, http.MethodGet, nil, nil,
+		int(consts.RunnerConnectTimeout.Seconds()), int(consts.RunnerConnectTimeout.Seconds())*2)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Error  string              `json:"error"`
+		Result runner.RunnerStatus `json:"result"`
+	}{}
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return nil, fmt.Errorf("unexpected response: %s", respData)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf(resp.Error)
+	}
+	return &resp.Result, nil
+}
+
+// RefreshRunnerStatus 对 runner 进行心跳检查并保存最新状态
+func RefreshRunnerStatus(sess *db.Session, service *api.AgentService) (*models.RunnerStatus, e.Error) {
+	status := &models.RunnerStatus{}
+	if err := sess.Where("runner_id = ?", service.ID).First(status); err != nil {
+		if !e.IsRecordNotFound(err) {
+			return nil, e.New(e.DBError, err)
+		}
+		status = &models.RunnerStatus{RunnerId: service.ID}
+	}
+	status.Tags = service.Tags
+
+	rs, er := FetchRunnerStatus(fmt.Sprintf("http://%s:%d", service.Address, service.Port))
+	if er != nil {
+		status.FailedCount += 1
+		if status.FailedCount >= RunnerUnhealthyThreshold {
+			status.Healthy = false
+			status.Message = fmt.Sprintf("heartbeat failed: %v", er)
+		}
+	} else {
+		now := models.Time(time.Now())
+		status.LastHeartbeatAt = &now
+		status.FailedCount = 0
+		status.Version = rs.Version
+		status.MaxTasks = rs.MaxTasks
+		status.CPUs = rs.CPUs
+		status.LoadAvg = rs.LoadAvg
+		status.DiskTotal = rs.DiskTotal
+		status.DiskFree = rs.DiskFree
+		status.ExecutorHealthy = rs.ExecutorHealthy
+		status.Healthy = rs.ExecutorHealthy
+		status.Message = ""
+		if !rs.ExecutorHealthy {
+			status.Message = fmt.Sprintf("executor unavailable: %s", rs.ExecutorError)
+		}
+	}
+
+	if _, er := sess.Save(status); er != nil {
+		return nil, e.New(e.DBError, er)
+	}
+	return status, nil
+}
+
+func QueryRunnerStatus(sess *db.Session) ([]*models.RunnerStatus, e.Error) {
+	statuses := make([]*models.RunnerStatus, 0)
+	if err := sess.Model(&models.RunnerStatus{}).Find(&statuses); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return statuses, nil
+}
+
+// DeleteRunnerStatusExcept 删除己下线(不在 runnerIds 中)的 runner 状态
+func DeleteRunnerStatusExcept(sess *db.Session, runnerIds []string) e.Error {
+	query := sess.Where("1 = 1")
+	if len(runnerIds) > 0 {
+		query = sess.Where("runner_id NOT IN (?)", runnerIds)
+	}
+	if _, err := query.Delete(&models.RunnerStatus{}); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
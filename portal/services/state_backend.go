@@ -0,0 +1,134 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/models"
+	"cloudiac/utils"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// DefaultStateBackend 环境未配置 state 存储时使用的存储方式，db 注册模式下不部署 consul，默认使用 local 存储
+func DefaultStateBackend(registry configs.RegistryConfig) string {
+	if registry.IsDB() {
+		return models.StateBackendLocal
+	}
+	return models.StateBackendConsul
+}
+
+var (
+	s3BucketRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{1,254}$`)
+	s3RegionRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// CheckStateBackend 检查 state 存储配置是否有效
+func CheckStateBackend(backend string, conf models.StateBackendConfig, registry configs.RegistryConfig) e.Error {
+	switch backend {
+	case "", models.StateBackendLocal:
+		return nil
+	case models.StateBackendConsul:
+		if registry.IsDB() {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("consul state backend is not available when registry is db"))
+		}
+		return nil
+	case models.StateBackendS3:
+		if conf.Bucket == "" {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("s3 bucket is required"))
+		}
+		if !s3BucketRegex.MatchString(conf.Bucket) {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("invalid s3 bucket '%s'", conf.Bucket))
+		}
+		if conf.Region == "" && conf.Endpoint == "" {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("s3 region or endpoint is required"))
+		}
+		if conf.Region != "" && !s3RegionRegex.MatchString(conf.Region) {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("invalid s3 region '%s'", conf.Region))
+		}
+		if conf.Endpoint != "" && !isHttpUrl(conf.Endpoint) {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("invalid s3 endpoint '%s'", conf.Endpoint))
+		}
+		return nil
+	case models.StateBackendHttp:
+		for _, addr := range []string{conf.Address, conf.LockAddress, conf.UnlockAddress} {
+			if addr != "" && !isHttpUrl(addr) {
+				return e.New(e.EnvStateBackendInvalid, fmt.Errorf("invalid http address '%s'", addr))
+			}
+		}
+		if conf.Address == "" {
+			return e.New(e.EnvStateBackendInvalid, fmt.Errorf("http address is required"))
+		}
+		return nil
+	default:
+		return e.New(e.EnvStateBackendInvalid, fmt.Errorf("unsupported state backend '%s'", backend))
+	}
+}
+
+func isHttpUrl(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// EncryptStateBackendSecret 加密 state 存储认证信息，未配置认证信息时返回空字符串
+func EncryptStateBackendSecret(secret models.StateBackendSecret) (string, e.Error) {
+	if secret == (models.StateBackendSecret{}) {
+		return "", nil
+	}
+	bs, err := json.Marshal(secret)
+	if err != nil {
+		return "", e.New(e.InternalError, err)
+	}
+	encrypted, err := utils.AesEncrypt(string(bs))
+	if err != nil {
+		return "", e.New(e.InternalError, err)
+	}
+	return encrypted, nil
+}
+
+func DecryptStateBackendSecret(encrypted string) (*models.StateBackendSecret, error) {
+	secret := models.StateBackendSecret{}
+	if encrypted == "" {
+		return &secret, nil
+	}
+	bs, err := utils.AesDecrypt(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(bs), &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// GetStateBackendSysEnvs 生成 state 存储认证需要的系统环境变量，变量值加密传输
+func GetStateBackendSysEnvs(env *models.Env) (map[string]string, error) {
+	secret, err := DecryptStateBackendSecret(env.StateBackendSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	switch env.StateBackend {
+	case models.StateBackendS3:
+		vars["AWS_ACCESS_KEY_ID"] = secret.AccessKey
+		vars["AWS_SECRET_ACCESS_KEY"] = secret.SecretKey
+	case models.StateBackendHttp:
+		vars["TF_HTTP_USERNAME"] = secret.Username
+		vars["TF_HTTP_PASSWORD"] = secret.Password
+	}
+
+	for k, v := range vars {
+		if v == "" {
+			delete(vars, k)
+			continue
+		}
+		if vars[k], err = utils.EncryptSecretVar(v); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStateBackend(t *testing.T) {
+	cases := []struct {
+		backend string
+		conf    models.StateBackendConfig
+		valid   bool
+	}{
+		{"", models.StateBackendConfig{}, true},
+		{models.StateBackendConsul, models.StateBackendConfig{}, true},
+		{models.StateBackendLocal, models.StateBackendConfig{}, true},
+		{models.StateBackendS3, models.StateBackendConfig{}, false},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: "iac"}, false},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: "iac", Region: "cn-north-1"}, true},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: `iac"`, Region: "cn-north-1"}, false},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: "iac", Region: "cn-north-1\"\n"}, false},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: "iac", Endpoint: "minio:9000"}, false},
+		{models.StateBackendS3, models.StateBackendConfig{Bucket: "iac", Endpoint: "http://minio:9000"}, true},
+		{models.StateBackendHttp, models.StateBackendConfig{}, false},
+		{models.StateBackendHttp, models.StateBackendConfig{Address: "http://state/env"}, true},
+		{models.StateBackendHttp, models.StateBackendConfig{Address: "http://state/env", LockAddress: "state"}, false},
+		{"gcs", models.StateBackendConfig{}, false},
+	}
+	for _, c := range cases {
+		err := CheckStateBackend(c.backend, c.conf, configs.RegistryConfig{})
+		assert.Equal(t, c.valid, err == nil, "%s %+v", c.backend, c.conf)
+	}
+
+	// db 注册模式下没有 consul
+	dbRegistry := configs.RegistryConfig{Type: configs.RegistryDB}
+	assert.Error(t, CheckStateBackend(models.StateBackendConsul, models.StateBackendConfig{}, dbRegistry))
+	assert.NoError(t, CheckStateBackend(models.StateBackendLocal, models.StateBackendConfig{}, dbRegistry))
+}
+
+func TestDefaultStateBackend(t *testing.T) {
+	assert.Equal(t, models.StateBackendConsul, DefaultStateBackend(configs.RegistryConfig{}))
+	assert.Equal(t, models.StateBackendLocal, DefaultStateBackend(configs.RegistryConfig{Type: configs.RegistryDB}))
+}
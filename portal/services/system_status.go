@@ -5,9 +5,11 @@ package services
 import (
 	"cloudiac/configs"
 	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/utils"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/pkg/errors"
@@ -18,6 +20,9 @@ func SystemStatusSearch() ([]api.AgentService, map[string]api.AgentCheck, []stri
 	IdInfo := make([]api.AgentService, 0)
 	serviceStatus := make(map[string]api.AgentCheck, 0)
 	conf := configs.Get()
+	if conf.Registry.IsDB() {
+		return dbSystemStatusSearch()
+	}
 	config := api.DefaultConfig()
 	config.Address = conf.Consul.Address
 
@@ -50,8 +55,56 @@ func SystemStatusSearch() ([]api.AgentService, map[string]api.AgentCheck, []stri
 	return IdInfo, serviceStatus, serviceList, nil
 }
 
+// dbSystemStatusSearch registry 为 db 时根据注册的 runner 及心跳状态构建服务状态
+func dbSystemStatusSearch() ([]api.AgentService, map[string]api.AgentCheck, []string, e.Error) {
+	runners, er := GetRunnerRegistry().ListRunners()
+	if er != nil {
+		return nil, nil, nil, er
+	}
+	statuses, er := QueryRunnerStatus(db.Get())
+	if er != nil {
+		return nil, nil, nil, er
+	}
+	statusMap := make(map[string]*models.RunnerStatus, len(statuses))
+	for _, s := range statuses {
+		statusMap[s.RunnerId] = s
+	}
+
+	serviceList := make([]string, 0)
+	IdInfo := make([]api.AgentService, 0, len(runners))
+	serviceStatus := make(map[string]api.AgentCheck, len(runners))
+	for _, r := range runners {
+		if !utils.StrInArray(r.Service, serviceList...) {
+			serviceList = append(serviceList, r.Service)
+		}
+		IdInfo = append(IdInfo, *r)
+
+		check := api.AgentCheck{ServiceID: r.ID, Status: api.HealthCritical}
+		if s, ok := statusMap[r.ID]; ok {
+			if s.Healthy {
+				check.Status = api.HealthPassing
+			}
+			check.Output = s.Message
+		}
+		serviceStatus[r.ID] = check
+	}
+	return IdInfo, serviceStatus, serviceList, nil
+}
+
 func ConsulKVSearch(key string) (interface{}, e.Error) {
 	conf := configs.Get()
+	if conf.Registry.IsDB() {
+		// runner tags 保存在数据库中，返回与 consul kv 相同格式的数据
+		runner, err := GetRunnerRegistry().GetRunner(key)
+		if err != nil {
+			if err.Code() == e.RunnerNotExists {
+				return nil, nil
+			}
+			return nil, err
+		}
+		bs, _ := json.Marshal(runner.Tags)
+		return string(bs), nil
+	}
 	config := api.DefaultConfig()
 	config.Address = conf.Consul.Address
 
@@ -71,29 +124,7 @@ func ConsulKVSearch(key string) (interface{}, e.Error) {
 }
 
 func RunnerSearch() ([]*api.AgentService, e.Error) {
-	resp := make([]*api.AgentService, 0)
-
-	conf := configs.Get()
-	config := api.DefaultConfig()
-	config.Address = conf.Consul.Address
-
-	client, err := api.NewClient(config)
-	if err != nil {
-		return nil, e.New(e.ConsulConnError, err)
-	}
-	//client.Catalog().ServiceMultipleTags()
-	services, err := client.Agent().Services()
-	if err != nil {
-		return nil, e.New(e.ConsulConnError, err)
-	}
-
-	for serviceName, _ := range services {
-		if strings.Contains(strings.ToLower(serviceName), "runner") {
-			resp = append(resp, services[serviceName])
-		}
-	}
-
-	return resp, nil
+	return GetRunnerRegistry().ListRunners()
 }
 
 func ConsulKVSave(key string, values []string) e.Error {
@@ -163,7 +194,7 @@ func ConsulServiceRegistered(serviceInfo *api.AgentService, tags []string) e.Err
 }
 
 func GetRunnerAddress(serviceId string) (string, error) {
-	s, err := ConsulServiceInfo(serviceId)
+	s, err := GetRunnerRegistry().GetRunner(serviceId)
 	if err != nil {
 		return "", errors.Wrapf(err, "get runner address, runnerId %s", serviceId)
 	}
@@ -142,17 +142,67 @@ func CloneNewDriftTask(tx *db.Session, src models.Task, env *models.Env) (*model
 	task.Name = common.CronDriftTaskName
 	task.Type = cronTaskType
 	task.IsDriftTask = true
+	task.Priority = models.TaskPriorityCronDrift
 	task.RepoAddr = repoAddr
 	task.CommitId = src.CommitId
 	task.CreatorId = consts.SysUserId
 	task.AutoApprove = env.AutoApproval
 	task.StopOnViolation = env.StopOnViolation
-	task.RunnerId = env.RunnerId
+	setTaskRunner(task, env.RunnerId, env)
 	task.KeyId = env.KeyId
 
 	return doCreateTask(tx, *task, tpl, env)
 }
 
+// CloneNewCronDeployTask 基于环境最后一次部署任务的参数创建定时部署任务
+func CloneNewCronDeployTask(tx *db.Session, src models.Task, env *models.Env) (*models.Task, e.Error) {
+	return cloneDeployTask(tx, src, env, func(task *models.Task) {
+		task.Name = common.CronDeployTaskName
+		task.Priority = models.TaskPriorityCronDeploy
+		task.CreatorId = consts.SysUserId
+	})
+}
+
+// CloneNewStackTask 基于环境最后一次部署任务的参数创建环境栈部署任务
+func CloneNewStackTask(tx *db.Session, src models.Task, env *models.Env, stack *models.DeployStack) (*models.Task, e.Error) {
+	return cloneDeployTask(tx, src, env, func(task *models.Task) {
+		task.Name = common.DeployStackTaskName
+		task.Priority = models.TaskPriorityManual
+		task.CreatorId = stack.CreatorId
+		task.StackId = stack.Id
+	})
+}
+
+// cloneDeployTask 基于 src 任务的参数创建 apply 任务，任务使用分支/标签最新的 commit 执行，
+// setter 用于设置任务的名称、创建者等属性
+func cloneDeployTask(tx *db.Session, src models.Task, env *models.Env, setter func(task *models.Task)) (*models.Task, e.Error) {
+	tpl, err := GetTemplateById(tx, src.TplId)
+	if err != nil {
+		return nil, e.New(e.InternalError, err)
+	}
+
+	task, er := newCommonTask(tpl, env, src)
+	if er != nil {
+		return nil, er
+	}
+
+	repoAddr, commitId, err := GetTaskRepoAddrAndCommitId(tx, tpl, task.Revision)
+	if err != nil {
+		return nil, e.AutoNew(err, e.InternalError)
+	}
+
+	task.Type = models.TaskTypeApply
+	task.RepoAddr = repoAddr
+	task.CommitId = commitId
+	task.AutoApprove = env.AutoApproval
+	task.StopOnViolation = env.StopOnViolation
+	setTaskRunner(task, env.RunnerId, env)
+	task.KeyId = env.KeyId
+	setter(task)
+
+	return doCreateTask(tx, *task, tpl, env)
+}
+
 func CreateTask(tx *db.Session, tpl *models.Template, env *models.Env, pt models.Task) (*models.Task, e.Error) {
 	// logger := logs.Get().WithField("func", "CreateTask")
 	// logger = logger.WithField("taskId", task.Id)
@@ -176,6 +226,26 @@ func CreateTask(tx *db.Session, tpl *models.Template, env *models.Env, pt models
 	return doCreateTask(tx, *task, tpl, env)
 }
 
+// CreateStateTask 创建 state 管理任务，step 为需要执行的 state 操作步骤
+func CreateStateTask(tx *db.Session, tpl *models.Template, env *models.Env, pt models.Task,
+	step models.PipelineStep) (*models.Task, e.Error) {
+	if !pt.IsStateTaskType(pt.Type) {
+		return nil, e.New(e.BadParam, fmt.Errorf("invalid state task type '%s'", pt.Type))
+	}
+	task, er := newCommonTask(tpl, env, pt)
+	if er != nil {
+		return nil, er
+	}
+
+	var err e.Error
+	task.RepoAddr, task.CommitId, err = GetTaskRepoAddrAndCommitId(tx, tpl, task.Revision)
+	if err != nil {
+		return nil, e.New(e.InternalError, err)
+	}
+	task.Flow = models.PipelineTask{Steps: []models.PipelineStep{step}}
+	return doCreateTask(tx, *task, tpl, env)
+}
+
 func newCommonTask(tpl *models.Template, env *models.Env, pt models.Task) (*models.Task, e.Error) {
 	firstVal := utils.FirstValueStr
 	task := models.Task{
@@ -204,6 +274,7 @@ func newCommonTask(tpl *models.Template, env *models.Env, pt models.Task) (*mode
 
 		Workdir:   tpl.Workdir,
 		TfVersion: tpl.TfVersion,
+		IacTool:   tpl.IacTool,
 
 		Playbook:     env.Playbook,
 		TfVarsFile:   env.TfVarsFile,
@@ -213,18 +284,30 @@ func newCommonTask(tpl *models.Template, env *models.Env, pt models.Task) (*mode
 			Type:        pt.Type,
 			Pipeline:    pt.Pipeline,
 			StepTimeout: utils.FirstValueInt(pt.StepTimeout, common.DefaultTaskStepTimeout),
-			RunnerId:    firstVal(pt.RunnerId, env.RunnerId),
 
 			Status:   models.TaskPending,
 			Message:  "",
 			CurrStep: 0,
 		},
 		Callback: pt.Callback,
+		Priority: utils.FirstValueInt(pt.Priority, models.TaskPriorityManual),
 	}
+	setTaskRunner(&task, firstVal(pt.RunnerId, env.RunnerId), env)
 	task.Id = models.Task{}.NewId()
 	return &task, nil
 }
 
+// setTaskRunner 设置任务的部署通道，环境配置了 runner tags 时不指定 runner，由 task manager 在执行任务时选择
+func setTaskRunner(task *models.Task, runnerId string, env *models.Env) {
+	if len(env.RunnerTags) > 0 {
+		task.RunnerId = ""
+		task.RunnerTags = env.RunnerTags
+	} else {
+		task.RunnerId = runnerId
+		task.RunnerTags = nil
+	}
+}
+
 func doCreateTask(tx *db.Session, task models.Task, tpl *models.Template, env *models.Env) (*models.Task, e.Error) {
 	// pipeline 内容可以从外部传入，如果没有传则尝试读取云模板目录下的文件
 	var err error
@@ -239,7 +322,7 @@ func doCreateTask(tx *db.Session, task models.Task, tpl *models.Template, env *m
 		if task.CommitId == "" {
 			return nil, e.New(e.BadParam, fmt.Errorf("'commitId' is required"))
 		}
-		if task.RunnerId == "" {
+		if task.RunnerId == "" && len(task.RunnerTags) == 0 {
 			return nil, e.New(e.BadParam, fmt.Errorf("'runnerId' is required"))
 		}
 	}
@@ -255,7 +338,20 @@ func doCreateTask(tx *db.Session, task models.Task, tpl *models.Template, env *m
 		return nil, e.New(e.InvalidPipeline, err)
 	}
 
-	task.Flow = GetTaskFlowWithPipeline(pipeline, task.Type)
+	// 将依赖环境的 outputs 传入 terraform 变量，销毁及 state 管理任务忽略不存在的 output
+	depVars, er := GetEnvDependencyVariables(tx, env.Id,
+		task.Type == models.TaskTypeDestroy || task.IsStateTaskType(task.Type))
+	if er != nil {
+		return nil, er
+	}
+	task.Variables = MergeEnvDependencyVariables(task.Variables, depVars)
+
+	if task.IsStateTaskType(task.Type) {
+		// state 管理任务的操作步骤由调用方通过 task.Flow 传入
+		task.Flow = GetStateTaskFlow(pipeline, task.Flow.Steps...)
+	} else {
+		task.Flow = GetTaskFlowWithPipeline(pipeline, task.Type)
+	}
 	steps := make([]models.TaskStep, 0)
 	stepIndex := 0
 	for i, pipelineStep := range task.Flow.Steps {
@@ -392,6 +488,26 @@ func ListPendingCronTask(tx *db.Session, envId models.Id) (bool, e.Error) {
 	return exist, nil
 }
 
+// OverrideTaskFreeze 标记排队中的任务忽略维护窗口限制
+func OverrideTaskFreeze(tx *db.Session, taskId models.Id) e.Error {
+	if _, err := tx.Model(&models.Task{}).Where("id = ? AND status = ?", taskId, models.TaskPending).
+		UpdateColumn("freeze_override", true); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
+
+// HasPendingCronDeployTask 环境是否有排队或执行中的定时部署任务
+func HasPendingCronDeployTask(tx *db.Session, envId models.Id) (bool, e.Error) {
+	query := tx.Where("env_id = ? AND name = ? AND status IN (?)", envId, common.CronDeployTaskName,
+		[]string{models.TaskPending, models.TaskRunning, models.TaskApproving})
+	exist, err := query.Model(&models.Task{}).Exists()
+	if err != nil {
+		return exist, e.New(e.DBError, err)
+	}
+	return exist, nil
+}
+
 func GetTaskById(tx *db.Session, id models.Id) (*models.Task, e.Error) {
 	o := models.Task{}
 	if err := tx.Where("id = ?", id).First(&o); err != nil {
@@ -421,6 +537,8 @@ var stepStatus2TaskStatusMap = map[string]string{
 	models.TaskStepFailed:    models.TaskFailed,
 	models.TaskStepTimeout:   models.TaskFailed,
 	models.TaskStepComplete:  models.TaskComplete,
+	models.TaskStepAborted:   models.TaskAborted,
+	models.TaskStepSkipped:   models.TaskComplete,
 }
 
 func stepStatus2TaskStatus(s string) string {
@@ -459,9 +577,19 @@ func ChangeTaskStatus(dbSess *db.Session, task *models.Task, status, message str
 		task.EndAt = &now
 	}
 
-	logs.Get().WithField("taskId", task.Id).Infof("change task to '%s'", status)
-	if _, err := dbSess.Model(task).Update(task); err != nil {
+	logger := logs.Get().WithField("taskId", task.Id)
+	logger.Infof("change task to '%s'", status)
+	query := dbSess.Model(task)
+	if status != models.TaskAborted {
+		// 任务被中止后不允许再修改状态
+		query = query.Where("status != ?", models.TaskAborted)
+	}
+	if n, err := query.Update(task); err != nil {
 		return e.AutoNew(err, e.DBError)
+	} else if n == 0 && status != models.TaskAborted {
+		logger.Infof("task aborted, skip change status to '%s'", status)
+		task.Status = models.TaskAborted
+		return nil
 	}
 
 	// 回调的消息通知只发送一次, 作业结束后发送通知
@@ -493,6 +621,161 @@ func ChangeTaskStatus(dbSess *db.Session, task *models.Task, status, message str
 	return nil
 }
 
+// AbortTask 中止任务。
+// 排队中的任务直接标记为中止；执行中的任务将当前步骤及任务标记为中止，并停止任务容器，
+// task manager 检测到步骤中止后会结束任务的执行流程(不再执行后续步骤及资源统计)
+func AbortTask(dbSess *db.Session, task *models.Task, message string) e.Error {
+	logger := logs.Get().WithField("func", "AbortTask").WithField("taskId", task.Id)
+
+	if task.Exited() {
+		return e.New(e.TaskCannotAbort, fmt.Errorf("task is %s", task.Status))
+	}
+
+	if task.Status == models.TaskPending {
+		now := models.Time(time.Now())
+		n, err := dbSess.Model(&models.Task{}).Where("id = ? AND status = ?", task.Id, models.TaskPending).
+			UpdateAttrs(models.Attrs{"status": models.TaskAborted, "message": message, "end_at": &now})
+		if err != nil {
+			return e.New(e.DBError, err)
+		}
+
+		if n > 0 {
+			task.Status = models.TaskAborted
+			task.Message = message
+			task.EndAt = &now
+			if _, err := dbSess.Model(&models.TaskStep{}).
+				Where("task_id = ? AND `index` = ? AND status = ?", task.Id, task.CurrStep, models.TaskStepPending).
+				UpdateAttrs(models.Attrs{"status": models.TaskStepAborted, "message": message}); err != nil {
+				return e.New(e.DBError, err)
+			}
+			if !task.IsDriftTask {
+				TaskStatusChangeSendMessage(task, models.TaskAborted)
+			}
+			logger.Infof("pending task aborted")
+			return nil
+		}
+
+		// 更新失败说明任务己被 task manager 启动，重新查询任务后按执行中的任务处理
+		t, er := GetTaskById(dbSess, task.Id)
+		if er != nil {
+			return er
+		}
+		*task = *t
+		if task.Exited() {
+			return e.New(e.TaskCannotAbort, fmt.Errorf("task is %s", task.Status))
+		}
+	}
+
+	step, er := GetTaskStep(dbSess, task.Id, task.CurrStep)
+	if er != nil {
+		return e.AutoNew(er, e.DBError)
+	}
+	if !step.IsExited() {
+		if er := ChangeTaskStepStatusAndUpdate(dbSess, task, step, models.TaskStepAborted, message); er != nil {
+			return er
+		}
+	}
+	if er := ChangeTaskStatus(dbSess, task, models.TaskAborted, message, false); er != nil {
+		return er
+	}
+	logger.Infof("running task aborted")
+
+	if task.ContainerId != "" {
+		if err := StopRunnerTaskContainers(task.RunnerId, task.Id, task.ContainerId); err != nil {
+			// 容器停止失败不影响任务状态，task manager 在任务结束时会再次尝试停止容器
+			logger.Warnf("stop task container: %v", err)
+		}
+	}
+	return nil
+}
+
+// ResumeTask 从指定步骤恢复执行失败的任务。
+// 任务重新进入排队状态，由 task manager 在原 runner 上启动新的容器并复用之前的工作目录(代码、plan 文件等)，
+// 从 stepIndex 开始执行后续步骤。重新执行的步骤会清除审批结果，需要审批的步骤(如重新 plan 后的 apply)需要重新审批
+func ResumeTask(tx *db.Session, task *models.Task, stepIndex int) e.Error {
+	if task.Status != models.TaskFailed {
+		return e.New(e.TaskCannotResume, fmt.Errorf("task is %s", task.Status))
+	}
+	if stepIndex < 0 || stepIndex > task.CurrStep {
+		return e.New(e.BadParam, fmt.Errorf("step must between 0 and %d", task.CurrStep))
+	}
+
+	// 环境有更新的任务时工作目录中的 plan 可能已经过期，不允许恢复
+	if n, err := tx.Model(&models.Task{}).
+		Where("env_id = ? AND id != ? AND created_at >= ?", task.EnvId, task.Id, task.CreatedAt).Count(); err != nil {
+		return e.New(e.DBError, err)
+	} else if n > 0 {
+		return e.New(e.TaskCannotResume, fmt.Errorf("environment has newer tasks"))
+	}
+
+	// 删除上次执行结束时创建的 callback 步骤，任务结束时会重新创建
+	if _, err := tx.Where("task_id = ? AND is_callback = ?", task.Id, true).Delete(&models.TaskStep{}); err != nil {
+		return e.New(e.DBError, err)
+	}
+	// 重新执行的步骤的审批记录与步骤解除关联(保留在任务评论中)，不再计入审批通过人数
+	resetSteps := tx.Model(&models.TaskStep{}).Select("id").Where("task_id = ? AND `index` >= ?", task.Id, stepIndex)
+	if _, err := tx.Model(&models.TaskComment{}).
+		Where("task_id = ? AND step_id IN (?)", task.Id, resetSteps.Expr()).
+		UpdateAttrs(models.Attrs{"step_id": ""}); err != nil {
+		return e.New(e.DBError, err)
+	}
+	if _, err := tx.Model(&models.TaskStep{}).Where("task_id = ? AND `index` >= ?", task.Id, stepIndex).
+		UpdateAttrs(models.Attrs{
+			"status":              models.TaskStepPending,
+			"message":             "",
+			"exit_code":           0,
+			"start_at":            nil,
+			"end_at":              nil,
+			"current_retry_count": 0,
+			"next_retry_time":     0,
+			"approver_id":         "",
+			"approval_expire_at":  nil,
+		}); err != nil {
+		return e.New(e.DBError, err)
+	}
+
+	attrs := models.Attrs{
+		"status":       models.TaskPending,
+		"message":      "",
+		"curr_step":    stepIndex,
+		"container_id": "",
+		"end_at":       nil,
+	}
+	if n, err := tx.Model(&models.Task{}).Where("id = ? AND status = ?", task.Id, models.TaskFailed).
+		UpdateAttrs(attrs); err != nil {
+		return e.New(e.DBError, err)
+	} else if n == 0 {
+		return e.New(e.TaskCannotResume, fmt.Errorf("task status changed"))
+	}
+
+	task.Status = models.TaskPending
+	task.Message = ""
+	task.CurrStep = stepIndex
+	task.ContainerId = ""
+	task.EndAt = nil
+	return nil
+}
+
+// StopRunnerTaskContainers 通知 runner 停止任务容器
+func StopRunnerTaskContainers(runnerId string, taskId models.Id, containerIds ...string) error {
+	runnerAddr, err := GetRunnerAddress(runnerId)
+	if err != nil {
+		return err
+	}
+
+	requestUrl := utils.JoinURL(runnerAddr, consts.RunnerStopTaskURL)
+	req := runner.TaskStopReq{
+		TaskId:       taskId.String(),
+		ContainerIds: containerIds,
+	}
+
+	header := &http.Header{}
+	header.Set("Content-Type", "application/json")
+	timeout := int(consts.RunnerConnectTimeout.Seconds())
+	_, err = utils.HttpService(requestUrl, "POST", header, req, timeout, timeout)
+	return err
+}
+
 type TfState struct {
 	FormVersion      string        `json:"form_version"`
 	TerraformVersion string        `json:"terraform_version"`
@@ -633,6 +916,18 @@ type TfPlanResourceChange struct {
 	After   interface{} `json:"after"`
 }
 
+// HasChanges 判断 plan 是否包含资源变更(no-op、read 之外的操作)
+func (p *TfPlan) HasChanges() bool {
+	for _, r := range p.ResourceChanges {
+		for _, action := range r.Change.Actions {
+			if action != "no-op" && action != "read" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func UnmarshalPlanJson(bs []byte) (*TfPlan, error) {
 	plan := TfPlan{}
 	err := json.Unmarshal(bs, &plan)
@@ -1187,15 +1482,22 @@ func QueryTaskStepsById(query *db.Session, taskId models.Id) *db.Session {
 
 // 查询任务下某一个单独步骤的具体执行日志
 func GetTaskStepLogById(tx *db.Session, stepId models.Id) ([]byte, e.Error) {
-	query := tx.Joins("left join iac_task_step on iac_task_step.log_path=iac_storage.path").
-		Where("iac_task_step.id = ?", stepId).
-		LazySelectAppend("iac_storage.content")
-
-	var dbStorage models.DBStorage
-	if err := query.Find(&dbStorage); err != nil {
+	step := models.TaskStep{}
+	if err := tx.Where("id = ?", stepId).First(&step); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, nil
+		}
 		return nil, e.New(e.DBError, err)
 	}
-	return dbStorage.Content, nil
+
+	content, err := logstorage.Get().Read(step.LogPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, e.New(e.InternalError, err)
+	}
+	return content, nil
 }
 
 func SendKafkaMessage(session *db.Session, task *models.Task, taskStatus string) {
@@ -29,6 +29,7 @@ func CreateAutoDestroyTask(tx *db.Session, env *models.Env) (*models.Task, e.Err
 		Variables:       vars,
 		AutoApprove:     true,
 		StopOnViolation: env.StopOnViolation,
+		Priority:        models.TaskPriorityAutoDestroy,
 		BaseTask: models.BaseTask{
 			Type: models.TaskTypeDestroy,
 		},
@@ -0,0 +1,168 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services/logstorage"
+	"cloudiac/utils/logs"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LogSavePeriodPermanent = "Permanent"
+)
+
+// LogCleanResult 日志清理结果统计
+type LogCleanResult struct {
+	Tasks         int         // 清理的任务数量
+	Files         int         // 删除的文件数量
+	Bytes         int64       // 释放的存储空间
+	FailedTaskIds []models.Id // 清理失败的任务，下次清理时重试
+}
+
+func (r LogCleanResult) String() string {
+	return fmt.Sprintf("tasks: %d, files: %d, bytes: %d, failed: %d", r.Tasks, r.Files, r.Bytes, len(r.FailedTaskIds))
+}
+
+// ParseLogSavePeriod 解析日志保存周期配置，配置值为保存天数，返回 0 表示永久保存
+func ParseLogSavePeriod(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, LogSavePeriodPermanent) {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid log save period '%s'", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// GetLogSavePeriod 查询系统配置的日志保存周期，返回 0 表示永久保存
+func GetLogSavePeriod(sess *db.Session) (time.Duration, e.Error) {
+	return getSavePeriodConfig(sess, models.SysCfgNamePeriodOfLogSave)
+}
+
+// GetAuditLogSavePeriod 查询系统配置的审计日志保存周期，返回 0 表示永久保存
+func GetAuditLogSavePeriod(sess *db.Session) (time.Duration, e.Error) {
+	return getSavePeriodConfig(sess, models.SysCfgNamePeriodOfAuditLogSave)
+}
+
+func getSavePeriodConfig(sess *db.Session, name string) (time.Duration, e.Error) {
+	cfg := models.SystemCfg{}
+	if err := QuerySystemConfig(sess).Where("name = ?", name).First(&cfg); err != nil {
+		if e.IsRecordNotFound(err) {
+			return 0, nil
+		}
+		return 0, e.New(e.DBError, err)
+	}
+
+	period, err := ParseLogSavePeriod(cfg.Value)
+	if err != nil {
+		return 0, e.New(e.BadParam, err)
+	}
+	return period, nil
+}
+
+// 有未清理日志的步骤的任务
+const taskHasStepLogCond = "EXISTS (SELECT 1 FROM iac_task_step WHERE iac_task_step.task_id = %s.id AND iac_task_step.log_path != '')"
+
+// CleanExpiredTaskLogs 清理 before 之前结束的任务的步骤日志及 state、plan 等文件，每次最多处理 limit 个任务。
+// 环境最后一次执行的部署任务(LastTaskId、LastResTaskId)及环境、云模板最后一次执行的扫描任务会被保留。
+// 清理后步骤的 log_path 会被置空，所以已清理的任务不会被重复处理。
+// 单个任务清理失败时记录到 FailedTaskIds 并继续处理其他任务，skipTaskIds 中的任务不会被处理，
+// 调用方可以传入之前失败的任务，避免这些任务阻塞后续的清理。
+func CleanExpiredTaskLogs(sess *db.Session, before time.Time, limit int, skipTaskIds []models.Id) (*LogCleanResult, e.Error) {
+	result := &LogCleanResult{}
+	logger := logs.Get().WithField("func", "CleanExpiredTaskLogs")
+
+	tasks := make([]*models.Task, 0)
+	query := sess.Unscoped().Model(&models.Task{}).
+		Where("end_at < ?", before).
+		Where(fmt.Sprintf(taskHasStepLogCond, models.Task{}.TableName())).
+		Where("NOT EXISTS (SELECT 1 FROM iac_env WHERE iac_env.last_task_id = iac_task.id " +
+			"OR iac_env.last_res_task_id = iac_task.id)")
+	if len(skipTaskIds) > 0 {
+		query = query.Where("iac_task.id NOT IN (?)", skipTaskIds)
+	}
+	if err := query.Order("end_at").Limit(limit).Find(&tasks); err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	for _, t := range tasks {
+		if err := cleanTaskLogs(sess, t.Id, taskOutputPaths(t), result); err != nil {
+			logger.Warnf("clean task %s logs: %v", t.Id, err)
+			result.FailedTaskIds = append(result.FailedTaskIds, t.Id)
+		}
+	}
+
+	scanTasks := make([]*models.ScanTask, 0)
+	query = sess.Unscoped().Model(&models.ScanTask{}).
+		Where("end_at < ?", before).
+		Where(fmt.Sprintf(taskHasStepLogCond, models.ScanTask{}.TableName())).
+		Where("NOT EXISTS (SELECT 1 FROM iac_env WHERE iac_env.last_scan_task_id = iac_scan_task.id)").
+		Where("NOT EXISTS (SELECT 1 FROM iac_template WHERE iac_template.last_scan_task_id = iac_scan_task.id)")
+	if len(skipTaskIds) > 0 {
+		query = query.Where("iac_scan_task.id NOT IN (?)", skipTaskIds)
+	}
+	if err := query.Order("end_at").Limit(limit).Find(&scanTasks); err != nil {
+		return result, e.New(e.DBError, err)
+	}
+	for _, t := range scanTasks {
+		if err := cleanTaskLogs(sess, t.Id, scanTaskOutputPaths(t), result); err != nil {
+			logger.Warnf("clean scan task %s logs: %v", t.Id, err)
+			result.FailedTaskIds = append(result.FailedTaskIds, t.Id)
+		}
+	}
+
+	return result, nil
+}
+
+// taskOutputPaths 任务执行后保存的 state、plan 等文件，terraform.tfstate 包含敏感信息，
+// 环境的 state 版本单独保存，所以这里可以删除
+func taskOutputPaths(t *models.Task) []string {
+	return []string{t.StateJsonPath(), t.TfStatePath(), t.ProviderSchemaJsonPath(), t.PlanJsonPath(),
+		t.TfParseJsonPath(), t.TfResultJsonPath()}
+}
+
+func scanTaskOutputPaths(t *models.ScanTask) []string {
+	return []string{t.TfParseJsonPath(), t.TfResultJsonPath()}
+}
+
+func cleanTaskLogs(sess *db.Session, taskId models.Id, paths []string, result *LogCleanResult) e.Error {
+	steps := make([]*models.TaskStep, 0)
+	if err := sess.Model(&models.TaskStep{}).Where("task_id = ? AND log_path != ''", taskId).
+		Find(&steps); err != nil {
+		return e.New(e.DBError, err)
+	}
+	for _, s := range steps {
+		paths = append(paths, s.LogPath)
+		if len(s.Artifacts) > 0 {
+			paths = append(paths, s.ArtifactsPath())
+		}
+	}
+
+	storage := logstorage.Get()
+	for _, p := range paths {
+		size, err := storage.Delete(p)
+		if err != nil {
+			return e.New(e.InternalError, fmt.Errorf("delete '%s': %v", p, err))
+		}
+		if size > 0 {
+			result.Files += 1
+			result.Bytes += size
+		}
+	}
+
+	if _, err := sess.Model(&models.TaskStep{}).Where("task_id = ?", taskId).
+		UpdateColumn("log_path", ""); err != nil {
+		return e.New(e.DBError, err)
+	}
+	result.Tasks += 1
+	return nil
+}
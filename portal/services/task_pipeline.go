@@ -6,9 +6,16 @@ import (
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/db"
 	"cloudiac/portal/models"
+	"cloudiac/portal/services/vcsrv"
+	"cloudiac/utils"
 	"cloudiac/utils/logs"
+	"fmt"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 
+	"github.com/Masterminds/semver"
 	"gopkg.in/yaml.v2"
 )
 
@@ -18,17 +25,32 @@ func GetTplPipeline(sess *db.Session, tplId models.Id, revision, workdir string)
 		return pipeline, er
 	}
 
+	content, er := ReadRepoPipeline(repo, revision, workdir)
+	if er != nil || content == "" {
+		return "", er
+	}
+
+	if pipeline, err := DecodePipeline(content); err != nil {
+		return "", e.AutoNew(err, e.InvalidPipeline)
+	} else {
+		// 检查 version 是否合法
+		_, ok := models.GetPipelineByVersion(pipeline.Version)
+		if !ok {
+			return "", e.New(e.InvalidPipelineVersion)
+		}
+	}
+	return content, nil
+}
+
+// ReadRepoPipeline 读取仓库中的 pipeline 文件内容，优先读取 workdir 下的文件，文件不存在时返回空字符串
+func ReadRepoPipeline(repo vcsrv.RepoIface, revision, workdir string) (string, e.Error) {
 	paths := []string{filepath.Join(workdir, common.PipelineFileName)}
 	if workdir != "" {
 		paths = append(paths, common.PipelineFileName)
 	}
 
-	var (
-		content []byte
-		err     error
-	)
 	for _, path := range paths {
-		content, err = repo.ReadFileContent(revision, path)
+		content, err := repo.ReadFileContent(revision, path)
 		if err != nil {
 			// TODO 所有 vcs 的 ReadFileContent() 实现需要在文件不存在时返回 ObjectNotExists 错误
 			if e.Is(err, e.ObjectNotExists) {
@@ -36,27 +58,11 @@ func GetTplPipeline(sess *db.Session, tplId models.Id, revision, workdir string)
 			}
 
 			logs.Get().Warnf("read file content error(%T): %v", err, err)
-			return pipeline, e.New(e.VcsError, err)
-		} else {
-			break
+			return "", e.New(e.VcsError, err)
 		}
+		return string(content), nil
 	}
-
-	if len(content) == 0 {
-		return "", nil
-	}
-
-	if pipeline, err := DecodePipeline(string(content)); err != nil {
-		return "", e.AutoNew(err, e.InvalidPipeline)
-	} else {
-		// 检查 version 是否合法
-		_, ok := models.GetPipelineByVersion(pipeline.Version)
-		if !ok {
-			return "", e.New(e.InvalidPipelineVersion)
-		}
-	}
-
-	return string(content), nil
+	return "", nil
 }
 
 // 从 pipeline 中返回指定 typ 的 task，如果 pipeline 中未定义该类型 task 则返回默认 pipeline 中的值
@@ -69,7 +75,18 @@ func GetTaskFlowWithPipeline(p models.Pipeline, typ string) models.PipelineTask
 		flow.Image = customFlow.Image
 	}
 	if len(customFlow.Steps) != 0 {
-		flow.Steps = customFlow.Steps
+		// 未设置名称的步骤使用默认流程中同类型步骤的名称
+		defaultNames := make(map[string]string)
+		for _, step := range flow.Steps {
+			defaultNames[step.Type] = step.Name
+		}
+		flow.Steps = make([]models.PipelineStep, 0, len(customFlow.Steps))
+		for _, step := range customFlow.Steps {
+			if step.Name == "" {
+				step.Name = defaultNames[step.Type]
+			}
+			flow.Steps = append(flow.Steps, step)
+		}
 	}
 	if customFlow.OnFail != nil {
 		flow.OnFail = customFlow.OnFail
@@ -80,14 +97,145 @@ func GetTaskFlowWithPipeline(p models.Pipeline, typ string) models.PipelineTask
 	return flow
 }
 
+// GetStateTaskFlow 生成 state 管理任务的执行流程，在 checkout、init 后执行 state 操作步骤，
+// 任务镜像使用 pipeline 中 apply 任务的配置
+func GetStateTaskFlow(p models.Pipeline, steps ...models.PipelineStep) models.PipelineTask {
+	flow := models.PipelineTask{
+		Image: GetTaskFlowWithPipeline(p, common.TaskJobApply).Image,
+		Steps: []models.PipelineStep{
+			{Type: common.TaskStepCheckout, Name: "Checkout Code"},
+			{Type: models.TaskStepInit, Name: "Terraform Init"},
+		},
+	}
+	flow.Steps = append(flow.Steps, steps...)
+	return flow
+}
+
+// DecodePipeline 解析 pipeline 内容，并检查步骤配置是否有效
 func DecodePipeline(s string) (models.Pipeline, error) {
 	p := models.Pipeline{}
 	if s == "" {
 		return p, nil
 	}
 	buffer := bytes.NewBufferString(s)
-	err := yaml.NewDecoder(buffer).Decode(&p)
-	return p, err
+	if err := yaml.NewDecoder(buffer).Decode(&p); err != nil {
+		return p, err
+	}
+	return p, validatePipeline(p)
+}
+
+var (
+	envNameRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	cacheKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// when、image、env 等步骤配置需要 0.4 及以上版本
+const pipelineStepOptionsVersion = "0.4"
+
+var errPipelineStepOptionsVersion = fmt.Errorf("when, image, env, timeout, continueOnError, artifacts and cache "+
+	"require pipeline version %s", pipelineStepOptionsVersion)
+
+// pipelineVersionAtLeast 按数值比较 pipeline 版本号(如 0.10 大于 0.4)，版本号为空或无效时返回 false
+func pipelineVersionAtLeast(version, min string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return !v.LessThan(semver.MustParse(min))
+}
+
+func validatePipeline(p models.Pipeline) error {
+	stepOptionsSupported := pipelineVersionAtLeast(p.Version, pipelineStepOptionsVersion)
+
+	for _, typ := range common.TaskJobTypes {
+		task := p.GetTask(typ)
+		names := make([]string, 0)
+		steps := make([]models.PipelineStep, 0)
+		for i, step := range task.Steps {
+			names = append(names, fmt.Sprintf("%s.steps[%d]", typ, i))
+			steps = append(steps, step)
+		}
+		if task.OnSuccess != nil {
+			names = append(names, typ+".onSuccess")
+			steps = append(steps, *task.OnSuccess)
+		}
+		if task.OnFail != nil {
+			names = append(names, typ+".onFail")
+			steps = append(steps, *task.OnFail)
+		}
+
+		for i, step := range steps {
+			name := names[i]
+			if !stepOptionsSupported && hasPipelineStepOptions(step) {
+				return fmt.Errorf("%s: %s", name, errPipelineStepOptionsVersion)
+			}
+			if err := validatePipelineStep(step); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func hasPipelineStepOptions(step models.PipelineStep) bool {
+	w := step.When
+	return step.Image != "" || len(step.Env) > 0 || step.Timeout != 0 || step.ContinueOnError ||
+		w.Status != "" || len(w.Branch) > 0 || w.Changes != nil ||
+		len(step.Artifacts) > 0 || step.Cache.Key != "" || len(step.Cache.Paths) > 0
+}
+
+// 检查 artifacts、cache 路径，必须是代码工作目录下的相对路径
+func validateStepFilePaths(paths []string) error {
+	for _, p := range paths {
+		name := path.Clean(p)
+		if p == "" || name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid path '%s'", p)
+		}
+	}
+	return nil
+}
+
+func validatePipelineStep(step models.PipelineStep) error {
+	if strings.ContainsAny(step.Image, " \t\n") {
+		return fmt.Errorf("invalid image '%s'", step.Image)
+	}
+	for k := range step.Env {
+		if !envNameRegex.MatchString(k) {
+			return fmt.Errorf("invalid env name '%s'", k)
+		}
+	}
+	if step.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", step.Timeout)
+	}
+	if step.Retry != nil && (step.Retry.Count < 0 || step.Retry.Delay < 0) {
+		return fmt.Errorf("invalid retry count or delay")
+	}
+
+	if err := validateStepFilePaths(step.Artifacts); err != nil {
+		return fmt.Errorf("artifacts: %v", err)
+	}
+	if step.Cache.Key != "" || len(step.Cache.Paths) > 0 {
+		if !cacheKeyRegex.MatchString(step.Cache.Key) || step.Cache.Key == "." || step.Cache.Key == ".." {
+			return fmt.Errorf("invalid cache key '%s'", step.Cache.Key)
+		}
+		if len(step.Cache.Paths) == 0 {
+			return fmt.Errorf("cache paths is required")
+		}
+		if err := validateStepFilePaths(step.Cache.Paths); err != nil {
+			return fmt.Errorf("cache: %v", err)
+		}
+	}
+
+	w := step.When
+	if !utils.StrInArray(w.Status, "", models.PipelineWhenSuccess, models.PipelineWhenFailure, models.PipelineWhenAlways) {
+		return fmt.Errorf("invalid when status '%s'", w.Status)
+	}
+	for _, pattern := range w.Branch {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid when branch '%s'", pattern)
+		}
+	}
+	return nil
 }
 
 func UpdateTaskContainerId(sess *db.Session, taskId models.Id, containerId string) e.Error {
@@ -0,0 +1,148 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cloudiac/common"
+	"cloudiac/portal/models"
+)
+
+func TestDecodePipelineStepOptions(t *testing.T) {
+	pipeline, err := DecodePipeline(`
+version: 0.4
+apply:
+  steps:
+    - type: terraformPlan
+    - type: command
+      name: notify
+      image: alpine:3.14
+      timeout: 60
+      continueOnError: true
+      env:
+        CHANNEL: deploy
+      when:
+        status: always
+        branch: ["release/*"]
+        changes: true
+      args:
+        - echo $CHANNEL
+    - type: terraformInit
+      cache:
+        key: modules
+        paths: [".terraform/modules"]
+      artifacts:
+        - dist/
+`)
+	assert.NoError(t, err)
+
+	steps := GetTaskFlowWithPipeline(pipeline, common.TaskJobApply).Steps
+	assert.Equal(t, 3, len(steps))
+	assert.NotEmpty(t, steps[0].Name, "use default step name")
+
+	notify := steps[1]
+	assert.Equal(t, "alpine:3.14", notify.Image)
+	assert.Equal(t, 60, notify.Timeout)
+	assert.True(t, notify.ContinueOnError)
+	assert.Equal(t, models.PipelineStepEnv{"CHANNEL": "deploy"}, notify.Env)
+	assert.Equal(t, models.PipelineWhenAlways, notify.When.Status)
+	assert.Equal(t, []string{"release/*"}, notify.When.Branch)
+	assert.True(t, *notify.When.Changes)
+
+	initStep := steps[2]
+	assert.Equal(t, models.PipelineStepCache{Key: "modules", Paths: []string{".terraform/modules"}}, initStep.Cache)
+	assert.Equal(t, models.StrSlice{"dist/"}, initStep.Artifacts)
+
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"require version 0.4", `
+version: 0.3
+apply:
+  steps:
+    - type: terraformApply
+      when:
+        status: always
+`},
+		{"invalid when status", `
+version: 0.4
+apply:
+  steps:
+    - type: terraformApply
+      when:
+        status: finished
+`},
+		{"invalid env name", `
+version: 0.4
+apply:
+  onFail:
+    type: command
+    env:
+      1ST: x
+`},
+		{"invalid branch pattern", `
+version: 0.4
+plan:
+  steps:
+    - type: terraformPlan
+      when:
+        branch: ["[master"]
+`},
+		{"invalid timeout", `
+version: 0.4
+plan:
+  steps:
+    - type: terraformPlan
+      timeout: -1
+`},
+		{"invalid artifacts path", `
+version: 0.4
+apply:
+  steps:
+    - type: command
+      artifacts: ["../secret"]
+`},
+		{"cache key is required", `
+version: 0.4
+apply:
+  steps:
+    - type: terraformInit
+      cache:
+        paths: [".terraform/modules"]
+`},
+	}
+	for _, c := range cases {
+		_, err := DecodePipeline(c.content)
+		assert.Error(t, err, c.name)
+	}
+}
+
+func TestDecodePipelineStepOptionsVersion(t *testing.T) {
+	content := `
+version: %s
+plan:
+  steps:
+    - type: terraformPlan
+      timeout: 60
+`
+	// 版本号按数值比较，0.10 不能被当作小于 0.4
+	_, err := DecodePipeline(fmt.Sprintf(content, "0.10"))
+	assert.NoError(t, err)
+
+	_, err = DecodePipeline(fmt.Sprintf(content, "0.3"))
+	assert.EqualError(t, err, "plan.steps[0]: "+errPipelineStepOptionsVersion.Error())
+}
+
+func TestPipelineVersionAtLeast(t *testing.T) {
+	assert.True(t, pipelineVersionAtLeast("0.4", "0.4"))
+	assert.True(t, pipelineVersionAtLeast("0.10", "0.4"))
+	assert.True(t, pipelineVersionAtLeast("1.0", "0.4"))
+	assert.False(t, pipelineVersionAtLeast("0.3", "0.4"))
+	assert.False(t, pipelineVersionAtLeast("", "0.4"))
+	assert.False(t, pipelineVersionAtLeast("latest", "0.4"))
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"sort"
+)
+
+// OrderPendingTasks 对排队中的任务进行排序，决定任务的执行顺序。
+// 优先级高的任务先执行，相同优先级的任务在各项目间轮流选取(每个项目内按创建时间排序)，
+// 避免单个项目大量排队的任务阻塞其他项目。
+// 传入的 tasks 需要己按创建时间排序
+func OrderPendingTasks(tasks []*models.Task) []*models.Task {
+	sorted := make([]*models.Task, len(tasks))
+	copy(sorted, tasks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	result := make([]*models.Task, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].Priority == sorted[start].Priority {
+			end++
+		}
+		result = append(result, roundRobinByProject(sorted[start:end])...)
+		start = end
+	}
+	return result
+}
+
+// roundRobinByProject 按项目轮流选取任务，项目的顺序按其最早的任务决定
+func roundRobinByProject(tasks []*models.Task) []*models.Task {
+	projectIds := make([]models.Id, 0)
+	queues := make(map[models.Id][]*models.Task)
+	for _, t := range tasks {
+		if _, ok := queues[t.ProjectId]; !ok {
+			projectIds = append(projectIds, t.ProjectId)
+		}
+		queues[t.ProjectId] = append(queues[t.ProjectId], t)
+	}
+
+	result := make([]*models.Task, 0, len(tasks))
+	for len(result) < len(tasks) {
+		for _, pid := range projectIds {
+			if q := queues[pid]; len(q) > 0 {
+				result = append(result, q[0])
+				queues[pid] = q[1:]
+			}
+		}
+	}
+	return result
+}
+
+// QueryPendingTasks 查询所有排队中的部署任务(只查询排序需要的字段)
+func QueryPendingTasks(sess *db.Session) ([]*models.Task, e.Error) {
+	tasks := make([]*models.Task, 0)
+	err := sess.Model(&models.Task{}).
+		Select("id, org_id, project_id, env_id, runner_id, priority, created_at").
+		Where("status = ?", models.TaskPending).
+		Order("created_at, id").Find(&tasks)
+	if err != nil {
+		return nil, e.New(e.DBError, err)
+	}
+	return tasks, nil
+}
+
+// GetTaskQueuePosition 获取排队中的任务在队列中的位置(从 1 开始)，任务不在排队中时返回 0。
+// 同一环境的任务需要串行执行，且受并发数限制，所以返回值只是一个预估的位置
+func GetTaskQueuePosition(sess *db.Session, task *models.Task) (int, e.Error) {
+	if task.Status != models.TaskPending {
+		return 0, nil
+	}
+
+	tasks, err := QueryPendingTasks(sess)
+	if err != nil {
+		return 0, err
+	}
+	for i, t := range OrderPendingTasks(tasks) {
+		if t.Id == task.Id {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -7,8 +7,11 @@ import (
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/db"
 	"cloudiac/portal/models"
+	"cloudiac/portal/services/logstorage"
 	"cloudiac/utils"
 	"cloudiac/utils/logs"
+	"net/http"
+	"os"
 	"time"
 )
 
@@ -114,8 +117,17 @@ func ChangeTaskStepStatusAndUpdate(dbSess *db.Session, task models.Tasker, taskS
 		logger.Debugf("change step to '%s'", status)
 	}
 
-	if _, err := dbSess.Model(taskStep).Update(taskStep); err != nil {
+	query := dbSess.Model(taskStep)
+	if status != models.TaskStepAborted {
+		// 步骤被中止后不允许再修改状态
+		query = query.Where("status != ?", models.TaskStepAborted)
+	}
+	if n, err := query.Update(taskStep); err != nil {
 		return e.New(e.DBError, err)
+	} else if n == 0 && status != models.TaskStepAborted {
+		logger.Infof("step aborted, skip change status to '%s'", status)
+		taskStep.Status = models.TaskStepAborted
+		return nil
 	}
 
 	if taskStep.IsExited() && !taskStep.IsRejected() {
@@ -143,11 +155,24 @@ func newTaskStep(tx *db.Session, task models.Task, stepBody models.PipelineStep,
 		Status:       models.TaskStepPending,
 		Message:      "",
 		NextStep:     "",
-		RetryNumber:  task.RetryNumber,
 	}
 
-	// apply 和 destroy 步骤需要审批
-	if !task.AutoApprove && (s.Type == common.TaskStepTfApply || s.Type == common.TaskStepTfDestroy) {
+	// 步骤配置了重试策略时使用步骤的配置，否则使用任务的重试配置
+	if stepBody.Retry != nil {
+		s.RetryNumber = stepBody.Retry.Count
+		s.RetryDelay = stepBody.Retry.Delay
+	} else if task.RetryAble {
+		s.RetryNumber = task.RetryNumber
+		s.RetryDelay = task.RetryDelay
+	}
+
+	// apply、destroy 及修改 state 的步骤需要审批
+	if !task.AutoApprove && utils.StrInArray(s.Type, common.TaskStepTfApply, common.TaskStepTfDestroy,
+		common.TaskStepTfStateMv, common.TaskStepTfStateRm, common.TaskStepTfImport) {
+		s.MustApproval = true
+	}
+	// 恢复 state 会覆盖当前 state，即使开启了自动审批也需要审批
+	if s.Type == common.TaskStepTfStatePush {
 		s.MustApproval = true
 	}
 
@@ -224,3 +249,18 @@ func GetTaskPlanStep(sess *db.Session, taskId models.Id) (*models.TaskStep, e.Er
 	}
 	return &taskStep, nil
 }
+
+// GetTaskStepArtifacts 读取步骤保存的 artifacts 打包内容
+func GetTaskStepArtifacts(step *models.TaskStep) ([]byte, e.Error) {
+	if len(step.Artifacts) == 0 {
+		return nil, e.New(e.TaskArtifactNotExists, http.StatusNotFound)
+	}
+	content, err := logstorage.Get().Read(step.ArtifactsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, e.New(e.TaskArtifactNotExists, http.StatusNotFound)
+		}
+		return nil, e.New(e.InternalError, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cloudiac/common"
+	"cloudiac/portal/models"
+)
+
+func TestNewTaskStepRetry(t *testing.T) {
+	pipeline, err := DecodePipeline(`
+version: 0.3
+apply:
+  steps:
+    - type: terraformPlan
+    - type: terraformApply
+      retry:
+        count: 2
+        delay: 30
+`)
+	assert.NoError(t, err)
+	steps := GetTaskFlowWithPipeline(pipeline, common.TaskJobApply).Steps
+
+	task := models.Task{RetryNumber: 3, RetryDelay: 5}
+	plan := newTaskStep(nil, task, steps[0], 0)
+	apply := newTaskStep(nil, task, steps[1], 1)
+	assert.Equal(t, 0, plan.RetryNumber, "task retry disabled")
+	assert.Equal(t, 2, apply.RetryNumber)
+	assert.Equal(t, 30, apply.RetryDelay)
+
+	task.RetryAble = true
+	plan = newTaskStep(nil, task, steps[0], 0)
+	assert.Equal(t, 3, plan.RetryNumber)
+	assert.Equal(t, 5, plan.RetryDelay)
+
+	// 步骤配置 count 为 0 时不使用任务的重试配置
+	noRetry := newTaskStep(nil, task, models.PipelineStep{
+		Type: common.TaskStepTfApply, Retry: &models.PipelineStepRetry{}}, 1)
+	assert.Equal(t, 0, noRetry.RetryNumber)
+}
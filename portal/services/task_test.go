@@ -3,9 +3,12 @@
 package services
 
 import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/models"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 const testStateJson = `
@@ -320,3 +323,86 @@ func TestUnmarshalTfResultJson(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLogSavePeriod(t *testing.T) {
+	cases := []struct {
+		value  string
+		expect time.Duration
+		err    bool
+	}{
+		{"", 0, false},
+		{"Permanent", 0, false},
+		{"30", 30 * 24 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"-1", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, c := range cases {
+		p, err := ParseLogSavePeriod(c.value)
+		if c.err {
+			assert.Error(t, err, "%v", c)
+			continue
+		}
+		assert.NoError(t, err, "%v", c)
+		assert.Equal(t, c.expect, p, "%v", c)
+	}
+}
+
+func TestTaskOutputPaths(t *testing.T) {
+	task := &models.Task{}
+	task.Id, task.ProjectId, task.EnvId = "run-1", "p-1", "env-1"
+	paths := taskOutputPaths(task)
+	// 清理任务日志时需要删除包含敏感信息的 state 文件
+	assert.Contains(t, paths, "p-1/env-1/run-1/terraform.tfstate")
+	assert.Contains(t, paths, "p-1/env-1/run-1/tfstate.json")
+	assert.Contains(t, paths, "p-1/env-1/run-1/tfplan.json")
+}
+
+func TestOrderPendingTasks(t *testing.T) {
+	newTask := func(id, projectId string, priority int) *models.Task {
+		task := &models.Task{ProjectId: models.Id(projectId), Priority: priority}
+		task.Id = models.Id(id)
+		return task
+	}
+
+	tasks := []*models.Task{
+		newTask("1", "p1", models.TaskPriorityCronDrift),
+		newTask("2", "p1", models.TaskPriorityCronDrift),
+		newTask("3", "p1", models.TaskPriorityCronDrift),
+		newTask("4", "p2", models.TaskPriorityCronDrift),
+		newTask("5", "p1", models.TaskPriorityAutoDestroy),
+		newTask("6", "p3", models.TaskPriorityManual),
+		newTask("7", "p2", models.TaskPriorityCronDrift),
+		newTask("8", "p1", models.TaskPriorityWebhook),
+	}
+
+	ids := make([]string, 0)
+	for _, t := range OrderPendingTasks(tasks) {
+		ids = append(ids, string(t.Id))
+	}
+	assert.Equal(t, []string{"6", "8", "1", "4", "2", "7", "3", "5"}, ids)
+}
+
+func TestCheckTaskApprover(t *testing.T) {
+	task := &models.Task{}
+	task.CreatorId = "u-creator"
+	apply := &models.TaskStep{PipelineStep: models.PipelineStep{Type: models.TaskStepApply}}
+	destroy := &models.TaskStep{PipelineStep: models.PipelineStep{Type: models.TaskStepDestroy}}
+
+	// 未配置策略，任何人都可以审批
+	assert.Nil(t, CheckTaskApprover(nil, task, apply, "u-creator", ""))
+
+	policy := &models.ApprovalPolicy{
+		ApplyApproverRoles: []string{"approver"},
+		DestroyApproverIds: []string{"u-admin"},
+	}
+	assert.Equal(t, e.TaskApproveSelf, CheckTaskApprover(policy, task, apply, "u-creator", "approver").Code())
+	assert.Nil(t, CheckTaskApprover(policy, task, apply, "u-other", "approver"))
+	assert.Equal(t, e.TaskApproverNotAllow, CheckTaskApprover(policy, task, apply, "u-other", "operator").Code())
+	assert.Nil(t, CheckTaskApprover(policy, task, destroy, "u-admin", ""))
+	assert.Equal(t, e.TaskApproverNotAllow, CheckTaskApprover(policy, task, destroy, "u-other", "approver").Code())
+
+	policy.AllowSelfApproval = true
+	assert.Nil(t, CheckTaskApprover(policy, task, apply, "u-creator", "approver"))
+}
@@ -34,6 +34,7 @@ type exportedTpl struct {
 	Playbook     string `json:"playbook"`
 	PlayVarsFile string `json:"playVarsFile"`
 	TfVersion    string `json:"tfVersion"`
+	IacTool      string `json:"iacTool"`
 
 	Variables   []exportedTplVar `json:"variables"`
 	VarGroupIds []models.Id      `json:"varGroupIds"`
@@ -125,6 +126,7 @@ func ExportTemplates(dbSess *db.Session, orgId models.Id, ids []models.Id) (*Tpl
 			Playbook:     t.Playbook,
 			PlayVarsFile: t.PlayVarsFile,
 			TfVersion:    t.TfVersion,
+			IacTool:      t.IacTool,
 			Variables:    []exportedTplVar{},
 		}
 
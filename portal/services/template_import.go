@@ -390,6 +390,7 @@ func (t *TplImporter) getTplFromExportData(tpl exportedTpl) (*models.Template, e
 		PlayVarsFile:   tpl.PlayVarsFile,
 		LastScanTaskId: "",
 		TfVersion:      tpl.TfVersion,
+		IacTool:        tpl.IacTool,
 	}
 	newTpl.Id = models.Id(tpl.Id)
 
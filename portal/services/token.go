@@ -8,6 +8,8 @@ import (
 	"cloudiac/portal/consts/e"
 	"cloudiac/portal/libs/db"
 	"cloudiac/portal/models"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -125,11 +127,22 @@ func IsActiveToken(dbSess *db.Session, token, tokenType string) (*models.Token,
 	return &t, nil
 }
 
+// HashApiToken 计算 api token 的 sha256 值，数据库中只保存该值
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetApiTokenByToken 查询有效的 api token，轮换后的旧 token 在宽限期内同样有效
 func GetApiTokenByToken(dbSess *db.Session, token string) (*models.Token, e.Error) {
+	hash := HashApiToken(token)
+	now := time.Now()
 	tokenResp := &models.Token{}
 	if err := dbSess.
-		Where("`key` = ?", token).
 		Where("`type` = ?", consts.TokenApi).
+		Where("`status` = ?", models.Enable).
+		Where("`expired_at` > ? or expired_at is null", now).
+		Where("`key` = ? or (`prev_key` = ? and `prev_key_expired_at` > ?)", hash, hash, now).
 		First(tokenResp); err != nil {
 		if e.IsRecordNotFound(err) {
 			return tokenResp, e.New(e.TokenNotExists)
@@ -139,4 +152,25 @@ func GetApiTokenByToken(dbSess *db.Session, token string) (*models.Token, e.Erro
 	return tokenResp, nil
 }
 
+func GetTokenById(query *db.Session, id models.Id) (*models.Token, e.Error) {
+	token := &models.Token{}
+	if err := query.Where("id = ?", id).First(token); err != nil {
+		if e.IsRecordNotFound(err) {
+			return nil, e.New(e.TokenNotExists, err)
+		}
+		return nil, e.New(e.DBError, err)
+	}
+	return token, nil
+}
 
+// UpdateTokenLastUsed 更新 token 的最后使用时间，间隔小于 consts.TokenLastUsedInterval 时不更新，避免每个请求都写数据库
+func UpdateTokenLastUsed(tx *db.Session, id models.Id) e.Error {
+	now := time.Now()
+	if _, err := tx.Model(&models.Token{}).
+		Where("id = ?", id).
+		Where("last_used_at is null or last_used_at < ?", now.Add(-consts.TokenLastUsedInterval)).
+		UpdateColumn("last_used_at", now); err != nil {
+		return e.New(e.DBError, err)
+	}
+	return nil
+}
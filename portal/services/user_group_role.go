@@ -0,0 +1,208 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts"
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/libs/db"
+	"cloudiac/portal/models"
+	"cloudiac/utils/logs"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	orgRolePriority     = []string{consts.OrgRoleMember, consts.OrgRoleAdmin}
+	projectRolePriority = []string{consts.ProjectRoleGuest, consts.ProjectRoleOperator,
+		consts.ProjectRoleApprover, consts.ProjectRoleManager}
+)
+
+// higherRole 返回两个角色中权限较高的角色，priority 中越靠后权限越高
+func higherRole(priority []string, a, b string) string {
+	index := func(role string) int {
+		for i, r := range priority {
+			if r == role {
+				return i
+			}
+		}
+		return -1
+	}
+	if index(b) > index(a) {
+		return b
+	}
+	return a
+}
+
+// groupMatch 判断组是否匹配，不区分大小写，ldap 组可以使用 DN 或 CN 匹配
+func groupMatch(pattern string, group string) bool {
+	if strings.EqualFold(pattern, group) {
+		return true
+	}
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 {
+		return false
+	}
+	for _, attr := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") && strings.EqualFold(attr.Value, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupsMatchAny 判断 groups 中是否有匹配 patterns 中任一项的组
+func GroupsMatchAny(patterns []string, groups []string) bool {
+	for _, p := range patterns {
+		for _, g := range groups {
+			if groupMatch(p, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GroupMappedRoles 根据用户所在的组计算组织及项目角色，
+// 返回映射中出现的所有组织、项目的角色，用户不属于对应的组时角色为空字符串
+func GroupMappedRoles(groups []string, mappings []configs.GroupRoleMapping) (orgRoles map[models.Id]string, projectRoles map[models.Id]string) {
+	orgRoles = make(map[models.Id]string)
+	projectRoles = make(map[models.Id]string)
+
+	for _, m := range mappings {
+		orgId, projectId := models.Id(m.OrgId), models.Id(m.ProjectId)
+		if _, ok := orgRoles[orgId]; !ok {
+			orgRoles[orgId] = ""
+		}
+		if _, ok := projectRoles[projectId]; !ok && projectId != "" {
+			projectRoles[projectId] = ""
+		}
+
+		matched := false
+		for _, g := range groups {
+			if groupMatch(m.Group, g) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		orgRole := m.OrgRole
+		if orgRole == "" {
+			orgRole = consts.OrgRoleMember
+		}
+		orgRoles[orgId] = higherRole(orgRolePriority, orgRoles[orgId], orgRole)
+
+		if projectId != "" {
+			projectRole := m.ProjectRole
+			if projectRole == "" {
+				projectRole = consts.ProjectRoleOperator
+			}
+			projectRoles[projectId] = higherRole(projectRolePriority, projectRoles[projectId], projectRole)
+		}
+	}
+	return orgRoles, projectRoles
+}
+
+// filterGroupMappings 过滤掉项目不存在或者项目不属于 org_id 指定组织的映射，
+// projectOrgs 为映射中的项目 id 到组织 id 的对应关系，返回有效的映射及无效映射的组
+func filterGroupMappings(mappings []configs.GroupRoleMapping, projectOrgs map[models.Id]models.Id) (
+	valid []configs.GroupRoleMapping, invalidGroups []string) {
+	for _, m := range mappings {
+		if m.ProjectId != "" {
+			if orgId, ok := projectOrgs[models.Id(m.ProjectId)]; !ok || orgId != models.Id(m.OrgId) {
+				invalidGroups = append(invalidGroups, m.Group)
+				continue
+			}
+		}
+		valid = append(valid, m)
+	}
+	return valid, invalidGroups
+}
+
+// validGroupMappings 查询映射中的项目所属的组织，返回有效的映射
+func validGroupMappings(tx *db.Session, mappings []configs.GroupRoleMapping) ([]configs.GroupRoleMapping, e.Error) {
+	projectIds := make([]models.Id, 0)
+	for _, m := range mappings {
+		if m.ProjectId != "" {
+			projectIds = append(projectIds, models.Id(m.ProjectId))
+		}
+	}
+
+	projectOrgs := make(map[models.Id]models.Id)
+	if len(projectIds) > 0 {
+		projects := make([]models.Project, 0)
+		if err := tx.Model(&models.Project{}).Where("id IN (?)", projectIds).Find(&projects); err != nil {
+			return nil, e.New(e.DBError, err)
+		}
+		for _, p := range projects {
+			projectOrgs[p.Id] = p.OrgId
+		}
+	}
+
+	valid, invalidGroups := filterGroupMappings(mappings, projectOrgs)
+	if len(invalidGroups) > 0 {
+		logs.Get().WithField("func", "validGroupMappings").
+			Warnf("project of group mappings not exists or not belongs to org_id, groups: %v", invalidGroups)
+	}
+	return valid, nil
+}
+
+// SyncUserGroupRoles 根据组映射同步用户的组织及项目角色，
+// 映射中出现的组织、项目以 ldap 或 oidc 返回的组为准(添加、更新或删除)，其他组织、项目的角色不做修改。
+// 项目不属于 org_id 指定组织的映射会被忽略
+func SyncUserGroupRoles(tx *db.Session, userId models.Id, groups []string, mappings []configs.GroupRoleMapping) e.Error {
+	mappings, err := validGroupMappings(tx, mappings)
+	if err != nil {
+		return err
+	}
+	orgRoles, projectRoles := GroupMappedRoles(groups, mappings)
+
+	for orgId, role := range orgRoles {
+		rels, err := FindUsersOrgRel(tx, userId, orgId)
+		if err != nil {
+			return e.AutoNew(err, e.DBError)
+		}
+		switch {
+		case role == "" && len(rels) > 0:
+			if err := DeleteUserOrgRel(tx, userId, orgId); err != nil {
+				return err
+			}
+		case role != "" && len(rels) == 0:
+			if _, err := CreateUserOrgRel(tx, models.UserOrg{UserId: userId, OrgId: orgId, Role: role}); err != nil {
+				return err
+			}
+		case role != "" && rels[0].Role != role:
+			if err := UpdateUserOrgRel(tx, models.UserOrg{UserId: userId, OrgId: orgId, Role: role}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for projectId, role := range projectRoles {
+		rels := make([]models.UserProject, 0)
+		if err := tx.Where("user_id = ? AND project_id = ?", userId, projectId).Find(&rels); err != nil {
+			return e.New(e.DBError, err)
+		}
+		query := tx.Where("user_id = ? AND project_id = ?", userId, projectId)
+		switch {
+		case role == "" && len(rels) > 0:
+			if _, err := query.Delete(&models.UserProject{}); err != nil {
+				return e.New(e.DBError, err)
+			}
+		case role != "" && len(rels) == 0:
+			if _, err := CreateProjectUser(tx, models.UserProject{UserId: userId, ProjectId: projectId, Role: role}); err != nil {
+				return err
+			}
+		case role != "" && rels[0].Role != role:
+			if err := UpdateProjectUser(query, models.Attrs{"role": role}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package services
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/consts"
+	"cloudiac/portal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupMappedRoles(t *testing.T) {
+	mappings := []configs.GroupRoleMapping{
+		{Group: "devops", OrgId: "org-1", ProjectId: "p-1", ProjectRole: consts.ProjectRoleOperator},
+		{Group: "cn=leads,ou=groups,dc=example,dc=com", OrgId: "org-1", OrgRole: consts.OrgRoleAdmin,
+			ProjectId: "p-1", ProjectRole: consts.ProjectRoleManager},
+		{Group: "auditors", OrgId: "org-2", ProjectId: "p-2", ProjectRole: consts.ProjectRoleGuest},
+	}
+
+	orgRoles, projectRoles := GroupMappedRoles([]string{"CN=DevOps,ou=groups,dc=example,dc=com"}, mappings)
+	assert.Equal(t, map[models.Id]string{"org-1": consts.OrgRoleMember, "org-2": ""}, orgRoles)
+	assert.Equal(t, map[models.Id]string{"p-1": consts.ProjectRoleOperator, "p-2": ""}, projectRoles)
+
+	orgRoles, projectRoles = GroupMappedRoles([]string{
+		"cn=devops,ou=groups,dc=example,dc=com",
+		"cn=leads,ou=groups,dc=example,dc=com",
+	}, mappings)
+	assert.Equal(t, consts.OrgRoleAdmin, orgRoles["org-1"])
+	assert.Equal(t, consts.ProjectRoleManager, projectRoles["p-1"])
+}
+
+func TestFilterGroupMappings(t *testing.T) {
+	mappings := []configs.GroupRoleMapping{
+		{Group: "devops", OrgId: "org-1"},
+		{Group: "leads", OrgId: "org-1", ProjectId: "p-1"},
+		{Group: "auditors", OrgId: "org-1", ProjectId: "p-2"},
+		{Group: "testers", OrgId: "org-1", ProjectId: "p-3"},
+	}
+
+	valid, invalidGroups := filterGroupMappings(mappings, map[models.Id]models.Id{"p-1": "org-1", "p-2": "org-2"})
+	assert.Equal(t, mappings[:2], valid)
+	assert.Equal(t, []string{"auditors", "testers"}, invalidGroups)
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"cloudiac/portal/consts/e"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services"
+	"runtime/debug"
+)
+
+// processDeployStacks 推进执行中的环境栈部署: 当前环境的任务成功后创建下一个环境的任务，任务失败则中止后续部署
+func (m *TaskManager) processDeployStacks() {
+	logger := m.logger.WithField("func", "processDeployStacks")
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("panic: %v", r)
+			logger.Debugf("%s", debug.Stack())
+		}
+	}()
+
+	stacks, err := services.GetRunningDeployStacks(m.db)
+	if err != nil {
+		logger.Errorf("get running deploy stacks: %v", err)
+		return
+	}
+
+	for _, stack := range stacks {
+		logger := logger.WithField("stackId", stack.Id)
+
+		tx := m.db.Begin()
+		if err := services.ProcessDeployStack(tx, stack); err != nil {
+			_ = tx.Rollback()
+			logger.Errorf("process deploy stack: %v", err)
+			if err.Code() == e.DBError {
+				// 数据库错误，下次循环重试
+				continue
+			}
+			if er := services.ChangeDeployStackStatus(m.db, stack, models.DeployStackFailed, err.Error()); er != nil {
+				logger.Errorf("change deploy stack status: %v", er)
+			}
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			_ = tx.Rollback()
+			logger.Errorf("commit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"cloudiac/configs"
+	"cloudiac/portal/services"
+	"context"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	logCleanInterval  = time.Hour
+	logCleanBatchSize = 64
+
+	auditLogCleanBatchSize = 1000
+)
+
+// processLogClean 按系统配置的日志保存周期清理过期的任务日志及审计日志，并清理长时间未注册的 runner，每小时执行一次
+func (m *TaskManager) processLogClean(ctx context.Context) {
+	if time.Since(m.lastLogCleanAt) < logCleanInterval {
+		return
+	}
+	// 上一次清理还未结束
+	if !atomic.CompareAndSwapInt32(&m.logCleaning, 0, 1) {
+		return
+	}
+	m.lastLogCleanAt = time.Now()
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			atomic.StoreInt32(&m.logCleaning, 0)
+			m.wg.Done()
+		}()
+		m.doLogClean(ctx)
+		m.doAuditLogClean(ctx)
+		m.doRunnerClean()
+	}()
+}
+
+func (m *TaskManager) doLogClean(ctx context.Context) {
+	logger := m.logger.WithField("func", "doLogClean")
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("panic: %v", r)
+			logger.Debugf("%s", debug.Stack())
+		}
+	}()
+
+	period, err := services.GetLogSavePeriod(m.db)
+	if err != nil {
+		logger.Errorf("get log save period: %v", err)
+		return
+	} else if period == 0 {
+		return
+	}
+
+	before := time.Now().Add(-period)
+	total := services.LogCleanResult{}
+	for {
+		// 清理失败的任务在本次清理中跳过，下次清理时重试
+		result, err := services.CleanExpiredTaskLogs(m.db, before, logCleanBatchSize, total.FailedTaskIds)
+		if result != nil {
+			total.Tasks += result.Tasks
+			total.Files += result.Files
+			total.Bytes += result.Bytes
+			total.FailedTaskIds = append(total.FailedTaskIds, result.FailedTaskIds...)
+		}
+		if err != nil {
+			logger.Errorf("clean expired task logs: %v", err)
+			break
+		}
+		if result.Tasks == 0 && len(result.FailedTaskIds) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Infof("context done, stop log clean")
+			return
+		default:
+		}
+	}
+
+	if total.Tasks > 0 || len(total.FailedTaskIds) > 0 {
+		logger.Infof("expired task logs before %s cleaned, %s", before.Format(time.RFC3339), total)
+	}
+}
+
+func (m *TaskManager) doAuditLogClean(ctx context.Context) {
+	logger := m.logger.WithField("func", "doAuditLogClean")
+
+	period, err := services.GetAuditLogSavePeriod(m.db)
+	if err != nil {
+		logger.Errorf("get audit log save period: %v", err)
+		return
+	} else if period == 0 {
+		return
+	}
+
+	before := time.Now().Add(-period)
+	var total int64
+	for {
+		n, err := services.CleanExpiredOperationLogs(m.db, before, auditLogCleanBatchSize)
+		total += n
+		if err != nil {
+			logger.Errorf("clean expired audit logs: %v", err)
+			break
+		}
+		if n < auditLogCleanBatchSize {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Infof("context done, stop audit log clean")
+			return
+		default:
+		}
+	}
+
+	if total > 0 {
+		logger.Infof("expired audit logs before %s cleaned, count: %d", before.Format(time.RFC3339), total)
+	}
+}
+
+// doRunnerClean 删除长时间未重新注册的 runner(registry 配置为 db 时)
+func (m *TaskManager) doRunnerClean() {
+	if !configs.Get().Registry.IsDB() {
+		return
+	}
+
+	logger := m.logger.WithField("func", "doRunnerClean")
+	n, err := services.CleanExpiredRunners(m.db)
+	if err != nil {
+		logger.Errorf("clean expired runners: %v", err)
+		return
+	}
+	if n > 0 {
+		logger.Infof("expired runners cleaned, count: %d", n)
+	}
+}
@@ -43,12 +43,22 @@ type TaskManager struct {
 	db     *db.Session
 	logger logs.Logger
 
-	envRunningTask sync.Map       // 每个环境下正在执行的任务
-	runnerTaskNum  map[string]int // 每个 runner 正在执行的任务数量
+	envRunningTask sync.Map // 每个环境下正在执行的任务
+
+	taskNumLock    sync.Mutex
+	runnerTaskNum  map[string]int    // 每个 runner 正在执行的任务数量
+	orgTaskNum     map[models.Id]int // 每个组织正在执行的部署任务数量
+	projectTaskNum map[models.Id]int // 每个项目正在执行的部署任务数量
 
 	wg sync.WaitGroup // 等待执行任务协程退出的 wait group
 
-	maxTasksPerRunner int // 每个 runner 并发任务数量限制
+	maxTasksPerRunner int                             // 每个 runner 并发任务数量限制
+	runnerStatus      map[string]*models.RunnerStatus // runner 心跳状态，由 taskNumLock 保护
+	lastHeartbeatAt   time.Time                       // 最近一次检查 runner 心跳的时间
+	heartbeating      int32                           // 是否正在检查 runner 心跳
+
+	lastLogCleanAt time.Time // 最近一次执行日志清理的时间
+	logCleaning    int32     // 是否正在执行日志清理
 }
 
 func Start(serviceId string) {
@@ -76,12 +86,32 @@ func (m *TaskManager) reset() {
 	m.db = db.Get()
 	m.envRunningTask = sync.Map{}
 	m.runnerTaskNum = make(map[string]int)
+	m.orgTaskNum = make(map[models.Id]int)
+	m.projectTaskNum = make(map[models.Id]int)
 	m.wg = sync.WaitGroup{}
 	m.maxTasksPerRunner = services.GetRunnerMax()
+	m.runnerStatus = make(map[string]*models.RunnerStatus)
+	m.lastHeartbeatAt = time.Time{}
+	m.heartbeating = 0
+	m.lastLogCleanAt = time.Time{}
+	m.logCleaning = 0
+}
+
+// locker 分布式锁，consul lock 及 services.DBLocker 均实现了该接口
+type locker interface {
+	Lock(stopCh <-chan struct{}) (<-chan struct{}, error)
+	Unlock() error
+}
+
+func (m *TaskManager) getLocker() (locker, error) {
+	if configs.Get().Registry.IsDB() {
+		return services.NewDBLocker(TaskManagerLockKey, m.id), nil
+	}
+	return consul.GetLocker(TaskManagerLockKey, []byte(m.id), configs.Get().Consul.Address)
 }
 
 func (m *TaskManager) acquireLock(ctx context.Context) (<-chan struct{}, error) {
-	locker, err := consul.GetLocker(TaskManagerLockKey, []byte(m.id), configs.Get().Consul.Address)
+	locker, err := m.getLocker()
 	if err != nil {
 		return nil, errors.Wrap(err, "get locker")
 	}
@@ -144,6 +174,9 @@ func (m *TaskManager) start() {
 	defer ticker.Stop()
 
 	for {
+		// 检查 runner 心跳
+		m.processRunnerHeartbeat()
+
 		if err := m.processAutoDestroy(); err != nil {
 			m.logger.Errorf("process auto destroy error: %v", err)
 		}
@@ -151,6 +184,12 @@ func (m *TaskManager) start() {
 		m.processPendingTask(ctx)
 		// 执行所有偏移检测任务
 		m.beginCronDriftTask()
+		// 执行所有定时部署任务
+		m.beginCronDeployTask()
+		// 推进环境栈部署
+		m.processDeployStacks()
+		// 清理过期的任务日志
+		m.processLogClean(ctx)
 		select {
 		case <-ticker.C:
 			continue
@@ -223,6 +262,54 @@ func (m *TaskManager) beginCronDriftTask() {
 	}
 }
 
+// 创建到达执行时间的定时部署任务
+func (m *TaskManager) beginCronDeployTask() {
+	logger := m.logger.WithField("func", "beginCronDeployTask")
+	envs := make([]*models.Env, 0)
+	query := m.db.Where("archived = ? AND cron_deploy_express != '' AND next_deploy_task_time <= ?", false, time.Now())
+	if err := query.Model(&models.Env{}).Find(&envs); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for _, env := range envs {
+		logger := logger.WithField("envId", env.Id)
+		// 无论任务是否创建成功都更新下次执行时间，避免每次循环重复处理
+		nextTime, err := apps.ParseCronDeployExpress(env.CronDeployExpress)
+		if err != nil {
+			logger.Errorf("parse cron deploy express failed, error: %v", err)
+			nextTime = nil
+		}
+		if _, err := services.UpdateEnv(m.db, env.Id, models.Attrs{"nextDeployTaskTime": nextTime}); err != nil {
+			logger.Errorf("update next deploy task time failed, error: %v", err)
+			continue
+		}
+
+		// 环境未执行过部署任务，无法获取部署参数
+		if env.LastTaskId == "" {
+			logger.Infof("env has no deploy task, skip cron deploy")
+			continue
+		}
+		// 已有排队或执行中的定时部署任务，本次跳过
+		if exist, err := services.HasPendingCronDeployTask(m.db, env.Id); err != nil {
+			logger.Errorf("query pending cron deploy task failed, error: %v", err)
+			continue
+		} else if exist {
+			continue
+		}
+
+		task, err := services.GetTaskById(m.db, env.LastTaskId)
+		if err != nil {
+			logger.Errorf("create cron deploy task failed, error: %v", err)
+			continue
+		}
+		if _, err = services.CloneNewCronDeployTask(m.db, *task, env); err != nil {
+			logger.Errorf("create cron deploy task failed, error: %v", err)
+			continue
+		}
+	}
+}
+
 func (m *TaskManager) recoverTask(ctx context.Context) error {
 	logger := m.logger
 	query := m.db.Where("status IN (?)", []string{models.TaskRunning, models.TaskApproving})
@@ -285,7 +372,8 @@ func (m *TaskManager) getPendingDeployTasks() []*models.Task {
 		"AND iac_task.status = ? GROUP BY env_id", firstPendingQuery.Expr(), models.TaskPending)
 
 	// 通过 id 查询完整任务信息
-	query := m.db.Model(&models.Task{}).Joins("JOIN (?) AS t ON t.task_id = iac_task.id", firstPendingIdQuery.Expr())
+	query := m.db.Model(&models.Task{}).Joins("JOIN (?) AS t ON t.task_id = iac_task.id", firstPendingIdQuery.Expr()).
+		Order("iac_task.created_at, iac_task.id")
 
 	if len(runningEnvs) > 0 {
 		// 过滤掉同一环境下有其他任务在执行的任务
@@ -298,13 +386,13 @@ func (m *TaskManager) getPendingDeployTasks() []*models.Task {
 		query = query.Where("runner_id NOT IN (?)", limitedRunners)
 	}
 
-	queryTaskLimit := 64 // 单次查询任务数量限制
+	// 每个环境只会返回一条任务，所以这里不限制查询数量，以保证所有项目的任务都参与排序
 	tasks := make([]*models.Task, 0)
-	if err := query.Limit(queryTaskLimit).Find(&tasks); err != nil {
+	if err := query.Find(&tasks); err != nil {
 		logger.Panicf("find '%s' task error: %v", models.TaskPending, err)
 	}
 
-	return tasks
+	return services.OrderPendingTasks(tasks)
 }
 
 func (m *TaskManager) getPendingScanTasks() []*models.ScanTask {
@@ -329,9 +417,18 @@ func (m *TaskManager) getPendingScanTasks() []*models.ScanTask {
 }
 
 func (m *TaskManager) getLimitedRunner() []string {
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+
 	limitedRunners := make([]string, 0)
 	for runnerId, count := range m.runnerTaskNum {
-		if count >= m.maxTasksPerRunner {
+		if max := m.runnerMaxTasks(runnerId); max > 0 && count >= max {
+			limitedRunners = append(limitedRunners, runnerId)
+		}
+	}
+	// 不健康的 runner 也不再分配任务
+	for runnerId, s := range m.runnerStatus {
+		if !s.Healthy {
 			limitedRunners = append(limitedRunners, runnerId)
 		}
 	}
@@ -354,6 +451,22 @@ func (m *TaskManager) processPendingTask(ctx context.Context) {
 		tasks[scanTasksLen+idx] = deployTasks[idx]
 	}
 
+	var (
+		quotas  *taskQuotas
+		windows map[models.Id]*models.MaintenanceWindow
+	)
+	if len(deployTasks) > 0 {
+		var err error
+		if quotas, err = m.getTaskQuotas(); err != nil {
+			logger.Errorf("get task quotas error: %v", err)
+			return
+		}
+		if windows, err = apps.GetActiveMaintenanceWindows(m.db, time.Now()); err != nil {
+			logger.Errorf("get maintenance windows error: %v", err)
+			return
+		}
+	}
+
 	for i := range tasks {
 		select {
 		case <-ctx.Done():
@@ -362,11 +475,35 @@ func (m *TaskManager) processPendingTask(ctx context.Context) {
 		}
 
 		task := tasks[i]
-		// 判断 runner 并发数量
-		n := m.runnerTaskNum[task.GetRunnerId()]
-		if n >= m.maxTasksPerRunner {
-			logger.WithField("count", n).Infof("runner %s: %v", task.GetRunnerId(), ErrMaxTasksPerRunner)
-			continue
+		// 判断 runner 健康状态及并发数量，通过 runner tags 调度的任务在执行前再选择 runner
+		if task.GetRunnerId() != "" {
+			if err := m.checkRunnerAvailable(task.GetRunnerId()); err != nil {
+				logger.WithField("taskId", task.GetId()).Infof("%v", err)
+				continue
+			}
+		}
+
+		if t, ok := task.(*models.Task); ok {
+			// 项目处于维护窗口期间，暂停执行 apply、destroy 任务
+			if w := windows[t.ProjectId]; w != nil && apps.IsFreezeTask(t) {
+				logger.WithField("taskId", t.Id).Debugf("project in maintenance window '%s'", w.Name)
+				continue
+			}
+			// 判断组织、项目并发数量
+			if err := m.checkTaskQuota(t, quotas); err != nil {
+				logger.WithField("taskId", t.Id).Debugf("%v", err)
+				continue
+			}
+		}
+
+		if t, ok := task.(*models.Task); ok && t.RunnerId == "" {
+			if _, running := m.envRunningTask.Load(t.EnvId); running {
+				continue
+			}
+			if err := m.assignTaskRunner(t); err != nil {
+				logger.WithField("taskId", t.Id).Infof("assign runner: %v", err)
+				continue
+			}
 		}
 
 		if err := m.runTask(ctx, task); err != nil {
@@ -394,9 +531,11 @@ func (m *TaskManager) runTask(ctx context.Context, task models.Tasker) error {
 		}
 	}
 
+	m.updateTaskNum(task, 1)
 	m.wg.Add(1)
 	go func() {
 		defer func() {
+			m.updateTaskNum(task, -1)
 			if t, ok := task.(*models.Task); ok {
 				m.envRunningTask.Delete(t.EnvId)
 			}
@@ -479,10 +618,37 @@ func (m *TaskManager) doRunTask(ctx context.Context, task *models.Task) (startEr
 		taskStartFailed(errors.Wrap(err, "get task steps"))
 		return
 	}
-	var step *models.TaskStep
+	// 合规检查失败且未开启 StopOnViolation 或者步骤配置了 continueOnError 时，步骤失败不影响任务执行
+	ignoreStepFail := func(step *models.TaskStep) bool {
+		return step.ContinueOnError || (step.Type == common.TaskStepOpaScan && !task.StopOnViolation)
+	}
+	hasChanges := func() bool {
+		return planHasChanges(task)
+	}
+
+	var (
+		step        *models.TaskStep
+		failedStep  *models.TaskStep // 第一个执行失败的步骤，有步骤失败后只执行 when.status 为 failure、always 的步骤
+		resultStep  *models.TaskStep // 决定任务最终状态的步骤
+		interrupted bool             // 任务被中止、驳回或者出现异常时不再修改任务的当前步骤
+	)
 	for _, step = range steps {
 		if step.Index < task.CurrStep {
-			// 跳过己执行的步骤
+			// 跳过己执行的步骤(任务恢复执行时需要找回之前失败的步骤)
+			if step.IsFail() && !ignoreStepFail(step) && failedStep == nil {
+				failedStep = step
+			} else if step.IsSuccess() {
+				resultStep = step
+			}
+			continue
+		}
+
+		if ok, reason := checkStepWhen(step.When, failedStep != nil, task.Revision, hasChanges); !ok {
+			logger.Infof("skip step %d(%s): %s", step.Index, step.Type, reason)
+			if err := services.ChangeTaskStepStatusAndUpdate(m.db, task, step, models.TaskStepSkipped, reason); err != nil {
+				taskStartFailed(errors.Wrap(err, "skip task step"))
+				return
+			}
 			continue
 		}
 
@@ -505,17 +671,53 @@ func (m *TaskManager) doRunTask(ctx context.Context, task *models.Task) (startEr
 		runErr := m.runTaskStep(ctx, *runTaskReq, task, step)
 		if err := m.processStepDone(task, step); err != nil {
 			logger.Warnf("process step done: %v", err)
+			interrupted = true
 			break
 		}
 
 		if runErr != nil {
-			if step.Type == common.TaskStepOpaScan && !task.StopOnViolation {
-				// 合规任务失败不影响环境部署流程
-				logger.Warnf("run scan task step: %v", runErr)
+			if ctx.Err() != nil || runErr == ErrTaskStepRejected || runErr == ErrTaskStepAborted {
+				logger.Infof("run task step: %v", runErr)
+				interrupted = true
+				break
+			}
+			// runTaskStep() 中审批后会重新查询步骤，所以这里需要查询步骤的最新状态
+			if latest, err := services.GetTaskStep(m.db, task.Id, step.Index); err != nil || !latest.IsFail() {
+				logger.Infof("run task step: %v", runErr)
+				interrupted = true
+				break
+			} else {
+				step = latest
+			}
+
+			if ignoreStepFail(step) {
+				logger.Warnf("run task step(ignore failure): %v", runErr)
+				if !step.ContinueOnError {
+					resultStep = step
+				}
 				continue
 			}
 			logger.Infof("run task step: %v", runErr)
-			break
+			if failedStep == nil {
+				failedStep = step
+			}
+			// 继续执行后续 when.status 为 failure、always 的步骤
+			continue
+		}
+		resultStep = step
+	}
+
+	if !interrupted {
+		// 任务的最终状态基于当前步骤确定，有步骤失败时为失败的步骤，否则为最后一个执行成功的步骤
+		if failedStep != nil {
+			resultStep = failedStep
+		}
+		if resultStep != nil && resultStep.Index != task.CurrStep {
+			if _, err = m.db.Model(task).UpdateAttrs(models.Attrs{"CurrStep": resultStep.Index}); err != nil {
+				logger.Errorf("update task current step: %v", err)
+			} else {
+				task.CurrStep = resultStep.Index
+			}
 		}
 	}
 
@@ -698,13 +900,17 @@ func (m *TaskManager) processTaskDone(taskId models.Id) {
 		return nil
 	}
 
-	// 任务被审批驳回时会即时更新状态，且不会执行资源统计步骤，所以不需要执行下面这段逻辑
-	if !lastStep.IsRejected() {
-		if task.IsEffectTask() {
+	// 任务被审批驳回或中止时会即时更新状态，且不会执行资源统计步骤，所以不需要执行下面这段逻辑
+	if !lastStep.IsRejected() && !lastStep.IsAborted() {
+		if task.IsCollectTask() {
 			if err := processState(); err != nil {
 				logger.Errorf("process task state: %v", err)
 			}
-
+			if _, err := services.SaveEnvStateVersion(dbSess, task); err != nil {
+				logger.Errorf("save env state version: %v", err)
+			}
+		}
+		if task.IsEffectTask() {
 			// 任务执行成功才会进行 changes 统计，失败的话基于 plan 文件进行变更统计是不准确的
 			// (terraform 执行 apply 失败也不会输出资源变更情况)
 			if lastStep.Status == models.TaskComplete {
@@ -770,12 +976,13 @@ func (m *TaskManager) processTaskDone(taskId models.Id) {
 			logger.Errorf("update task status error: %v", err)
 		}
 
-		if task.IsEffectTask() {
+		if task.IsCollectTask() {
 			// 注意：环境的 lastResTaskId 必须在资源漂移信息统计后执行
 			if err = services.UpdateEnvModel(dbSess, task.EnvId, models.Env{LastResTaskId: task.Id}); err != nil {
 				logger.Errorf("update env lastResTaskId: %v", err)
 			}
-
+		}
+		if task.IsEffectTask() {
 			// 注意: 该步骤需要在环境状态被更新之后执行
 			if err := processAutoDestroy(); err != nil {
 				logger.Errorf("process auto destroy: %v", err)
@@ -819,8 +1026,28 @@ func (m *TaskManager) runTaskStep(
 		}
 	}
 
+	// 步骤还有剩余的重试次数时重置为 pending 状态，等待重试间隔后重新执行该步骤
+	retryStep := func(reason string) bool {
+		if step.RetryNumber <= 0 || step.CurrentRetryCount >= step.RetryNumber {
+			return false
+		}
+		// 下次重试时间为当前步骤失败时间点加步骤设置的重试间隔时间。
+		step.NextRetryTime = time.Now().Unix() + int64(step.RetryDelay)
+		step.CurrentRetryCount += 1
+		message := fmt.Sprintf("Task step %s and try again. The current number of retries is %d", reason, step.CurrentRetryCount)
+		changeStepStatusAndStepRetryTimes(models.TaskStepPending, message, step)
+		return true
+	}
+
 	if step.MustApproval && !step.IsApproved() {
 		logger.Infof("waitting task step approve")
+		if step.ApprovalExpireAt == nil {
+			policy, err := services.GetTaskApprovalPolicy(m.db, task)
+			if err != nil {
+				return errors.Wrap(err, "get task approval policy")
+			}
+			step.ApprovalExpireAt = services.GetApprovalExpireAt(policy, time.Now())
+		}
 		changeStepStatusAndStepRetryTimes(models.TaskStepApproving, "", step)
 		var newStep *models.TaskStep
 		if newStep, err = WaitTaskStepApprove(ctx, m.db, step.TaskId, step.Index); err != nil {
@@ -829,7 +1056,7 @@ func (m *TaskManager) runTaskStep(
 			}
 
 			logger.Errorf("wait task step approve error: %v", err)
-			if err != ErrTaskStepRejected {
+			if err != ErrTaskStepRejected && err != ErrTaskStepAborted {
 				changeStepStatusAndStepRetryTimes(models.TaskStepFailed, err.Error(), step)
 			}
 			return err
@@ -837,6 +1064,30 @@ func (m *TaskManager) runTaskStep(
 		step = newStep
 	}
 
+	if step.Type == models.TaskStepStatePush && len(step.Args) > 0 {
+		// 恢复 state 的步骤参数为 state 版本 id，读取版本内容后随步骤一起发送到 runner
+		version, er := services.GetEnvStateVersionById(m.db, models.Id(step.Args[0]))
+		if er == nil {
+			taskReq.StateContent, er = services.GetEnvStateVersionContent(version)
+		}
+		if er != nil {
+			logger.Errorf("load state version %s: %v", step.Args[0], er)
+			changeStepStatusAndStepRetryTimes(models.TaskStepFailed, er.Error(), step)
+			return er
+		}
+	}
+
+	if step.Cache.Key != "" {
+		// 恢复步骤缓存，缓存不存在或读取失败时不影响步骤执行
+		if content, er := readIfExist(step.CachePath()); er != nil {
+			logger.Warnf("read step cache %s: %v", step.Cache.Key, er)
+		} else if len(content) > runner.MaxStepCacheSize {
+			logger.Warnf("step cache %s too large (%d bytes), ignored", step.Cache.Key, len(content))
+		} else {
+			taskReq.CacheContent = content
+		}
+	}
+
 loop:
 	for {
 		select {
@@ -857,18 +1108,13 @@ loop:
 		case models.TaskStepPending, models.TaskApproving:
 			// 先将步骤置为 running 状态，然后再发起调用，保证步骤不会重复执行
 			changeStepStatusAndStepRetryTimes(models.TaskStepRunning, "", step)
+			if step.IsAborted() {
+				break loop
+			}
 			if cid, retryAble, err := StartTaskStep(taskReq, *step); err != nil {
 				logger.Warnf("start task step %s(%d): %v", step.Type, step.Index, err)
-				// 如果是可重试错误，并且任务设定可以重试, 则运行重试逻辑
-				if retryAble && task.RetryAble {
-					if step.RetryNumber > 0 && step.CurrentRetryCount < step.RetryNumber {
-						// 下次重试时间为当前任务失败时间点加任务设置重试间隔时间。
-						step.NextRetryTime = time.Now().Unix() + int64(task.RetryDelay)
-						step.CurrentRetryCount += 1
-						message := fmt.Sprintf("Task step start failed and try again. The current number of retries is %d", step.CurrentRetryCount)
-						changeStepStatusAndStepRetryTimes(models.TaskStepPending, message, step)
-					}
-				} else {
+				// 如果是可重试错误，并且步骤还有剩余的重试次数, 则运行重试逻辑
+				if !retryAble || !retryStep("start failed") {
 					changeStepStatusAndStepRetryTimes(models.TaskStepFailed, err.Error(), step)
 					return err
 				}
@@ -892,12 +1138,7 @@ loop:
 				break loop
 			}
 			if stepResult.Status == models.TaskStepFailed || stepResult.Status == models.TaskStepTimeout {
-				if task.RetryAble && step.RetryNumber > 0 && step.CurrentRetryCount < step.RetryNumber {
-					step.NextRetryTime = time.Now().Unix() + int64(task.RetryDelay)
-					step.CurrentRetryCount += 1
-					message := fmt.Sprintf("Task step start failed and try again. The current number of retries is %d", step.CurrentRetryCount)
-					changeStepStatusAndStepRetryTimes(models.TaskStepPending, message, step)
-				}
+				retryStep(stepResult.Status)
 			}
 		default:
 			break loop
@@ -919,6 +1160,8 @@ loop:
 			message = step.Message
 		}
 		return fmt.Errorf(message)
+	case models.TaskStepAborted:
+		return ErrTaskStepAborted
 	default:
 		return fmt.Errorf("unknown step status: %v", step.Status)
 	}
@@ -943,6 +1186,7 @@ func buildRunTaskReq(dbSess *db.Session, task models.Task) (taskReq *runner.RunT
 		Playbook:        task.Playbook,
 		PlayVarsFile:    task.PlayVarsFile,
 		TfVersion:       task.TfVersion,
+		IacTool:         task.IacTool,
 		EnvironmentVars: make(map[string]string),
 		TerraformVars:   make(map[string]string),
 		AnsibleVars:     make(map[string]string),
@@ -950,6 +1194,9 @@ func buildRunTaskReq(dbSess *db.Session, task models.Task) (taskReq *runner.RunT
 
 	if runnerEnv.TfVersion == "" {
 		runnerEnv.TfVersion = consts.DefaultTerraformVersion
+		if runnerEnv.IacTool == common.IacToolOpenTofu {
+			runnerEnv.TfVersion = consts.DefaultOpenTofuVersion
+		}
 	}
 
 	for _, v := range task.Variables {
@@ -966,11 +1213,15 @@ func buildRunTaskReq(dbSess *db.Session, task models.Task) (taskReq *runner.RunT
 		}
 	}
 
-	stateStore := runner.StateStore{
-		Backend: "consul",
-		Scheme:  "http",
-		Path:    task.StatePath,
-		Address: "",
+	env, err := services.GetEnvById(dbSess, task.EnvId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get env '%s' error: %v", task.EnvId, err)
+	}
+	stateStore := buildStateStore(env, task.StatePath, configs.Get().Registry)
+
+	project, err := services.GetProjectsById(dbSess, task.ProjectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get project '%s' error: %v", task.ProjectId, err)
 	}
 
 	pk := ""
@@ -994,6 +1245,7 @@ func buildRunTaskReq(dbSess *db.Session, task models.Task) (taskReq *runner.RunT
 		Timeout:         task.StepTimeout,
 		StopOnViolation: task.StopOnViolation,
 		ContainerId:     task.ContainerId,
+		ContainerLimits: project.ContainerLimits.Merge(env.ContainerLimits),
 	}
 
 	if err := runTaskReqAddSysEnvs(taskReq); err != nil {
@@ -1369,6 +1621,45 @@ loop:
 	}
 }
 
+// buildStateStore 根据环境的 state 存储配置生成 runner 使用的 StateStore，认证信息通过系统环境变量传递
+func buildStateStore(env *models.Env, statePath string, registry configs.RegistryConfig) runner.StateStore {
+	conf := env.StateBackendConfig
+	backend := env.StateBackend
+	if backend == "" {
+		backend = services.DefaultStateBackend(registry)
+	}
+	switch backend {
+	case models.StateBackendS3:
+		return runner.StateStore{
+			Backend:        models.StateBackendS3,
+			Path:           statePath,
+			Bucket:         conf.Bucket,
+			Region:         conf.Region,
+			Endpoint:       conf.Endpoint,
+			ForcePathStyle: conf.ForcePathStyle,
+		}
+	case models.StateBackendHttp:
+		return runner.StateStore{
+			Backend:       models.StateBackendHttp,
+			Address:       conf.Address,
+			LockAddress:   conf.LockAddress,
+			UnlockAddress: conf.UnlockAddress,
+		}
+	case models.StateBackendLocal:
+		return runner.StateStore{
+			Backend: models.StateBackendLocal,
+			Path:    statePath,
+		}
+	default:
+		return runner.StateStore{
+			Backend: models.StateBackendConsul,
+			Scheme:  "http",
+			Path:    statePath,
+			Address: "",
+		}
+	}
+}
+
 // 为 req 添加 sysEnvs(直接修改传入的 req)
 func runTaskReqAddSysEnvs(req *runner.RunTaskReq) error {
 	sysEnvs := make(map[string]string)
@@ -1408,6 +1699,17 @@ func runTaskReqAddSysEnvs(req *runner.RunTaskReq) error {
 		sysEnvs["CLOUDIAC_ENV_RESOURCES"] = fmt.Sprintf("%d", resCount)
 		// CLOUDIAC_TF_VERSION	当前任务使用的 terraform 版本号(eg. 0.14.11)
 		sysEnvs["CLOUDIAC_TF_VERSION"] = req.Env.TfVersion
+		// CLOUDIAC_IAC_TOOL	当前任务使用的 IaC 工具(terraform, opentofu, terragrunt)
+		sysEnvs["CLOUDIAC_IAC_TOOL"] = utils.FirstValueStr(req.Env.IacTool, common.IacToolTerraform)
+
+		// state 存储的认证信息
+		backendEnvs, er := services.GetStateBackendSysEnvs(env)
+		if er != nil {
+			return errors.Wrapf(er, "%s, get state backend envs", req.Env.Id)
+		}
+		for k, v := range backendEnvs {
+			sysEnvs[k] = v
+		}
 	}
 
 	req.SysEnvironments = sysEnvs
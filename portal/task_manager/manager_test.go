@@ -1,8 +1,11 @@
 package task_manager
 
 import (
+	"cloudiac/configs"
 	"cloudiac/portal/models"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var TextCase = `
@@ -100,3 +103,21 @@ func TestParseResourceDriftInfo(t *testing.T) {
 	}
 
 }
+
+func TestBuildStateStore(t *testing.T) {
+	env := &models.Env{}
+	consulRegistry := configs.RegistryConfig{Type: configs.RegistryConsul}
+	dbRegistry := configs.RegistryConfig{Type: configs.RegistryDB}
+
+	assert.Equal(t, models.StateBackendConsul, buildStateStore(env, "env/state", consulRegistry).Backend)
+	// db 注册模式下未配置 state 存储的环境不依赖 consul
+	store := buildStateStore(env, "env/state", dbRegistry)
+	assert.Equal(t, models.StateBackendLocal, store.Backend)
+	assert.Equal(t, "env/state", store.Path)
+
+	env.StateBackend = models.StateBackendS3
+	env.StateBackendConfig = models.StateBackendConfig{Bucket: "iac", Region: "cn-north-1"}
+	store = buildStateStore(env, "env/state", dbRegistry)
+	assert.Equal(t, models.StateBackendS3, store.Backend)
+	assert.Equal(t, "iac", store.Bucket)
+}
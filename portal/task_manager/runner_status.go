@@ -0,0 +1,142 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"cloudiac/portal/models"
+	"cloudiac/portal/services"
+)
+
+const runnerHeartbeatInterval = time.Second * 10
+
+// processRunnerHeartbeat 定时检查所有 runner 的心跳，更新 runner 的健康状态及容量信息
+func (m *TaskManager) processRunnerHeartbeat() {
+	if time.Since(m.lastHeartbeatAt) < runnerHeartbeatInterval {
+		return
+	}
+	// 上一次检查还未结束
+	if !atomic.CompareAndSwapInt32(&m.heartbeating, 0, 1) {
+		return
+	}
+	m.lastHeartbeatAt = time.Now()
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			atomic.StoreInt32(&m.heartbeating, 0)
+			m.wg.Done()
+		}()
+		m.doRunnerHeartbeat()
+	}()
+}
+
+func (m *TaskManager) doRunnerHeartbeat() {
+	logger := m.logger.WithField("func", "doRunnerHeartbeat")
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("panic: %v", r)
+			logger.Debugf("%s", debug.Stack())
+		}
+	}()
+
+	runners, err := services.RunnerSearch()
+	if err != nil {
+		logger.Errorf("search runners error: %v", err)
+		return
+	}
+
+	statuses := make(map[string]*models.RunnerStatus, len(runners))
+	runnerIds := make([]string, 0, len(runners))
+	for _, r := range runners {
+		runnerIds = append(runnerIds, r.ID)
+		status, err := services.RefreshRunnerStatus(m.db, r)
+		if err != nil {
+			logger.Errorf("refresh runner %s status error: %v", r.ID, err)
+			continue
+		}
+		if !status.Healthy {
+			logger.Warnf("runner %s unhealthy: %s", r.ID, status.Message)
+		}
+		statuses[r.ID] = status
+	}
+	if err := services.DeleteRunnerStatusExcept(m.db, runnerIds); err != nil {
+		logger.Errorf("delete offline runner status error: %v", err)
+	}
+
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+	m.runnerStatus = statuses
+}
+
+// runnerMaxTasks 返回 runner 的并发任务数限制，runner 上报了 maxTasks 时优先使用，0 表示不限制。
+// 调用者需要持有 taskNumLock
+func (m *TaskManager) runnerMaxTasks(runnerId string) int {
+	if s := m.runnerStatus[runnerId]; s != nil && s.MaxTasks > 0 {
+		return s.MaxTasks
+	}
+	return m.maxTasksPerRunner
+}
+
+// checkRunnerAvailable 检查 runner 是否可以执行新任务，
+// 未获取到心跳状态的 runner(如 portal 刚启动时)只检查并发数
+func (m *TaskManager) checkRunnerAvailable(runnerId string) error {
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+
+	if s := m.runnerStatus[runnerId]; s != nil && !s.Healthy {
+		return fmt.Errorf("runner %s unhealthy: %s", runnerId, s.Message)
+	}
+	if max := m.runnerMaxTasks(runnerId); max > 0 && m.runnerTaskNum[runnerId] >= max {
+		return fmt.Errorf("runner %s: %v", runnerId, ErrMaxTasksPerRunner)
+	}
+	return nil
+}
+
+// selectRunner 从包含所有 tags 的健康 runner 中选择负载最低的 runner，
+// 负载为正在执行的任务数与并发限制的比值，runner 无并发限制时直接使用任务数
+func (m *TaskManager) selectRunner(tags []string) (string, error) {
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+
+	selected, minLoad := "", 0.0
+	for id, s := range m.runnerStatus {
+		if !s.Healthy || !s.HasTags(tags) {
+			continue
+		}
+
+		running := m.runnerTaskNum[id]
+		load := float64(running)
+		if max := m.runnerMaxTasks(id); max > 0 {
+			if running >= max {
+				continue
+			}
+			load = float64(running) / float64(max)
+		}
+		if selected == "" || load < minLoad || (load == minLoad && id < selected) {
+			selected, minLoad = id, load
+		}
+	}
+	if selected == "" {
+		return "", fmt.Errorf("no available runner with tags %v", tags)
+	}
+	return selected, nil
+}
+
+// assignTaskRunner 为通过 runner tags 调度的任务选择 runner 并保存
+func (m *TaskManager) assignTaskRunner(task *models.Task) error {
+	runnerId, err := m.selectRunner(task.RunnerTags)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.Model(&models.Task{}).Where("id = ?", task.Id).
+		UpdateColumn("runner_id", runnerId); err != nil {
+		return err
+	}
+	task.RunnerId = runnerId
+	return nil
+}
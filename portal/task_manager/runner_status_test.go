@@ -0,0 +1,41 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cloudiac/portal/models"
+)
+
+func TestSelectRunner(t *testing.T) {
+	m := TaskManager{
+		maxTasksPerRunner: 4,
+		runnerTaskNum:     map[string]int{"r1": 1, "r2": 1, "r3": 0, "r4": 2},
+		runnerStatus: map[string]*models.RunnerStatus{
+			"r1": {RunnerId: "r1", Healthy: true, Tags: []string{"prod", "aws"}},
+			"r2": {RunnerId: "r2", Healthy: true, Tags: []string{"prod"}, MaxTasks: 10},
+			"r3": {RunnerId: "r3", Healthy: false, Tags: []string{"prod"}, Message: "executor unavailable"},
+			"r4": {RunnerId: "r4", Healthy: true, Tags: []string{"aws"}, MaxTasks: 2},
+		},
+	}
+
+	runnerId, err := m.selectRunner([]string{"prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, "r2", runnerId, "least loaded healthy runner")
+
+	runnerId, err = m.selectRunner([]string{"aws"})
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", runnerId, "r4 reached its max tasks")
+
+	_, err = m.selectRunner([]string{"prod", "gcp"})
+	assert.Error(t, err)
+
+	assert.Error(t, m.checkRunnerAvailable("r3"))
+	assert.Error(t, m.checkRunnerAvailable("r4"))
+	assert.NoError(t, m.checkRunnerAvailable("r1"))
+	assert.NoError(t, m.checkRunnerAvailable("unknown"), "runner without heartbeat status")
+	assert.ElementsMatch(t, []string{"r3", "r4"}, m.getLimitedRunner())
+}
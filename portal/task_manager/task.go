@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -47,6 +48,11 @@ func StartTaskStep(taskReq runner.RunTaskReq, step models.TaskStep) (
 	taskReq.Step = step.Index
 	taskReq.StepType = step.Type
 	taskReq.StepArgs = step.Args
+	taskReq.StepImage = step.Image
+	taskReq.StepEnv = step.Env
+	taskReq.StepArtifacts = step.Artifacts
+	taskReq.StepCachePaths = step.Cache.Paths
+	taskReq.Timeout = step.GetTimeout(taskReq.Timeout)
 
 	respData, err := utils.HttpService(requestUrl, "POST", header, taskReq,
 		int(consts.RunnerConnectTimeout.Seconds()), int(consts.RunnerConnectTimeout.Seconds())*10)
@@ -88,7 +94,7 @@ func WaitTaskStep(ctx context.Context, sess *db.Session, task *models.Task, step
 	}
 
 	// runner 端己经增加了超时处理，portal 端的超时暂时保留，但时间设置为给定时间的 2 倍
-	taskDeadline := time.Time(*step.StartAt).Add(time.Duration(task.StepTimeout*2) * time.Second)
+	taskDeadline := time.Time(*step.StartAt).Add(time.Duration(step.GetTimeout(task.StepTimeout)*2) * time.Second)
 
 	// 当前版本实现中需要 portal 主动连接到 runner 获取状态
 	err = utils.RetryFunc(10, time.Second*10, func(retryN int) (retry bool, er error) {
@@ -118,36 +124,29 @@ func WaitTaskStep(ctx context.Context, sess *db.Session, task *models.Task, step
 			logger.Infof("task log content: %s", content)
 		}
 	}
-	if len(stepResult.Result.TfStateJson) > 0 {
-		path := task.StateJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfStateJson); err != nil {
-			logger.WithField("path", path).Errorf("write task state json error: %v", err)
-		}
+	artifacts := []taskArtifact{
+		{Name: runner.TFStateJsonFile, Path: task.StateJsonPath()},
+		{Name: runner.TFStateFile, Path: task.TfStatePath()},
+		{Name: runner.TFProviderSchema, Path: task.ProviderSchemaJsonPath(), Convert: runner.BuildProviderSensitiveAttrMap},
+		{Name: runner.TFPlanJsonFile, Path: task.PlanJsonPath()},
+		{Name: runner.TerrascanJsonFile, Path: task.TfParseJsonPath()},
+		{Name: runner.TerrascanResultFile, Path: task.TfResultJsonPath()},
 	}
-	if len(stepResult.Result.TFProviderSchemaJson) > 0 {
-		path := task.ProviderSchemaJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TFProviderSchemaJson); err != nil {
-			logger.WithField("path", path).Errorf("write task provider json error: %v", err)
+	// 步骤声明的 artifacts、cache 只在步骤执行成功时由 runner 打包
+	if stepResult.Status == models.TaskStepComplete {
+		stepDir := runner.GetTaskDirName(step.Index)
+		if len(step.Artifacts) > 0 {
+			artifacts = append(artifacts, taskArtifact{
+				Name: path.Join(stepDir, runner.StepArtifactsFile), Path: step.ArtifactsPath(),
+				MaxSize: runner.MaxStepArtifactsSize})
 		}
-	}
-	if len(stepResult.Result.TfPlanJson) > 0 {
-		path := task.PlanJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfPlanJson); err != nil {
-			logger.WithField("path", path).Errorf("write task plan json error: %v", err)
-		}
-	}
-	if len(stepResult.Result.TfScanJson) > 0 {
-		path := task.TfParseJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfScanJson); err != nil {
-			logger.WithField("path", path).Errorf("write task parse json error: %v", err)
-		}
-	}
-	if len(stepResult.Result.TfResultJson) > 0 {
-		path := task.TfResultJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfResultJson); err != nil {
-			logger.WithField("path", path).Errorf("write task scan result json error: %v", err)
+		if step.Cache.Key != "" {
+			artifacts = append(artifacts, taskArtifact{
+				Name: path.Join(stepDir, runner.StepCacheFile), Path: step.CachePath(),
+				MaxSize: runner.MaxStepCacheSize})
 		}
 	}
+	saveTaskArtifacts(task, step, artifacts)
 
 	message := ""
 	switch stepResult.Status {
@@ -292,6 +291,7 @@ func pullTaskStepStatus(ctx context.Context, task models.Tasker, step *models.Ta
 
 var (
 	ErrTaskStepRejected = fmt.Errorf("rejected")
+	ErrTaskStepAborted  = fmt.Errorf("aborted")
 )
 
 // WaitTaskStepApprove
@@ -314,9 +314,26 @@ func WaitTaskStepApprove(ctx context.Context, dbSess *db.Session, taskId models.
 
 			if taskStep.Status == models.TaskStepRejected {
 				return nil, ErrTaskStepRejected
+			} else if taskStep.IsAborted() {
+				return nil, ErrTaskStepAborted
 			} else if taskStep.IsApproved() {
 				return taskStep, nil
 			}
+
+			// 审批超时自动驳回
+			if taskStep.ApprovalExpireAt != nil && time.Now().After(time.Time(*taskStep.ApprovalExpireAt)) {
+				task, er := services.GetTask(dbSess, taskId)
+				if er != nil {
+					return nil, er
+				}
+				if er = services.ExpireTaskStepApproval(dbSess, task, taskStep); er != nil {
+					return nil, er
+				}
+				if taskStep.IsAborted() {
+					return nil, ErrTaskStepAborted
+				}
+				return nil, ErrTaskStepRejected
+			}
 		}
 	}
 }
@@ -364,18 +381,10 @@ func WaitScanTaskStep(ctx context.Context, sess *db.Session, task *models.ScanTa
 			logger.Infof("task log content: %s", content)
 		}
 	}
-	if len(stepResult.Result.TfScanJson) > 0 {
-		path := task.TfParseJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfScanJson); err != nil {
-			logger.WithField("path", path).Errorf("write task parse json error: %v", err)
-		}
-	}
-	if len(stepResult.Result.TfResultJson) > 0 {
-		path := task.TfResultJsonPath()
-		if err := logstorage.Get().Write(path, stepResult.Result.TfResultJson); err != nil {
-			logger.WithField("path", path).Errorf("write task scan result json error: %v", err)
-		}
-	}
+	saveTaskArtifacts(task, step, []taskArtifact{
+		{Name: runner.TerrascanJsonFile, Path: task.TfParseJsonPath()},
+		{Name: runner.TerrascanResultFile, Path: task.TfResultJsonPath()},
+	})
 	// 合规任务暂时不需要发送消息
 	//if stepResult.Status != models.TaskRunning && task.Extra.Source == consts.WorkFlow {
 	//	k := kafka.Get()
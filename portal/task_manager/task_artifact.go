@@ -0,0 +1,139 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloudiac/portal/consts"
+	"cloudiac/portal/models"
+	"cloudiac/portal/services"
+	"cloudiac/portal/services/logstorage"
+	"cloudiac/runner"
+	"cloudiac/utils"
+	"cloudiac/utils/logs"
+)
+
+// 下载 artifact 的超时时间，artifact 可能较大(如 provider schema)，所以设置得比普通请求长
+const runnerArtifactTimeout = time.Minute * 5
+
+// taskArtifact 需要从 runner 获取并保存的任务文件
+type taskArtifact struct {
+	Name    string                       // runner 端的 artifact 名称
+	Path    string                       // 保存到 logstorage 的路径
+	Convert func([]byte) ([]byte, error) // 保存前对内容进行转换
+	MaxSize int64                        // 文件大小限制，超过时不保存，0 表示不限制
+}
+
+func listTaskArtifacts(runnerAddr string, envId models.Id, taskId models.Id) (map[string]runner.TaskArtifact, error) {
+	params := url.Values{}
+	params.Add("envId", envId.String())
+	params.Add("taskId", taskId.String())
+	requestUrl := utils.JoinURL(runnerAddr, consts.RunnerTaskArtifactsURL) + "?" + params.Encode()
+
+	respData, err := utils.HttpService(requestUrl, http.MethodGet, nil, nil,
+		int(consts.RunnerConnectTimeout.Seconds()), int(consts.RunnerConnectTimeout.Seconds())*10)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Error  string                `json:"error"`
+		Result []runner.TaskArtifact `json:"result"`
+	}{}
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		return nil, fmt.Errorf("unexpected response: %s", respData)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf(resp.Error)
+	}
+
+	artifacts := make(map[string]runner.TaskArtifact)
+	for _, a := range resp.Result {
+		artifacts[a.Name] = a
+	}
+	return artifacts, nil
+}
+
+// fetchTaskArtifact 下载 artifact 内容并校验 checksum
+func fetchTaskArtifact(runnerAddr string, envId models.Id, taskId models.Id, artifact runner.TaskArtifact) ([]byte, error) {
+	params := url.Values{}
+	params.Add("envId", envId.String())
+	params.Add("taskId", taskId.String())
+	params.Add("name", artifact.Name)
+	requestUrl := utils.JoinURL(runnerAddr, consts.RunnerTaskArtifactURL) + "?" + params.Encode()
+
+	client := http.Client{Timeout: runnerArtifactTimeout}
+	resp, err := client.Get(requestUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 内容大小不超过 list 接口返回的大小，避免读取过大的内容
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, artifact.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, content)
+	}
+
+	sum := sha256.Sum256(content)
+	if checksum := hex.EncodeToString(sum[:]); checksum != resp.Header.Get("X-Checksum-Sha256") {
+		return nil, fmt.Errorf("artifact '%s' checksum mismatch", artifact.Name)
+	}
+	return content, nil
+}
+
+// saveTaskArtifacts 从 runner 获取任务产生的文件并保存到 logstorage，
+// 任务未产生的文件会被忽略，获取或保存失败只记录日志，不影响任务状态
+func saveTaskArtifacts(task models.Tasker, step *models.TaskStep, artifacts []taskArtifact) {
+	logger := logs.Get().WithField("action", "SaveTaskArtifacts").WithField("taskId", task.GetId())
+
+	runnerAddr, err := services.GetRunnerAddress(task.GetRunnerId())
+	if err != nil {
+		logger.Errorf("get runner address error: %v", err)
+		return
+	}
+
+	exists, err := listTaskArtifacts(runnerAddr, step.EnvId, step.TaskId)
+	if err != nil {
+		logger.Errorf("list task artifacts error: %v", err)
+		return
+	}
+
+	for _, a := range artifacts {
+		artifact, ok := exists[a.Name]
+		if !ok {
+			continue
+		}
+		if a.MaxSize > 0 && artifact.Size > a.MaxSize {
+			logger.WithField("name", a.Name).Warnf("task artifact too large (%d bytes), ignored", artifact.Size)
+			continue
+		}
+
+		content, err := fetchTaskArtifact(runnerAddr, step.EnvId, step.TaskId, artifact)
+		if err != nil {
+			logger.WithField("name", a.Name).Errorf("fetch task artifact error: %v", err)
+			continue
+		}
+		if a.Convert != nil {
+			if content, err = a.Convert(content); err != nil {
+				logger.WithField("name", a.Name).Errorf("convert task artifact error: %v", err)
+				continue
+			}
+		}
+		if err := logstorage.Get().Write(a.Path, content); err != nil {
+			logger.WithField("path", a.Path).Errorf("write task artifact error: %v", err)
+		}
+	}
+}
@@ -1,14 +1,10 @@
 package task_manager
 
 import (
-	"cloudiac/portal/consts"
 	"cloudiac/portal/libs/db"
 	"cloudiac/portal/models"
 	"cloudiac/portal/services"
-	"cloudiac/runner"
-	"cloudiac/utils"
 	"cloudiac/utils/logs"
-	"net/http"
 )
 
 func StopTaskContainers(sess *db.Session, taskId models.Id) error {
@@ -42,21 +38,5 @@ func stopTaskContainers(sess *db.Session, taskId models.Id, isScanTask bool) err
 		containerId = task.ContainerId
 	}
 
-	runnerAddr, err := services.GetRunnerAddress(runnerId)
-	if err != nil {
-		return err
-	}
-
-	requestUrl := utils.JoinURL(runnerAddr, consts.RunnerStopTaskURL)
-	req := runner.TaskStopReq{
-		TaskId:       taskId.String(),
-		ContainerIds: []string{},
-	}
-	req.ContainerIds = append(req.ContainerIds, containerId)
-
-	header := &http.Header{}
-	header.Set("Content-Type", "application/json")
-	timeout := int(consts.RunnerConnectTimeout.Seconds())
-	_, err = utils.HttpService(requestUrl, "POST", header, req, timeout, timeout)
-	return err
+	return services.StopRunnerTaskContainers(runnerId, taskId, containerId)
 }
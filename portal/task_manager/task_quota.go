@@ -0,0 +1,70 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"cloudiac/portal/models"
+	"fmt"
+)
+
+// taskQuotas 组织、项目的并发任务数量限制，未设置限制的组织、项目不会出现在 map 中
+type taskQuotas struct {
+	org     map[models.Id]int
+	project map[models.Id]int
+}
+
+func (m *TaskManager) getTaskQuotas() (*taskQuotas, error) {
+	quotas := &taskQuotas{
+		org:     make(map[models.Id]int),
+		project: make(map[models.Id]int),
+	}
+
+	orgs := make([]*models.Organization, 0)
+	if err := m.db.Model(&models.Organization{}).Select("id, max_concurrent_tasks").
+		Where("max_concurrent_tasks > 0").Find(&orgs); err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		quotas.org[o.Id] = o.MaxConcurrentTasks
+	}
+
+	projects := make([]*models.Project, 0)
+	if err := m.db.Model(&models.Project{}).Select("id, max_concurrent_tasks").
+		Where("max_concurrent_tasks > 0").Find(&projects); err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		quotas.project[p.Id] = p.MaxConcurrentTasks
+	}
+	return quotas, nil
+}
+
+// checkTaskQuota 检查任务所在组织、项目的并发任务数是否己达上限
+func (m *TaskManager) checkTaskQuota(task *models.Task, quotas *taskQuotas) error {
+	if quotas == nil {
+		return nil
+	}
+
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+
+	if max, ok := quotas.org[task.OrgId]; ok && m.orgTaskNum[task.OrgId] >= max {
+		return fmt.Errorf("org %s: concurrent limit %d", task.OrgId, max)
+	}
+	if max, ok := quotas.project[task.ProjectId]; ok && m.projectTaskNum[task.ProjectId] >= max {
+		return fmt.Errorf("project %s: concurrent limit %d", task.ProjectId, max)
+	}
+	return nil
+}
+
+// updateTaskNum 更新 runner、组织、项目正在执行的任务数量
+func (m *TaskManager) updateTaskNum(task models.Tasker, delta int) {
+	m.taskNumLock.Lock()
+	defer m.taskNumLock.Unlock()
+
+	m.runnerTaskNum[task.GetRunnerId()] += delta
+	if t, ok := task.(*models.Task); ok {
+		m.orgTaskNum[t.OrgId] += delta
+		m.projectTaskNum[t.ProjectId] += delta
+	}
+}
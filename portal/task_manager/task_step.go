@@ -5,6 +5,8 @@ import (
 	"cloudiac/portal/models"
 	"cloudiac/portal/services"
 	"context"
+	"fmt"
+	"path"
 
 	"github.com/pkg/errors"
 )
@@ -28,6 +30,12 @@ func (m *TaskManager) runTaskStepsDoneActions(ctx context.Context, taskId models
 		return errors.Wrapf(err, "get task current step")
 	}
 
+	// 任务被中止时不再执行 callback 和信息采集步骤
+	if currStep.IsAborted() {
+		logger.Infof("task aborted, skip done actions")
+		return nil
+	}
+
 	// 执行 callback 步骤
 	func() {
 		taskLastStep, err := services.GetTaskLastStep(m.db, task.Id)
@@ -69,7 +77,7 @@ func (m *TaskManager) runTaskStepsDoneActions(ctx context.Context, taskId models
 		}
 	}()
 
-	if task.IsEffectTask() && !currStep.IsRejected() {
+	if task.IsCollectTask() && !currStep.IsRejected() {
 		// 执行信息采集步骤
 		logger.Infof("run task collect step")
 		if err := m.runTaskStep(ctx, *runTaskReq, task, &models.TaskStep{
@@ -89,3 +97,53 @@ func (m *TaskManager) runTaskStepsDoneActions(ctx context.Context, taskId models
 
 	return nil
 }
+
+// checkStepWhen 检查步骤是否满足 when 条件，不满足时返回跳过的原因。
+// failed 表示之前是否有步骤执行失败，hasChanges 用于按需读取 plan 结果判断是否有资源变更
+func checkStepWhen(when models.PipelineStepWhen, failed bool, revision string, hasChanges func() bool) (bool, string) {
+	switch when.Status {
+	case models.PipelineWhenAlways:
+	case models.PipelineWhenFailure:
+		if !failed {
+			return false, "previous steps succeeded"
+		}
+	default:
+		if failed {
+			return false, "previous step failed"
+		}
+	}
+
+	if len(when.Branch) > 0 {
+		matched := false
+		for _, pattern := range when.Branch {
+			if ok, _ := path.Match(pattern, revision); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, fmt.Sprintf("revision '%s' not match", revision)
+		}
+	}
+
+	if when.Changes != nil && *when.Changes != hasChanges() {
+		if *when.Changes {
+			return false, "no resource changes"
+		}
+		return false, "resource changed"
+	}
+	return true, ""
+}
+
+// planHasChanges 根据任务的 plan 结果判断是否有资源变更，未生成 plan 结果时认为没有变更
+func planHasChanges(task *models.Task) bool {
+	bs, err := readIfExist(task.PlanJsonPath())
+	if err != nil || len(bs) == 0 {
+		return false
+	}
+	plan, err := services.UnmarshalPlanJson(bs)
+	if err != nil {
+		return false
+	}
+	return plan.HasChanges()
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package task_manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"cloudiac/portal/models"
+)
+
+func TestCheckStepWhen(t *testing.T) {
+	changed, unchanged := true, false
+	hasChanges := func() bool { return true }
+
+	cases := []struct {
+		when   models.PipelineStepWhen
+		failed bool
+		run    bool
+	}{
+		{models.PipelineStepWhen{}, false, true},
+		{models.PipelineStepWhen{}, true, false},
+		{models.PipelineStepWhen{Status: models.PipelineWhenFailure}, false, false},
+		{models.PipelineStepWhen{Status: models.PipelineWhenFailure}, true, true},
+		{models.PipelineStepWhen{Status: models.PipelineWhenAlways}, true, true},
+		{models.PipelineStepWhen{Branch: []string{"release/*"}}, false, false},
+		{models.PipelineStepWhen{Branch: []string{"dev", "mas*"}}, false, true},
+		{models.PipelineStepWhen{Changes: &changed}, false, true},
+		{models.PipelineStepWhen{Changes: &unchanged}, false, false},
+	}
+	for i, c := range cases {
+		run, reason := checkStepWhen(c.when, c.failed, "master", hasChanges)
+		assert.Equal(t, c.run, run, "case %d", i)
+		assert.Equal(t, c.run, reason == "", "case %d", i)
+	}
+}
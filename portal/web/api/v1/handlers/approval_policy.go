@@ -0,0 +1,115 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handlers
+
+import (
+	"cloudiac/portal/apps"
+	"cloudiac/portal/libs/ctrl"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/models/forms"
+)
+
+type ApprovalPolicy struct {
+	ctrl.GinController
+}
+
+// Search 查询审批策略
+// @Summary 查询审批策略
+// @Description 查询项目下的任务审批策略
+// @Tags 审批策略
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param form query forms.SearchApprovalPolicyForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=page.PageResp{list=[]models.ApprovalPolicy}}
+// @Router /approval_policies [get]
+func (ApprovalPolicy) Search(c *ctx.GinRequest) {
+	form := &forms.SearchApprovalPolicyForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.SearchApprovalPolicy(c.Service(), form))
+}
+
+// Create 创建审批策略
+// @Summary 创建审批策略
+// @Description 创建项目或环境的任务审批策略，每个项目或环境只能有一个审批策略
+// @Tags 审批策略
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param json body forms.CreateApprovalPolicyForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.ApprovalPolicy}
+// @Router /approval_policies [post]
+func (ApprovalPolicy) Create(c *ctx.GinRequest) {
+	form := &forms.CreateApprovalPolicyForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.CreateApprovalPolicy(c.Service(), form))
+}
+
+// Update 修改审批策略
+// @Summary 修改审批策略
+// @Description 修改审批策略
+// @Tags 审批策略
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "审批策略ID"
+// @Param json body forms.UpdateApprovalPolicyForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.ApprovalPolicy}
+// @Router /approval_policies/{id} [put]
+func (ApprovalPolicy) Update(c *ctx.GinRequest) {
+	form := &forms.UpdateApprovalPolicyForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.UpdateApprovalPolicy(c.Service(), form))
+}
+
+// Delete 删除审批策略
+// @Summary 删除审批策略
+// @Description 删除审批策略
+// @Tags 审批策略
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "审批策略ID"
+// @Success 200 {object} ctx.JSONResult
+// @Router /approval_policies/{id} [delete]
+func (ApprovalPolicy) Delete(c *ctx.GinRequest) {
+	form := &forms.DeleteApprovalPolicyForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeleteApprovalPolicy(c.Service(), form))
+}
+
+// Detail 审批策略详情
+// @Summary 审批策略详情
+// @Description 审批策略详情
+// @Tags 审批策略
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "审批策略ID"
+// @Success 200 {object} ctx.JSONResult{result=models.ApprovalPolicy}
+// @Router /approval_policies/{id} [get]
+func (ApprovalPolicy) Detail(c *ctx.GinRequest) {
+	form := &forms.DetailApprovalPolicyForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DetailApprovalPolicy(c.Service(), form))
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handlers
+
+import (
+	"cloudiac/portal/apps"
+	"cloudiac/portal/libs/ctrl"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/models/forms"
+	"fmt"
+	"time"
+)
+
+type AuditLog struct {
+	ctrl.GinController
+}
+
+// Search 查询审计日志
+// @Summary 查询审计日志
+// @Description 查询用户的修改操作记录，平台管理员可以查询所有组织的日志，组织管理员只能查询当前组织的日志。
+// @Description 传入 format 参数(csv 或 jsonl)时导出符合条件的日志文件
+// @Tags 审计日志
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string false "组织ID"
+// @Param form query forms.SearchAuditLogForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=page.PageResp{list=[]models.OperationLog}}
+// @Router /audit/logs [get]
+func (AuditLog) Search(c *ctx.GinRequest) {
+	form := &forms.SearchAuditLogForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	if form.Format == "" {
+		c.JSONResult(apps.SearchAuditLog(c.Service(), form))
+		return
+	}
+
+	data, err := apps.ExportAuditLog(c.Service(), form)
+	if err != nil {
+		c.JSONError(err)
+		return
+	}
+	contentType := "text/csv"
+	if form.Format == apps.AuditLogFormatJsonl {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().Format("20060102150405"), form.Format)
+	c.FileDownloadResponse(data, filename, contentType)
+}
@@ -3,10 +3,18 @@
 package handlers
 
 import (
+	"cloudiac/configs"
 	"cloudiac/portal/apps"
 	"cloudiac/portal/libs/ctrl"
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models/forms"
+	"net/http"
+	"strings"
+)
+
+const (
+	oidcCookieName = "cloudiac_oidc"
+	oidcCookiePath = "/api/v1/auth/oidc"
 )
 
 type Auth struct {
@@ -73,3 +81,44 @@ func (a Auth) Login(c *ctx.GinRequest) {
 	}
 	c.JSONResult(apps.Login(c.Service(), &form))
 }
+
+// OidcLogin oidc 单点登录
+// @Tags 鉴权
+// @Summary oidc 单点登录，跳转到 oidc 服务的登录页面
+// @router /auth/oidc/login [get]
+// @Success 302
+func (a Auth) OidcLogin(c *ctx.GinRequest) {
+	authUrl, authReq, err := apps.OidcLogin(c.Service())
+	if err != nil {
+		c.JSONError(err)
+		return
+	}
+	secure := strings.HasPrefix(configs.Get().Portal.Address, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookieName, authReq, int(apps.OidcAuthRequestExpire.Seconds()), oidcCookiePath, "", secure, true)
+	c.Redirect(http.StatusFound, authUrl)
+}
+
+// OidcCallback oidc 登录回调
+// @Tags 鉴权
+// @Summary oidc 登录回调，登录成功后跳转到前端页面，token 通过 url fragment 传递
+// @Param code query string false "authorization code"
+// @Param state query string false "state"
+// @router /auth/oidc/callback [get]
+// @Success 302
+func (a Auth) OidcCallback(c *ctx.GinRequest) {
+	form := forms.OidcCallbackForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	authReq, _ := c.Cookie(oidcCookieName)
+	// 登录请求参数只能使用一次
+	c.SetCookie(oidcCookieName, "", -1, oidcCookiePath, "", false, true)
+
+	redirectUrl, err := apps.OidcCallback(c.Service(), &form, authReq)
+	if err != nil {
+		c.JSONError(err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirectUrl)
+}
@@ -151,6 +151,115 @@ func (Env) Destroy(c *ctx.GinRequest) {
 	c.JSONResult(apps.EnvDeploy(c.Service(), &form))
 }
 
+// State 环境 state 管理
+// @Tags 环境
+// @Summary 环境 state 管理(state list/show/mv/rm、import)
+// @Description 创建 state 管理任务，需要环境的部署权限，操作输出通过任务步骤日志查看。mv、rm、import 操作需要审批，执行后会重新统计环境资源。
+// @Description 只读的 list、show 操作也可以通过 GET 请求执行，只需要环境的读权限
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param data body forms.EnvStateForm true "state 操作参数"
+// @router /envs/{envId}/state [post]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Env) State(c *ctx.GinRequest) {
+	form := forms.EnvStateForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.EnvState(c.Service(), &form))
+}
+
+// QueryState 环境 state 查询
+// @Tags 环境
+// @Summary 环境 state 查询(state list/show)
+// @Description 创建只读的 state 查询任务，只需要环境的读权限，查询结果通过任务步骤日志查看
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param form query forms.EnvStateForm true "state 操作参数，action 为 list 或 show"
+// @router /envs/{envId}/state [get]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Env) QueryState(c *ctx.GinRequest) {
+	form := forms.EnvStateForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.EnvStateQuery(c.Service(), &form))
+}
+
+// SearchStateVersions 环境 state 版本列表
+// @Tags 环境
+// @Summary 环境 state 版本列表
+// @Description 部署、销毁及修改 state 的任务执行后会保存 state 版本，state 未变化时不生成新版本
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param form query forms.SearchEnvStateVersionForm true "parameter"
+// @router /envs/{envId}/states [get]
+// @Success 200 {object} ctx.JSONResult{result=page.PageResp{list=[]models.EnvStateVersionDetail}}
+func (Env) SearchStateVersions(c *ctx.GinRequest) {
+	form := forms.SearchEnvStateVersionForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.SearchEnvStateVersion(c.Service(), &form))
+}
+
+// DownloadStateVersion 下载环境 state 版本
+// @Tags 环境
+// @Summary 下载环境 state 版本
+// @Produce application/octet-stream
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param versionId path string true "state 版本ID"
+// @router /envs/{envId}/states/{versionId}/download [get]
+// @Success 200 {file} file "state 文件"
+func (Env) DownloadStateVersion(c *ctx.GinRequest) {
+	form := forms.EnvStateVersionForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	content, err := apps.EnvStateVersionContent(c.Service(), &form)
+	if err != nil {
+		c.JSONError(err)
+		return
+	}
+	c.FileDownloadResponse(content, form.VersionId.String()+".tfstate", "application/json")
+}
+
+// RestoreStateVersion 恢复环境 state 到指定版本
+// @Tags 环境
+// @Summary 恢复环境 state 到指定版本
+// @Description 创建 state push 任务将指定版本写回 state 存储，任务需要审批，只允许恢复与当前 state lineage 相同的版本
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param versionId path string true "state 版本ID"
+// @router /envs/{envId}/states/{versionId}/restore [post]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Env) RestoreStateVersion(c *ctx.GinRequest) {
+	form := forms.EnvStateVersionForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.RestoreEnvStateVersion(c.Service(), &form))
+}
+
 // SearchResources 获取环境资源列表
 // @Tags 环境
 // @Summary 获取环境资源列表
@@ -332,3 +441,121 @@ func (Env) ResourceGraphDetail(c *ctx.GinRequest) {
 	}
 	c.JSONResult(apps.ResourceGraphDetail(c.Service(), form))
 }
+
+// SearchDependencies 查询环境依赖的上游环境
+// @Tags 环境
+// @Summary 查询环境依赖
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @router /envs/{envId}/dependencies [get]
+// @Success 200 {object} ctx.JSONResult{result=[]models.EnvDependency}
+func (Env) SearchDependencies(c *ctx.GinRequest) {
+	form := forms.SearchEnvDependencyForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.SearchEnvDependency(c.Service(), &form))
+}
+
+// CreateDependency 添加环境依赖
+// @Tags 环境
+// @Summary 添加环境依赖
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param form body forms.CreateEnvDependencyForm true "parameter"
+// @router /envs/{envId}/dependencies [post]
+// @Success 200 {object} ctx.JSONResult{result=models.EnvDependency}
+func (Env) CreateDependency(c *ctx.GinRequest) {
+	form := forms.CreateEnvDependencyForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.CreateEnvDependency(c.Service(), &form))
+}
+
+// UpdateDependency 修改环境依赖的 output 映射
+// @Tags 环境
+// @Summary 修改环境依赖
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param dependencyId path string true "环境依赖ID"
+// @Param form body forms.UpdateEnvDependencyForm true "parameter"
+// @router /envs/{envId}/dependencies/{dependencyId} [put]
+// @Success 200 {object} ctx.JSONResult{result=models.EnvDependency}
+func (Env) UpdateDependency(c *ctx.GinRequest) {
+	form := forms.UpdateEnvDependencyForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.UpdateEnvDependency(c.Service(), &form))
+}
+
+// DeleteDependency 删除环境依赖
+// @Tags 环境
+// @Summary 删除环境依赖
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @Param dependencyId path string true "环境依赖ID"
+// @router /envs/{envId}/dependencies/{dependencyId} [delete]
+// @Success 200 {object} ctx.JSONResult
+func (Env) DeleteDependency(c *ctx.GinRequest) {
+	form := forms.DeleteEnvDependencyForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeleteEnvDependency(c.Service(), &form))
+}
+
+// DeployStack 按依赖顺序部署环境及其所有下游环境
+// @Tags 环境
+// @Summary 部署环境栈
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param envId path string true "环境ID"
+// @router /envs/{envId}/deploy_stack [post]
+// @Success 200 {object} ctx.JSONResult{result=models.DeployStack}
+func (Env) DeployStack(c *ctx.GinRequest) {
+	form := forms.DeployStackForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeployStack(c.Service(), &form))
+}
+
+// DeployStackDetail 环境栈部署详情
+// @Tags 环境
+// @Summary 环境栈部署详情
+// @Accept json
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param stackId path string true "环境栈部署ID"
+// @router /deploy_stacks/{stackId} [get]
+// @Success 200 {object} ctx.JSONResult{result=models.DeployStack}
+func (Env) DeployStackDetail(c *ctx.GinRequest) {
+	form := forms.DetailDeployStackForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeployStackDetail(c.Service(), &form))
+}
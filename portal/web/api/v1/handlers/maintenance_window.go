@@ -0,0 +1,115 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handlers
+
+import (
+	"cloudiac/portal/apps"
+	"cloudiac/portal/libs/ctrl"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/models/forms"
+)
+
+type MaintenanceWindow struct {
+	ctrl.GinController
+}
+
+// Search 查询维护窗口
+// @Summary 查询维护窗口
+// @Description 查询项目的维护窗口(冻结期)
+// @Tags 维护窗口
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param form query forms.SearchMaintenanceWindowForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=page.PageResp{list=[]models.MaintenanceWindow}}
+// @Router /maintenance_windows [get]
+func (MaintenanceWindow) Search(c *ctx.GinRequest) {
+	form := &forms.SearchMaintenanceWindowForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.SearchMaintenanceWindow(c.Service(), form))
+}
+
+// Create 创建维护窗口
+// @Summary 创建维护窗口
+// @Description 创建项目维护窗口，窗口期间项目下的 apply、destroy 任务会暂停执行
+// @Tags 维护窗口
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param json body forms.CreateMaintenanceWindowForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.MaintenanceWindow}
+// @Router /maintenance_windows [post]
+func (MaintenanceWindow) Create(c *ctx.GinRequest) {
+	form := &forms.CreateMaintenanceWindowForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.CreateMaintenanceWindow(c.Service(), form))
+}
+
+// Update 修改维护窗口
+// @Summary 修改维护窗口
+// @Description 修改维护窗口
+// @Tags 维护窗口
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "维护窗口ID"
+// @Param json body forms.UpdateMaintenanceWindowForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.MaintenanceWindow}
+// @Router /maintenance_windows/{id} [put]
+func (MaintenanceWindow) Update(c *ctx.GinRequest) {
+	form := &forms.UpdateMaintenanceWindowForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.UpdateMaintenanceWindow(c.Service(), form))
+}
+
+// Delete 删除维护窗口
+// @Summary 删除维护窗口
+// @Description 删除维护窗口
+// @Tags 维护窗口
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "维护窗口ID"
+// @Success 200 {object} ctx.JSONResult
+// @Router /maintenance_windows/{id} [delete]
+func (MaintenanceWindow) Delete(c *ctx.GinRequest) {
+	form := &forms.DeleteMaintenanceWindowForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeleteMaintenanceWindow(c.Service(), form))
+}
+
+// Detail 维护窗口详情
+// @Summary 维护窗口详情
+// @Description 维护窗口详情
+// @Tags 维护窗口
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "维护窗口ID"
+// @Success 200 {object} ctx.JSONResult{result=models.MaintenanceWindow}
+// @Router /maintenance_windows/{id} [get]
+func (MaintenanceWindow) Detail(c *ctx.GinRequest) {
+	form := &forms.DetailMaintenanceWindowForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DetailMaintenanceWindow(c.Service(), form))
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handlers
+
+import (
+	"cloudiac/portal/apps"
+	"cloudiac/portal/libs/ctrl"
+	"cloudiac/portal/libs/ctx"
+	"cloudiac/portal/models/forms"
+)
+
+type Role struct {
+	ctrl.GinController
+}
+
+// Search 查询自定义角色
+// @Summary 查询自定义角色
+// @Description 查询组织的自定义角色
+// @Tags 自定义角色
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param form query forms.SearchRoleForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=page.PageResp{list=[]models.Role}}
+// @Router /roles [get]
+func (Role) Search(c *ctx.GinRequest) {
+	form := &forms.SearchRoleForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.SearchRole(c.Service(), form))
+}
+
+// Create 创建自定义角色
+// @Summary 创建自定义角色
+// @Description 创建组织自定义角色，org 角色可以分配给组织成员及 api token，project 角色可以分配给项目成员
+// @Tags 自定义角色
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param json body forms.CreateRoleForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.Role}
+// @Router /roles [post]
+func (Role) Create(c *ctx.GinRequest) {
+	form := &forms.CreateRoleForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.CreateRole(c.Service(), form))
+}
+
+// Update 修改自定义角色
+// @Summary 修改自定义角色
+// @Description 修改自定义角色
+// @Tags 自定义角色
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param id path string true "角色ID"
+// @Param json body forms.UpdateRoleForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.Role}
+// @Router /roles/{id} [put]
+func (Role) Update(c *ctx.GinRequest) {
+	form := &forms.UpdateRoleForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.UpdateRole(c.Service(), form))
+}
+
+// Delete 删除自定义角色
+// @Summary 删除自定义角色
+// @Description 删除自定义角色，已分配给用户或 token 的角色不能删除
+// @Tags 自定义角色
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param id path string true "角色ID"
+// @Success 200 {object} ctx.JSONResult
+// @Router /roles/{id} [delete]
+func (Role) Delete(c *ctx.GinRequest) {
+	form := &forms.DeleteRoleForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeleteRole(c.Service(), form))
+}
+
+// Detail 自定义角色详情
+// @Summary 自定义角色详情
+// @Description 自定义角色详情
+// @Tags 自定义角色
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param id path string true "角色ID"
+// @Success 200 {object} ctx.JSONResult{result=models.Role}
+// @Router /roles/{id} [get]
+func (Role) Detail(c *ctx.GinRequest) {
+	form := &forms.DetailRoleForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DetailRole(c.Service(), form))
+}
@@ -33,7 +33,7 @@ func ConsulKVSearch(c *ctx.GinRequest) {
 // @Accept  json
 // @Produce  json
 // @Security AuthToken
-// @Success 200
+// @Success 200 {object} ctx.JSONResult{result=[]apps.RunnerResp}
 // @Router /runners [get]
 func RunnerSearch(c *ctx.GinRequest) {
 	c.JSONResult(apps.RunnerSearch())
@@ -56,3 +56,39 @@ func ConsulTagUpdate(c *ctx.GinRequest) {
 	}
 	c.JSONResult(apps.ConsulTagUpdate(form))
 }
+
+// RegisterRunner runner 注册
+// @Summary runner 注册
+// @Description registry 配置为 db 时 runner 通过该接口注册，使用 registry token 认证
+// @Tags runner
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "registry token"
+// @Param data body forms.RegisterRunnerForm true "runner 信息"
+// @Success 200
+// @Router /runners/register [post]
+func RegisterRunner(c *ctx.GinRequest) {
+	form := forms.RegisterRunnerForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.RegisterRunner(c.Service(), &form))
+}
+
+// DeregisterRunner runner 注销
+// @Summary runner 注销
+// @Description registry 配置为 db 时 runner 退出前通过该接口注销，使用 registry token 认证
+// @Tags runner
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "registry token"
+// @Param id path string true "runner ID"
+// @Success 200
+// @Router /runners/register/{id} [delete]
+func DeregisterRunner(c *ctx.GinRequest) {
+	form := forms.DeregisterRunnerForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.DeregisterRunner(c.Service(), &form))
+}
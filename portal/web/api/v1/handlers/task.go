@@ -7,6 +7,7 @@ import (
 	"cloudiac/portal/libs/ctrl"
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models/forms"
+	"fmt"
 )
 
 type Task struct {
@@ -120,6 +121,67 @@ func (Task) TaskApprove(c *ctx.GinRequest) {
 	c.JSONResult(apps.ApproveTask(c.Service(), form))
 }
 
+// TaskAbort 中止任务
+// @Tags 环境
+// @Summary 中止任务
+// @Description 排队中的任务直接中止，执行中的任务会停止任务容器
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param taskId path string true "任务ID"
+// @router /tasks/{taskId}/abort [post]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Task) TaskAbort(c *ctx.GinRequest) {
+	form := &forms.AbortTaskForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.AbortTask(c.Service(), form))
+}
+
+// TaskResume 从指定步骤恢复执行任务
+// @Tags 环境
+// @Summary 从指定步骤恢复执行任务
+// @Description 失败的任务可以从失败的步骤(或之前的步骤)恢复执行，任务会在原 runner 上复用之前的工作目录及 plan 文件
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param taskId path string true "任务ID"
+// @Param form formData forms.ResumeTaskForm true "parameter"
+// @router /tasks/{taskId}/resume [post]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Task) TaskResume(c *ctx.GinRequest) {
+	form := &forms.ResumeTaskForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.ResumeTask(c.Service(), form))
+}
+
+// TaskFreezeOverride 强制执行维护窗口期间的任务
+// @Tags 环境
+// @Summary 强制执行维护窗口期间的任务
+// @Description 项目处于维护窗口期间 apply、destroy 任务会保持排队状态，组织管理员可以强制执行
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param taskId path string true "任务ID"
+// @router /tasks/{taskId}/freeze_override [post]
+// @Success 200 {object} ctx.JSONResult{result=models.Task}
+func (Task) TaskFreezeOverride(c *ctx.GinRequest) {
+	form := &forms.OverrideTaskFreezeForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.OverrideTaskFreeze(c.Service(), form))
+}
+
 // Log 任务日志
 // @Tags 环境
 // @Summary 任务日志
@@ -223,6 +285,31 @@ func (Task) GetTaskStepLog(c *ctx.GinRequest) {
 
 }
 
+// DownloadStepArtifacts 下载步骤保存的 artifacts
+// @Tags 任务管理
+// @Summary 下载步骤保存的 artifacts
+// @Description 下载 pipeline 步骤通过 artifacts 声明并在执行成功后保存的文件(tar.gz 格式)
+// @Produce application/octet-stream
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param IaC-Project-Id header string true "项目ID"
+// @Param id path string true "任务ID"
+// @Param stepId path string true "任务步骤ID"
+// @router /tasks/{id}/steps/{stepId}/artifacts [get]
+// @Success 200 {file} file "artifacts 打包文件"
+func (Task) DownloadStepArtifacts(c *ctx.GinRequest) {
+	form := forms.TaskStepArtifactsForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	step, content, err := apps.TaskStepArtifacts(c.Service(), &form)
+	if err != nil {
+		c.JSONError(err)
+		return
+	}
+	c.FileDownloadResponse(content, fmt.Sprintf("%s-step%d-artifacts.tar.gz", step.TaskId, step.Index), "application/gzip")
+}
+
 // ResourceGraph 获取任务资源列表
 // @Tags 环境
 // @Summary 获取任务资源列表
@@ -222,6 +222,22 @@ func TemplateChecks(c *ctx.GinRequest) {
 	c.JSONResult(apps.TemplateChecks(c.Service(), &form))
 }
 
+// ValidatePipeline
+// @Tags 云模板
+// @Accept application/json
+// @Summary 检查 pipeline 文件内容
+// @Security AuthToken
+// @Param json body forms.PipelineValidateForm true "parameter"
+// @router /pipelines/validate [POST]
+// @Success 200 {object} ctx.JSONResult{result=apps.PipelineValidateResp}
+func ValidatePipeline(c *ctx.GinRequest) {
+	form := forms.PipelineValidateForm{}
+	if err := c.Bind(&form); err != nil {
+		return
+	}
+	c.JSONResult(apps.ValidatePipeline(c.Service(), &form))
+}
+
 // TemplateExport 云模板导出
 // @Tags 云模板
 // @Summary 云模板导出接口
@@ -91,6 +91,26 @@ func (Token) Delete(c *ctx.GinRequest) {
 	c.JSONResult(apps.DeleteToken(c.Service(), form))
 }
 
+// Rotate 轮换 api token
+// @Summary 轮换 api token
+// @Description 生成新的 token 并返回，旧 token 在宽限期内仍然可以使用
+// @Tags Token
+// @Accept  json
+// @Produce  json
+// @Security AuthToken
+// @Param IaC-Org-Id header string true "组织ID"
+// @Param tokenId path string true "TokenID"
+// @Param data body forms.RotateTokenForm true "parameter"
+// @Success 200 {object} ctx.JSONResult{result=models.Token}
+// @Router /tokens/{tokenId}/rotate [post]
+func (Token) Rotate(c *ctx.GinRequest) {
+	form := &forms.RotateTokenForm{}
+	if err := c.Bind(form); err != nil {
+		return
+	}
+	c.JSONResult(apps.RotateToken(c.Service(), form))
+}
+
 // VcsWebhookUrl 触发器token详情
 // @Summary 触发器token详情
 // @Description 触发器token详情
@@ -46,6 +46,10 @@ func Register(g *gin.RouterGroup) {
 	apiToken.POST("/webhooks/:vcsType/:vcsId", w(handlers.WebhooksApiHandler))
 
 	g.POST("/auth/login", w(handlers.Auth{}.Login))
+	g.GET("/auth/oidc/login", w(handlers.Auth{}.OidcLogin))
+	g.GET("/auth/oidc/callback", w(handlers.Auth{}.OidcCallback))
+	g.POST("/runners/register", w(middleware.AuthRegistryToken), w(handlers.RegisterRunner))
+	g.DELETE("/runners/register/:id", w(middleware.AuthRegistryToken), w(handlers.DeregisterRunner))
 
 	// Authorization Header 鉴权
 	g.Use(w(middleware.Auth)) // 解析 header token
@@ -71,6 +75,8 @@ func Register(g *gin.RouterGroup) {
 	g.GET("/systems", ac(), w(handlers.SystemConfig{}.Search))
 	// 系统状态
 	g.GET("/systems/status", w(handlers.PortalSystemStatusSearch))
+	// pipeline 检查
+	g.POST("/pipelines/validate", w(handlers.ValidatePipeline))
 
 	// 策略管理
 	ctrl.Register(g.Group("policies", ac()), &handlers.Policy{})
@@ -118,6 +124,12 @@ func Register(g *gin.RouterGroup) {
 	g.POST("/orgs/:id/users/invite", ac("orgs", "adduser"), w(handlers.Organization{}.InviteUser))
 	g.DELETE("/orgs/:id/users/:userId", ac("orgs", "removeuser"), w(handlers.Organization{}.RemoveUserForOrg))
 
+	// 自定义角色
+	ctrl.Register(g.Group("roles", ac()), &handlers.Role{})
+
+	// 审计日志
+	g.GET("/audit/logs", ac(), w(handlers.AuditLog{}.Search))
+
 	g.GET("/projects/users", ac(), w(handlers.ProjectUser{}.Search))
 	g.GET("/projects/authorization/users", ac(), w(handlers.ProjectUser{}.SearchProjectAuthorizationUser))
 	g.POST("/projects/users", ac(), w(handlers.ProjectUser{}.Create))
@@ -143,6 +155,7 @@ func Register(g *gin.RouterGroup) {
 
 	//token管理
 	ctrl.Register(g.Group("tokens", ac()), &handlers.Token{})
+	g.POST("/tokens/:id/rotate", ac("tokens", "update"), w(handlers.Token{}.Rotate))
 	//密钥管理
 	ctrl.Register(g.Group("keys", ac()), &handlers.Key{})
 
@@ -177,6 +190,17 @@ func Register(g *gin.RouterGroup) {
 	g.GET("/envs/:id/tasks/last", ac(), w(handlers.Env{}.LastTask))
 	g.POST("/envs/:id/deploy", ac("envs", "deploy"), w(handlers.Env{}.Deploy))
 	g.POST("/envs/:id/destroy", ac("envs", "destroy"), w(handlers.Env{}.Destroy))
+	g.GET("/envs/:id/state", ac("envs", "read"), w(handlers.Env{}.QueryState))
+	g.POST("/envs/:id/state", ac("envs", "deploy"), w(handlers.Env{}.State))
+	g.GET("/envs/:id/states", ac(), w(handlers.Env{}.SearchStateVersions))
+	g.GET("/envs/:id/states/:versionId/download", ac("envs", "deploy"), w(handlers.Env{}.DownloadStateVersion))
+	g.POST("/envs/:id/states/:versionId/restore", ac("envs", "restore"), w(handlers.Env{}.RestoreStateVersion))
+	g.GET("/envs/:id/dependencies", ac(), w(handlers.Env{}.SearchDependencies))
+	g.POST("/envs/:id/dependencies", ac("envs", "update"), w(handlers.Env{}.CreateDependency))
+	g.PUT("/envs/:id/dependencies/:dependencyId", ac("envs", "update"), w(handlers.Env{}.UpdateDependency))
+	g.DELETE("/envs/:id/dependencies/:dependencyId", ac("envs", "update"), w(handlers.Env{}.DeleteDependency))
+	g.POST("/envs/:id/deploy_stack", ac("envs", "deploy"), w(handlers.Env{}.DeployStack))
+	g.GET("/deploy_stacks/:id", ac("envs", "read"), w(handlers.Env{}.DeployStackDetail))
 	g.GET("/envs/:id/resources", ac(), w(handlers.Env{}.SearchResources))
 	g.GET("/envs/:id/output", ac(), w(handlers.Env{}.Output))
 	g.GET("/envs/:id/resources/:resourceId", ac(), w(handlers.Env{}.ResourceDetail))
@@ -185,6 +209,11 @@ func Register(g *gin.RouterGroup) {
 	g.GET("/envs/:id/resources/graph", ac(), w(handlers.Env{}.SearchResourcesGraph))
 	g.GET("/envs/:id/resources/graph/:resourceId", ac(), w(handlers.Env{}.ResourceGraphDetail))
 
+	// 审批策略
+	ctrl.Register(g.Group("approval_policies", ac()), &handlers.ApprovalPolicy{})
+	// 维护窗口
+	ctrl.Register(g.Group("maintenance_windows", ac()), &handlers.MaintenanceWindow{})
+
 	// 任务管理
 	g.GET("/tasks", ac(), w(handlers.Task{}.Search))
 	g.GET("/tasks/:id", ac(), w(handlers.Task{}.Detail))
@@ -192,11 +221,15 @@ func Register(g *gin.RouterGroup) {
 	g.GET("/tasks/:id/output", ac(), w(handlers.Task{}.Output))
 	g.GET("/tasks/:id/resources", ac(), w(handlers.Task{}.Resource))
 	g.POST("/tasks/:id/approve", ac("tasks", "approve"), w(handlers.Task{}.TaskApprove))
+	g.POST("/tasks/:id/abort", ac("tasks", "abort"), w(handlers.Task{}.TaskAbort))
+	g.POST("/tasks/:id/resume", ac("tasks", "resume"), w(handlers.Task{}.TaskResume))
+	g.POST("/tasks/:id/freeze_override", ac("tasks", "override"), w(handlers.Task{}.TaskFreezeOverride))
 	g.POST("/tasks/:id/comment", ac(), w(handlers.TaskComment{}.Create))
 	g.GET("/tasks/:id/comment", ac(), w(handlers.TaskComment{}.Search))
 	g.GET("/tasks/:id/steps", ac(), w(handlers.Task{}.SearchTaskStep))
 	g.GET("/tasks/:id/steps/:stepId/log", ac(), w(handlers.Task{}.GetTaskStepLog))
 	g.GET("/tasks/:id/steps/:stepId/log/sse", ac(), w(handlers.Task{}.FollowStepLogSse))
+	g.GET("/tasks/:id/steps/:stepId/artifacts", ac(), w(handlers.Task{}.DownloadStepArtifacts))
 	g.GET("/tasks/:id/resources/graph", ac(), w(handlers.Task{}.ResourceGraph))
 
 	//g.GET("/tokens/trigger", ac(), w(handlers.Token{}.VcsWebhookUrl))
@@ -9,6 +9,7 @@ import (
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models"
 	"cloudiac/portal/services"
+	"crypto/subtle"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"net/http"
@@ -37,18 +38,23 @@ func Auth(c *ctx.GinRequest) {
 			c.Service().Username = consts.DefaultSysName
 			c.Service().IsSuperAdmin = false
 			c.Service().UserIpAddr = c.ClientIP()
+			c.Service().ApiToken = apiToken
 			apiTokenOrgId = apiToken.OrgId
+			if err := services.UpdateTokenLastUsed(c.Service().DB(), apiToken.Id); err != nil {
+				c.Logger().Warnf("update token last used: %v", err)
+			}
 			return nil
 		}
 
-		if claims, ok := token.Claims.(*services.Claims); ok && token.Valid {
-			c.Service().UserId = claims.UserId
-			c.Service().Username = claims.Username
-			c.Service().IsSuperAdmin = claims.IsAdmin
-			c.Service().UserIpAddr = c.ClientIP()
-		} else {
-			c.JSONError(e.New(e.InvalidToken), http.StatusUnauthorized)
+		// 用户 token 没有 audience，其他用途的 jwt(如 oidc 登录请求)不能用于认证
+		claims, ok := token.Claims.(*services.Claims)
+		if !ok || !token.Valid || claims.UserId == "" || claims.Audience != "" {
+			return fmt.Errorf("invalid token")
 		}
+		c.Service().UserId = claims.UserId
+		c.Service().Username = claims.Username
+		c.Service().IsSuperAdmin = claims.IsAdmin
+		c.Service().UserIpAddr = c.ClientIP()
 		return nil
 	}()
 
@@ -119,6 +125,12 @@ func AuthProjectId(c *ctx.GinRequest) {
 		c.JSONError(e.New(e.InvalidProjectId), http.StatusForbidden)
 		return
 	}
+	// 项目及环境范围的 api token 只能访问所属的项目
+	if token := c.Service().ApiToken; token != nil && token.Scope != consts.ScopeOrg &&
+		token.ProjectId != c.Service().ProjectId {
+		c.JSONError(e.New(e.PermissionDeny, fmt.Errorf("token not allowed to access project")), http.StatusForbidden)
+		return
+	}
 	return
 }
 
@@ -129,3 +141,17 @@ func AuthApiToken(c *ctx.GinRequest) {
 		return
 	}
 }
+
+// AuthRegistryToken 验证 runner 注册使用的共享 token，仅 registry 为 db 时可用
+func AuthRegistryToken(c *ctx.GinRequest) {
+	conf := configs.Get().Registry
+	if !conf.IsDB() || conf.Token == "" {
+		c.JSONError(e.New(e.PermissionDeny, fmt.Errorf("runner registry is not enabled")), http.StatusForbidden)
+		return
+	}
+	token := c.GetHeader("Authorization")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(conf.Token)) != 1 {
+		c.JSONError(e.New(e.PermissionDeny, fmt.Errorf("invalid registry token")), http.StatusForbidden)
+		return
+	}
+}
@@ -6,118 +6,92 @@ import (
 	"bytes"
 	"cloudiac/portal/libs/ctx"
 	"cloudiac/portal/models"
+	"cloudiac/portal/services"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"time"
-
-	//"github.com/gin-gonic/gin"
 	"strings"
+	"time"
 )
 
-type HandleTableAndDesc map[string]string
+// 请求方法对应的审计操作类型
+var operationActions = map[string]string{
+	"POST":   models.AuditActionCreate,
+	"PUT":    models.AuditActionUpdate,
+	"PATCH":  models.AuditActionUpdate,
+	"DELETE": models.AuditActionDelete,
+}
 
+// Operation 记录用户的修改操作(审计日志)，在请求处理完成后记录，未登录的请求(如登录接口)不记录
 func Operation(c *ctx.GinRequest) {
-	if c.Request.Method == "" {
-		c.Next() // 注意 next()方法的作用是跳过该调用链去直接后面的中间件以及api路由
-	}
-
-	opMethod := &OperationMethod{C: c}
-	// 根据method方法名确定操作行为
-	switch c.Request.Method {
-	case "PUT":
-		err := opMethod.putOperation()
-		if err != nil {
-			c.Next()
-		}
-	case "POST":
-		err := opMethod.postOperation()
-		if err != nil {
-			c.Next()
-		}
-	case "DELETE":
-		err := opMethod.deleteOperation()
-		if err != nil {
-			c.Next()
-		}
-	default:
+	action, ok := operationActions[c.Request.Method]
+	if !ok {
 		return
 	}
 
-}
+	var bodyBytes []byte
+	if c.Request.Body != nil {
+		bodyBytes, _ = ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	}
 
-type OperationMethod struct {
-	C *ctx.GinRequest
-}
+	c.Next()
 
-func (o *OperationMethod) putOperation() (err error) {
-	bodyBytes, err := ioutil.ReadAll(o.C.Request.Body)
-	o.C.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-	cxt := o.C.Service()
-	url := o.C.Request.URL.String()
-	operationLog := models.OperationLog{
-		UserID:        cxt.UserId,
-		Username:      cxt.Username,
-		UserAddr:      cxt.UserIpAddr,
-		OperationAt:   models.Time(time.Now()),
-		OperationType: "PUT",
-		OperationInfo: fmt.Sprintf("修改了%s中的数据", strings.Split(url, "/")[len(strings.Split(url, "/"))-2]),
-		Desc:          models.JSON(bodyBytes),
+	sc := c.Service()
+	if sc.UserId == "" {
+		return
 	}
 
-	err = operationLog.InsertLog()
-	if err != nil {
-		return err
+	resourceType := parseOperationResource(c.Request.URL.Path)
+	resourceId := c.Param("id")
+	if resourceId == "" && len(sc.AuditChanges) == 1 {
+		resourceId = sc.AuditChanges[0].ResourceId.String()
 	}
 
-	return
-}
-
-func (o *OperationMethod) postOperation() (err error) {
-	// 新建操作 需要记录新插入的数据
-	name := o.C.PostForm("name")
-	bodyBytes, err := ioutil.ReadAll(o.C.Request.Body)
-	o.C.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-	cxt := o.C.Service()
-	url := o.C.Request.URL.String()
 	operationLog := models.OperationLog{
-		UserID:        cxt.UserId,
-		Username:      cxt.Username,
-		UserAddr:      cxt.UserIpAddr,
+		UserID:        sc.UserId,
+		Username:      sc.Username,
+		UserAddr:      sc.UserIpAddr,
 		OperationAt:   models.Time(time.Now()),
-		OperationType: "Create",
-		OperationInfo: fmt.Sprintf("创建了%s中的名称为%s数据", strings.Split(url, "/")[len(strings.Split(url, "/"))-2], name),
-		Desc:          models.JSON(bodyBytes),
+		OperationType: c.Request.Method,
+		OperationInfo: operationInfo(action, resourceType, resourceId, bodyBytes),
+		Desc:          services.MaskAuditBody(bodyBytes),
+
+		OrgId:        sc.OrgId,
+		ProjectId:    sc.ProjectId,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceId:   models.Id(resourceId),
+		Method:       c.Request.Method,
+		Path:         c.Request.URL.Path,
+		StatusCode:   c.Writer.Status(),
+		Changes:      sc.AuditChanges,
 	}
-
-	err = operationLog.InsertLog()
-	if err != nil {
-		return
+	if err := operationLog.InsertLog(); err != nil {
+		c.Logger().Errorf("insert operation log: %v", err)
 	}
-
-	return
 }
 
-func (o *OperationMethod) deleteOperation() (err error) {
-	// 删除操作
-	cxt := o.C.Service()
-	id := o.C.PostForm("id")
-	bodyBytes, err := ioutil.ReadAll(o.C.Request.Body)
-	o.C.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-	url := o.C.Request.URL.String()
-	operationLog := models.OperationLog{
-		UserID:        cxt.UserId,
-		Username:      cxt.Username,
-		UserAddr:      cxt.UserIpAddr,
-		OperationAt:   models.Time(time.Now()),
-		OperationType: "delete",
-		OperationInfo: fmt.Sprintf("删除%s中了id为%s的数据", strings.Split(url, "/")[len(strings.Split(url, "/"))-2], id),
-		Desc:          models.JSON(bodyBytes),
+// parseOperationResource 从请求路径解析资源类型，如 /api/v1/envs/env-xxx/deploy 解析为 envs
+func parseOperationResource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 2 && parts[0] == "api" {
+		parts = parts[2:]
 	}
+	return parts[0]
+}
 
-	err = operationLog.InsertLog()
-	if err != nil {
-		return
+func operationInfo(action string, resourceType string, resourceId string, body []byte) string {
+	switch action {
+	case models.AuditActionCreate:
+		params := struct {
+			Name string `json:"name"`
+		}{}
+		_ = json.Unmarshal(body, &params)
+		return fmt.Sprintf("创建了%s中的名称为%s数据", resourceType, params.Name)
+	case models.AuditActionDelete:
+		return fmt.Sprintf("删除%s中了id为%s的数据", resourceType, resourceId)
+	default:
+		return fmt.Sprintf("修改了%s中的数据", resourceType)
 	}
-
-	return nil
 }
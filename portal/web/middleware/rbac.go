@@ -77,6 +77,8 @@ func AccessControl(args ...string) gin.HandlerFunc {
 			role = consts.RoleAnonymous
 		case s.IsSuperAdmin:
 			role = consts.RoleRoot
+		case s.ApiToken != nil:
+			role, _ = apiTokenRoles(s.ApiToken, s.ProjectId)
 		// FIXME 临时处理系统管理员权限
 		case s.UserId == consts.SysUserId:
 			role = consts.OrgRoleAdmin
@@ -97,6 +99,8 @@ func AccessControl(args ...string) gin.HandlerFunc {
 		switch {
 		case s.IsSuperAdmin:
 			proj = consts.ProjectRoleManager
+		case s.ApiToken != nil:
+			_, proj = apiTokenRoles(s.ApiToken, s.ProjectId)
 		case services.UserHasOrgRole(s.UserId, s.OrgId, consts.OrgRoleAdmin):
 			proj = consts.ProjectRoleManager
 		case s.ProjectId != "":
@@ -128,6 +132,12 @@ func AccessControl(args ...string) gin.HandlerFunc {
 			proj = consts.RoleDemo
 		}
 
+		// 环境范围的 api token 只能访问所属的环境及环境的任务
+		if s.ApiToken != nil && s.ApiToken.Scope == consts.ScopeEnv && !apiTokenEnvAllowed(c, s.ApiToken, object) {
+			c.JSONError(e.New(e.PermissionDeny, fmt.Errorf("token not allowed to access %s", object)), http.StatusForbidden)
+			return
+		}
+
 		// 根据 角色 和 项目角色 判断资源访问许可
 		allow, err := rbac.Enforce(role, proj, object, action)
 		if err != nil {
@@ -143,3 +153,32 @@ func AccessControl(args ...string) gin.HandlerFunc {
 		}
 	}
 }
+
+// apiTokenRoles 返回 api token 的组织角色及项目角色
+func apiTokenRoles(token *models.Token, projectId models.Id) (role string, proj string) {
+	switch {
+	case token.Scope == consts.ScopeProject || token.Scope == consts.ScopeEnv:
+		// 项目及环境 token 只在所属项目中拥有项目角色
+		if projectId == token.ProjectId {
+			return "", token.Role
+		}
+		return "", ""
+	case token.Role == "" || token.Role == consts.OrgRoleAdmin:
+		// 未指定角色的组织 token 按组织管理员处理
+		return consts.OrgRoleAdmin, consts.ProjectRoleManager
+	default:
+		return token.Role, ""
+	}
+}
+
+func apiTokenEnvAllowed(c *ctx.GinRequest, token *models.Token, object string) bool {
+	id := models.Id(c.Param("id"))
+	switch object {
+	case "envs":
+		return id == token.EnvId
+	case "tasks":
+		task, err := services.GetTaskById(c.Service().DB(), id)
+		return err == nil && task.EnvId == token.EnvId
+	}
+	return false
+}
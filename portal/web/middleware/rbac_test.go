@@ -0,0 +1,32 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package middleware
+
+import (
+	"cloudiac/portal/consts"
+	"cloudiac/portal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiTokenRoles(t *testing.T) {
+	cases := []struct {
+		token     models.Token
+		projectId models.Id
+		role      string
+		proj      string
+	}{
+		{models.Token{Scope: consts.ScopeOrg}, "p-a", consts.OrgRoleAdmin, consts.ProjectRoleManager},
+		{models.Token{Scope: consts.ScopeOrg, Role: consts.OrgRoleMember}, "p-a", consts.OrgRoleMember, ""},
+		{models.Token{Scope: consts.ScopeOrg, Role: "role-xxx"}, "", "role-xxx", ""},
+		{models.Token{Scope: consts.ScopeProject, ProjectId: "p-a", Role: consts.ProjectRoleOperator}, "p-a", "", consts.ProjectRoleOperator},
+		{models.Token{Scope: consts.ScopeProject, ProjectId: "p-a", Role: consts.ProjectRoleOperator}, "p-b", "", ""},
+		{models.Token{Scope: consts.ScopeEnv, ProjectId: "p-a", EnvId: "env-a", Role: consts.ProjectRoleGuest}, "p-a", "", consts.ProjectRoleGuest},
+	}
+	for _, c := range cases {
+		role, proj := apiTokenRoles(&c.token, c.projectId)
+		assert.Equal(t, c.role, role)
+		assert.Equal(t, c.proj, proj)
+	}
+}
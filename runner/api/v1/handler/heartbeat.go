@@ -0,0 +1,13 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handler
+
+import (
+	"cloudiac/runner"
+	"cloudiac/runner/api/ctx"
+)
+
+// Heartbeat 返回 runner 的容量及健康状态，portal 定时调用该接口检查 runner 状态
+func Heartbeat(c *ctx.Context) {
+	c.Result(runner.GetRunnerStatus())
+}
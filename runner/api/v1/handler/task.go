@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/docker/docker/errdefs"
 	"github.com/gin-gonic/gin"
 
 	"cloudiac/runner"
@@ -36,26 +35,13 @@ func StopTask(c *ctx.Context) {
 		return
 	}
 
-	cli, err := runner.DockerClient()
-	if err != nil {
-		c.Error(err, http.StatusInternalServerError)
-		return
-	}
-
 	// 这里仅 kill container，container 的 remove 通过启动时的 AutoRemove 参数配置
 	for _, cid := range req.ContainerIds {
-		// default signal "SIGKILL"
-		if err := cli.ContainerKill(c.Context, cid, ""); err != nil {
-			if _, ok := err.(errdefs.ErrNotFound); ok {
-				continue
-			}
-
-			if err != nil {
-				// 有可能己经提交了删除请求，这里忽略掉这些报错
-				if !strings.Contains(err.Error(), "already in progress") &&
-					!strings.Contains(err.Error(), "No such container") {
-					logger.Warnf("kill container error: %v", err)
-				}
+		if err := runner.GetExecutor().Kill(cid); err != nil {
+			// 有可能己经提交了删除请求，这里忽略掉这些报错
+			if !strings.Contains(err.Error(), "already in progress") &&
+				!strings.Contains(err.Error(), "No such container") {
+				logger.Warnf("kill container error: %v", err)
 			}
 
 			c.Error(err, http.StatusInternalServerError)
@@ -0,0 +1,90 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"cloudiac/runner"
+	"cloudiac/runner/api/ctx"
+)
+
+// TaskArtifacts 列出任务产生的文件
+func TaskArtifacts(c *ctx.Context) {
+	req := runner.TaskArtifactReq{}
+	if err := c.BindQuery(&req); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	artifacts, err := runner.ListTaskArtifacts(req.EnvId, req.TaskId)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.Error(fmt.Errorf("not exists"), http.StatusNotFound)
+		} else if errors.Is(err, runner.ErrInvalidArtifact) {
+			c.Error(err, http.StatusBadRequest)
+		} else {
+			c.Error(err, http.StatusInternalServerError)
+		}
+		return
+	}
+	c.Result(artifacts)
+}
+
+// TaskArtifactDownload 下载任务产生的文件，支持 Range 请求，
+// 响应头 X-Checksum-Sha256 返回文件的 sha256 值
+func TaskArtifactDownload(c *ctx.Context) {
+	req := runner.TaskArtifactReq{}
+	if err := c.BindQuery(&req); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	path, err := runner.GetTaskArtifactPath(req.EnvId, req.TaskId, req.Name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.Error(fmt.Errorf("not exists"), http.StatusNotFound)
+		} else if errors.Is(err, runner.ErrInvalidArtifact) {
+			c.Error(err, http.StatusBadRequest)
+		} else {
+			c.Error(err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.Error(fmt.Errorf("not exists"), http.StatusNotFound)
+		} else {
+			c.Error(err, http.StatusInternalServerError)
+		}
+		return
+	}
+	defer fp.Close()
+
+	info, err := fp.Stat()
+	if err != nil {
+		c.Error(err, http.StatusInternalServerError)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		c.Error(fmt.Errorf("not a regular file"), http.StatusBadRequest)
+		return
+	}
+
+	checksum, err := runner.FileChecksum(path)
+	if err != nil {
+		c.Error(err, http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("X-Checksum-Sha256", checksum)
+	c.Header("ETag", fmt.Sprintf(`"%s"`, checksum))
+	c.Header("Content-Type", "application/octet-stream")
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), fp)
+}
@@ -84,35 +84,6 @@ func doTaskStatus(wsConn *websocket.Conn, task *runner.StartedTask, closedCh <-c
 			} else {
 				msg.LogContent = logContent
 			}
-
-			if stateJson, err := runner.FetchStateJson(task.EnvId, task.TaskId); err != nil {
-				logger.Errorf("fetch terraform state json error: %v", err)
-			} else {
-				msg.TfStateJson = stateJson
-			}
-
-			if providerJson, err := runner.FetchProviderJson(task.EnvId, task.TaskId); err != nil {
-				logger.Errorf("fetch terraform provider json error: %v", err)
-			} else {
-				msg.TFProviderSchemaJson = providerJson
-			}
-
-			if planJson, err := runner.FetchPlanJson(task.EnvId, task.TaskId); err != nil {
-				logger.Errorf("fetch terraform state json error: %v", err)
-			} else {
-				msg.TfPlanJson = planJson
-			}
-
-			if parseJson, err := runner.FetchJson(task.EnvId, task.TaskId, runner.TerrascanJsonFile); err != nil {
-				logger.Errorf("fetch terrascan parsed json error: %v", err)
-			} else {
-				msg.TfScanJson = parseJson
-			}
-			if resultJson, err := runner.FetchJson(task.EnvId, task.TaskId, runner.TerrascanResultFile); err != nil {
-				logger.Errorf("fetch terrascan scan result json error: %v", err)
-			} else {
-				msg.TfResultJson = resultJson
-			}
 		}
 
 		if err := wsConn.WriteJSON(msg); err != nil {
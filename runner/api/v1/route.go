@@ -21,9 +21,14 @@ func RegisterRoute(apiV1 *gin.RouterGroup) {
 		})
 	})
 
+	// 心跳接口调用频繁，不记录访问日志
+	apiV1.GET("/heartbeat", w(handler.Heartbeat))
+
 	apiV1.Use(gin.Logger())
 	apiV1.POST("/task/step/run", w(handler.RunTask))
 	apiV1.GET("/task/step/status", w(handler.TaskStatus))
 	apiV1.POST("/task/stop", w(handler.StopTask))
 	apiV1.GET("/task/step/log/follow", w(handler.TaskLogFollow))
+	apiV1.GET("/task/artifacts", w(handler.TaskArtifacts))
+	apiV1.GET("/task/artifacts/download", w(handler.TaskArtifactDownload))
 }
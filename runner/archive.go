@@ -0,0 +1,187 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrArchiveTooLarge 打包文件超过大小限制
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// limitedBuffer 写入内容超过 limit 时返回 ErrArchiveTooLarge，limit 为 0 表示不限制
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.Len()+len(p) > b.limit {
+		return 0, ErrArchiveTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// checkNoSymlink 检查 root 下的相对路径 name 的各级路径都不是符号链接，
+// 避免通过工作目录中的符号链接读写 root 之外的文件，不存在的路径不检查
+func checkNoSymlink(root string, name string) error {
+	path := root
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path '%s' is a symlink", filepath.ToSlash(name))
+		}
+	}
+	return nil
+}
+
+// PackFiles 将 root 下的 dir 目录中的 paths(文件或目录)打包为 tar.gz 保存到 target，打包文件中的路径相对于 dir，
+// 不存在的 path 会被忽略，所有 path 都不存在时不生成 target 文件。
+// 打包内容超过 maxSize 时返回 ErrArchiveTooLarge，maxSize 为 0 表示不限制。
+// 不会打包符号链接，dir 及 path 的各级路径中包含符号链接时返回错误
+func PackFiles(root string, dir string, paths []string, target string, maxSize int) error {
+	dir, err := cleanArchivePath(dir)
+	if err != nil {
+		return err
+	}
+	buffer := limitedBuffer{limit: maxSize}
+	gw := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gw)
+
+	count := 0
+	for _, p := range paths {
+		name, err := cleanArchivePath(p)
+		if err != nil {
+			return err
+		}
+		if err := checkNoSymlink(root, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		// Walk 不会跟随子目录中的符号链接
+		err = filepath.Walk(filepath.Join(root, dir, name), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				// 忽略符号链接等特殊文件
+				return nil
+			}
+
+			rel, err := filepath.Rel(filepath.Join(root, dir), path)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			count += 1
+			if info.IsDir() {
+				return nil
+			}
+
+			fp, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+			_, err = io.Copy(tw, fp)
+			return err
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return os.WriteFile(target, buffer.Bytes(), 0644)
+}
+
+// UnpackFiles 将 tar.gz 内容解压到 root 下的 dir 目录，不允许解压到 dir 之外的路径，
+// dir 及其中已存在的符号链接(如代码仓库中提交的符号链接)不会被跟随
+func UnpackFiles(content []byte, root string, dir string) error {
+	dir, err := cleanArchivePath(dir)
+	if err != nil {
+		return err
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name, err := cleanArchivePath(header.Name)
+		if err != nil {
+			return err
+		}
+		name = filepath.Join(dir, name)
+		if err := checkNoSymlink(root, name); err != nil {
+			return err
+		}
+		path := filepath.Join(root, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			fp, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fp, tr)
+			fp.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// cleanArchivePath 检查并返回清理后的相对路径，不允许绝对路径及上层目录
+func cleanArchivePath(p string) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(p))
+	if name == "." || name == ".." || filepath.IsAbs(name) || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path '%s'", p)
+	}
+	return name, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackAndUnpackFiles(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"code/.terraform/modules/vpc/main.tf": "module",
+		"code/dist/app.zip":                   "zip",
+		"code/other.txt":                      "other",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	target := filepath.Join(t.TempDir(), StepCacheFile)
+	assert.NoError(t, PackFiles(src, "code", []string{".terraform/modules", "dist/app.zip", "not-exists"}, target, 0))
+	content, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+
+	dst := t.TempDir()
+	assert.NoError(t, UnpackFiles(content, dst, "code/app"))
+	bs, err := ioutil.ReadFile(filepath.Join(dst, "code/app/.terraform/modules/vpc/main.tf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "module", string(bs))
+	_, err = os.Stat(filepath.Join(dst, "code/app/dist/app.zip"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dst, "code/app/other.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 所有路径都不存在时不生成打包文件
+	empty := filepath.Join(t.TempDir(), StepArtifactsFile)
+	assert.NoError(t, PackFiles(src, "code", []string{"not-exists"}, empty, 0))
+	_, err = os.Stat(empty)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, PackFiles(src, "code", []string{"../etc"}, target, 0))
+	assert.Error(t, PackFiles(src, "../code", []string{"dist"}, target, 0))
+}
+
+func TestPackAndUnpackFilesSymlink(t *testing.T) {
+	src := t.TempDir()
+	outside := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "code"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(src, "code", "dist")))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "secret"), filepath.Join(src, "code", "secret")))
+	// 代码工作目录本身是符号链接
+	assert.NoError(t, os.Symlink(outside, filepath.Join(src, "code", "workdir")))
+
+	// 不能通过符号链接打包 workspace 之外的文件
+	target := filepath.Join(t.TempDir(), StepArtifactsFile)
+	assert.Error(t, PackFiles(src, "code", []string{"dist/secret"}, target, 0))
+	assert.Error(t, PackFiles(src, "code", []string{"dist"}, target, 0))
+	assert.Error(t, PackFiles(src, "code", []string{"secret"}, target, 0))
+	assert.Error(t, PackFiles(src, "code/workdir", []string{"secret"}, target, 0))
+
+	// 解压时不能通过己存在的符号链接写入 workspace 之外的文件
+	files := filepath.Join(t.TempDir(), "files")
+	assert.NoError(t, os.MkdirAll(filepath.Join(files, "code", "dist"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(files, "code", "dist", "new"), []byte("new"), 0644))
+	assert.NoError(t, PackFiles(files, "code", []string{"dist"}, target, 0))
+	content, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Error(t, UnpackFiles(content, src, "code"))
+	assert.Error(t, UnpackFiles(content, src, "code/workdir"))
+	_, err = os.Stat(filepath.Join(outside, "new"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(outside, "dist"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 超过大小限制
+	assert.ErrorIs(t, PackFiles(files, "code", []string{"dist"}, target, 10), ErrArchiveTooLarge)
+}
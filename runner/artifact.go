@@ -0,0 +1,160 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"cloudiac/common"
+	"cloudiac/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TaskArtifact 任务执行产生的文件(tfplan、tfstate、扫描结果、步骤日志等)，
+// Name 为相对任务 workspace 的路径
+type TaskArtifact struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum"` // sha256
+	ModTime  time.Time `json:"modTime"`
+}
+
+// workspace 下允许通过 artifact 接口获取的文件，代码目录、ssh 密钥、生成的 tf 文件等都不允许获取
+var workspaceArtifacts = []string{
+	TFStateJsonFile, TFPlanJsonFile, TFProviderSchema, TFStateFile,
+	TerrascanJsonFile, TerrascanResultFile, TerrascanLogFile, RegoResultFile,
+}
+
+// 步骤目录下允许通过 artifact 接口获取的文件
+var stepArtifacts = []string{TaskLogName, StepArtifactsFile, StepCacheFile}
+
+// ErrInvalidArtifact 请求的任务或 artifact 名称不合法
+var ErrInvalidArtifact = errors.New("invalid artifact")
+
+// checkTaskPathId 检查 envId、taskId 是否可以作为路径使用，避免通过 ../ 等访问任务目录以外的文件
+func checkTaskPathId(id string) error {
+	if id == "" || id == "." || strings.Contains(id, "..") || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("%w: invalid id '%s'", ErrInvalidArtifact, id)
+	}
+	return nil
+}
+
+// checkTaskPathIds 云模板的扫描任务没有 envId，此时 workspace 为 <storage>/<taskId>
+func checkTaskPathIds(envId string, taskId string) error {
+	if envId != "" {
+		if err := checkTaskPathId(envId); err != nil {
+			return err
+		}
+	}
+	return checkTaskPathId(taskId)
+}
+
+// isTaskArtifact name 是否为允许获取的 artifact
+func isTaskArtifact(name string) bool {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		return utils.StrInArray(base, workspaceArtifacts...)
+	}
+	return isTaskStepDir(filepath.Clean(dir)) && utils.StrInArray(base, stepArtifacts...)
+}
+
+// GetTaskArtifactPath 返回 artifact 在 runner 本地的路径，只允许获取 ListTaskArtifacts 返回的文件，
+// 文件为软链接时解析后的路径也必须在任务 workspace 下
+func GetTaskArtifactPath(envId string, taskId string, name string) (string, error) {
+	if err := checkTaskPathIds(envId, taskId); err != nil {
+		return "", err
+	}
+	name = filepath.Clean(filepath.FromSlash(name))
+	if !isTaskArtifact(name) {
+		return "", fmt.Errorf("%w: invalid artifact name '%s'", ErrInvalidArtifact, name)
+	}
+
+	workspace, err := filepath.EvalSymlinks(GetTaskWorkspace(envId, taskId))
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(workspace, name))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(path, workspace+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: invalid artifact name '%s'", ErrInvalidArtifact, name)
+	}
+	return path, nil
+}
+
+// ListTaskArtifacts 列出任务 workspace 及各步骤目录下允许获取的文件
+func ListTaskArtifacts(envId string, taskId string) ([]TaskArtifact, error) {
+	if err := checkTaskPathIds(envId, taskId); err != nil {
+		return nil, err
+	}
+	workspace := GetTaskWorkspace(envId, taskId)
+	if _, err := os.Stat(workspace); err != nil {
+		return nil, err
+	}
+
+	dirs := []string{""}
+	artifacts := make([]TaskArtifact, 0)
+	for i := 0; i < len(dirs); i++ {
+		infos, err := ioutil.ReadDir(filepath.Join(workspace, dirs[i]))
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			name := filepath.Join(dirs[i], info.Name())
+			if info.IsDir() {
+				if dirs[i] == "" && isTaskStepDir(info.Name()) {
+					dirs = append(dirs, name)
+				}
+				continue
+			}
+			// 软链接等非普通文件不作为 artifact
+			if !info.Mode().IsRegular() || !isTaskArtifact(name) {
+				continue
+			}
+
+			checksum, err := FileChecksum(filepath.Join(workspace, name))
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, TaskArtifact{
+				Name:     filepath.ToSlash(name),
+				Size:     info.Size(),
+				Checksum: checksum,
+				ModTime:  info.ModTime(),
+			})
+		}
+	}
+	return artifacts, nil
+}
+
+func isTaskStepDir(name string) bool {
+	var step int
+	if name == GetTaskDirName(common.CollectTaskStepIndex) {
+		return true
+	}
+	_, err := fmt.Sscanf(name, "step%d", &step)
+	return err == nil && name == GetTaskDirName(step)
+}
+
+// FileChecksum 计算文件 sha256 值
+func FileChecksum(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTaskArtifacts(t *testing.T) {
+	initTestRunnerConfig(t, t.TempDir())
+
+	workspace := GetTaskWorkspace("env-1", "run-1")
+	files := map[string]string{
+		TFPlanJsonFile:           "{}",
+		"ssh_key":                "key",
+		CloudIacTfFile:           "tf",
+		"step0/" + TaskLogName:   "log",
+		".step-collect/run.sh":   "sh",
+		"code/main.tf":           "tf",
+		"step0/sub/ignored.json": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(workspace, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	// 步骤可能在 workspace 中创建指向 runner 主机文件的软链接
+	assert.NoError(t, os.Symlink("/etc/passwd", filepath.Join(workspace, TFStateJsonFile)))
+
+	artifacts, err := ListTaskArtifacts("env-1", "run-1")
+	assert.NoError(t, err)
+	names := make([]string, 0)
+	for _, a := range artifacts {
+		names = append(names, a.Name)
+	}
+	assert.ElementsMatch(t, []string{TFPlanJsonFile, "step0/" + TaskLogName}, names)
+	for _, a := range artifacts {
+		if a.Name == TFPlanJsonFile {
+			assert.Equal(t, int64(2), a.Size)
+			assert.Equal(t, "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", a.Checksum)
+		}
+	}
+
+	_, err = ListTaskArtifacts("env-1", "not-exists")
+	assert.True(t, os.IsNotExist(err))
+	_, err = ListTaskArtifacts("../..", "run-1")
+	assert.ErrorIs(t, err, ErrInvalidArtifact)
+
+	path, err := GetTaskArtifactPath("env-1", "run-1", "step0/"+TaskLogName)
+	assert.NoError(t, err)
+	realWorkspace, _ := filepath.EvalSymlinks(workspace)
+	assert.Equal(t, filepath.Join(realWorkspace, "step0", TaskLogName), path)
+
+	_, err = GetTaskArtifactPath("env-1", "run-1", TFStateFile)
+	assert.True(t, os.IsNotExist(err))
+
+	for _, name := range []string{"", "../run-2/tfplan.json", "/etc/passwd", "ssh_key", "step0/../../x",
+		"code/main.tf", CloudIacTfFile, ".step-collect/run.sh", TFStateJsonFile} {
+		_, err = GetTaskArtifactPath("env-1", "run-1", name)
+		assert.Error(t, err, name)
+	}
+	for _, ids := range [][2]string{{"env-1", ""}, {"../../../..", "etc"}, {"env-1", ".."}, {"env-1/run-1", "step0"}} {
+		_, err = GetTaskArtifactPath(ids[0], ids[1], TFPlanJsonFile)
+		assert.ErrorIs(t, err, ErrInvalidArtifact, ids)
+	}
+}
@@ -14,30 +14,12 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/pkg/errors"
 
-	"cloudiac/common"
-	"cloudiac/configs"
 	"cloudiac/utils"
 )
 
-// Task Executor
-type Executor struct {
-	Image      string
-	Name       string
-	Env        []string
-	Timeout    int
-	PrivateKey string
-
-	TerraformVersion string
-	Commands         []string
-	HostWorkdir      string // 宿主机目录
-	Workdir          string // 容器目录
-	AutoRemove       bool   // 开启容器的自动删除？
-	// for container
-	//ContainerInstance *Container
-}
-
 // Container Info
 type Container struct {
 	Context context.Context
@@ -45,6 +27,9 @@ type Container struct {
 	RunID   string
 }
 
+// DockerExecutor 基于 docker 的任务执行器，每个任务启动一个容器，步骤通过 docker exec 执行
+type DockerExecutor struct{}
+
 func DockerClient() (*client.Client, error) {
 	return dockerClient()
 }
@@ -60,8 +45,8 @@ func dockerClient() (*client.Client, error) {
 	return cli, nil
 }
 
-func (exec *Executor) tryPullImage(cli *client.Client) {
-	logger := logger.WithField("image", exec.Image).WithField("action", "TryPullImage")
+func tryPullImage(cli *client.Client, image string) {
+	logger := logger.WithField("image", image).WithField("action", "TryPullImage")
 	if cli == nil {
 		var err error
 		cli, err = dockerClient()
@@ -71,7 +56,7 @@ func (exec *Executor) tryPullImage(cli *client.Client) {
 		}
 	}
 
-	reader, err := cli.ImagePull(context.Background(), exec.Image, types.ImagePullOptions{})
+	reader, err := cli.ImagePull(context.Background(), image, types.ImagePullOptions{})
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			logger.Debugf("pull image: %v", err)
@@ -86,86 +71,49 @@ func (exec *Executor) tryPullImage(cli *client.Client) {
 	logger.Tracef("pull image: %s", bs)
 }
 
-func (exec *Executor) Start() (string, error) {
-	logger := logger.WithField("taskId", filepath.Base(exec.HostWorkdir))
+func (DockerExecutor) Start(spec ContainerSpec) (string, error) {
+	logger := logger.WithField("taskId", filepath.Base(spec.HostWorkdir))
 	cli, err := dockerClient()
 	if err != nil {
 		logger.Error(err)
 		return "", err
 	}
-	logger.Infof("pull image: %s", exec.Image)
-	exec.tryPullImage(cli)
+	logger.Infof("pull image: %s", spec.Image)
+	tryPullImage(cli, spec.Image)
 
-	conf := configs.Get()
 	mountConfigs := []mount.Mount{
-		{
-			Type:   mount.TypeBind,
-			Source: exec.HostWorkdir,
-			Target: ContainerWorkspace,
-		},
-		{
-			Type:   mount.TypeBind,
-			Source: conf.Runner.AbsPluginCachePath(),
-			Target: ContainerPluginCachePath,
-		},
 		{
 			Type:   mount.TypeBind,
 			Source: "/var/run/docker.sock",
 			Target: "/var/run/docker.sock",
 		},
 	}
-
-	// assets_path 配置为空则表示直接使用 worker 容器中打包的 assets。
-	// 在 runner 容器化部署时运行 runner 的宿主机(docker host)并没有 assets 目录，
-	// 如果配置了 assets 路径，进行 bind mount 时会因为源目录不存在而报错。
-	if conf.Runner.AssetsPath != "" {
+	for _, m := range spec.containerMounts() {
 		mountConfigs = append(mountConfigs, mount.Mount{
 			Type:     mount.TypeBind,
-			Source:   conf.Runner.AbsAssetsPath(),
-			Target:   ContainerAssetsDir,
-			ReadOnly: true,
-		})
-		mountConfigs = append(mountConfigs, mount.Mount{
-			// providers 需要挂载到指定目录才能被 terraform 查找到，所以单独做一次挂载
-			Type:     mount.TypeBind,
-			Source:   conf.Runner.ProviderPath(),
-			Target:   ContainerPluginPath,
-			ReadOnly: true,
-		})
-	}
-
-	// 内置 tf 版本列表中无该版本，我们挂载缓存目录到容器，下载后会保存到宿主机，下次可以直接使用。
-	// 注意，该方案有个问题：客户无法自定义镜像预先安装需要的 terraform 版本，
-	// 因为判断版本不在 TerraformVersions 列表中就会挂载目录，客户自定义镜像安装的版本会被覆盖
-	//（考虑把版本列表写到配置文件？）
-	if !utils.StrInArray(exec.TerraformVersion, common.TerraformVersions...) {
-		mountConfigs = append(mountConfigs, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: conf.Runner.AbsTfenvVersionsCachePath(),
-			Target: "/root/.tfenv/versions",
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
 		})
 	}
 
 	c, err := cli.ContainerCreate(
 		context.Background(),
 		&container.Config{
-			Image:        exec.Image,
-			WorkingDir:   exec.Workdir,
-			Cmd:          exec.Commands,
-			Env:          exec.Env,
+			Image:        spec.Image,
+			WorkingDir:   spec.Workdir,
+			Cmd:          spec.Commands,
+			Env:          spec.Env,
 			OpenStdin:    true,
 			Tty:          true,
 			AttachStdin:  false,
 			AttachStdout: true,
 			AttachStderr: true,
 		},
-		&container.HostConfig{
-			AutoRemove: exec.AutoRemove,
-			Mounts:     mountConfigs,
-		},
+		dockerHostConfig(spec, mountConfigs),
 		nil,
 		nil,
-		exec.Name)
+		spec.Name)
 	if err != nil {
 		logger.Errorf("create container err: %v", err)
 		return "", err
@@ -177,7 +125,30 @@ func (exec *Executor) Start() (string, error) {
 	return cid, err
 }
 
-func (Executor) RunCommand(cid string, command []string) (execId string, err error) {
+func dockerHostConfig(spec ContainerSpec, mounts []mount.Mount) *container.HostConfig {
+	hostConfig := &container.HostConfig{
+		AutoRemove: spec.AutoRemove,
+		Mounts:     mounts,
+	}
+
+	limits := spec.Limits
+	hostConfig.NanoCPUs = int64(limits.CPUs * 1e9)
+	hostConfig.Memory = limits.Memory * 1024 * 1024
+	if limits.Pids > 0 {
+		hostConfig.PidsLimit = &limits.Pids
+	}
+	if limits.IsNetworkDisabled() {
+		hostConfig.NetworkMode = "none"
+	}
+	if limits.IsReadonlyRootfs() {
+		// 只读根文件系统时 /tmp 使用 tmpfs，其他需要写入的目录需要位于挂载目录中
+		hostConfig.ReadonlyRootfs = true
+		hostConfig.Tmpfs = map[string]string{"/tmp": ""}
+	}
+	return hostConfig
+}
+
+func (DockerExecutor) RunCommand(cid string, command []string) (execId string, err error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return "", err
@@ -201,34 +172,29 @@ func (Executor) RunCommand(cid string, command []string) (execId string, err err
 	return resp.ID, nil
 }
 
-func (Executor) GetExecInfo(execId string) (execInfo types.ContainerExecInspect, err error) {
-	cli, err := dockerClient()
-	if err != nil {
-		return execInfo, err
+func toExecInfo(inspect types.ContainerExecInspect) ExecInfo {
+	return ExecInfo{
+		ExecID:      inspect.ExecID,
+		ContainerID: inspect.ContainerID,
+		Running:     inspect.Running,
+		ExitCode:    inspect.ExitCode,
+		Pid:         inspect.Pid,
 	}
-	execInfo, err = cli.ContainerExecInspect(context.Background(), execId)
-	if err != nil {
-		return execInfo, errors.Wrap(err, "container exec attach")
-	}
-	return execInfo, nil
 }
 
-func (Executor) Wait(ctx context.Context, cid string) error {
+func (DockerExecutor) GetExecInfo(execId string) (execInfo ExecInfo, err error) {
 	cli, err := dockerClient()
 	if err != nil {
-		return err
+		return execInfo, err
 	}
-
-	okCh, errCh := cli.ContainerWait(ctx, cid, container.WaitConditionNotRunning)
-	select {
-	case <-okCh:
-		return nil
-	case err = <-errCh:
-		return err
+	inspect, err := cli.ContainerExecInspect(context.Background(), execId)
+	if err != nil {
+		return execInfo, errors.Wrap(err, "container exec attach")
 	}
+	return toExecInfo(inspect), nil
 }
 
-func (Executor) WaitCommand(ctx context.Context, execId string) (execInfo types.ContainerExecInspect, err error) {
+func (DockerExecutor) WaitCommand(ctx context.Context, execId string) (execInfo ExecInfo, err error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return execInfo, err
@@ -255,26 +221,7 @@ func (Executor) WaitCommand(ctx context.Context, execId string) (execInfo types.
 	}
 }
 
-func (exec Executor) WaitCommandWithDeadline(ctx context.Context, execId string, deadline time.Time) (execInfo types.ContainerExecInspect, err error) {
-	var cancel context.CancelFunc
-	ctx, cancel = context.WithDeadline(ctx, deadline)
-	defer cancel()
-
-	logger.Debugf("wait exec %s, deadline: %s", execId, deadline.Format(time.RFC3339))
-	if execInfo, err = exec.WaitCommand(ctx, execId); err != nil {
-		if err == context.DeadlineExceeded {
-			// logger.Infof("task %s/step%s: %v", exec..TaskId, t.req.Step, err)
-			if err := (Executor{}).StopCommand(execId); err != nil {
-				logger.WithField("cid", execInfo.ContainerID).Errorf("stop command error: %v", err)
-			}
-		}
-		return execInfo, err
-	}
-
-	return execInfo, err
-}
-
-func (e Executor) StopCommand(execId string) (err error) {
+func (e DockerExecutor) StopCommand(execId string) (err error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return err
@@ -291,7 +238,7 @@ func (e Executor) StopCommand(execId string) (err error) {
 	return nil
 }
 
-func (Executor) IsPaused(cid string) (bool, error) {
+func (DockerExecutor) IsPaused(cid string) (bool, error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return false, err
@@ -305,7 +252,7 @@ func (Executor) IsPaused(cid string) (bool, error) {
 	return inspect.State.Paused, nil
 }
 
-func (Executor) Pause(cid string) (err error) {
+func (DockerExecutor) Pause(cid string) (err error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return err
@@ -323,7 +270,7 @@ func (Executor) Pause(cid string) (err error) {
 	return nil
 }
 
-func (Executor) Unpause(cid string) (err error) {
+func (DockerExecutor) Unpause(cid string) (err error) {
 	cli, err := dockerClient()
 	if err != nil {
 		return err
@@ -335,3 +282,47 @@ func (Executor) Unpause(cid string) (err error) {
 	}
 	return nil
 }
+
+func (DockerExecutor) Kill(cid string) error {
+	cli, err := dockerClient()
+	if err != nil {
+		return err
+	}
+
+	// default signal "SIGKILL"
+	if err := cli.ContainerKill(context.Background(), cid, ""); err != nil {
+		if _, ok := err.(errdefs.ErrNotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (DockerExecutor) Remove(cid string) error {
+	cli, err := dockerClient()
+	if err != nil {
+		return err
+	}
+
+	containerRemoveOpts := types.ContainerRemoveOptions{
+		RemoveVolumes: true,
+		Force:         true,
+	}
+	if err := cli.ContainerRemove(context.Background(), cid, containerRemoveOpts); err != nil {
+		if _, ok := err.(errdefs.ErrNotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (DockerExecutor) Ping(ctx context.Context) error {
+	cli, err := dockerClient()
+	if err != nil {
+		return err
+	}
+	_, err = cli.Ping(ctx)
+	return err
+}
@@ -11,10 +11,6 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/errdefs"
 )
 
 var logger = logs.Get()
@@ -29,7 +25,13 @@ type StartedTask struct {
 	StartedAt *time.Time `json:"startedAt"`
 	Timeout   int        `json:"timeout"`
 
-	PauseOnFinish bool `json:"pauseOnFinish"` // 该步骤结束时暂停容器
+	PauseOnFinish  bool `json:"pauseOnFinish"`  // 该步骤结束时暂停容器
+	RemoveOnFinish bool `json:"removeOnFinish"` // 该步骤结束时删除容器(步骤使用独立的容器执行)
+
+	// 步骤执行成功后打包 CodeDir 下的 artifacts 及 cache 文件到步骤目录
+	CodeDir       string   `json:"codeDir"`
+	ArtifactPaths []string `json:"artifactPaths,omitempty"`
+	CachePaths    []string `json:"cachePaths,omitempty"`
 
 	containerInfoLock sync.RWMutex `json:"-"`
 }
@@ -50,32 +52,15 @@ func LoadStartedTask(envId string, taskId string, step int) (*StartedTask, error
 }
 
 func (task *StartedTask) Cancel() error {
-	cli, err := client.NewClientWithOpts()
-	cli.NegotiateAPIVersion(context.Background())
-	if err != nil {
-		logger.Warnf("unable to create docker client, error: %v", err)
-		return err
-	}
-
-	containerRemoveOpts := types.ContainerRemoveOptions{
-		RemoveVolumes: true,
-		Force:         true,
-	}
-	if err := cli.ContainerRemove(context.Background(), task.ContainerId, containerRemoveOpts); err != nil {
-		if _, ok := err.(errdefs.ErrNotFound); ok {
-			return nil
-		}
-		return err
-	}
-	return nil
+	return GetExecutor().Remove(task.ContainerId)
 }
 
-func (task *StartedTask) Status() (info types.ContainerExecInspect, err error) {
+func (task *StartedTask) Status() (info ExecInfo, err error) {
 	if task.hasContainerInfo() {
 		return task.readContainerInfo()
 	}
 
-	info, err = Executor{}.GetExecInfo(task.ExecId)
+	info, err = GetExecutor().GetExecInfo(task.ExecId)
 	if err != nil {
 		return info, err
 	}
@@ -90,7 +75,7 @@ func (task *StartedTask) containerInfoPath() string {
 	return filepath.Join(task.TaskDir(), TaskContainerInfoFileName)
 }
 
-func (task *StartedTask) writeContainerInfo(info *types.ContainerExecInspect) error {
+func (task *StartedTask) writeContainerInfo(info *ExecInfo) error {
 	task.containerInfoLock.Lock()
 	defer task.containerInfoLock.Unlock()
 
@@ -116,7 +101,7 @@ func (task *StartedTask) hasContainerInfo() bool {
 	return true
 }
 
-func (task *StartedTask) readContainerInfo() (info types.ContainerExecInspect, err error) {
+func (task *StartedTask) readContainerInfo() (info ExecInfo, err error) {
 	task.containerInfoLock.RLock()
 	defer task.containerInfoLock.RUnlock()
 
@@ -130,6 +115,37 @@ func (task *StartedTask) readContainerInfo() (info types.ContainerExecInspect, e
 	return info, err
 }
 
+// packStepFiles 打包步骤声明的 artifacts 及 cache，打包失败只记录日志，不影响步骤结果
+func (task *StartedTask) packStepFiles() {
+	logger := logger.WithField("taskId", task.TaskId)
+	if len(task.ArtifactPaths) == 0 && len(task.CachePaths) == 0 {
+		return
+	}
+
+	// 从 workspace 开始检查路径中的符号链接，代码工作目录本身也可能是符号链接
+	workspace := GetTaskWorkspace(task.EnvId, task.TaskId)
+	codeDir, err := filepath.Rel(workspace, task.CodeDir)
+	if err != nil {
+		logger.Warnf("pack step files error: %v", err)
+		return
+	}
+	for name, paths := range map[string][]string{
+		StepArtifactsFile: task.ArtifactPaths,
+		StepCacheFile:     task.CachePaths,
+	} {
+		if len(paths) == 0 {
+			continue
+		}
+		maxSize := MaxStepArtifactsSize
+		if name == StepCacheFile {
+			maxSize = MaxStepCacheSize
+		}
+		if err := PackFiles(workspace, codeDir, paths, filepath.Join(task.TaskDir(), name), maxSize); err != nil {
+			logger.Warnf("pack %s error: %v", name, err)
+		}
+	}
+}
+
 // Wait 等待任务结束返回退出码，若超时返回 error=context.DeadlineExceeded
 // 如果等待到任务结束则会将容器状态信息写入到文件，并判断是否需要暂停容器
 func (task *StartedTask) Wait(ctx context.Context) (int64, error) {
@@ -145,14 +161,14 @@ func (task *StartedTask) Wait(ctx context.Context) (int64, error) {
 	}
 
 	var (
-		info types.ContainerExecInspect
+		info ExecInfo
 		err  error
 	)
 	if task.StartedAt != nil && task.Timeout > 0 {
 		deadline := task.StartedAt.Add(time.Duration(task.Timeout) * time.Second)
-		info, err = Executor{}.WaitCommandWithDeadline(ctx, task.ExecId, deadline)
+		info, err = WaitCommandWithDeadline(ctx, task.ExecId, deadline)
 	} else {
-		info, err = Executor{}.WaitCommand(ctx, task.ExecId)
+		info, err = GetExecutor().WaitCommand(ctx, task.ExecId)
 	}
 
 	if err != nil {
@@ -165,14 +181,25 @@ func (task *StartedTask) Wait(ctx context.Context) (int64, error) {
 		// 调用 Status() 获取一次任务最新状态，并保存状态到文件
 		if info, err = task.Status(); err != nil {
 			logger.Warnf("get task status error: %v", err)
-		} else if err := task.writeContainerInfo(&info); err != nil {
-			logger.Warnf("write container info error: %v", err)
+		} else {
+			// 打包需要在写入状态文件之前完成，portal 获取到步骤结束状态后即会拉取打包文件
+			if info.ExitCode == 0 {
+				task.packStepFiles()
+			}
+			if err := task.writeContainerInfo(&info); err != nil {
+				logger.Warnf("write container info error: %v", err)
+			}
 		}
 
-		// 暂停容器
-		if task.PauseOnFinish {
+		if task.RemoveOnFinish {
+			logger.Debugf("remove container %s", info.ContainerID)
+			if err := GetExecutor().Remove(task.ContainerId); err != nil {
+				logger.Warn(err)
+			}
+		} else if task.PauseOnFinish {
+			// 暂停容器
 			logger.Debugf("pause container %s", info.ContainerID)
-			if err := (Executor{}).Pause(info.ContainerID); err != nil {
+			if err := GetExecutor().Pause(info.ContainerID); err != nil {
 				logger.Warn(err)
 			}
 		}
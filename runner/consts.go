@@ -25,6 +25,7 @@ const (
 	ContainerAssetsDir       = "/cloudiac/assets"                  // 挂载依赖资源，如 terraform.py 等(己打包到 worker 镜像)
 	ContainerPluginPath      = "/cloudiac/terraform/plugins"       // 预置 providers 目录(己打包到镜像)
 	ContainerPluginCachePath = "/cloudiac/terraform/plugins-cache" // terraform plugins 缓存目录
+	ContainerStatePath       = "/cloudiac/states"                  // local backend 的 state 存储目录
 )
 
 const (
@@ -34,12 +35,20 @@ const (
 	TaskInfoFileName          = "info.json"
 	TaskContainerInfoFileName = "container.json"
 
+	StepArtifactsFile = "artifacts.tar.gz" // 步骤声明的 artifacts 打包文件，保存在步骤目录下
+	StepCacheFile     = "cache.tar.gz"     // 步骤声明的 cache 打包文件，保存在步骤目录下
+
+	MaxStepCacheSize     = 32 * 1024 * 1024  // 步骤 cache 打包文件的最大大小，cache 内容会随任务请求发送到 runner
+	MaxStepArtifactsSize = 128 * 1024 * 1024 // 步骤 artifacts 打包文件的最大大小，打包及上传时内容会保存在内存中
+
 	CloudIacTfFile   = "_cloudiac.tf"
 	CloudIacPlayVars = "_cloudiac_play_vars.yml"
 
 	TFStateJsonFile  = "tfstate.json"
 	TFPlanJsonFile   = "tfplan.json"
 	TFProviderSchema = "tfproviderschema.json"
+	TFStateFile      = "terraform.tfstate"      // terraform state pull 导出的 state 文件
+	TFStatePushFile  = "_cloudiac_push.tfstate" // 待恢复(state push)的 state 文件
 
 	AnsibleStateAnalysisName = "terraform.py"
 
@@ -0,0 +1,163 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloudiac/common"
+	"cloudiac/configs"
+	"cloudiac/utils"
+)
+
+const (
+	ExecutorDocker     = "docker"
+	ExecutorKubernetes = "kubernetes"
+)
+
+// Executor 任务执行器，负责启动任务容器并在容器中执行步骤命令。
+// 任务的所有步骤在同一个容器中执行，容器启动后通过 RunCommand 执行每个步骤的命令
+type Executor interface {
+	// Start 启动任务容器，返回容器 id
+	Start(spec ContainerSpec) (cid string, err error)
+	// RunCommand 在容器中异步执行命令，返回 execId，之后通过 GetExecInfo/WaitCommand 获取执行结果
+	RunCommand(cid string, command []string) (execId string, err error)
+	GetExecInfo(execId string) (ExecInfo, error)
+	// WaitCommand 等待命令执行结束
+	WaitCommand(ctx context.Context, execId string) (ExecInfo, error)
+	StopCommand(execId string) error
+
+	// IsPaused、Pause、Unpause 用于在步骤等待审批时暂停容器，执行器不支持暂停时 Pause 直接返回
+	IsPaused(cid string) (bool, error)
+	Pause(cid string) error
+	Unpause(cid string) error
+
+	// Kill 停止容器(容器的删除由 AutoRemove 配置控制)
+	Kill(cid string) error
+	// Remove 强制删除容器
+	Remove(cid string) error
+
+	// Ping 检查执行器是否可用(docker daemon、kubernetes api server 是否可以访问)
+	Ping(ctx context.Context) error
+}
+
+// ContainerSpec 任务容器配置
+type ContainerSpec struct {
+	Image      string
+	Name       string
+	Env        []string
+	Timeout    int
+	PrivateKey string
+
+	IacTool          string
+	TerraformVersion string
+	Commands         []string
+	HostWorkdir      string // 宿主机目录
+	Workdir          string // 容器目录
+	AutoRemove       bool   // 开启容器的自动删除？
+	StateHostDir     string // local backend 的 state 存储目录(宿主机)
+
+	Limits common.ContainerLimits // 资源限制及隔离配置
+}
+
+// ExecInfo 命令执行信息，字段名与 docker ContainerExecInspect 保持一致，以兼容己保存的执行信息文件
+type ExecInfo struct {
+	ExecID      string
+	ContainerID string
+	Running     bool
+	ExitCode    int
+	Pid         int
+}
+
+// Mount 任务容器的目录挂载，Source 为 runner 所在主机(或 runner 容器)中的路径
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// containerMounts 生成任务容器需要挂载的目录
+func (spec ContainerSpec) containerMounts() []Mount {
+	conf := configs.Get()
+	mounts := []Mount{
+		{Source: spec.HostWorkdir, Target: ContainerWorkspace},
+		{Source: conf.Runner.AbsPluginCachePath(), Target: ContainerPluginCachePath},
+	}
+
+	// assets_path 配置为空则表示直接使用 worker 容器中打包的 assets。
+	// 在 runner 容器化部署时运行 runner 的宿主机(docker host)并没有 assets 目录，
+	// 如果配置了 assets 路径，进行 bind mount 时会因为源目录不存在而报错。
+	if conf.Runner.AssetsPath != "" {
+		mounts = append(mounts,
+			Mount{Source: conf.Runner.AbsAssetsPath(), Target: ContainerAssetsDir, ReadOnly: true},
+			// providers 需要挂载到指定目录才能被 terraform 查找到，所以单独做一次挂载
+			Mount{Source: conf.Runner.ProviderPath(), Target: ContainerPluginPath, ReadOnly: true},
+		)
+	}
+
+	if spec.StateHostDir != "" {
+		mounts = append(mounts, Mount{Source: spec.StateHostDir, Target: ContainerStatePath})
+	}
+
+	// 内置 tf 版本列表中无该版本，我们挂载缓存目录到容器，下载后会保存到宿主机，下次可以直接使用。
+	// 注意，该方案有个问题：客户无法自定义镜像预先安装需要的 terraform 版本，
+	// 因为判断版本不在 TerraformVersions 列表中就会挂载目录，客户自定义镜像安装的版本会被覆盖
+	//（考虑把版本列表写到配置文件？）
+	// opentofu 没有内置版本，总是挂载 tofuenv 的缓存目录
+	if spec.IacTool == common.IacToolOpenTofu {
+		mounts = append(mounts, Mount{Source: conf.Runner.AbsTofuenvVersionsCachePath(), Target: "/root/.tofuenv/versions"})
+	} else if !utils.StrInArray(spec.TerraformVersion, common.TerraformVersions...) {
+		mounts = append(mounts, Mount{Source: conf.Runner.AbsTfenvVersionsCachePath(), Target: "/root/.tfenv/versions"})
+	}
+	return mounts
+}
+
+var (
+	executor     Executor
+	executorOnce sync.Once
+)
+
+// GetExecutor 返回 runner 配置的任务执行器
+func GetExecutor() Executor {
+	executorOnce.Do(func() {
+		var err error
+		executor, err = newExecutor(configs.Get().Runner)
+		if err != nil {
+			logger.Fatalf("create executor: %v", err)
+		}
+	})
+	return executor
+}
+
+func newExecutor(conf configs.RunnerConfig) (Executor, error) {
+	switch conf.Executor {
+	case "", ExecutorDocker:
+		return DockerExecutor{}, nil
+	case ExecutorKubernetes:
+		return NewKubernetesExecutor(conf.Kubernetes)
+	default:
+		return nil, fmt.Errorf("unknown executor '%s'", conf.Executor)
+	}
+}
+
+// WaitCommandWithDeadline 等待命令执行结束，超过 deadline 后停止命令并返回 context.DeadlineExceeded
+func WaitCommandWithDeadline(ctx context.Context, execId string, deadline time.Time) (execInfo ExecInfo, err error) {
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	logger.Debugf("wait exec %s, deadline: %s", execId, deadline.Format(time.RFC3339))
+	if execInfo, err = GetExecutor().WaitCommand(ctx, execId); err != nil {
+		if err == context.DeadlineExceeded {
+			if err := GetExecutor().StopCommand(execId); err != nil {
+				logger.WithField("cid", execInfo.ContainerID).Errorf("stop command error: %v", err)
+			}
+		}
+		return execInfo, err
+	}
+
+	return execInfo, err
+}
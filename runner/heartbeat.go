@@ -0,0 +1,61 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloudiac/common"
+	"cloudiac/configs"
+)
+
+// 检查执行器可用性的超时时间
+const executorPingTimeout = time.Second * 5
+
+// GetRunnerStatus 获取 runner 当前的容量及健康状态
+func GetRunnerStatus() RunnerStatus {
+	conf := configs.Get().Runner
+	status := RunnerStatus{
+		Version:  common.VERSION,
+		MaxTasks: conf.MaxTasks,
+		CPUs:     runtime.NumCPU(),
+		LoadAvg:  loadAvg(),
+	}
+
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(conf.AbsStoragePath(), &st); err != nil {
+		logger.Warnf("statfs %s: %v", conf.AbsStoragePath(), err)
+	} else {
+		status.DiskTotal = uint64(st.Blocks) * uint64(st.Bsize)
+		status.DiskFree = uint64(st.Bavail) * uint64(st.Bsize)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), executorPingTimeout)
+	defer cancel()
+	if err := GetExecutor().Ping(ctx); err != nil {
+		status.ExecutorError = err.Error()
+	} else {
+		status.ExecutorHealthy = true
+	}
+	return status
+}
+
+// loadAvg 读取 /proc/loadavg 中最近 1 分钟的平均负载，获取失败返回 0
+func loadAvg() float64 {
+	content, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return 0
+	}
+	load, _ := strconv.ParseFloat(fields[0], 64)
+	return load
+}
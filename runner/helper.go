@@ -76,6 +76,17 @@ func GetTaskWorkspace(envId string, taskId string) string {
 	return filepath.Join(conf.Runner.AbsStoragePath(), envId, taskId)
 }
 
+// ShellQuote 使用单引号转义 shell 参数
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// GetLocalStateDir local backend 的 state 存储目录
+func GetLocalStateDir() string {
+	conf := configs.Get()
+	return filepath.Join(conf.Runner.AbsStoragePath(), ".states")
+}
+
 func GetTaskDir(envId string, taskId string, step int) string {
 	return filepath.Join(GetTaskWorkspace(envId, taskId), GetTaskDirName(step))
 }
@@ -92,34 +103,6 @@ func FetchTaskLog(envId string, taskId string, step int) ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
-func FetchStateJson(envId string, taskId string) ([]byte, error) {
-	path := filepath.Join(GetTaskWorkspace(envId, taskId), TFStateJsonFile)
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return content, nil
-}
-
-func FetchProviderJson(envId string, taskId string) ([]byte, error) {
-	path := filepath.Join(GetTaskWorkspace(envId, taskId), TFProviderSchema)
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	providerSchemaContent, err := BuildProviderSensitiveAttrMap(content)
-	if err != nil {
-		return nil, err
-	}
-	return providerSchemaContent, nil
-}
-
 func BuildProviderSensitiveAttrMap(body []byte) ([]byte, error) {
 	providerMeta := &ProviderMeta{}
 	err := json.Unmarshal(body, providerMeta)
@@ -148,27 +131,3 @@ func BuildProviderSensitiveAttrMap(body []byte) ([]byte, error) {
 	}
 	return providerMap, nil
 }
-
-func FetchPlanJson(envId string, taskId string) ([]byte, error) {
-	path := filepath.Join(GetTaskWorkspace(envId, taskId), TFPlanJsonFile)
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return content, nil
-}
-
-func FetchJson(envId string, taskId string, jsonFile string) ([]byte, error) {
-	path := filepath.Join(GetTaskWorkspace(envId, taskId), jsonFile)
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return content, nil
-}
@@ -0,0 +1,46 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"cloudiac/common"
+	"cloudiac/portal/consts"
+)
+
+// iacTool 步骤命令模板中使用的 IaC 工具信息
+type iacTool struct {
+	Command        string // 执行命令
+	VersionManager string // 版本管理工具
+	VersionEnv     string // 版本管理工具读取的版本号环境变量
+	DefaultVersion string // 未指定版本时使用的版本
+}
+
+var iacTools = map[string]iacTool{
+	common.IacToolTerraform: {
+		Command:        "terraform",
+		VersionManager: "tfenv",
+		VersionEnv:     "TFENV_TERRAFORM_VERSION",
+		DefaultVersion: consts.DefaultTerraformVersion,
+	},
+	common.IacToolOpenTofu: {
+		Command:        "tofu",
+		VersionManager: "tofuenv",
+		VersionEnv:     "TOFUENV_TOFU_VERSION",
+		DefaultVersion: consts.DefaultOpenTofuVersion,
+	},
+	// terragrunt 调用 terraform 执行，版本号为 terraform 的版本
+	common.IacToolTerragrunt: {
+		Command:        "terragrunt",
+		VersionManager: "tfenv",
+		VersionEnv:     "TFENV_TERRAFORM_VERSION",
+		DefaultVersion: consts.DefaultTerraformVersion,
+	},
+}
+
+// getIacTool 返回 IaC 工具信息，未知或为空时使用 terraform
+func getIacTool(name string) iacTool {
+	if tool, ok := iacTools[name]; ok {
+		return tool
+	}
+	return iacTools[common.IacToolTerraform]
+}
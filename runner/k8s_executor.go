@@ -0,0 +1,409 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"cloudiac/common"
+	"cloudiac/configs"
+)
+
+const (
+	k8sContainerName = "worker"
+	k8sTaskLabel     = "cloudiac.io/task"
+	k8sDataVolume    = "data"
+	k8sTmpVolume     = "tmp"
+
+	// 步骤命令在 pod 中后台执行，执行进程的 pid 及退出码保存到该目录下
+	k8sExecDir = "/tmp/cloudiac-exec"
+)
+
+var (
+	k8sPodPollInterval = time.Second
+	k8sPodStartTimeout = 5 * time.Minute
+)
+
+// KubernetesExecutor 基于 kubernetes 的任务执行器，每个任务启动一个 pod，步骤通过 exec 在 pod 中执行。
+// kubernetes 不支持暂停 pod，所以步骤等待审批时 pod 保持运行
+type KubernetesExecutor struct {
+	conf   configs.KubernetesExecutorConfig
+	client kubernetes.Interface
+	// exec 在 pod 中同步执行命令并返回标准输出
+	exec func(pod string, command []string) (string, error)
+}
+
+func NewKubernetesExecutor(conf configs.KubernetesExecutorConfig) (*KubernetesExecutor, error) {
+	var (
+		restConf *rest.Config
+		err      error
+	)
+	if conf.Kubeconfig != "" {
+		restConf, err = clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	} else {
+		restConf, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "load kubernetes config")
+	}
+
+	client, err := kubernetes.NewForConfig(restConf)
+	if err != nil {
+		return nil, errors.Wrap(err, "create kubernetes client")
+	}
+
+	e := newKubernetesExecutor(conf, client)
+	e.exec = func(pod string, command []string) (string, error) {
+		return k8sPodExec(restConf, client, e.conf.Namespace, pod, command)
+	}
+	return e, nil
+}
+
+func newKubernetesExecutor(conf configs.KubernetesExecutorConfig, client kubernetes.Interface) *KubernetesExecutor {
+	if conf.Namespace == "" {
+		conf.Namespace = metav1.NamespaceDefault
+	}
+	return &KubernetesExecutor{conf: conf, client: client}
+}
+
+func k8sPodExec(restConf *rest.Config, client kubernetes.Interface, namespace, pod string, command []string) (string, error) {
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(namespace).Name(pod).SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: k8sContainerName,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(restConf, "POST", req.URL())
+	if err != nil {
+		return "", errors.Wrap(err, "create pod exec")
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := exec.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return "", errors.Wrapf(err, "pod exec: %s", stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+var invalidPodNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// k8sPodName 将任务 id 转为合法的 pod 名称
+func k8sPodName(name string) string {
+	name = invalidPodNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+// podVolumes 将任务容器的目录挂载转为 pod 的 volume 配置。
+// 配置了 pvc 时通过 subPath 挂载 pvc 中的对应目录，不在 pvc 中的目录会被忽略；未配置 pvc 时使用 hostPath 挂载
+func (e *KubernetesExecutor) podVolumes(mounts []Mount) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := make([]corev1.Volume, 0)
+	volumeMounts := make([]corev1.VolumeMount, 0)
+
+	if e.conf.VolumeClaim != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: k8sDataVolume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: e.conf.VolumeClaim},
+			},
+		})
+	}
+
+	for i, m := range mounts {
+		if e.conf.VolumeClaim == "" {
+			name := fmt.Sprintf("vol%d", i)
+			volumes = append(volumes, corev1.Volume{
+				Name: name,
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: m.Source},
+				},
+			})
+			volumeMounts = append(volumeMounts, corev1.VolumeMount{
+				Name: name, MountPath: m.Target, ReadOnly: m.ReadOnly,
+			})
+			continue
+		}
+
+		subPath, err := filepath.Rel(e.conf.VolumeMountPath, m.Source)
+		if err != nil || subPath == ".." || strings.HasPrefix(subPath, "../") {
+			logger.Warnf("mount source '%s' is not in volume claim '%s', ignored", m.Source, e.conf.VolumeClaim)
+			continue
+		}
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name: k8sDataVolume, MountPath: m.Target, SubPath: subPath, ReadOnly: m.ReadOnly,
+		})
+	}
+	return volumes, volumeMounts
+}
+
+// applyLimits 设置 pod 的资源限制及隔离配置，进程数限制需要在 kubelet 配置(podPidsLimit)，这里不做处理
+func (e *KubernetesExecutor) applyLimits(pod *corev1.Pod, limits common.ContainerLimits) {
+	c := &pod.Spec.Containers[0]
+	resources := corev1.ResourceList{}
+	if limits.CPUs > 0 {
+		resources[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(limits.CPUs*1000), resource.DecimalSI)
+	}
+	if limits.Memory > 0 {
+		resources[corev1.ResourceMemory] = *resource.NewQuantity(limits.Memory*1024*1024, resource.BinarySI)
+	}
+	if len(resources) > 0 {
+		c.Resources.Limits = resources
+	}
+
+	if limits.IsReadonlyRootfs() {
+		// 只读根文件系统时 /tmp 使用 emptyDir，其他需要写入的目录需要位于挂载目录中
+		readonly := true
+		c.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: &readonly}
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name:         k8sTmpVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: k8sTmpVolume, MountPath: "/tmp"})
+	}
+}
+
+// createDenyAllNetworkPolicy 创建禁止 pod 所有出入流量的 NetworkPolicy，需要集群网络插件支持 NetworkPolicy
+func (e *KubernetesExecutor) createDenyAllNetworkPolicy(podName string) error {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: e.conf.Namespace,
+			Labels:    map[string]string{k8sTaskLabel: podName},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{k8sTaskLabel: podName}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+	_, err := e.client.NetworkingV1().NetworkPolicies(e.conf.Namespace).
+		Create(context.Background(), policy, metav1.CreateOptions{})
+	return err
+}
+
+func (e *KubernetesExecutor) Start(spec ContainerSpec) (string, error) {
+	logger := logger.WithField("taskId", filepath.Base(spec.HostWorkdir))
+
+	env := make([]corev1.EnvVar, 0, len(spec.Env))
+	for _, kv := range spec.Env {
+		if i := strings.Index(kv, "="); i > 0 {
+			env = append(env, corev1.EnvVar{Name: kv[:i], Value: kv[i+1:]})
+		}
+	}
+	volumes, volumeMounts := e.podVolumes(spec.containerMounts())
+
+	podName := k8sPodName(spec.Name)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: e.conf.Namespace,
+			Labels:    map[string]string{k8sTaskLabel: podName},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: e.conf.ServiceAccount,
+			Containers: []corev1.Container{{
+				Name:            k8sContainerName,
+				Image:           spec.Image,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         spec.Commands,
+				WorkingDir:      spec.Workdir,
+				Env:             env,
+				VolumeMounts:    volumeMounts,
+				Stdin:           true,
+				TTY:             true,
+			}},
+			Volumes: volumes,
+		},
+	}
+
+	e.applyLimits(pod, spec.Limits)
+	if spec.Limits.IsNetworkDisabled() {
+		// 先创建 NetworkPolicy 再创建 pod，保证 pod 启动后网络即被禁用
+		if err := e.createDenyAllNetworkPolicy(podName); err != nil {
+			return "", errors.Wrap(err, "create network policy")
+		}
+	}
+
+	if _, err := e.client.CoreV1().Pods(e.conf.Namespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		logger.Errorf("create pod err: %v", err)
+		e.removeFailedPod(podName)
+		return "", errors.Wrap(err, "create pod")
+	}
+	logger.Infof("pod: %s/%s", e.conf.Namespace, podName)
+
+	// 等待 pod 运行后才能执行步骤命令，启动失败时删除 pod 及 NetworkPolicy
+	if err := e.waitPodRunning(podName); err != nil {
+		e.removeFailedPod(podName)
+		return "", err
+	}
+	return podName, nil
+}
+
+func (e *KubernetesExecutor) waitPodRunning(podName string) error {
+	pods := e.client.CoreV1().Pods(e.conf.Namespace)
+	deadline := time.Now().Add(k8sPodStartTimeout)
+	for {
+		p, err := pods.Get(context.Background(), podName, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "get pod")
+		}
+		switch p.Status.Phase {
+		case corev1.PodRunning:
+			return nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return fmt.Errorf("pod %s exited, phase: %s", podName, p.Status.Phase)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait pod %s running timeout", podName)
+		}
+		time.Sleep(k8sPodPollInterval)
+	}
+}
+
+func (e *KubernetesExecutor) removeFailedPod(podName string) {
+	if err := e.Remove(podName); err != nil {
+		logger.WithField("pod", podName).Warnf("remove failed pod: %v", err)
+	}
+}
+
+func parseK8sExecId(execId string) (pod string, id string, err error) {
+	parts := strings.SplitN(execId, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid exec id '%s'", execId)
+	}
+	return parts[0], parts[1], nil
+}
+
+func k8sExecFile(id string, ext string) string {
+	return path.Join(k8sExecDir, id+ext)
+}
+
+// RunCommand 在 pod 中后台执行命令，命令结束后退出码写入文件，execId 格式为 "pod/id"
+func (e *KubernetesExecutor) RunCommand(cid string, command []string) (execId string, err error) {
+	id := xid.New().String()
+	args := make([]string, 0, len(command))
+	for _, c := range command {
+		args = append(args, ShellQuote(c))
+	}
+	script := fmt.Sprintf("mkdir -p %s && (%s & echo $! >%s; wait $!; echo $? >%s) >/dev/null 2>&1 &",
+		k8sExecDir, strings.Join(args, " "), k8sExecFile(id, ".pid"), k8sExecFile(id, ".exit"))
+	if _, err := e.exec(cid, []string{"/bin/sh", "-c", script}); err != nil {
+		return "", errors.Wrap(err, "pod exec")
+	}
+	return cid + "/" + id, nil
+}
+
+func (e *KubernetesExecutor) GetExecInfo(execId string) (execInfo ExecInfo, err error) {
+	pod, id, err := parseK8sExecId(execId)
+	if err != nil {
+		return execInfo, err
+	}
+
+	script := fmt.Sprintf("cat %s 2>/dev/null; echo; cat %s 2>/dev/null; exit 0",
+		k8sExecFile(id, ".pid"), k8sExecFile(id, ".exit"))
+	out, err := e.exec(pod, []string{"/bin/sh", "-c", script})
+	if err != nil {
+		return execInfo, errors.Wrap(err, "pod exec")
+	}
+
+	execInfo = ExecInfo{ExecID: execId, ContainerID: pod, Running: true}
+	lines := strings.SplitN(out, "\n", 2)
+	execInfo.Pid, _ = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		if execInfo.ExitCode, err = strconv.Atoi(strings.TrimSpace(lines[1])); err != nil {
+			return execInfo, errors.Wrap(err, "parse exit code")
+		}
+		execInfo.Running = false
+	}
+	return execInfo, nil
+}
+
+func (e *KubernetesExecutor) WaitCommand(ctx context.Context, execId string) (execInfo ExecInfo, err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return execInfo, ctx.Err()
+		default:
+		}
+
+		if execInfo, err = e.GetExecInfo(execId); err != nil {
+			return execInfo, err
+		}
+		if !execInfo.Running {
+			return execInfo, nil
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (e *KubernetesExecutor) StopCommand(execId string) error {
+	pod, id, err := parseK8sExecId(execId)
+	if err != nil {
+		return err
+	}
+	if _, err := e.exec(pod, []string{"/bin/sh", "-c", fmt.Sprintf("kill -9 $(cat %s)", k8sExecFile(id, ".pid"))}); err != nil {
+		return errors.Wrap(err, "kill process")
+	}
+	return nil
+}
+
+func (e *KubernetesExecutor) IsPaused(cid string) (bool, error) {
+	return false, nil
+}
+
+func (e *KubernetesExecutor) Pause(cid string) error {
+	return nil
+}
+
+func (e *KubernetesExecutor) Unpause(cid string) error {
+	return nil
+}
+
+// Kill 删除任务 pod，pod 无法像容器一样停止后保留
+func (e *KubernetesExecutor) Kill(cid string) error {
+	return e.Remove(cid)
+}
+
+func (e *KubernetesExecutor) Remove(cid string) error {
+	grace := int64(0)
+	err := e.client.CoreV1().Pods(e.conf.Namespace).Delete(context.Background(), cid,
+		metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete pod %s", cid)
+	}
+
+	err = e.client.NetworkingV1().NetworkPolicies(e.conf.Namespace).
+		Delete(context.Background(), cid, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete network policy %s", cid)
+	}
+	return nil
+}
+
+func (e *KubernetesExecutor) Ping(ctx context.Context) error {
+	_, err := e.client.CoreV1().Pods(e.conf.Namespace).List(ctx, metav1.ListOptions{Limit: 1})
+	return err
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"cloudiac/common"
+	"cloudiac/configs"
+)
+
+func initTestRunnerConfig(t *testing.T, storagePath string) {
+	content := fmt.Sprintf(`secretKey: "test"
+runner:
+  default_image: "cloudiac/ct-worker:latest"
+  storage_path: "%s"
+  plugin_cache_path: "%s"
+`, storagePath, filepath.Join(storagePath, "plugin-cache"))
+	file := filepath.Join(t.TempDir(), "config-runner.yml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.ParseRunnerConfig(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKubernetesExecutorStart(t *testing.T) {
+	dataPath := t.TempDir()
+	initTestRunnerConfig(t, dataPath)
+	pollInterval := k8sPodPollInterval
+	defer func() { k8sPodPollInterval = pollInterval }()
+	k8sPodPollInterval = time.Millisecond
+
+	client := fake.NewSimpleClientset()
+	e := newKubernetesExecutor(configs.KubernetesExecutorConfig{
+		Namespace:       "iac",
+		VolumeClaim:     "runner-data",
+		VolumeMountPath: dataPath,
+	}, client)
+
+	// fake clientset 不会运行 pod，创建后手动将 pod 状态设置为 running
+	go func() {
+		pods := client.CoreV1().Pods("iac")
+		for {
+			pod, err := pods.Get(context.Background(), "run-c1abc", metav1.GetOptions{})
+			if err == nil {
+				pod.Status.Phase = corev1.PodRunning
+				_, _ = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	disabled := true
+	cid, err := e.Start(ContainerSpec{
+		Image:            "cloudiac/ct-worker:latest",
+		Name:             "run-C1abc",
+		Env:              []string{"A=1", "B=x=y"},
+		Commands:         []string{"/bin/bash"},
+		HostWorkdir:      filepath.Join(dataPath, "env-1", "run-c1abc"),
+		Workdir:          ContainerWorkspace,
+		TerraformVersion: common.TerraformVersions[0],
+		Limits:           common.ContainerLimits{CPUs: 1.5, Memory: 512, NetworkDisabled: &disabled},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "run-c1abc", cid)
+
+	pod, err := client.CoreV1().Pods("iac").Get(context.Background(), cid, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "runner-data", pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+
+	c := pod.Spec.Containers[0]
+	assert.Equal(t, []corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "x=y"}}, c.Env)
+	subPaths := map[string]string{}
+	for _, m := range c.VolumeMounts {
+		subPaths[m.MountPath] = m.SubPath
+	}
+	assert.Equal(t, map[string]string{
+		ContainerWorkspace:       "env-1/run-c1abc",
+		ContainerPluginCachePath: "plugin-cache",
+	}, subPaths)
+
+	assert.Equal(t, "1500m", c.Resources.Limits.Cpu().String())
+	assert.Equal(t, "512Mi", c.Resources.Limits.Memory().String())
+	_, err = client.NetworkingV1().NetworkPolicies("iac").Get(context.Background(), cid, metav1.GetOptions{})
+	assert.NoError(t, err, "network policy created")
+
+	assert.NoError(t, e.Remove(cid))
+	assert.NoError(t, e.Remove(cid), "remove not exists pod")
+
+	// pod 启动失败时删除 pod 及 NetworkPolicy
+	go func() {
+		pods := client.CoreV1().Pods("iac")
+		for {
+			pod, err := pods.Get(context.Background(), "run-c2abc", metav1.GetOptions{})
+			if err == nil {
+				pod.Status.Phase = corev1.PodFailed
+				_, _ = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	cid, err = e.Start(ContainerSpec{
+		Image:       "cloudiac/ct-worker:latest",
+		Name:        "run-c2abc",
+		HostWorkdir: filepath.Join(dataPath, "env-1", "run-c2abc"),
+		Workdir:     ContainerWorkspace,
+		Limits:      common.ContainerLimits{NetworkDisabled: &disabled},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "", cid)
+	_, err = client.CoreV1().Pods("iac").Get(context.Background(), "run-c2abc", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err), "failed pod removed")
+	_, err = client.NetworkingV1().NetworkPolicies("iac").Get(context.Background(), "run-c2abc", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err), "network policy removed")
+}
+
+func TestKubernetesExecutorCommand(t *testing.T) {
+	e := newKubernetesExecutor(configs.KubernetesExecutorConfig{}, fake.NewSimpleClientset())
+
+	// 模拟 pod 中执行命令的 pid 及退出码文件内容
+	var script, pid, exitCode string
+	e.exec = func(pod string, command []string) (string, error) {
+		assert.Equal(t, "run-c1abc", pod)
+		if strings.HasPrefix(command[2], "mkdir") {
+			script = command[2]
+			return "", nil
+		}
+		return pid + "\n" + exitCode, nil
+	}
+
+	execId, err := e.RunCommand("run-c1abc", []string{"/bin/sh", "-c", "echo 'ok' >>log"})
+	assert.NoError(t, err)
+	assert.Contains(t, script, `('/bin/sh' '-c' 'echo '"'"'ok'"'"' >>log' & echo $!`)
+
+	pid = "12"
+	info, err := e.GetExecInfo(execId)
+	assert.NoError(t, err)
+	assert.True(t, info.Running)
+	assert.Equal(t, 12, info.Pid)
+
+	exitCode = "2\n"
+	info, err = e.WaitCommand(context.Background(), execId)
+	assert.NoError(t, err)
+	assert.False(t, info.Running)
+	assert.Equal(t, 2, info.ExitCode)
+	assert.Equal(t, "run-c1abc", info.ContainerID)
+
+	_, err = e.GetExecInfo("invalid")
+	assert.Error(t, err)
+}
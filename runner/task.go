@@ -6,7 +6,6 @@ import (
 	"bytes"
 	"cloudiac/common"
 	"cloudiac/configs"
-	"cloudiac/portal/consts"
 	"cloudiac/utils"
 	"cloudiac/utils/logs"
 	"context"
@@ -14,6 +13,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -27,6 +27,8 @@ type Task struct {
 	logger    logs.Logger
 	config    configs.RunnerConfig
 	workspace string
+
+	removeOnFinish bool // 步骤结束后删除容器(步骤使用独立容器执行时)
 }
 
 func NewTask(req RunTaskReq, logger logs.Logger) *Task {
@@ -38,6 +40,18 @@ func NewTask(req RunTaskReq, logger logs.Logger) *Task {
 }
 
 func (t *Task) Run() (cid string, err error) {
+	if t.req.StepImage != "" {
+		// 步骤配置了镜像时在独立的容器中执行，该容器在步骤结束后删除，返回的仍然是任务的容器 id
+		taskCid := t.req.ContainerId
+		if cid, err = t.start(); err != nil {
+			return taskCid, err
+		}
+		t.req.ContainerId = cid
+		t.req.PauseTask = false
+		t.removeOnFinish = true
+		return taskCid, t.runStep()
+	}
+
 	if t.req.ContainerId == "" {
 		cid, err = t.start()
 		if err != nil {
@@ -56,7 +70,7 @@ func (t *Task) Run() (cid string, err error) {
 
 func (t *Task) start() (cid string, err error) {
 	for _, vars := range []map[string]string{
-		t.req.Env.EnvironmentVars, t.req.Env.TerraformVars, t.req.Env.AnsibleVars} {
+		t.req.Env.EnvironmentVars, t.req.Env.TerraformVars, t.req.Env.AnsibleVars, t.req.SysEnvironments} {
 		if err = t.decryptVariables(vars); err != nil {
 			return "", errors.Wrap(err, "decrypt variables")
 		}
@@ -75,17 +89,31 @@ func (t *Task) start() (cid string, err error) {
 	}
 
 	conf := configs.Get().Runner
-	cmd := Executor{
+	cmd := ContainerSpec{
 		Image:       conf.DefaultImage,
 		Name:        t.req.TaskId,
 		Timeout:     t.req.Timeout,
 		Workdir:     ContainerWorkspace,
 		HostWorkdir: t.workspace,
+		Limits:      t.req.ContainerLimits.Restrict(conf.Limits),
 	}
 
 	if t.req.DockerImage != "" {
 		cmd.Image = t.req.DockerImage
 	}
+	if t.req.StepImage != "" {
+		cmd.Image = t.req.StepImage
+		cmd.Name = fmt.Sprintf("%s-step%d", t.req.TaskId, t.req.Step)
+	}
+
+	if t.req.StateStore.Backend == "local" {
+		// local backend 的 state 文件保存在 runner 的 storage 目录下，需要挂载到容器中
+		cmd.StateHostDir = GetLocalStateDir()
+		stateDir := filepath.Dir(filepath.Join(cmd.StateHostDir, t.req.StateStore.Path))
+		if err = os.MkdirAll(stateDir, 0755); err != nil {
+			return "", errors.Wrap(err, "create state dir")
+		}
+	}
 
 	reserveContainer := conf.ReserveContainer
 	if v, ok := t.req.Env.EnvironmentVars["CLOUDIAC_RESERVER_CONTAINER"]; ok {
@@ -117,11 +145,16 @@ func (t *Task) start() (cid string, err error) {
 	for k, v := range t.req.Env.TerraformVars {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("TF_VAR_%s=%s", k, v))
 	}
+	tool := getIacTool(t.req.Env.IacTool)
 	if t.req.Env.TfVersion == "" {
-		t.req.Env.TfVersion = consts.DefaultTerraformVersion
+		t.req.Env.TfVersion = tool.DefaultVersion
 	}
+	cmd.IacTool = t.req.Env.IacTool
 	cmd.TerraformVersion = t.req.Env.TfVersion
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TFENV_TERRAFORM_VERSION=%s", cmd.TerraformVersion))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", tool.VersionEnv, cmd.TerraformVersion))
+	if t.req.Env.IacTool == common.IacToolTerragrunt {
+		cmd.Env = append(cmd.Env, "TERRAGRUNT_NON_INTERACTIVE=true")
+	}
 
 	// 容器启动后执行 /bin/bash 以保持运行，然后通过 exec 在容器中执行步骤命令
 	cmd.Commands = []string{"/bin/bash"}
@@ -135,7 +168,7 @@ func (t *Task) start() (cid string, err error) {
 	}
 
 	t.logger.Infof("start task step, %s", stepDir)
-	if cid, err = cmd.Start(); err != nil {
+	if cid, err = GetExecutor().Start(cmd); err != nil {
 		return cid, err
 	}
 
@@ -156,6 +189,19 @@ func (t *Task) runStep() (err error) {
 		return errors.Wrap(err, "generate step script")
 	}
 
+	// 删除步骤重试前可能存在的打包文件，然后恢复步骤的缓存
+	stepDir := GetTaskDir(t.req.Env.Id, t.req.TaskId, t.req.Step)
+	for _, name := range []string{StepArtifactsFile, StepCacheFile} {
+		if err := os.Remove(filepath.Join(stepDir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(t.req.CacheContent) > 0 {
+		if err := UnpackFiles(t.req.CacheContent, t.workspace, codeDirName(t.req.Env.Workdir)); err != nil {
+			return errors.Wrap(err, "restore step cache")
+		}
+	}
+
 	containerScriptPath := filepath.Join(t.stepDirName(t.req.Step), TaskScriptName)
 	logPath := filepath.Join(t.stepDirName(t.req.Step), TaskLogName)
 
@@ -167,19 +213,20 @@ func (t *Task) runStep() (err error) {
 		command = fmt.Sprintf("%s >>%s 2>&1", containerScriptPath, logPath)
 	}
 
-	if ok, err := (Executor{}).IsPaused(t.req.ContainerId); err != nil {
+	executor := GetExecutor()
+	if ok, err := executor.IsPaused(t.req.ContainerId); err != nil {
 		return err
 	} else if ok {
 		logger.Debugf("container %s is paused", t.req.ContainerId)
 
 		logger.Debugf("unpause container")
-		if err := (Executor{}).Unpause(t.req.ContainerId); err != nil {
+		if err := executor.Unpause(t.req.ContainerId); err != nil {
 			return err
 		}
 		logger.Debugf("unpause container done")
 	}
 
-	execId, err := (&Executor{}).RunCommand(t.req.ContainerId, t.generateCommand(command))
+	execId, err := executor.RunCommand(t.req.ContainerId, t.generateCommand(command))
 	if err != nil {
 		return err
 	}
@@ -190,28 +237,32 @@ func (t *Task) runStep() (err error) {
 	// 同时 task.Wait() 函数也会在任务结束后暂停容器，两边同时处理保证容器被暂停
 	if t.req.PauseTask {
 		go func() {
-			_, err := (Executor{}).WaitCommand(context.Background(), execId)
+			_, err := executor.WaitCommand(context.Background(), execId)
 			if err != nil {
 				logger.Debugf("container %s: %v", t.req.ContainerId, err)
 				return
 			}
 
 			logger.Debugf("pause container %s", t.req.ContainerId)
-			if err := (&Executor{}).Pause(t.req.ContainerId); err != nil {
+			if err := executor.Pause(t.req.ContainerId); err != nil {
 				logger.Debugf("container %s: %v", t.req.ContainerId, err)
 			}
 		}()
 	}
 
 	infoJson := utils.MustJSON(StartedTask{
-		EnvId:         t.req.Env.Id,
-		TaskId:        t.req.TaskId,
-		Step:          t.req.Step,
-		ContainerId:   t.req.ContainerId,
-		PauseOnFinish: t.req.PauseTask,
-		ExecId:        execId,
-		StartedAt:     &now,
-		Timeout:       t.req.Timeout,
+		EnvId:          t.req.Env.Id,
+		TaskId:         t.req.TaskId,
+		Step:           t.req.Step,
+		ContainerId:    t.req.ContainerId,
+		PauseOnFinish:  t.req.PauseTask,
+		RemoveOnFinish: t.removeOnFinish,
+		CodeDir:        t.codeDir(),
+		ArtifactPaths:  t.req.StepArtifacts,
+		CachePaths:     t.req.StepCachePaths,
+		ExecId:         execId,
+		StartedAt:      &now,
+		Timeout:        t.req.Timeout,
 	})
 	stepInfoFile := filepath.Join(
 		GetTaskDir(t.req.Env.Id, t.req.TaskId, t.req.Step),
@@ -268,11 +319,36 @@ func (t *Task) initWorkspace() (workspace string, err error) {
 
 var iacTerraformTpl = template.Must(template.New("").Parse(` terraform {
   backend "{{.State.Backend}}" {
+{{- if eq .State.Backend "s3"}}
+    bucket = {{printf "%q" .State.Bucket}}
+    key    = {{printf "%q" .State.Path}}
+    region = {{if .State.Region}}{{printf "%q" .State.Region}}{{else}}"us-east-1"{{end}}
+{{- if .State.Endpoint}}
+    endpoint = {{printf "%q" .State.Endpoint}}
+    skip_credentials_validation = true
+    skip_region_validation      = true
+    skip_metadata_api_check     = true
+{{- end}}
+{{- if .State.ForcePathStyle}}
+    force_path_style = true
+{{- end}}
+{{- else if eq .State.Backend "http"}}
+    address = {{printf "%q" .State.Address}}
+{{- if .State.LockAddress}}
+    lock_address = {{printf "%q" .State.LockAddress}}
+{{- end}}
+{{- if .State.UnlockAddress}}
+    unlock_address = {{printf "%q" .State.UnlockAddress}}
+{{- end}}
+{{- else if eq .State.Backend "local"}}
+    path = "{{.LocalStatePath}}"
+{{- else}}
     address = "{{.State.Address}}"
     scheme  = "{{.State.Scheme}}"
     path    = "{{.State.Path}}"
     lock    = true
     gzip    = false
+{{- end}}
   }
 }
 
@@ -293,7 +369,10 @@ func execTpl2File(tpl *template.Template, data interface{}, savePath string) err
 }
 
 func (t *Task) genIacTfFile(workspace string) error {
-	if t.req.StateStore.Address == "" {
+	if t.req.StateStore.Backend == "" {
+		t.req.StateStore.Backend = "consul"
+	}
+	if t.req.StateStore.Backend == "consul" && t.req.StateStore.Address == "" {
 		if os.Getenv("IAC_WORKER_CONSUL") != "" {
 			t.req.StateStore.Address = os.Getenv("IAC_WORKER_CONSUL")
 		} else {
@@ -304,6 +383,7 @@ func (t *Task) genIacTfFile(workspace string) error {
 		"Workspace":      workspace,
 		"PrivateKeyPath": t.up2Workspace("ssh_key"),
 		"State":          t.req.StateStore,
+		"LocalStatePath": filepath.Join(ContainerStatePath, t.req.StateStore.Path),
 	}
 	if err := execTpl2File(iacTerraformTpl, ctx, filepath.Join(workspace, CloudIacTfFile)); err != nil {
 		return err
@@ -356,6 +436,16 @@ func (t *Task) executeTpl(tpl *template.Template, data interface{}) (string, err
 	return buffer.String(), nil
 }
 
+// codeDir 代码工作目录在 runner 本地的路径
+func (t *Task) codeDir() string {
+	return filepath.Join(t.workspace, codeDirName(t.req.Env.Workdir))
+}
+
+// codeDirName 代码工作目录相对于 workspace 的路径
+func codeDirName(workdir string) string {
+	return filepath.Join("code", workdir)
+}
+
 func (t *Task) stepDirName(step int) string {
 	return GetTaskDirName(step)
 }
@@ -383,6 +473,11 @@ func (t *Task) genStepScript() (string, error) {
 		command, err = t.stepCommand()
 	case common.TaskStepCollect:
 		command, err = t.collectCommand()
+	case common.TaskStepTfStateList, common.TaskStepTfStateShow, common.TaskStepTfStateMv,
+		common.TaskStepTfStateRm, common.TaskStepTfImport:
+		command, err = t.stepState()
+	case common.TaskStepTfStatePush:
+		command, err = t.stepStatePush()
 	case common.TaskStepScanInit:
 		command, err = t.stepScanInit()
 	case common.TaskStepRegoParse:
@@ -395,6 +490,7 @@ func (t *Task) genStepScript() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	command = addStepEnvExports(command, t.req.StepEnv)
 
 	stepDir := GetTaskDir(t.req.Env.Id, t.req.TaskId, t.req.Step)
 	if err = os.MkdirAll(stepDir, 0755); err != nil {
@@ -415,6 +511,32 @@ func (t *Task) genStepScript() (string, error) {
 	return scriptPath, nil
 }
 
+// addStepEnvExports 在步骤脚本的 shebang 之后插入步骤环境变量的 export 语句
+func addStepEnvExports(script string, env map[string]string) string {
+	if len(env) == 0 {
+		return script
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	exports := strings.Builder{}
+	for _, k := range keys {
+		exports.WriteString(fmt.Sprintf("export %s=%s\n", k, ShellQuote(env[k])))
+	}
+
+	if strings.HasPrefix(script, "#!") {
+		if i := strings.Index(script, "\n"); i >= 0 {
+			return script[:i+1] + exports.String() + script[i+1:]
+		}
+		return script + "\n" + exports.String()
+	}
+	return exports.String() + script
+}
+
 var checkoutCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
 if [[ ! -e code ]]; then git clone '{{.Req.RepoAddress}}' code || exit $?; fi && \
 cd code && \
@@ -433,9 +555,9 @@ var initCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
 cd 'code/{{.Req.Env.Workdir}}' && \
 ln -sf '{{.IacTfFile}}' . && \
 ln -sf '{{.terraformrc}}' ~/.terraformrc && \
-tfenv install $TFENV_TERRAFORM_VERSION && \
-tfenv use $TFENV_TERRAFORM_VERSION  && \
-terraform init -input=false {{- range $arg := .Req.StepArgs }} {{$arg}}{{ end }}
+{{.Tool.VersionManager}} install ${{.Tool.VersionEnv}} && \
+{{.Tool.VersionManager}} use ${{.Tool.VersionEnv}}  && \
+{{.Tool.Command}} init -input=false {{- range $arg := .Req.StepArgs }} {{$arg}}{{ end }}
 `))
 
 // 将 workspace 根目录下的文件名转为可以在环境的 code/workdir 下访问的相对路径
@@ -456,6 +578,7 @@ func (t *Task) stepInit() (command string, err error) {
 	tfrc := filepath.Join(ContainerAssetsDir, tfrcName)
 	return t.executeTpl(initCommandTpl, map[string]interface{}{
 		"Req":             t.req,
+		"Tool":            getIacTool(t.req.Env.IacTool),
 		"terraformrc":     tfrc,
 		"PluginCachePath": ContainerPluginCachePath,
 		"IacTfFile":       t.up2Workspace(CloudIacTfFile),
@@ -464,15 +587,16 @@ func (t *Task) stepInit() (command string, err error) {
 
 var planCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
 cd 'code/{{.Req.Env.Workdir}}' && \
-terraform plan -input=false -out=_cloudiac.tfplan \
+{{.Tool.Command}} plan -input=false -out=_cloudiac.tfplan \
 {{if .TfVars}}-var-file={{.TfVars}}{{end}} \
 {{ range $arg := .Req.StepArgs }}{{$arg}} {{ end }}&& \
-terraform show -no-color -json _cloudiac.tfplan >{{.TFPlanJsonFilePath}}
+{{.Tool.Command}} show -no-color -json _cloudiac.tfplan >{{.TFPlanJsonFilePath}}
 `))
 
 func (t *Task) stepPlan() (command string, err error) {
 	return t.executeTpl(planCommandTpl, map[string]interface{}{
 		"Req":                t.req,
+		"Tool":               getIacTool(t.req.Env.IacTool),
 		"TfVars":             t.req.Env.TfVarsFile,
 		"TFPlanJsonFilePath": t.up2Workspace(TFPlanJsonFile),
 	})
@@ -481,13 +605,14 @@ func (t *Task) stepPlan() (command string, err error) {
 // 当指定了 plan 文件时不需要也不能传 -var-file 参数
 var applyCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
 cd 'code/{{.Req.Env.Workdir}}' && \
-terraform apply -input=false -auto-approve \
+{{.Tool.Command}} apply -input=false -auto-approve \
 {{ range $arg := .Req.StepArgs}}{{$arg}} {{ end }}_cloudiac.tfplan
 `))
 
 func (t *Task) stepApply() (command string, err error) {
 	return t.executeTpl(applyCommandTpl, map[string]interface{}{
-		"Req": t.req,
+		"Req":  t.req,
+		"Tool": getIacTool(t.req.Env.IacTool),
 	})
 }
 
@@ -495,7 +620,72 @@ func (t *Task) stepDestroy() (command string, err error) {
 	// destroy 任务通过会先执行 plan(传入 --destroy 参数)，然后再 apply plan 文件实现。
 	// 这样可以保证 destroy 时执行的是用户审批时看到的 plan 内容
 	return t.executeTpl(applyCommandTpl, map[string]interface{}{
-		"Req": t.req,
+		"Req":  t.req,
+		"Tool": getIacTool(t.req.Env.IacTool),
+	})
+}
+
+var stateCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
+cd 'code/{{.Req.Env.Workdir}}' && \
+{{.Tool.Command}} {{.Command}} {{- range $arg := .Args }} {{$arg}}{{ end }}
+`))
+
+// stepState 执行 state 管理命令，步骤参数为资源地址、资源 id 等用户输入，需要转义后传入
+func (t *Task) stepState() (command string, err error) {
+	var cmd string
+	switch t.req.StepType {
+	case common.TaskStepTfStateList:
+		cmd = "state list"
+	case common.TaskStepTfStateShow:
+		cmd = "state show -no-color"
+	case common.TaskStepTfStateMv:
+		cmd = "state mv"
+	case common.TaskStepTfStateRm:
+		cmd = "state rm"
+	case common.TaskStepTfImport:
+		cmd = "import -input=false -no-color"
+		if t.req.Env.TfVarsFile != "" {
+			cmd = fmt.Sprintf("%s -var-file=%s", cmd, ShellQuote(t.req.Env.TfVarsFile))
+		}
+	default:
+		return "", fmt.Errorf("unknown state step type '%s'", t.req.StepType)
+	}
+
+	args := make([]string, 0, len(t.req.StepArgs))
+	for _, arg := range t.req.StepArgs {
+		if strings.HasPrefix(arg, "-") {
+			return "", fmt.Errorf("invalid state argument '%s'", arg)
+		}
+		args = append(args, ShellQuote(arg))
+	}
+	return t.executeTpl(stateCommandTpl, map[string]interface{}{
+		"Req":     t.req,
+		"Tool":    getIacTool(t.req.Env.IacTool),
+		"Command": cmd,
+		"Args":    args,
+	})
+}
+
+var statePushCommandTpl = template.Must(template.New("").Parse(`#!/bin/sh
+cd 'code/{{.Req.Env.Workdir}}' && \
+{{.Tool.Command}} state push -force {{.StatePushFile}} && \
+rm -f {{.StatePushFile}}
+`))
+
+// stepStatePush 将 portal 传入的 state 内容写入工作目录后 push 到 state 存储，
+// 恢复的版本 serial 可能小于当前 state，所以需要使用 -force 参数
+func (t *Task) stepStatePush() (command string, err error) {
+	if len(t.req.StateContent) == 0 {
+		return "", fmt.Errorf("state content is empty")
+	}
+	workspace := GetTaskWorkspace(t.req.Env.Id, t.req.TaskId)
+	if err = os.WriteFile(filepath.Join(workspace, TFStatePushFile), t.req.StateContent, 0600); err != nil {
+		return "", errors.Wrap(err, "write state file")
+	}
+	return t.executeTpl(statePushCommandTpl, map[string]interface{}{
+		"Req":           t.req,
+		"Tool":          getIacTool(t.req.Env.IacTool),
+		"StatePushFile": t.up2Workspace(TFStatePushFile),
 	})
 }
 
@@ -547,14 +737,17 @@ func (t *Task) stepCommand() (command string, err error) {
 // collect command 失败不影响任务状态
 var collectCommandTpl = template.Must(template.New("").Parse(`# state collect command
 cd 'code/{{.Req.Env.Workdir}}' && \
-terraform show -no-color -json >{{.TFStateJsonFilePath}} && \
-terraform providers schema -json > {{.TFProviderSchema}}
+{{.Tool.Command}} show -no-color -json >{{.TFStateJsonFilePath}} && \
+{{.Tool.Command}} state pull >{{.TFStateFilePath}} && \
+{{.Tool.Command}} providers schema -json > {{.TFProviderSchema}}
 `))
 
 func (t *Task) collectCommand() (string, error) {
 	return t.executeTpl(collectCommandTpl, map[string]interface{}{
 		"Req":                 t.req,
+		"Tool":                getIacTool(t.req.Env.IacTool),
 		"TFStateJsonFilePath": t.up2Workspace(TFStateJsonFile),
+		"TFStateFilePath":     t.up2Workspace(TFStateFile),
 		"TFProviderSchema":    t.up2Workspace(TFProviderSchema),
 	})
 }
@@ -0,0 +1,56 @@
+// Copyright 2021 CloudJ Company Limited. All rights reserved.
+
+package runner
+
+import (
+	"cloudiac/common"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddStepEnvExports(t *testing.T) {
+	env := map[string]string{"B": "it's", "A": "1"}
+	assert.Equal(t, "#!/bin/sh\nexport A='1'\nexport B='it'\"'\"'s'\necho ok\n",
+		addStepEnvExports("#!/bin/sh\necho ok\n", env))
+	assert.Equal(t, "export A='1'\nexport B='it'\"'\"'s'\necho ok\n",
+		addStepEnvExports("echo ok\n", env))
+	assert.Equal(t, "echo ok\n", addStepEnvExports("echo ok\n", nil))
+}
+
+func TestIacToolCommands(t *testing.T) {
+	cases := []struct {
+		tool    string
+		command string
+	}{
+		{"", "terraform"},
+		{common.IacToolTerraform, "terraform"},
+		{common.IacToolOpenTofu, "tofu"},
+		{common.IacToolTerragrunt, "terragrunt"},
+	}
+	for _, c := range cases {
+		task := &Task{req: RunTaskReq{Env: TaskEnv{Workdir: "app", IacTool: c.tool}}}
+
+		plan, err := task.stepPlan()
+		assert.NoError(t, err)
+		assert.True(t, strings.Contains(plan, c.command+" plan -input=false"), c.tool)
+		assert.True(t, strings.Contains(plan, c.command+" show -no-color -json"), c.tool)
+
+		apply, err := task.stepApply()
+		assert.NoError(t, err)
+		assert.True(t, strings.Contains(apply, c.command+" apply -input=false"), c.tool)
+	}
+}
+
+func TestIacTerraformTplEscape(t *testing.T) {
+	buf := strings.Builder{}
+	err := iacTerraformTpl.Execute(&buf, map[string]interface{}{
+		"State": StateStore{Backend: "s3", Bucket: `iac"`, Path: "env/state", Region: "cn\"\n}"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, buf.String(), `bucket = "iac\""`)
+	assert.Contains(t, buf.String(), `region = "cn\"\n}"`)
+}
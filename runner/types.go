@@ -2,6 +2,8 @@
 
 package runner
 
+import "cloudiac/common"
+
 /*
 portal 和 runner 通信使用的消息结构体
 */
@@ -16,6 +18,7 @@ type TaskEnv struct {
 	Playbook     string `json:"playbook"`
 	PlayVarsFile string `json:"playVarsFile"`
 	TfVersion    string `json:"tfVersion"`
+	IacTool      string `json:"iacTool"` // terraform, opentofu, terragrunt，为空表示 terraform
 
 	EnvironmentVars map[string]string `json:"environment"`
 	TerraformVars   map[string]string `json:"terraform"`
@@ -23,10 +26,20 @@ type TaskEnv struct {
 }
 
 type StateStore struct {
-	Backend string `json:"backend" binding:""`
+	Backend string `json:"backend" binding:""` // consul, s3, http, local
 	Scheme  string `json:"scheme" binding:""`
-	Path    string `json:"path" binding:""`
-	Address string `json:"address" binding:""` // consul 地址 runner 会自动设置
+	Path    string `json:"path" binding:""`    // state 路径，s3 backend 作为 key 使用
+	Address string `json:"address" binding:""` // consul 地址 runner 会自动设置; http backend 的 state 地址
+
+	// s3 backend
+	Bucket         string `json:"bucket,omitempty"`
+	Region         string `json:"region,omitempty"`
+	Endpoint       string `json:"endpoint,omitempty"`
+	ForcePathStyle bool   `json:"forcePathStyle,omitempty"`
+
+	// http backend
+	LockAddress   string `json:"lockAddress,omitempty"`
+	UnlockAddress string `json:"unlockAddress,omitempty"`
 }
 
 type RunTaskReq struct {
@@ -54,6 +67,18 @@ type RunTaskReq struct {
 
 	ContainerId string `json:"containerId"`
 	PauseTask   bool   `json:"pauseTask"` // 本次执行结束后暂停任务
+
+	StateContent []byte `json:"stateContent,omitempty"` // state push 步骤恢复的 state 内容
+
+	ContainerLimits common.ContainerLimits `json:"containerLimits"` // 项目、环境配置的容器资源限制
+
+	StepImage string            `json:"stepImage,omitempty"` // 步骤使用的镜像，不为空时步骤在独立的容器中执行
+	StepEnv   map[string]string `json:"stepEnv,omitempty"`   // 步骤的环境变量
+
+	// 步骤执行成功后打包保存的文件或目录，路径相对于代码工作目录
+	StepArtifacts  []string `json:"stepArtifacts,omitempty"`
+	StepCachePaths []string `json:"stepCachePaths,omitempty"`
+	CacheContent   []byte   `json:"cacheContent,omitempty"` // 步骤执行前恢复到代码工作目录的缓存内容(tar.gz)
 }
 
 type Repository struct {
@@ -80,6 +105,12 @@ type TaskPolicy struct {
 
 type TaskLogReq TaskStatusReq
 
+type TaskArtifactReq struct {
+	EnvId  string `json:"envId" form:"envId" binding:""` // 云模板的扫描任务没有 envId
+	TaskId string `json:"taskId" form:"taskId" binding:"required"`
+	Name   string `json:"name" form:"name" binding:""` // artifact 名称，下载时必传
+}
+
 // TaskStatusMessage runner 通知任务状态到 portal
 type TaskStatusMessage struct {
 	Timeout bool `json:"timeout"` // 任务是否己超时？
@@ -88,12 +119,22 @@ type TaskStatusMessage struct {
 	Exited   bool `json:"exited"`
 	ExitCode int  `json:"status_code"`
 
-	LogContent           []byte `json:"logContent"`
-	TfStateJson          []byte `json:"tfStateJson"`
-	TfPlanJson           []byte `json:"tfPlanJson"`
-	TfScanJson           []byte `json:"tfScanJson"`
-	TfResultJson         []byte `json:"tfResultJson"`
-	TFProviderSchemaJson []byte `json:"tfProviderSchemaJson"`
+	// tfstate、tfplan 等任务产生的文件不在状态消息中传输，由 portal 通过 artifact 接口获取
+	LogContent []byte `json:"logContent"`
+}
+
+// RunnerStatus runner 心跳接口返回的状态及容量信息
+type RunnerStatus struct {
+	Version  string `json:"version"`
+	MaxTasks int    `json:"maxTasks"` // 最大并发任务数，0 表示使用 portal 的全局配置
+
+	CPUs      int     `json:"cpus"`
+	LoadAvg   float64 `json:"loadAvg"`   // 最近 1 分钟的系统平均负载
+	DiskTotal uint64  `json:"diskTotal"` // storage_path 所在磁盘的总空间(bytes)
+	DiskFree  uint64  `json:"diskFree"`  // storage_path 所在磁盘的可用空间(bytes)
+
+	ExecutorHealthy bool   `json:"executorHealthy"` // 任务执行器(docker、kubernetes)是否可用
+	ExecutorError   string `json:"executorError,omitempty"`
 }
 
 type ErrorMessage struct {